@@ -0,0 +1,326 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/configurator"
+	"github.com/spf13/cobra"
+
+	"github.com/noodnik2/flightvisualizer/internal"
+	"github.com/noodnik2/flightvisualizer/internal/artifacts"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+const cmdFlagArtifactsDir = "artifactsDir"
+const cmdFlagArtifactsPruneOlderThan = "olderThan"
+const cmdFlagArtifactsPruneMaxSize = "maxSize"
+const cmdFlagArtifactsDryRun = "dryRun"
+const cmdFlagArtifactsBundleOutput = "output"
+const cmdFlagArtifactsMigrateSchemaVersion = "schemaVersion"
+
+func init() {
+	rootCmd.AddCommand(artifactsCmd)
+	artifactsCmd.AddCommand(listArtifactsCmd)
+	artifactsCmd.AddCommand(showArtifactCmd)
+	artifactsCmd.AddCommand(pruneArtifactsCmd)
+	artifactsCmd.AddCommand(verifyArtifactsCmd)
+	artifactsCmd.AddCommand(relateArtifactsCmd)
+	artifactsCmd.AddCommand(migrateArtifactsCmd)
+	artifactsCmd.AddCommand(bundleArtifactsCmd)
+	artifactsCmd.PersistentFlags().String(cmdFlagArtifactsDir, "", "Directory of the artifacts (default from config)")
+	pruneArtifactsCmd.Flags().Duration(cmdFlagArtifactsPruneOlderThan, 0, "Remove artifacts saved longer ago than this (e.g., 720h)")
+	pruneArtifactsCmd.Flags().Int64(cmdFlagArtifactsPruneMaxSize, 0, "Remove the oldest artifacts until their total size (bytes) is within this")
+	pruneArtifactsCmd.Flags().Bool(cmdFlagArtifactsDryRun, false, "Only list the artifacts which would be removed")
+	migrateArtifactsCmd.Flags().Bool(cmdFlagArtifactsDryRun, false, "Only list the artifacts which would be migrated")
+	migrateArtifactsCmd.Flags().Int(cmdFlagArtifactsMigrateSchemaVersion, aeroapi.EnvelopeSchemaVersionUnknown,
+		fmt.Sprintf("Schema version under which the responses were saved, if known (e.g., %d; default unknown)", aeroapi.EnvelopeSchemaVersion))
+	bundleArtifactsCmd.Flags().StringP(cmdFlagArtifactsBundleOutput, "o", "", "Name of the bundle file (default 'fvs_{query}.zip' in the artifacts directory)")
+}
+
+var artifactsCmd = &cobra.Command{
+	Use:     "artifacts",
+	Short:   "Manages the saved artifacts",
+	Version: rootCmd.Version,
+}
+
+var listArtifactsCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the saved artifacts",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		infos, listErr := catalog.List()
+		if listErr != nil {
+			return listErr
+		}
+		log.Printf("INFO: %d artifact(s) in '%s'\n", len(infos), catalog.Dir)
+		for _, info := range infos {
+			log.Printf("%s %-7s %-10s %9d %s\n", info.SavedAt.Format(time.RFC3339), info.Kind, info.Ident, info.Size, info.Name)
+		}
+		return nil
+	},
+}
+
+var showArtifactCmd = &cobra.Command{
+	Use:     "show <name>",
+	Short:   "Shows the metadata of a saved artifact",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 1 {
+			return errors.New("invalid syntax")
+		}
+
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		info, findErr := catalog.Find(cmd.Flags().Arg(0))
+		if findErr != nil {
+			return findErr
+		}
+		log.Printf("name:      %s\n", info.Name)
+		log.Printf("kind:      %s\n", info.Kind)
+		log.Printf("ident:     %s\n", info.Ident)
+		if info.FlightId != "" {
+			log.Printf("flightId:  %s\n", info.FlightId)
+		}
+		if !info.TimeRange.IsZero() {
+			log.Printf("timeRange: %s\n", info.TimeRange)
+		}
+		if info.Layers != "" {
+			log.Printf("layers:    %s\n", info.Layers)
+		}
+		log.Printf("size:      %d\n", info.Size)
+		log.Printf("savedAt:   %s\n", info.SavedAt.Format(time.RFC3339))
+		log.Printf("indexed:   %t\n", info.Indexed)
+		if len(info.Sources) > 0 {
+			log.Printf("sources:   %s\n", strings.Join(info.Sources, ", "))
+		}
+		return nil
+	},
+}
+
+var pruneArtifactsCmd = &cobra.Command{
+	Use:     "prune",
+	Short:   "Removes artifacts by age and/or total size",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		var options artifacts.PruneOptions
+		var flagErr error
+		if options.MaxAge, flagErr = cmd.Flags().GetDuration(cmdFlagArtifactsPruneOlderThan); flagErr != nil {
+			return flagErr
+		}
+		if options.MaxTotalSize, flagErr = cmd.Flags().GetInt64(cmdFlagArtifactsPruneMaxSize); flagErr != nil {
+			return flagErr
+		}
+		if options.DryRun, flagErr = cmd.Flags().GetBool(cmdFlagArtifactsDryRun); flagErr != nil {
+			return flagErr
+		}
+		if options.MaxAge <= 0 && options.MaxTotalSize <= 0 {
+			return errors.New("specify --olderThan and/or --maxSize")
+		}
+
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		pruned, pruneErr := catalog.Prune(options)
+		verb := "pruned"
+		if options.DryRun {
+			verb = "would prune"
+		}
+		for _, info := range pruned {
+			log.Printf("%s %9d %s\n", info.SavedAt.Format(time.RFC3339), info.Size, info.Name)
+		}
+		log.Printf("INFO: %s %d artifact(s) from '%s'\n", verb, len(pruned), catalog.Dir)
+		return pruneErr
+	},
+}
+
+var verifyArtifactsCmd = &cobra.Command{
+	Use:     "verify",
+	Short:   "Checks the integrity of the saved artifacts",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		problems, verifyErr := catalog.Verify()
+		if verifyErr != nil {
+			return verifyErr
+		}
+		for _, problem := range problems {
+			log.Printf("ERROR: %s: %s\n", problem.Name, problem.Reason)
+		}
+		if len(problems) > 0 {
+			return errors.New("artifact verification failed")
+		}
+		log.Printf("INFO: all artifacts in '%s' verified\n", catalog.Dir)
+		return nil
+	},
+}
+
+var relateArtifactsCmd = &cobra.Command{
+	Use:     "relate",
+	Short:   "Relates each KML visualization to the track artifact(s) from which it was made",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		relations, relateErr := catalog.Relate()
+		if relateErr != nil {
+			return relateErr
+		}
+		for _, relation := range relations {
+			tracks := strings.Join(relation.Tracks, ", ")
+			switch {
+			case len(relation.Tracks) == 0:
+				tracks = "(unknown)"
+			case relation.Inferred:
+				tracks += " (inferred)"
+			}
+			log.Printf("%s <- %s\n", relation.Kmz, tracks)
+		}
+		return nil
+	},
+}
+
+var migrateArtifactsCmd = &cobra.Command{
+	Use:     "migrate",
+	Short:   "Wraps saved AeroAPI responses in an envelope recording their provenance",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		dryRun, dryRunFlagErr := cmd.Flags().GetBool(cmdFlagArtifactsDryRun)
+		if dryRunFlagErr != nil {
+			return dryRunFlagErr
+		}
+		schemaVersion, schemaVersionFlagErr := cmd.Flags().GetInt(cmdFlagArtifactsMigrateSchemaVersion)
+		if schemaVersionFlagErr != nil {
+			return schemaVersionFlagErr
+		}
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		provenance := aeroapi.Provenance{Tool: artifacts.ProvenanceTool, Version: rootCmd.Version}
+		migrated, migrateErr := catalog.Migrate(provenance, schemaVersion, dryRun)
+		verb := "migrated"
+		if dryRun {
+			verb = "would migrate"
+		}
+		for _, info := range migrated {
+			log.Printf("%s %s\n", info.Kind, info.Name)
+		}
+		log.Printf("INFO: %s %d artifact(s) in '%s'\n", verb, len(migrated), catalog.Dir)
+		return migrateErr
+	},
+}
+
+var bundleArtifactsCmd = &cobra.Command{
+	Use:     "bundle <fvf_name>",
+	Short:   "Bundles a list of flights with its tracks and their KML visualizations into one file",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 1 {
+			return errors.New("invalid syntax")
+		}
+
+		bundleFilename, outputFlagErr := cmd.Flags().GetString(cmdFlagArtifactsBundleOutput)
+		if outputFlagErr != nil {
+			return outputFlagErr
+		}
+		catalog, getCatalogErr := getArtifactsCatalog(cmd)
+		if getCatalogErr != nil {
+			return getCatalogErr
+		}
+		cmd.SilenceUsage = true
+
+		flightIdsName := cmd.Flags().Arg(0)
+		if bundleFilename == "" {
+			bundleFilename = filepath.Join(catalog.Dir, artifacts.MakeBundleFilename(flightIdsName))
+		}
+		names, bundleErr := catalog.CreateBundle(flightIdsName, bundleFilename)
+		if bundleErr != nil {
+			return bundleErr
+		}
+		for _, name := range names {
+			log.Printf("%s\n", name)
+		}
+		log.Printf("INFO: bundled %d artifact(s) into '%s'\n", len(names), bundleFilename)
+		return nil
+	},
+}
+
+func getArtifactsCatalog(cmd *cobra.Command) (*artifacts.Catalog, error) {
+	artifactsDir, flagErr := cmd.Flags().GetString(cmdFlagArtifactsDir)
+	if flagErr != nil {
+		return nil, flagErr
+	}
+	if artifactsDir == "" {
+		configFilename, getArgsErr := getArgs(cmd)
+		if getArgsErr != nil {
+			return nil, getArgsErr
+		}
+		var config internal.Config
+		if loadConfigErr := configurator.LoadConfig(configFilename, &config); loadConfigErr != nil {
+			// e.g., the (irrelevant) API key is missing
+			log.Printf("NOTE: %v\n", loadConfigErr)
+		}
+		artifactsDir = config.ArtifactsDir
+	}
+	if persistence.IsS3Url(artifactsDir) {
+		return nil, fmt.Errorf("artifact catalog commands support only local directories; not(%s)", artifactsDir)
+	}
+	return artifacts.NewCatalog(artifactsDir), nil
+}
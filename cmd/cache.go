@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"github.com/noodnik2/configurator"
+	"github.com/spf13/cobra"
+
+	"github.com/noodnik2/flightvisualizer/internal"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+const cmdFlagCachePurgeAll = "all"
+
+func init() {
+	rootCmd.AddCommand(cacheCmd)
+	cacheCmd.AddCommand(listCacheCmd)
+	cacheCmd.AddCommand(purgeCacheCmd)
+	purgeCacheCmd.Flags().Bool(cmdFlagCachePurgeAll, false, "Purge all responses, not only those expired")
+}
+
+var cacheCmd = &cobra.Command{
+	Use:     "cache",
+	Short:   "Manages the cache of AeroAPI responses",
+	Version: rootCmd.Version,
+}
+
+var listCacheCmd = &cobra.Command{
+	Use:     "list",
+	Short:   "Lists the cached AeroAPI responses",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		cache, getCacheErr := getResponseCache(cmd)
+		if getCacheErr != nil {
+			return getCacheErr
+		}
+		cmd.SilenceUsage = true
+
+		infos, listErr := cache.List()
+		if listErr != nil {
+			return listErr
+		}
+		log.Printf("INFO: %d response(s) cached in '%s'\n", len(infos), cache.Dir)
+		for _, info := range infos {
+			if info.Err != nil {
+				log.Printf("%-20s %-9s %8d %s: %v\n", "", "unreadable", info.Size, info.Filename, info.Err)
+				continue
+			}
+			var freshness string
+			switch {
+			case info.Immutable:
+				freshness = "immutable"
+			case info.Expired:
+				freshness = "expired"
+			default:
+				freshness = "fresh"
+			}
+			log.Printf("%s %-9s %8d %s\n", info.FetchedAt.Format(time.RFC3339), freshness, info.Size, info.BaseUrl+info.Endpoint)
+		}
+		return nil
+	},
+}
+
+var purgeCacheCmd = &cobra.Command{
+	Use:     "purge",
+	Short:   "Removes expired and unreadable (or all) cached AeroAPI responses",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		all, allFlagErr := cmd.Flags().GetBool(cmdFlagCachePurgeAll)
+		if allFlagErr != nil {
+			return allFlagErr
+		}
+		cache, getCacheErr := getResponseCache(cmd)
+		if getCacheErr != nil {
+			return getCacheErr
+		}
+		cmd.SilenceUsage = true
+
+		nPurged, purgeErr := cache.Purge(all)
+		log.Printf("INFO: purged %d response(s) from '%s'\n", nPurged, cache.Dir)
+		return purgeErr
+	},
+}
+
+func getResponseCache(cmd *cobra.Command) (*aeroapi.ResponseCache, error) {
+	configFilename, getArgsErr := getArgs(cmd)
+	if getArgsErr != nil {
+		return nil, getArgsErr
+	}
+	var config internal.Config
+	if loadConfigErr := configurator.LoadConfig(configFilename, &config); loadConfigErr != nil {
+		// e.g., the (irrelevant) API key is missing
+		log.Printf("NOTE: %v\n", loadConfigErr)
+	}
+	cacheDir, getCacheDirErr := config.GetCacheDir()
+	if getCacheDirErr != nil {
+		return nil, getCacheDirErr
+	}
+	return &aeroapi.ResponseCache{Dir: cacheDir}, nil
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/manifoldco/promptui"
+
+	iaeroapi "github.com/noodnik2/flightvisualizer/internal/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// isInteractive returns true if the user can be prompted (i.e., the standard input is a terminal)
+func isInteractive() bool {
+	stdinInfo, statErr := os.Stdin.Stat()
+	return statErr == nil && stdinInfo.Mode()&os.ModeCharDevice != 0
+}
+
+// chooseFlights prompts the user to choose one (or all) of the flights matching the ident
+func chooseFlights(ident string, flights []aeroapi.Flight) ([]aeroapi.Flight, error) {
+	var items []string
+	for _, flight := range flights {
+		items = append(items, iaeroapi.DescribeFlight(flight))
+	}
+	items = append(items, fmt.Sprintf("All %d flights", len(flights)))
+
+	prompt := promptui.Select{
+		Label: fmt.Sprintf("Several flights match '%s'; choose", ident),
+		Items: items,
+		Size:  len(items),
+	}
+	i, _, runErr := prompt.Run()
+	if runErr != nil {
+		return nil, runErr
+	}
+	if i == len(flights) {
+		return flights, nil
+	}
+	return flights[i : i+1], nil
+}
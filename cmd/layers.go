@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/noodnik2/configurator"
+	"github.com/spf13/cobra"
+
+	"github.com/noodnik2/flightvisualizer/internal"
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+)
+
+func init() {
+	rootCmd.AddCommand(layersCmd)
+}
+
+var layersCmd = &cobra.Command{
+	Use:     "layers",
+	Short:   "Lists the layers available to KML visualizations",
+	Long:    "Lists the layers (and their options) that can be given to the 'layers' option of the 'tracks' command, including those implemented by plugins",
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 0 {
+			return errors.New("invalid syntax")
+		}
+
+		verbose, getVerboseErr := cmd.Flags().GetBool(cmdFlagRootVerbose)
+		if getVerboseErr != nil {
+			return getVerboseErr
+		}
+		var config internal.Config
+		if loadConfigErr := configurator.LoadConfig(internal.GetConfigFilename(verbose), &config); loadConfigErr != nil {
+			// e.g., the (irrelevant) API key is missing
+			log.Printf("NOTE: %v\n", loadConfigErr)
+		}
+		cmd.SilenceUsage = true
+		if registerErr := config.RegisterLayerPlugins(); registerErr != nil {
+			return registerErr
+		}
+
+		settings := builders.Settings{MinAglFeet: cmdFlagTracksMinAglDefault}
+		for _, layer := range builders.Layers() {
+			var isDefault string
+			if layer.Default {
+				isDefault = "(default)"
+			}
+			log.Printf("%-10s %-9s %s\n", layer.Name, isDefault, layer.Description)
+			for _, option := range layer.Options(settings) {
+				log.Printf("    %s=%s: %s\n", option.Name, option.Value, option.Description)
+			}
+		}
+		return nil
+	},
+}
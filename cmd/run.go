@@ -0,0 +1,261 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/configurator"
+	"github.com/spf13/cobra"
+
+	"github.com/noodnik2/flightvisualizer/internal"
+)
+
+const cmdFlagRunJob = "job"
+const cmdFlagRunCheck = "check"
+
+func init() {
+	rootCmd.AddCommand(runCmd)
+	runCmd.Flags().String(cmdFlagRunJob, "", "Name of the (only) job to run (default all)")
+	runCmd.Flags().Bool(cmdFlagRunCheck, false, "Validate the job file and show the equivalent 'tracks' commands, without running them")
+}
+
+var runCmd = &cobra.Command{
+	Use:     "run <job file>",
+	Short:   "Runs the jobs declared in a job file",
+	Long:    `Runs, in order, the jobs declared in a (YAML) job file, each equivalent to an invocation of the 'tracks' command`,
+	Version: rootCmd.Version,
+	RunE: func(cmd *cobra.Command, args []string) error {
+
+		if cmd.Flags().NArg() != 1 {
+			return errors.New("invalid syntax")
+		}
+		jobName, getJobErr := cmd.Flags().GetString(cmdFlagRunJob)
+		if getJobErr != nil {
+			return getJobErr
+		}
+		check, getCheckErr := cmd.Flags().GetBool(cmdFlagRunCheck)
+		if getCheckErr != nil {
+			return getCheckErr
+		}
+
+		verbose, getVerboseErr := cmd.Flags().GetBool(cmdFlagRootVerbose)
+		if getVerboseErr != nil {
+			return getVerboseErr
+		}
+		var config internal.Config
+		if configErr := configurator.LoadConfig(internal.GetConfigFilename(verbose), &config); configErr != nil {
+			if !check {
+				return configErr
+			}
+			// e.g., the API key isn't needed to check the jobs
+			log.Printf("NOTE: %v\n", configErr)
+		}
+
+		cmd.SilenceUsage = true
+		// the jobs may depict layers implemented by plugins
+		if registerErr := config.RegisterLayerPlugins(); registerErr != nil {
+			return registerErr
+		}
+		jobFilename := cmd.Flags().Arg(0)
+		contents, readErr := os.ReadFile(jobFilename)
+		if readErr != nil {
+			return readErr
+		}
+		jobs, parseErr := internal.ParseJobs(contents)
+		if parseErr != nil {
+			return fmt.Errorf("invalid job file(%s): %w", jobFilename, parseErr)
+		}
+		if jobName != "" {
+			if jobs = selectJob(jobs, jobName); jobs == nil {
+				return fmt.Errorf("job(%s) not found in(%s)", jobName, jobFilename)
+			}
+		}
+
+		// each job is parsed as the 'tracks' command would parse its equivalent options
+		jobCmdArgs := make([]internal.TracksCommandArgs, len(jobs))
+		for i, job := range jobs {
+			jobArgs := getJobArgs(job)
+			var parseJobErr error
+			if jobCmdArgs[i], parseJobErr = parseJobArgs(jobArgs); parseJobErr != nil {
+				return fmt.Errorf("invalid job(%s): %w", job.Name, parseJobErr)
+			}
+			if check {
+				log.Printf("INFO: job(%s): %s %s %s\n", job.Name, os.Args[0], tracksCmd.Use, formatArgs(jobArgs))
+			}
+		}
+		if check {
+			return nil
+		}
+
+		// cancel in-flight request(s) cleanly if the user interrupts (e.g., presses Ctrl-C)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		for i, job := range jobs {
+			log.Printf("INFO: running job(%s)\n", job.Name)
+			jobCmdArgs[i].Config = config
+			if generateErr := jobCmdArgs[i].GenerateTracks(ctx); generateErr != nil {
+				return fmt.Errorf("job(%s) failed: %w", job.Name, generateErr)
+			}
+		}
+		return nil
+	},
+}
+
+func selectJob(jobs []internal.Job, name string) []internal.Job {
+	for _, job := range jobs {
+		if job.Name == name {
+			return []internal.Job{job}
+		}
+	}
+	return nil
+}
+
+// parseJobArgs parses the options of the 'tracks' command equivalent to a job
+func parseJobArgs(jobArgs []string) (internal.TracksCommandArgs, error) {
+	jobCmd := &cobra.Command{Use: tracksCmd.Use}
+	addTracksFlags(jobCmd.Flags())
+	// e.g., "verbose" operation applies to all jobs
+	jobCmd.Flags().AddFlagSet(rootCmd.PersistentFlags())
+	if parseErr := jobCmd.Flags().Parse(jobArgs); parseErr != nil {
+		return internal.TracksCommandArgs{}, parseErr
+	}
+	return parseArgs(jobCmd)
+}
+
+// getJobArgs returns the options of the 'tracks' command equivalent to the job
+func getJobArgs(job internal.Job) []string {
+	var args []string
+	addString := func(flag, value string) {
+		if value != "" {
+			args = append(args, fmt.Sprintf("--%s=%s", flag, value))
+		}
+	}
+	addBool := func(flag string, value bool) {
+		if value {
+			args = append(args, "--"+flag)
+		}
+	}
+	addInt := func(flag string, value int) {
+		if value != 0 {
+			addString(flag, strconv.Itoa(value))
+		}
+	}
+	addFloat := func(flag string, value float64) {
+		if value != 0 {
+			addString(flag, formatFloat(value))
+		}
+	}
+	addDuration := func(flag string, value time.Duration) {
+		if value != 0 {
+			addString(flag, value.String())
+		}
+	}
+
+	source := job.Source
+	addString(cmdFlagTracksTailNumber, source.TailNumber)
+	addString(cmdFlagTracksFlightNumber, source.FlightId)
+	addString(cmdFlagTracksIdent, source.Ident)
+	addString(cmdFlagTracksDate, source.Date)
+	addString(cmdFlagTracksFromArtifacts, source.Artifacts)
+	addString(cmdFlagTracksFromArtifacts, source.Gpx)
+	addString(cmdFlagTracksReplay, source.Replay)
+	addBool(cmdFlagTracksNoCache, source.NoCache)
+	addFloat(cmdFlagTracksMaxCost, source.MaxCost)
+	addInt(cmdFlagTracksConcurrency, source.Concurrency)
+	addDuration(cmdFlagTracksTimeout, source.Timeout)
+
+	filters := job.Filters
+	addString(cmdFlagTracksStart, filters.Start)
+	addString(cmdFlagTracksEnd, filters.End)
+	addString(cmdFlagTracksTimeZone, filters.TimeZone)
+	addString(cmdFlagTracksOrigin, strings.Join(filters.Origin, ","))
+	addString(cmdFlagTracksDestination, strings.Join(filters.Destination, ","))
+	addString(cmdFlagTracksAircraftType, strings.Join(filters.AircraftType, ","))
+	addDuration(cmdFlagTracksMinDuration, filters.MinDuration)
+	addDuration(cmdFlagTracksMaxDuration, filters.MaxDuration)
+	addInt(cmdFlagTracksMinDistance, filters.MinDistance)
+	addString(cmdFlagTracksExclude, strings.Join(filters.Exclude, ","))
+	addInt(cmdFlagTracksFlightCount, filters.FlightCount)
+
+	transforms := job.Transforms
+	addBool(cmdFlagTracksNoBanking, transforms.NoBanking)
+	addString(cmdFlagTracksDemDir, transforms.DemDir)
+	addString(cmdFlagTracksAirportsDir, transforms.AirportsDir)
+
+	// layers (and their options) are given in order of their names, as they're built
+	var layers, layerSpecs, simplify []string
+	if transforms.Simplify != 0 {
+		simplify = append(simplify, formatFloat(transforms.Simplify))
+	}
+	for layer := range job.Layers {
+		layers = append(layers, layer)
+	}
+	sort.Strings(layers)
+	for _, layer := range layers {
+		options := job.Layers[layer]
+		if options.Simplify != 0 {
+			simplify = append(simplify, fmt.Sprintf("%s=%s", layer, formatFloat(options.Simplify)))
+		}
+		layerSpecs = append(layerSpecs, formatLayerSpec(layer, options.Options))
+	}
+	addString(cmdFlagTracksLayers, strings.Join(layerSpecs, ","))
+	addString(cmdFlagTracksSimplify, strings.Join(simplify, ","))
+
+	outputs := job.Outputs
+	addString(cmdFlagTracksArtifactsDir, outputs.ArtifactsDir)
+	addBool(cmdFlagTracksSaveArtifacts, outputs.SaveArtifacts)
+	addBool(cmdFlagTracksEnvelope, outputs.Envelope)
+	addString(cmdFlagTracksCompress, outputs.Compress)
+	addString(cmdFlagTracksOverwrite, outputs.Overwrite)
+	addBool(cmdFlagTracksForce, outputs.Force)
+	addBool(cmdFlagTracksLaunch, outputs.Launch)
+	addString(cmdFlagTracksRecord, outputs.Record)
+	addInt(cmdFlagTracksMaxFeatures, outputs.MaxFeatures)
+	addInt(cmdFlagTracksMaxDocSize, outputs.MaxDocSize)
+	return args
+}
+
+// formatLayerSpec returns the specification of a layer with its options, e.g., "path(color=#00ff00,width=5)"
+func formatLayerSpec(layer string, options map[string]string) string {
+	if len(options) == 0 {
+		return layer
+	}
+	var names []string
+	for name := range options {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	settings := make([]string, len(names))
+	for i, name := range names {
+		settings[i] = name + "=" + options[name]
+	}
+	return fmt.Sprintf("%s(%s)", layer, strings.Join(settings, ","))
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+var unquotedArgRe = regexp.MustCompile(`^[-\w=,.:/+@%]*$`)
+
+// formatArgs returns the options as they'd be typed in a (POSIX) shell
+func formatArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if unquotedArgRe.MatchString(arg) {
+			quoted[i] = arg
+		} else {
+			quoted[i] = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+	}
+	return strings.Join(quoted, " ")
+}
@@ -1,20 +1,30 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"github.com/noodnik2/configurator"
 	"log"
+	"os"
+	"os/signal"
 	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 
 	"github.com/noodnik2/flightvisualizer/internal"
+	iaeroapi "github.com/noodnik2/flightvisualizer/internal/aeroapi"
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
 )
 
 const cmdFlagTracksTailNumber = "tailNumber"
 const cmdFlagTracksFlightNumber = "flightNumber"
+const cmdFlagTracksIdent = "ident"
+const cmdFlagTracksDate = "date"
 const cmdFlagTracksFromArtifacts = "fromArtifacts"
 const cmdFlagTracksSaveArtifacts = "saveArtifacts"
 const cmdFlagTracksNoBanking = "noBanking"
@@ -22,28 +32,100 @@ const cmdFlagTracksLaunch = "launch"
 const cmdFlagTracksLayers = "layers"
 const cmdFlagTracksArtifactsDir = "artifactsDir"
 const cmdFlagTracksCutoffTime = "cutoffTime"
+const cmdFlagTracksStart = "start"
+const cmdFlagTracksEnd = "end"
+const cmdFlagTracksTimeZone = "tz"
+const cmdFlagTracksOrigin = "origin"
+const cmdFlagTracksDestination = "destination"
+const cmdFlagTracksAircraftType = "aircraftType"
+const cmdFlagTracksMinDuration = "minDuration"
+const cmdFlagTracksMaxDuration = "maxDuration"
+const cmdFlagTracksMinDistance = "minDistance"
+const cmdFlagTracksExclude = "exclude"
 const cmdFlagTracksFlightCount = "flightCount"
+const cmdFlagTracksDemDir = "demDir"
+const cmdFlagTracksMinAgl = "minAgl"
+const cmdFlagTracksAirportsDir = "airportsDir"
+const cmdFlagTracksSimplify = "simplify"
+const cmdFlagTracksMaxFeatures = "maxFeatures"
+const cmdFlagTracksMaxDocSize = "maxDocSize"
+const cmdFlagTracksConcurrency = "concurrency"
+const cmdFlagTracksTimeout = "timeout"
+const cmdFlagTracksNoCache = "noCache"
+const cmdFlagTracksMaxCost = "maxCost"
+const cmdFlagTracksDryRun = "dryRun"
+const cmdFlagTracksRecord = "record"
+const cmdFlagTracksReplay = "replay"
+const cmdFlagTracksEnvelope = "envelope"
+const cmdFlagTracksCompress = "compress"
+const cmdFlagTracksOverwrite = "overwrite"
+const cmdFlagTracksForce = "force"
 
-var cmdFlagTracksLayersDefault = []string{internal.TracksLayerCamera, internal.TracksLayerPath, internal.TracksLayerVector}
+const cmdFlagTracksMinAglDefault = 500
+
+const tracksCmdLong = "Generates KML visualizations of flight track logs retrieved from FlightAware's AeroAPI"
 
 func init() {
 	rootCmd.AddCommand(tracksCmd)
-	tracksCmd.Flags().StringP(cmdFlagTracksArtifactsDir, "a", "", "Directory to save or load artifacts")
-	tracksCmd.Flags().BoolP(cmdFlagTracksNoBanking, "b", false, "Disable banking heuristic calculations")
-	tracksCmd.Flags().IntP(cmdFlagTracksFlightCount, "c", 0, "Count of (most recent) flights to consider (0=unlimited)")
-	tracksCmd.Flags().StringP(cmdFlagTracksFromArtifacts, "f", "", "Use saved responses instead of querying AeroAPI")
-	tracksCmd.Flags().StringP(cmdFlagTracksFlightNumber, "i", "", "Flight number identifier")
-	tracksCmd.Flags().StringP(cmdFlagTracksLayers, "l", strings.Join(cmdFlagTracksLayersDefault, ","), "Layer(s) of the KML depiction to create")
-	tracksCmd.Flags().StringP(cmdFlagTracksTailNumber, "n", "", "Tail number identifier")
-	tracksCmd.Flags().BoolP(cmdFlagTracksLaunch, "o", false, "Open the KML visualization of the most recent flight retrieved")
-	tracksCmd.Flags().BoolP(cmdFlagTracksSaveArtifacts, "s", false, "Save responses from AeroAPI requests")
-	tracksCmd.Flags().StringP(cmdFlagTracksCutoffTime, "t", "", "Cut off time for flight(s) to consider")
+	addTracksFlags(tracksCmd.Flags())
+
+	// the layers are described as registered when help is shown, so as to include any registered by out-of-tree code
+	showHelp := tracksCmd.HelpFunc()
+	tracksCmd.SetHelpFunc(func(cmd *cobra.Command, args []string) {
+		cmd.Flags().Lookup(cmdFlagTracksLayers).DefValue = strings.Join(builders.DefaultLayerNames(), ",")
+		cmd.Long = tracksCmdLong + "\n\nLayer options (and their defaults):\n" +
+			internal.TracksCommandArgs{MinAglFeet: cmdFlagTracksMinAglDefault}.LayerOptionsUsage()
+		showHelp(cmd, args)
+	})
+}
+
+// addTracksFlags defines the options of the "tracks" command (also used to run jobs)
+func addTracksFlags(flags *pflag.FlagSet) {
+	flags.StringP(cmdFlagTracksArtifactsDir, "a", "", "Directory to save or load artifacts")
+	flags.BoolP(cmdFlagTracksNoBanking, "b", false, "Disable banking heuristic calculations")
+	flags.IntP(cmdFlagTracksFlightCount, "c", 0, "Count of (most recent) flights to consider (0=unlimited)")
+	flags.StringP(cmdFlagTracksFromArtifacts, "f", "", "Use saved responses (an artifact, a bundle, or a directory or pattern of tracks) instead of querying AeroAPI")
+	flags.StringP(cmdFlagTracksFlightNumber, "i", "", "Flight number identifier")
+	flags.StringP(cmdFlagTracksLayers, "l", "", "Layer(s) of the KML depiction to create, optionally with options (e.g., 'path(color=#00ff00,width=5),camera(tilt=70)'); see 'layers' command")
+	flags.StringP(cmdFlagTracksTailNumber, "n", "", "Tail number identifier")
+	flags.String(cmdFlagTracksIdent, "", "Airline flight identifier (e.g., 'UAL123')")
+	flags.String(cmdFlagTracksDate, "", "Date of departure (local to the origin) of the 'ident' flight (e.g., '2023-05-23')")
+	flags.BoolP(cmdFlagTracksLaunch, "o", false, "Open the KML visualization of the most recent flight retrieved")
+	flags.BoolP(cmdFlagTracksSaveArtifacts, "s", false, "Save responses from AeroAPI requests")
+	flags.Bool(cmdFlagTracksEnvelope, false, "Wrap saved responses in an envelope recording their provenance")
+	flags.String(cmdFlagTracksCompress, "", "Compress saved responses ('gzip' or 'zstd')")
+	flags.String(cmdFlagTracksOverwrite, persistence.OverwriteAlways, "Policy for an existing KML visualization of the same name ('fail', 'skip', 'suffix' or 'overwrite')")
+	flags.Bool(cmdFlagTracksForce, false, "Re-render all visualizations of a directory (or pattern) of artifacts, even those up to date")
+	flags.StringP(cmdFlagTracksCutoffTime, "t", "", "Cut off time for flight(s) to consider (same as 'end')")
+	flags.String(cmdFlagTracksStart, "", "Earliest departure time of flight(s) to consider (e.g., '2023-05-18T08:00:00Z', '-2d', 'yesterday' or 'today 08:00 local')")
+	flags.String(cmdFlagTracksEnd, "", "Latest departure time of flight(s) to consider (e.g., '2023-05-18T20:40:00-04:00', '-1h' or 'today')")
+	flags.String(cmdFlagTracksTimeZone, "", "Time zone (e.g., 'America/New_York' or 'UTC') of times given without one (default local)")
+	flags.String(cmdFlagTracksOrigin, "", "Airport(s) from which flight(s) to consider depart (e.g., 'KSFO,OAK')")
+	flags.String(cmdFlagTracksDestination, "", "Airport(s) to which flight(s) to consider are destined (e.g., 'PHOG')")
+	flags.String(cmdFlagTracksAircraftType, "", "Aircraft type(s) of flight(s) to consider (e.g., 'C172,PA28')")
+	flags.Duration(cmdFlagTracksMinDuration, 0, "Minimum duration (takeoff to landing) of flight(s) to consider (e.g., '30m')")
+	flags.Duration(cmdFlagTracksMaxDuration, 0, "Maximum duration (takeoff to landing) of flight(s) to consider (0=unlimited)")
+	flags.Int(cmdFlagTracksMinDistance, 0, "Minimum route distance (statute miles) of flight(s) to consider")
+	flags.String(cmdFlagTracksExclude, "", "Kind(s) of flight(s) not to consider: 'cancelled', 'diverted' and/or 'positionOnly'")
+	flags.String(cmdFlagTracksDemDir, "", "Directory containing SRTM (.hgt) terrain elevation tiles")
+	flags.Float64(cmdFlagTracksMinAgl, cmdFlagTracksMinAglDefault, "Height above ground (feet) below which the terrain layer warns")
+	flags.String(cmdFlagTracksAirportsDir, "", "Directory containing OurAirports 'airports.csv' and 'runways.csv' files")
+	flags.String(cmdFlagTracksSimplify, "", "Simplification tolerance (meters) for all layers (e.g., '100') or per layer (e.g., 'vector=100,camera=50')")
+	flags.Int(cmdFlagTracksConcurrency, iaeroapi.DefaultConcurrency, "Maximum number of flights retrieved and converted in parallel")
+	flags.Bool(cmdFlagTracksNoCache, false, "Disable re-use of (and don't cache) AeroAPI responses")
+	flags.Float64(cmdFlagTracksMaxCost, 0, "Maximum estimated cost (USD) of the AeroAPI requests made (0=unlimited)")
+	flags.Bool(cmdFlagTracksDryRun, false, "Show the AeroAPI requests planned, without making them")
+	flags.String(cmdFlagTracksRecord, "", "Record the AeroAPI requests made, and their responses, to a session file")
+	flags.String(cmdFlagTracksReplay, "", "Replay the AeroAPI responses recorded in a session file instead of querying AeroAPI")
+	flags.Duration(cmdFlagTracksTimeout, 0, "Maximum time allowed to retrieve and convert the flight(s) (e.g., '2m'; 0=unlimited)")
+	flags.Int(cmdFlagTracksMaxFeatures, 0, "Maximum number of KML features per document; layers exceeding it are omitted (0=unlimited)")
+	flags.Int(cmdFlagTracksMaxDocSize, 0, "Maximum size (bytes) of the KML per document; layers exceeding it are omitted (0=unlimited)")
 }
 
 var tracksCmd = &cobra.Command{
 	Use:     "tracks",
 	Short:   "Visualizes flight tracks",
-	Long:    `Generates KML visualizations of flight track logs retrieved from FlightAware's AeroAPI`,
+	Long:    tracksCmdLong,
 	Version: rootCmd.Version,
 	RunE: func(cmd *cobra.Command, args []string) error {
 
@@ -57,8 +139,14 @@ var tracksCmd = &cobra.Command{
 		if configErr := configurator.LoadConfig(internal.GetConfigFilename(cmdArgs.IsVerbose()), &cmdArgs.Config); configErr != nil {
 			return configErr
 		}
+		if registerErr := cmdArgs.Config.RegisterLayerPlugins(); registerErr != nil {
+			return registerErr
+		}
 
-		return cmdArgs.GenerateTracks()
+		// cancel in-flight request(s) cleanly if the user interrupts (e.g., presses Ctrl-C)
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return cmdArgs.GenerateTracks(ctx)
 	},
 }
 
@@ -84,6 +172,27 @@ func parseArgs(cmd *cobra.Command) (cmdArgs internal.TracksCommandArgs, err erro
 	if cmdArgs.SaveResponses, err = cmd.Flags().GetBool(cmdFlagTracksSaveArtifacts); err != nil {
 		return
 	}
+	if cmdArgs.EnvelopeArtifacts, err = cmd.Flags().GetBool(cmdFlagTracksEnvelope); err != nil {
+		return
+	}
+	cmdArgs.Version = rootCmd.Version
+	var compression string
+	if compression, err = cmd.Flags().GetString(cmdFlagTracksCompress); err != nil {
+		return
+	}
+	if cmdArgs.Compression, err = persistence.ParseCompression(compression); err != nil {
+		return
+	}
+	var overwrite string
+	if overwrite, err = cmd.Flags().GetString(cmdFlagTracksOverwrite); err != nil {
+		return
+	}
+	if cmdArgs.Overwrite, err = persistence.ParseOverwritePolicy(overwrite); err != nil {
+		return
+	}
+	if cmdArgs.Force, err = cmd.Flags().GetBool(cmdFlagTracksForce); err != nil {
+		return
+	}
 
 	if cmdArgs.TailNumber, err = cmd.Flags().GetString(cmdFlagTracksTailNumber); err != nil {
 		return
@@ -91,6 +200,9 @@ func parseArgs(cmd *cobra.Command) (cmdArgs internal.TracksCommandArgs, err erro
 	if cmdArgs.FlightNumber, err = cmd.Flags().GetString(cmdFlagTracksFlightNumber); err != nil {
 		return
 	}
+	if cmdArgs.Ident, err = cmd.Flags().GetString(cmdFlagTracksIdent); err != nil {
+		return
+	}
 	if cmdArgs.FromArtifacts, err = cmd.Flags().GetString(cmdFlagTracksFromArtifacts); err != nil {
 		return
 	}
@@ -100,58 +212,215 @@ func parseArgs(cmd *cobra.Command) (cmdArgs internal.TracksCommandArgs, err erro
 	if cmdArgs.KmlLayers, err = cmd.Flags().GetString(cmdFlagTracksLayers); err != nil {
 		return
 	}
-	var cutoffTimeString string
-	if cutoffTimeString, err = cmd.Flags().GetString(cmdFlagTracksCutoffTime); err != nil {
+	if cmdArgs.DemDir, err = cmd.Flags().GetString(cmdFlagTracksDemDir); err != nil {
+		return
+	}
+	if cmdArgs.MinAglFeet, err = cmd.Flags().GetFloat64(cmdFlagTracksMinAgl); err != nil {
+		return
+	}
+	if cmdArgs.AirportsDir, err = cmd.Flags().GetString(cmdFlagTracksAirportsDir); err != nil {
+		return
+	}
+	if cmdArgs.Simplify, err = cmd.Flags().GetString(cmdFlagTracksSimplify); err != nil {
+		return
+	}
+	if cmdArgs.Concurrency, err = cmd.Flags().GetInt(cmdFlagTracksConcurrency); err != nil {
+		return
+	}
+	if cmdArgs.NoCache, err = cmd.Flags().GetBool(cmdFlagTracksNoCache); err != nil {
+		return
+	}
+	if cmdArgs.MaxCost, err = cmd.Flags().GetFloat64(cmdFlagTracksMaxCost); err != nil {
+		return
+	}
+	if cmdArgs.DryRun, err = cmd.Flags().GetBool(cmdFlagTracksDryRun); err != nil {
+		return
+	}
+	if cmdArgs.RecordFile, err = cmd.Flags().GetString(cmdFlagTracksRecord); err != nil {
+		return
+	}
+	if cmdArgs.ReplayFile, err = cmd.Flags().GetString(cmdFlagTracksReplay); err != nil {
+		return
+	}
+	if cmdArgs.Timeout, err = cmd.Flags().GetDuration(cmdFlagTracksTimeout); err != nil {
+		return
+	}
+	if cmdArgs.MaxFeatures, err = cmd.Flags().GetInt(cmdFlagTracksMaxFeatures); err != nil {
+		return
+	}
+	if cmdArgs.MaxDocumentBytes, err = cmd.Flags().GetInt(cmdFlagTracksMaxDocSize); err != nil {
+		return
+	}
+	if cmdArgs.TimeRange, cmdArgs.TimeRangeOptions, err = parseTimeRange(cmd); err != nil {
+		return
+	}
+	if cmdArgs.FlightFilter, err = parseFlightFilter(cmd); err != nil {
+		return
+	}
+
+	var dateString string
+	if dateString, err = cmd.Flags().GetString(cmdFlagTracksDate); err != nil {
 		return
 	}
-	if cutoffTimeString != "" {
-		var toTime time.Time
-		if toTime, err = time.Parse(time.RFC3339, cutoffTimeString); err != nil {
+	if dateString != "" {
+		var date time.Time
+		if date, err = time.Parse(time.DateOnly, dateString); err != nil {
 			return
 		}
-		cmdArgs.CutoffTime = toTime
+		cmdArgs.FlightDate = date
 	}
 
 	if cmdArgs.FlightCount, err = cmd.Flags().GetInt(cmdFlagTracksFlightCount); err != nil {
 		return
 	}
 
-	if cmdArgs.TailNumber == "" && cmdArgs.FlightNumber == "" && cmdArgs.FromArtifacts == "" {
-		err = fmt.Errorf("required option missing; one of {'%s', '%s', '%s'} required",
-			cmdFlagTracksTailNumber, cmdFlagTracksFlightNumber, cmdFlagTracksFromArtifacts)
+	if cmdArgs.TailNumber == "" && cmdArgs.FlightNumber == "" && cmdArgs.Ident == "" && cmdArgs.FromArtifacts == "" {
+		err = fmt.Errorf("required option missing; one of {'%s', '%s', '%s', '%s'} required",
+			cmdFlagTracksTailNumber, cmdFlagTracksFlightNumber, cmdFlagTracksIdent, cmdFlagTracksFromArtifacts)
 		return
 	}
 
+	if isInteractive() {
+		// let the user choose among the flights matching an ident, rather than failing
+		cmdArgs.ChooseFlight = chooseFlights
+	}
+
 	// warn user of implications of option combinations by invoking knowledge of downstream semantics
+	if cmdArgs.EnvelopeArtifacts && !cmdArgs.SaveResponses { // only saved responses are wrapped
+		log.Printf("NOTE: ignoring '%s' option; it applies only with '%s'\n", cmdFlagTracksEnvelope, cmdFlagTracksSaveArtifacts)
+	}
+	if cmdArgs.Compression != "" && !cmdArgs.SaveResponses { // only saved responses are compressed
+		log.Printf("NOTE: ignoring '%s' option; it applies only with '%s'\n", cmdFlagTracksCompress, cmdFlagTracksSaveArtifacts)
+	}
+	isArtifactsBatch := cmdArgs.FromArtifacts != "" && internal.IsArtifactsBatch(cmdArgs.FromArtifacts)
+	if cmdArgs.Force && !isArtifactsBatch { // only visualizations of a batch of artifacts are checked for being up to date
+		log.Printf("NOTE: ignoring '%s' option; it applies only with a directory or pattern of '%s'\n", cmdFlagTracksForce, cmdFlagTracksFromArtifacts)
+	}
 	if cmdArgs.FromArtifacts != "" {
 		if cmdArgs.SaveResponses { // no reason to save artifacts when we're reading from artifacts
 			incompatibleOptions(cmdFlagTracksFromArtifacts, cmdFlagTracksSaveArtifacts)
 		}
-		if cmdArgs.TailNumber != "" { // tail number is inherent to saved artifact being used
+		if cmdArgs.TailNumber != "" && !isArtifactsBatch { // tail number is inherent to saved artifact being used
 			incompatibleOptions(cmdFlagTracksFromArtifacts, cmdFlagTracksTailNumber)
 		}
 		if cmdArgs.FlightNumber != "" { // flight number is inherent to saved artifact being used
 			incompatibleOptions(cmdFlagTracksFromArtifacts, cmdFlagTracksFlightNumber)
 		}
-		if !cmdArgs.CutoffTime.IsZero() { // cutoff time is inherent to saved artifact being used
-			incompatibleOptions(cmdFlagTracksFromArtifacts, cmdFlagTracksCutoffTime)
+		if cmdArgs.Ident != "" { // flight(s) are inherent to saved artifact being used
+			incompatibleOptions(cmdFlagTracksFromArtifacts, cmdFlagTracksIdent)
 		}
 	}
+	if cmdArgs.Ident != "" {
+		if cmdArgs.TailNumber != "" { // tail number is inherent to the identified flight
+			incompatibleOptions(cmdFlagTracksIdent, cmdFlagTracksTailNumber)
+		}
+		if cmdArgs.FlightCount != 0 { // the flight(s) considered are chosen among those matching
+			incompatibleOptions(cmdFlagTracksIdent, cmdFlagTracksFlightCount)
+		}
+	} else if !cmdArgs.FlightDate.IsZero() {
+		log.Printf("NOTE: ignoring '%s' option; used only with '%s'\n", cmdFlagTracksDate, cmdFlagTracksIdent)
+	}
 	if cmdArgs.FlightNumber != "" {
+		if cmdArgs.Ident != "" { // the flight number identifies the flight
+			incompatibleOptions(cmdFlagTracksFlightNumber, cmdFlagTracksIdent)
+		}
 		if cmdArgs.TailNumber != "" { // tail number is inherent to the identified flight
 			incompatibleOptions(cmdFlagTracksFlightNumber, cmdFlagTracksTailNumber)
 		}
-		if !cmdArgs.CutoffTime.IsZero() { // cutoff time is inherent to the identified flight
-			incompatibleOptions(cmdFlagTracksFlightNumber, cmdFlagTracksCutoffTime)
-		}
 		if cmdArgs.FlightCount != 0 { // flight count is inherent to the identified flight
 			incompatibleOptions(cmdFlagTracksFlightNumber, cmdFlagTracksFlightCount)
 		}
 	}
+	// time is inherent to the saved track(s) or identified flight, or the date selects the flight(s) considered
+	ignoredTimeRangeOptions, timeRangeOverride := cmdArgs.GetIgnoredTimeRangeOptions()
+	for _, ignoredTimeRangeOption := range ignoredTimeRangeOptions {
+		incompatibleOptions(map[string]string{
+			internal.TimeRangeOverriddenByArtifacts:    cmdFlagTracksFromArtifacts,
+			internal.TimeRangeOverriddenByFlightNumber: cmdFlagTracksFlightNumber,
+			internal.TimeRangeOverriddenByDate:         cmdFlagTracksDate,
+		}[timeRangeOverride], ignoredTimeRangeOption)
+	}
 
 	return
 }
 
-func incompatibleOptions(option1, option2 string) {
-	log.Printf("NOTE: ignoring '%s' option; incompatible with '%s'\n", option1, option2)
+// parseTimeRange returns the range of departure times of the flight(s) to consider,
+// along with the names of the options which gave it
+func parseTimeRange(cmd *cobra.Command) (timeRange aeroapi.TimeRange, options []string, err error) {
+	location := time.Local
+	var timeZone string
+	if timeZone, err = cmd.Flags().GetString(cmdFlagTracksTimeZone); err != nil {
+		return
+	}
+	if timeZone != "" {
+		if location, err = time.LoadLocation(timeZone); err != nil {
+			return
+		}
+	}
+
+	now := time.Now()
+	parseFlag := func(flag string) (time.Time, error) {
+		expression, getErr := cmd.Flags().GetString(flag)
+		if getErr != nil || expression == "" {
+			return time.Time{}, getErr
+		}
+		options = append(options, flag)
+		return internal.ParseTimeExpression(expression, now, location)
+	}
+	if timeRange.Start, err = parseFlag(cmdFlagTracksStart); err != nil {
+		return
+	}
+	if timeRange.End, err = parseFlag(cmdFlagTracksEnd); err != nil {
+		return
+	}
+	var cutoffTime time.Time
+	if cutoffTime, err = parseFlag(cmdFlagTracksCutoffTime); err != nil {
+		return
+	}
+	if !cutoffTime.IsZero() {
+		if timeRange.End.IsZero() {
+			timeRange.End = cutoffTime
+		} else {
+			incompatibleOptions(cmdFlagTracksEnd, cmdFlagTracksCutoffTime)
+			options = options[:len(options)-1]
+		}
+	}
+	err = timeRange.Validate()
+	return
+}
+
+// parseFlightFilter returns the criteria by which the flight(s) to consider are selected
+func parseFlightFilter(cmd *cobra.Command) (filter aeroapi.FlightFilter, err error) {
+	var origins, destinations, aircraftTypes, exclude string
+	if origins, err = cmd.Flags().GetString(cmdFlagTracksOrigin); err != nil {
+		return
+	}
+	if destinations, err = cmd.Flags().GetString(cmdFlagTracksDestination); err != nil {
+		return
+	}
+	if aircraftTypes, err = cmd.Flags().GetString(cmdFlagTracksAircraftType); err != nil {
+		return
+	}
+	if exclude, err = cmd.Flags().GetString(cmdFlagTracksExclude); err != nil {
+		return
+	}
+	filter.Origins = aeroapi.ParseCodes(origins)
+	filter.Destinations = aeroapi.ParseCodes(destinations)
+	filter.AircraftTypes = aeroapi.ParseCodes(aircraftTypes)
+	if filter.Exclude, err = aeroapi.ParseFlightKinds(exclude); err != nil {
+		return
+	}
+	if filter.MinDuration, err = cmd.Flags().GetDuration(cmdFlagTracksMinDuration); err != nil {
+		return
+	}
+	if filter.MaxDuration, err = cmd.Flags().GetDuration(cmdFlagTracksMaxDuration); err != nil {
+		return
+	}
+	filter.MinDistance, err = cmd.Flags().GetInt(cmdFlagTracksMinDistance)
+	return
+}
+
+// incompatibleOptions notes that the ignored option is ignored, since the (other) option takes precedence
+func incompatibleOptions(option, ignoredOption string) {
+	log.Printf("NOTE: ignoring '%s' option; incompatible with '%s'\n", ignoredOption, option)
 }
@@ -1,14 +1,18 @@
 module github.com/noodnik2/flightvisualizer
 
-go 1.18
+go 1.20
 
 //replace github.com/noodnik2/configurator v0.1.0 => ../configurator
 
 require (
+	github.com/klauspost/compress v1.17.0
+	github.com/manifoldco/promptui v0.9.0
 	github.com/noodnik2/configurator v0.1.2
 	github.com/spf13/cobra v1.7.0
+	github.com/spf13/pflag v1.0.5
 	github.com/stretchr/testify v1.8.4
 	github.com/twpayne/go-kml/v3 v3.1.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
@@ -17,11 +21,8 @@ require (
 	github.com/inconshreveable/mousetrap v1.1.0 // indirect
 	github.com/joho/godotenv v1.5.1 // indirect
 	github.com/kr/pretty v0.3.0 // indirect
-	github.com/manifoldco/promptui v0.9.0 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
 	github.com/sethvargo/go-envconfig v0.9.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
 	golang.org/x/sys v0.6.0 // indirect
 	gopkg.in/check.v1 v1.0.0-20180628173108-788fd7840127 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -1,42 +1,147 @@
 package aeroapi
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"io"
 	"log"
-	"time"
+	"sync"
 
 	"github.com/noodnik2/flightvisualizer/internal/kml"
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
 )
 
+// DefaultConcurrency is the default number of flights whose tracks are retrieved and converted in parallel
+const DefaultConcurrency = 4
+
 type TracksConverter struct {
 	Verbose     bool
-	CutoffTime  time.Time
+	TimeRange   aeroapi.TimeRange
+	Filter      aeroapi.FlightFilter
 	FlightCount int
+	Concurrency int       // maximum number of flights converted in parallel (0=DefaultConcurrency)
+	Preview     io.Writer // if set, receives a table of the flights selected (up to FlightCount), before they're converted
 }
 
-func (tc *TracksConverter) ConvertForTailNumber(aeroApi aeroapi.Api, tracker kml.TrackGenerator, tailNumber string) ([]*kml.Track, error) {
+func (tc *TracksConverter) ConvertForTailNumber(ctx context.Context, aeroApi aeroapi.Api, tracker kml.TrackGenerator, tailNumber string) ([]*kml.Track, error) {
 
-	flightIds, getIdsErr := aeroApi.GetFlightIds(tailNumber, tc.CutoffTime)
-	if getIdsErr != nil {
-		return nil, getIdsErr
+	flights, getFlightsErr := aeroApi.GetFlights(ctx, tailNumber, tc.TimeRange)
+	if getFlightsErr != nil {
+		return nil, getFlightsErr
+	}
+
+	selectedFlights := tc.Filter.Apply(flights)
+	if tc.Preview != nil {
+		if previewErr := tc.writePreview(selectedFlights, len(flights)); previewErr != nil {
+			return nil, previewErr
+		}
+	}
+	if len(selectedFlights) == 0 && len(flights) > 0 {
+		return nil, fmt.Errorf("none of the %d flight(s) of %s match the filter", len(flights), tailNumber)
+	}
+
+	var flightIds []string
+	for _, flight := range selectedFlights {
+		flightIds = append(flightIds, flight.FlightId)
+	}
+	return tc.ConvertForFlightIds(ctx, aeroApi, tracker, flightIds)
+}
+
+// writePreview writes the table of the (first FlightCount) flights selected, those expected to be converted
+func (tc *TracksConverter) writePreview(selectedFlights []aeroapi.Flight, nFound int) error {
+	previewedFlights := selectedFlights
+	if tc.FlightCount > 0 && len(selectedFlights) > tc.FlightCount {
+		previewedFlights = selectedFlights[:tc.FlightCount]
+	}
+	if previewErr := WriteFlightsPreview(tc.Preview, previewedFlights, nFound); previewErr != nil {
+		return previewErr
+	}
+	if nMore := len(selectedFlights) - len(previewedFlights); nMore > 0 {
+		_, writeErr := fmt.Fprintf(tc.Preview, "%d more flight(s) match the filter, converted only in place of any of these that fail\n", nMore)
+		return writeErr
+	}
+	return nil
+}
+
+// ConvertForFlightIds converts the (first FlightCount successfully converted) flights, in order,
+// using up to Concurrency workers; the flights following one whose error is fatal aren't converted,
+// and (since the tracks converted would be taken as all those wanted) an error wrapping the context's
+// error is returned once it's done (e.g., interrupted)
+func (tc *TracksConverter) ConvertForFlightIds(ctx context.Context, aeroApi aeroapi.Api, tracker kml.TrackGenerator, flightIds []string) ([]*kml.Track, error) {
+
+	concurrency := tc.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
 	}
 
-	var kmlTracks []*kml.Track
 	nFlights := len(flightIds)
+	results := make([]*kml.Track, nFlights)
+	errs := make([]error, nFlights)
+	flights := make(chan int)
+	converted := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < minInt(concurrency, nFlights); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range flights {
+				results[i], errs[i] = ConvertForFlightId(ctx, aeroApi, tracker, flightIds[i])
+				converted <- i
+			}
+		}()
+	}
+
+	// flights are handed to the workers in order, but only while more tracks are still needed
+	// (counting on those being converted to succeed), and until a fatal error is encountered
+	var next, nConverting, nSucceeded int
+	nConsidered := nFlights
+	var ctxErr error
+	ctxDone := ctx.Done()
+	for {
+		var nextFlight chan<- int
+		if next < nConsidered && ctxErr == nil && (tc.FlightCount == 0 || nSucceeded+nConverting < tc.FlightCount) {
+			nextFlight = flights
+		}
+		if nextFlight == nil && nConverting == 0 {
+			break
+		}
+		select {
+		case nextFlight <- next:
+			next++
+			nConverting++
+		case i := <-converted:
+			nConverting--
+			switch {
+			case errs[i] == nil:
+				nSucceeded++
+			case aeroapi.IsFatal(errs[i]) && i < nConsidered:
+				// e.g., the API key was rejected, or retries didn't overcome the rate limit
+				nConsidered = i + 1
+			}
+		case <-ctxDone:
+			// e.g., interrupted or timed out; abandon the remaining flights
+			ctxErr, ctxDone = ctx.Err(), nil
+		}
+	}
+	close(flights)
+	wg.Wait()
+
+	// results are considered in the order of the flights, which presumes the user's preferred flights are first
+	var kmlTracks []*kml.Track
 	var errorList []error
-	for i := 0; i < nFlights; i++ {
-		kmlTrack, convertErr := ConvertForFlightId(aeroApi, tracker, flightIds[i])
-		if convertErr != nil {
-			errorList = append(errorList, convertErr)
+	for i := 0; i < minInt(next, nConsidered); i++ {
+		if errs[i] != nil {
+			errorList = append(errorList, errs[i])
 			continue
 		}
-		kmlTracks = append(kmlTracks, kmlTrack)
-		if tc.FlightCount != 0 && len(kmlTracks) == tc.FlightCount {
-			// presumes the user's preferred flights are first in the list
-			break
+		if tc.FlightCount == 0 || len(kmlTracks) < tc.FlightCount {
+			kmlTracks = append(kmlTracks, results[i])
 		}
 	}
+	if ctxErr != nil {
+		return nil, fmt.Errorf("abandoned converting flights, having converted %d of %d: %w", len(kmlTracks), nFlights, ctxErr)
+	}
 	if errorList != nil {
 		verboseMessagePrinter := func(mt string) {
 			if tc.Verbose {
@@ -50,7 +155,7 @@ func (tc *TracksConverter) ConvertForTailNumber(aeroApi aeroapi.Api, tracker kml
 		nGenerated := len(kmlTracks)
 		if nGenerated == 0 {
 			verboseMessagePrinter("ERROR")
-			return nil, errors.New("error(s) encountered generating KML visualization(s)")
+			return nil, fmt.Errorf("error(s) encountered generating KML visualization(s): %w", errors.Join(errorList...))
 		}
 		verboseMessagePrinter("INFO")
 	}
@@ -58,14 +163,21 @@ func (tc *TracksConverter) ConvertForTailNumber(aeroApi aeroapi.Api, tracker kml
 	return kmlTracks, nil
 }
 
-func ConvertForFlightId(aeroApi aeroapi.Api, tracker kml.TrackGenerator, flightId string) (*kml.Track, error) {
-	track, getTrackErr := aeroApi.GetTrackForFlightId(flightId)
+func ConvertForFlightId(ctx context.Context, aeroApi aeroapi.Api, tracker kml.TrackGenerator, flightId string) (*kml.Track, error) {
+	track, getTrackErr := aeroApi.GetTrackForFlightId(ctx, flightId)
 	if getTrackErr != nil {
 		return nil, getTrackErr
 	}
-	kmlTrack, kmlTrackErr := tracker.Generate(track)
+	kmlTrack, kmlTrackErr := tracker.Generate(ctx, track)
 	if kmlTrackErr != nil {
 		return nil, kmlTrackErr
 	}
 	return kmlTrack, nil
 }
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -1,6 +1,11 @@
 package aeroapi
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
 	"testing"
 	"time"
 
@@ -43,7 +48,7 @@ func TestConvert(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			requirer := require.New(t)
-			convert, err := tc.ConvertForTailNumber(tc.Api, tc.TrackGenerator, "tail#")
+			convert, err := tc.ConvertForTailNumber(context.Background(), tc.Api, tc.TrackGenerator, "tail#")
 			requirer.NoError(err)
 			requirer.NotNil(convert)
 			requirer.Equal(1, len(convert))
@@ -56,6 +61,169 @@ type TestKmlTracker struct {
 	kml.Track
 }
 
-func (tkt *TestKmlTracker) Generate(*aeroapi.Track) (*kml.Track, error) {
+func (tkt *TestKmlTracker) Generate(context.Context, *aeroapi.Track) (*kml.Track, error) {
 	return &tkt.Track, nil
 }
+
+func TestConvertConcurrently(t *testing.T) {
+
+	testCases := []struct {
+		name              string
+		flightCount       int
+		concurrency       int
+		failingFlightIds  []string
+		fatalFlightIds    []string
+		expectedFlightIds []string
+		expectedRequests  int
+		expectedErrors    []string
+	}{
+		{
+			name:              "all flights",
+			concurrency:       3,
+			expectedFlightIds: []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6"},
+			expectedRequests:  7,
+		},
+		{
+			name:              "first flights",
+			flightCount:       2,
+			expectedFlightIds: []string{"f0", "f1"},
+			expectedRequests:  2,
+		},
+		{
+			name:              "first successful flights",
+			flightCount:       3,
+			concurrency:       2,
+			failingFlightIds:  []string{"f1", "f2"},
+			expectedFlightIds: []string{"f0", "f3", "f4"},
+			expectedRequests:  5,
+		},
+		{
+			name:             "no successful flights",
+			concurrency:      5,
+			failingFlightIds: []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6"},
+			expectedRequests: 7,
+			expectedErrors:   []string{"encountered", "f0 failed", "f6 failed"},
+		},
+		{
+			name:              "stops after fatal error",
+			concurrency:       1,
+			fatalFlightIds:    []string{"f3"},
+			expectedFlightIds: []string{"f0", "f1", "f2"},
+			expectedRequests:  4,
+		},
+		{
+			name:              "discards flights following fatal error",
+			concurrency:       3,
+			fatalFlightIds:    []string{"f1"},
+			expectedFlightIds: []string{"f0"},
+			expectedRequests:  4,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			api := &testConcurrentApi{
+				flightIds:        []string{"f0", "f1", "f2", "f3", "f4", "f5", "f6"},
+				failingFlightIds: tc.failingFlightIds,
+				fatalFlightIds:   tc.fatalFlightIds,
+			}
+			converter := &TracksConverter{FlightCount: tc.flightCount, Concurrency: tc.concurrency}
+			kmlTracks, convertErr := converter.ConvertForTailNumber(context.Background(), api, &testFlightIdTracker{}, "tail#")
+			requirer.Equal(tc.expectedRequests, api.nRequests)
+			if tc.expectedErrors != nil {
+				requirer.Error(convertErr)
+				for _, expectedErr := range tc.expectedErrors {
+					requirer.Contains(convertErr.Error(), expectedErr)
+				}
+				return
+			}
+			requirer.NoError(convertErr)
+			var flightIds []string
+			for _, kmlTrack := range kmlTracks {
+				flightIds = append(flightIds, string(kmlTrack.KmlDoc))
+			}
+			requirer.Equal(tc.expectedFlightIds, flightIds)
+		})
+	}
+}
+
+func TestConvertConcurrently_Canceled(t *testing.T) {
+	requirer := require.New(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	api := &testConcurrentApi{flightIds: []string{"f0", "f1", "f2", "f3"}}
+	tracker := &testCancelingTracker{cancelAt: "f1", cancel: cancel}
+	converter := &TracksConverter{Concurrency: 1}
+	kmlTracks, convertErr := converter.ConvertForTailNumber(ctx, api, tracker, "tail#")
+	// the track of f0 (and maybe f1) having been converted mustn't pass for all the tracks wanted
+	requirer.True(errors.Is(convertErr, context.Canceled), "%v", convertErr)
+	requirer.Contains(convertErr.Error(), "abandoned")
+	requirer.Nil(kmlTracks)
+}
+
+// testCancelingTracker generates KML tracks like testFlightIdTracker, but cancels
+// the conversion once it's generating the track of the flight cancelAt
+type testCancelingTracker struct {
+	testFlightIdTracker
+	cancelAt string
+	cancel   context.CancelFunc
+}
+
+func (tct *testCancelingTracker) Generate(ctx context.Context, track *aeroapi.Track) (*kml.Track, error) {
+	if track.FlightId == tct.cancelAt {
+		tct.cancel()
+	}
+	return tct.testFlightIdTracker.Generate(ctx, track)
+}
+
+// testConcurrentApi returns tracks for its flights after a delay which is
+// longer for earlier flights, so that responses arrive out of order
+type testConcurrentApi struct {
+	flightIds        []string
+	failingFlightIds []string
+	fatalFlightIds   []string
+	mu               sync.Mutex
+	nRequests        int
+}
+
+func (a *testConcurrentApi) GetFlights(context.Context, string, aeroapi.TimeRange) ([]aeroapi.Flight, error) {
+	var flights []aeroapi.Flight
+	for _, flightId := range a.flightIds {
+		flights = append(flights, aeroapi.Flight{FlightId: flightId})
+	}
+	return flights, nil
+}
+
+func (a *testConcurrentApi) GetFlightIds(context.Context, string, aeroapi.TimeRange) ([]string, error) {
+	return a.flightIds, nil
+}
+
+func (a *testConcurrentApi) GetTrackForFlightId(_ context.Context, flightId string) (*aeroapi.Track, error) {
+	a.mu.Lock()
+	a.nRequests++
+	a.mu.Unlock()
+	for i, id := range a.flightIds {
+		if id == flightId {
+			time.Sleep(time.Duration(len(a.flightIds)-i) * 5 * time.Millisecond)
+		}
+	}
+	for _, failingFlightId := range a.failingFlightIds {
+		if flightId == failingFlightId {
+			return nil, fmt.Errorf("%s failed", flightId)
+		}
+	}
+	for _, fatalFlightId := range a.fatalFlightIds {
+		if flightId == fatalFlightId {
+			return nil, &aeroapi.AuthError{ResponseError: &aeroapi.ResponseError{StatusCode: http.StatusUnauthorized}}
+		}
+	}
+	return &aeroapi.Track{FlightId: flightId}, nil
+}
+
+// testFlightIdTracker generates KML tracks whose document is the flight identifier
+type testFlightIdTracker struct{}
+
+func (*testFlightIdTracker) Generate(_ context.Context, track *aeroapi.Track) (*kml.Track, error) {
+	return &kml.Track{KmlDoc: []byte(track.FlightId)}, nil
+}
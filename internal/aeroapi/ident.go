@@ -0,0 +1,135 @@
+package aeroapi
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// FlightChooser asks the user to choose among the flights matching an ident, returning those chosen
+type FlightChooser func(ident string, flights []aeroapi.Flight) ([]aeroapi.Flight, error)
+
+// AmbiguousIdentError indicates that several flights match an ident, and none could be chosen
+type AmbiguousIdentError struct {
+	Ident      string
+	Candidates []aeroapi.Flight
+}
+
+func (e *AmbiguousIdentError) Error() string {
+	var candidates []string
+	for _, flight := range e.Candidates {
+		candidates = append(candidates, DescribeFlight(flight))
+	}
+	return fmt.Sprintf("%d flights match ident(%s); choose one using its flight number (fa_flight_id):\n\t%s",
+		len(e.Candidates), e.Ident, strings.Join(candidates, "\n\t"))
+}
+
+// IdentResolver resolves an airline flight identifier (e.g., "UAL123") to the AeroAPI flight(s) it denotes
+type IdentResolver struct {
+	Verbose   bool
+	Date      time.Time         // (civil) date of departure, local to the origin (zero=any)
+	TimeRange aeroapi.TimeRange // range of departure times considered, if no Date is given
+	Filter    aeroapi.FlightFilter
+	Choose    FlightChooser // chooses among several matching flights (nil=fail, listing them)
+}
+
+// ResolveIdent returns the flight id(s) of the flight(s) with the ident departing on the Date,
+// disambiguating codeshares and (if several legs remain) asking the user to choose
+func (ir *IdentResolver) ResolveIdent(ctx context.Context, aeroApi aeroapi.Api, ident string) ([]string, error) {
+
+	timeRange := ir.TimeRange
+	var date string
+	if !ir.Date.IsZero() {
+		// the date is local to the origin, which may be a day ahead of (or behind) UTC
+		date = ir.Date.Format(time.DateOnly)
+		timeRange = aeroapi.TimeRange{
+			Start: time.Date(ir.Date.Year(), ir.Date.Month(), ir.Date.Day()-1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(ir.Date.Year(), ir.Date.Month(), ir.Date.Day()+2, 0, 0, 0, 0, time.UTC),
+		}
+	}
+
+	flights, getFlightsErr := aeroApi.GetFlights(ctx, ident, timeRange)
+	if getFlightsErr != nil {
+		return nil, getFlightsErr
+	}
+
+	candidates := getCandidateFlights(flights, date)
+	if ir.Verbose {
+		log.Printf("INFO: %d of %d flight(s) of ident(%s) are candidates\n", len(candidates), len(flights), ident)
+	}
+	if !ir.Filter.IsZero() && len(candidates) > 0 {
+		nCandidates := len(candidates)
+		if candidates = ir.Filter.Apply(candidates); len(candidates) == 0 {
+			return nil, fmt.Errorf("none of the %d flight(s) of ident(%s) match the filter", nCandidates, ident)
+		}
+	}
+	switch {
+	case len(candidates) == 0 && date != "":
+		return nil, fmt.Errorf("no flights found for ident(%s) departing on %s", ident, date)
+	case len(candidates) == 0:
+		return nil, fmt.Errorf("no flights found for ident(%s)", ident)
+	case len(candidates) > 1:
+		if ir.Choose == nil {
+			return nil, &AmbiguousIdentError{Ident: ident, Candidates: candidates}
+		}
+		var chooseErr error
+		if candidates, chooseErr = ir.Choose(ident, candidates); chooseErr != nil {
+			return nil, chooseErr
+		}
+	}
+
+	var flightIds []string
+	for _, flight := range candidates {
+		flightIds = append(flightIds, flight.FlightId)
+	}
+	return flightIds, nil
+}
+
+// DescribeFlight returns a one-line description of the flight, for choosing among several
+func DescribeFlight(flight aeroapi.Flight) string {
+	departure := "departure unknown"
+	if departureTime := flight.GetDepartureTime(); !departureTime.IsZero() {
+		departure = "departs " + departureTime.Format(time.RFC3339)
+	}
+	description := fmt.Sprintf("%s %s %s, %s", flight.FlightId, flight.Ident, flight.GetRoute(), departure)
+	if flight.Status != "" {
+		description += fmt.Sprintf(" (%s)", flight.Status)
+	}
+	return description
+}
+
+// getCandidateFlights returns the flights (departing on the date, if given) which weren't cancelled,
+// omitting codeshares of the same flight, ordered by their departure (e.g., the legs of the day in order)
+func getCandidateFlights(flights []aeroapi.Flight, date string) []aeroapi.Flight {
+	// the same (operating) flight is reported under each of its codeshares
+	type departureKey struct {
+		route     string
+		departure time.Time
+	}
+	candidateIndexes := make(map[departureKey]int)
+	var candidates []aeroapi.Flight
+	for _, flight := range flights {
+		if flight.Cancelled || (date != "" && flight.GetDepartureDate() != date) {
+			continue
+		}
+		key := departureKey{route: flight.GetRoute(), departure: flight.GetDepartureTime()}
+		if i, found := candidateIndexes[key]; found && !key.departure.IsZero() {
+			// prefer the operating carrier's flight, which lists its codeshares
+			if len(flight.Codeshares) > len(candidates[i].Codeshares) {
+				candidates[i] = flight
+			}
+			continue
+		}
+		candidateIndexes[key] = len(candidates)
+		candidates = append(candidates, flight)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].GetDepartureTime().Before(candidates[j].GetDepartureTime())
+	})
+	return candidates
+}
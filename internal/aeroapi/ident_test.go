@@ -0,0 +1,145 @@
+package aeroapi
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+func TestIdentResolver_ResolveIdent(t *testing.T) {
+
+	honolulu := &aeroapi.FlightAirport{Code: "PHNL", Timezone: "Pacific/Honolulu"}
+	kahului := &aeroapi.FlightAirport{Code: "PHOG", Timezone: "Pacific/Honolulu"}
+	seattle := &aeroapi.FlightAirport{Code: "KSEA", Timezone: "America/Los_Angeles"}
+	departing := func(s string) *time.Time {
+		departure, parseErr := time.Parse(time.RFC3339, s)
+		if parseErr != nil {
+			t.Fatal(parseErr)
+		}
+		return &departure
+	}
+	may23 := time.Date(2023, 5, 23, 0, 0, 0, 0, time.UTC)
+
+	// the second (evening) leg departs on the 24th in UTC, but on the 23rd locally
+	firstLeg := aeroapi.Flight{FlightId: "ASA8-1", Ident: "ASA8", Origin: honolulu, Destination: kahului, ScheduledOut: departing("2023-05-23T18:00:00Z")}
+	secondLeg := aeroapi.Flight{FlightId: "ASA8-2", Ident: "ASA8", Origin: kahului, Destination: seattle, ScheduledOut: departing("2023-05-24T05:00:00Z")}
+	nextDay := aeroapi.Flight{FlightId: "ASA8-3", Ident: "ASA8", Origin: honolulu, Destination: kahului, ScheduledOut: departing("2023-05-24T18:00:00Z")}
+	cancelled := aeroapi.Flight{FlightId: "ASA8-4", Ident: "ASA8", Origin: honolulu, Destination: seattle, ScheduledOut: departing("2023-05-23T20:00:00Z"), Cancelled: true}
+	operating := aeroapi.Flight{FlightId: "QXE2001-1", Ident: "QXE2001", Codeshares: []string{"ASA2001"}, Origin: seattle, Destination: honolulu, ScheduledOut: departing("2023-05-23T16:00:00Z")}
+	marketing := aeroapi.Flight{FlightId: "ASA2001-1", Ident: "ASA2001", Origin: seattle, Destination: honolulu, ScheduledOut: departing("2023-05-23T16:00:00Z")}
+
+	testCases := []struct {
+		name              string
+		resolver          IdentResolver
+		flights           []aeroapi.Flight
+		expectedFlightIds []string
+		expectedErrors    []string
+	}{
+		{
+			name:              "single flight",
+			flights:           []aeroapi.Flight{firstLeg},
+			expectedFlightIds: []string{"ASA8-1"},
+		},
+		{
+			name:              "date local to origin",
+			resolver:          IdentResolver{Date: may23},
+			flights:           []aeroapi.Flight{nextDay, firstLeg},
+			expectedFlightIds: []string{"ASA8-1"},
+		},
+		{
+			name:           "several legs without chooser",
+			resolver:       IdentResolver{Date: may23},
+			flights:        []aeroapi.Flight{nextDay, secondLeg, firstLeg, cancelled},
+			expectedErrors: []string{"2 flights match ident(ASA8)", "ASA8-1 ASA8 PHNL-PHOG", "ASA8-2 ASA8 PHOG-KSEA"},
+		},
+		{
+			name: "several legs chosen in order of departure",
+			resolver: IdentResolver{Date: may23, Choose: func(_ string, flights []aeroapi.Flight) ([]aeroapi.Flight, error) {
+				return flights[1:], nil
+			}},
+			flights:           []aeroapi.Flight{secondLeg, firstLeg},
+			expectedFlightIds: []string{"ASA8-2"},
+		},
+		{
+			name: "chooser fails",
+			resolver: IdentResolver{Choose: func(string, []aeroapi.Flight) ([]aeroapi.Flight, error) {
+				return nil, errors.New("interrupted")
+			}},
+			flights:        []aeroapi.Flight{secondLeg, firstLeg},
+			expectedErrors: []string{"interrupted"},
+		},
+		{
+			name:              "codeshares prefer operating flight",
+			flights:           []aeroapi.Flight{marketing, operating},
+			expectedFlightIds: []string{"QXE2001-1"},
+		},
+		{
+			name:              "filter selects among legs",
+			resolver:          IdentResolver{Date: may23, Filter: aeroapi.FlightFilter{Destinations: []string{"KSEA"}}},
+			flights:           []aeroapi.Flight{secondLeg, firstLeg},
+			expectedFlightIds: []string{"ASA8-2"},
+		},
+		{
+			name:           "filter matches none",
+			resolver:       IdentResolver{Filter: aeroapi.FlightFilter{Origins: []string{"KSFO"}}},
+			flights:        []aeroapi.Flight{secondLeg, firstLeg},
+			expectedErrors: []string{"none of the 2 flight(s) of ident(ASA8) match the filter"},
+		},
+		{
+			name:           "only cancelled",
+			flights:        []aeroapi.Flight{cancelled},
+			expectedErrors: []string{"no flights found for ident(ASA8)"},
+		},
+		{
+			name:           "none on date",
+			resolver:       IdentResolver{Date: may23.AddDate(0, 0, 3)},
+			flights:        []aeroapi.Flight{nextDay, firstLeg},
+			expectedErrors: []string{"no flights found for ident(ASA8) departing on 2023-05-26"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			api := &testFlightsApi{flights: tc.flights}
+			flightIds, resolveErr := tc.resolver.ResolveIdent(context.Background(), api, "ASA8")
+			if tc.expectedErrors != nil {
+				requirer.Error(resolveErr)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(resolveErr.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(resolveErr)
+			requirer.Equal(tc.expectedFlightIds, flightIds)
+			if !tc.resolver.Date.IsZero() {
+				// the range allows for origins whose local date is ahead of (or behind) UTC
+				requirer.Equal(aeroapi.TimeRange{Start: tc.resolver.Date.AddDate(0, 0, -1), End: tc.resolver.Date.AddDate(0, 0, 2)}, api.timeRange)
+			}
+		})
+	}
+}
+
+// testFlightsApi returns its flights for any ident
+type testFlightsApi struct {
+	flights   []aeroapi.Flight
+	timeRange aeroapi.TimeRange
+}
+
+func (a *testFlightsApi) GetFlights(_ context.Context, _ string, timeRange aeroapi.TimeRange) ([]aeroapi.Flight, error) {
+	a.timeRange = timeRange
+	return a.flights, nil
+}
+
+func (a *testFlightsApi) GetFlightIds(context.Context, string, aeroapi.TimeRange) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (a *testFlightsApi) GetTrackForFlightId(context.Context, string) (*aeroapi.Track, error) {
+	return nil, errors.New("not implemented")
+}
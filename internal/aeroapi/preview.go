@@ -0,0 +1,49 @@
+package aeroapi
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// WriteFlightsPreview writes a table describing the flights selected from among those found
+func WriteFlightsPreview(w io.Writer, flights []aeroapi.Flight, nFound int) error {
+	if _, writeErr := fmt.Fprintf(w, "%d of %d flight(s) selected\n", len(flights), nFound); writeErr != nil {
+		return writeErr
+	}
+	if len(flights) == 0 {
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "FLIGHT ID\tDEPARTURE\tROUTE\tTYPE\tDURATION\tDISTANCE\tSTATUS")
+	for _, flight := range flights {
+		departure := "-"
+		if departureTime := flight.GetDepartureTime(); !departureTime.IsZero() {
+			departure = departureTime.Format(time.RFC3339)
+		}
+		duration := "-"
+		if flightDuration := flight.GetDuration(); flightDuration > 0 {
+			duration = flightDuration.Round(time.Minute).String()
+		}
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%dmi\t%s\n",
+			flight.FlightId,
+			departure,
+			flight.GetRoute(),
+			valueOrDash(flight.AircraftType),
+			duration,
+			flight.RouteDistance,
+			valueOrDash(flight.Status),
+		)
+	}
+	return tw.Flush()
+}
+
+func valueOrDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
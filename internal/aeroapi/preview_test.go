@@ -0,0 +1,57 @@
+package aeroapi
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+func TestTracksConverter_Preview(t *testing.T) {
+	requirer := require.New(t)
+
+	takeoff := time.Date(2023, 5, 23, 18, 0, 0, 0, time.UTC)
+	landing := takeoff.Add(97 * time.Minute)
+	ogg := &aeroapi.FlightAirport{Code: "PHOG"}
+	api := &testFlightsApi{flights: []aeroapi.Flight{
+		{FlightId: "N12345-1", Origin: ogg, Destination: ogg, AircraftType: "C172", ActualOff: &takeoff, ActualOn: &landing, Status: "Arrived"},
+		{FlightId: "N12345-2", Cancelled: true},
+	}}
+
+	var preview bytes.Buffer
+	tc := TracksConverter{
+		Filter:  aeroapi.FlightFilter{AircraftTypes: []string{"PA28"}},
+		Preview: &preview,
+	}
+	_, convertErr := tc.ConvertForTailNumber(context.Background(), api, &TestKmlTracker{}, "N12345")
+	requirer.Error(convertErr)
+	requirer.Contains(convertErr.Error(), "none of the 2 flight(s) of N12345 match the filter")
+	requirer.Equal("0 of 2 flight(s) selected\n", preview.String())
+
+	// only the flights within the flight count are previewed
+	preview.Reset()
+	tc = TracksConverter{FlightCount: 1, Preview: &preview}
+	// (the API has no tracks to convert, but the preview precedes their conversion)
+	_, _ = tc.ConvertForTailNumber(context.Background(), api, &TestKmlTracker{}, "N12345")
+	requirer.True(strings.HasPrefix(preview.String(), "1 of 2 flight(s) selected\n"))
+	requirer.Contains(preview.String(), "N12345-1")
+	requirer.NotContains(preview.String(), "N12345-2")
+	requirer.True(strings.HasSuffix(preview.String(), "1 more flight(s) match the filter, converted only in place of any of these that fail\n"))
+
+	preview.Reset()
+	requirer.NoError(WriteFlightsPreview(&preview, tc.Filter.Apply(nil), 0))
+	requirer.Equal("0 of 0 flight(s) selected\n", preview.String())
+
+	preview.Reset()
+	requirer.NoError(WriteFlightsPreview(&preview, api.flights, 3))
+	requirer.Equal(`2 of 3 flight(s) selected
+FLIGHT ID  DEPARTURE             ROUTE      TYPE  DURATION  DISTANCE  STATUS
+N12345-1   2023-05-23T18:00:00Z  PHOG-PHOG  C172  1h37m0s   0mi       Arrived
+N12345-2   -                     ?-?        -     -         0mi       -
+`, preview.String())
+}
@@ -0,0 +1,169 @@
+package artifacts
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+const (
+	BundleFilenamePrefix = "fvs_"
+	bundleFilenameSuffix = ".zip"
+)
+
+// Bundle is a "session bundle": a single archive holding a "flight ids" artifact, the track
+// artifacts of the flights it lists and the KML visualizations made from them, for sharing
+type Bundle struct {
+	Filename string
+	contents map[string][]byte
+}
+
+// IsBundleFilename returns true if the file is named as a session bundle
+func IsBundleFilename(fn string) bool {
+	base := filepath.Base(fn)
+	return strings.HasPrefix(base, BundleFilenamePrefix) && strings.HasSuffix(base, bundleFilenameSuffix)
+}
+
+// MakeBundleFilename returns the name of the session bundle of the "flight ids" artifact
+func MakeBundleFilename(flightIdsName string) string {
+	queryId := strings.TrimSuffix(persistence.TrimCompressionSuffix(filepath.Base(flightIdsName)), ".json")
+	return BundleFilenamePrefix + strings.TrimPrefix(queryId, "fvf_") + bundleFilenameSuffix
+}
+
+// CreateBundle writes the session bundle of the named "flight ids" artifact, returning the
+// names of the artifacts it holds; tracks not found in the catalog are omitted
+func (c *Catalog) CreateBundle(flightIdsName, bundleFilename string) ([]string, error) {
+	flightIdsInfo, findErr := c.Find(flightIdsName)
+	if findErr != nil {
+		return nil, findErr
+	}
+	if flightIdsInfo.Kind != KindFlightIds {
+		return nil, fmt.Errorf("artifact(%s) isn't a list of flights", flightIdsName)
+	}
+	flightsBytes, loadErr := (&persistence.FileLoader{}).Load(context.Background(), flightIdsInfo.Filename)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	flights, flightsErr := aeroapi.FlightsFromJson(flightsBytes)
+	if flightsErr != nil {
+		return nil, fmt.Errorf("invalid artifact(%s): %w", flightIdsInfo.Name, flightsErr)
+	}
+	flightIds := make(map[string]bool)
+	for _, flight := range flights.Flights {
+		flightIds[flight.FlightId] = true
+	}
+
+	infos, listErr := c.List()
+	if listErr != nil {
+		return nil, listErr
+	}
+	filenames := map[string]string{flightIdsInfo.Name: flightIdsInfo.Filename}
+	tracks := make(map[string]bool)
+	for _, info := range infos {
+		if info.Kind == KindTrack && flightIds[info.FlightId] {
+			filenames[info.Name] = info.Filename
+			// visualizations are related to the tracks by their uncompressed names
+			tracks[persistence.TrimCompressionSuffix(info.Name)] = true
+		}
+	}
+	relations, relateErr := c.Relate()
+	if relateErr != nil {
+		return nil, relateErr
+	}
+	for _, relation := range relations {
+		for _, track := range relation.Tracks {
+			if tracks[persistence.TrimCompressionSuffix(track)] {
+				filenames[relation.Kmz] = filepath.Join(c.Dir, relation.Kmz)
+			}
+		}
+	}
+
+	var names []string
+	for name := range filenames {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if writeErr := writeBundle(bundleFilename, names, filenames); writeErr != nil {
+		return nil, writeErr
+	}
+	return names, nil
+}
+
+// OpenBundle reads the session bundle
+func OpenBundle(filename string) (*Bundle, error) {
+	zipReader, openErr := zip.OpenReader(filename)
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer func() { _ = zipReader.Close() }()
+
+	bundle := &Bundle{Filename: filename, contents: make(map[string][]byte)}
+	for _, file := range zipReader.File {
+		rc, openFileErr := file.Open()
+		if openFileErr != nil {
+			return nil, fmt.Errorf("invalid bundle entry(%s): %w", file.Name, openFileErr)
+		}
+		contents, readErr := io.ReadAll(rc)
+		_ = rc.Close()
+		if readErr != nil {
+			return nil, fmt.Errorf("invalid bundle entry(%s): %w", file.Name, readErr)
+		}
+		bundle.contents[file.Name] = contents
+	}
+	return bundle, nil
+}
+
+// Read returns the contents of the artifact held in the bundle; it's a persistence.FileLoaderReader
+func (b *Bundle) Read(filePath string) ([]byte, error) {
+	contents, found := b.contents[filepath.Base(filePath)]
+	if !found {
+		return nil, fmt.Errorf("artifact(%s) not in bundle(%s): %w", filepath.Base(filePath), b.Filename, fs.ErrNotExist)
+	}
+	return contents, nil
+}
+
+// GetFlightIdsName returns the name of the "flight ids" artifact held in the bundle
+func (b *Bundle) GetFlightIdsName() (string, error) {
+	var flightIdsNames []string
+	for name := range b.contents {
+		if aeroapi.IsFlightIdsArtifactFilename(name) {
+			flightIdsNames = append(flightIdsNames, name)
+		}
+	}
+	if len(flightIdsNames) != 1 {
+		return "", fmt.Errorf("expected one list of flights in bundle(%s); found %d", b.Filename, len(flightIdsNames))
+	}
+	return flightIdsNames[0], nil
+}
+
+func writeBundle(bundleFilename string, names []string, filenames map[string]string) error {
+	var archive bytes.Buffer
+	zipWriter := zip.NewWriter(&archive)
+	for _, name := range names {
+		contents, readErr := os.ReadFile(filenames[name])
+		if readErr != nil {
+			return readErr
+		}
+		entryWriter, createErr := zipWriter.Create(name)
+		if createErr != nil {
+			return createErr
+		}
+		if _, writeErr := entryWriter.Write(contents); writeErr != nil {
+			return writeErr
+		}
+	}
+	if closeErr := zipWriter.Close(); closeErr != nil {
+		return closeErr
+	}
+	return (&persistence.FileSaver{}).Save(bundleFilename, archive.Bytes())
+}
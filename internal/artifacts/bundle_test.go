@@ -0,0 +1,63 @@
+package artifacts
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+func TestBundle(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	catalog := NewCatalog(dir)
+	flightIdsName := "fvf_N335SP_cutoff-20230523T220000Z.json"
+	requirer.NoError(os.WriteFile(filepath.Join(dir, flightIdsName),
+		[]byte(`{"flights":[{"fa_flight_id":"N335SP-1"},{"fa_flight_id":"N335SP-2"}]}`), 0644))
+	trackJson := []byte(`{"positions":[{"timestamp":"2023-05-23T20:35:50Z"},{"timestamp":"2023-05-23T22:10:02Z"}]}`)
+	requirer.NoError((&persistence.FileSaver{Compression: persistence.CompressionGzip}).
+		Save(filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1")), trackJson))
+	requirer.NoError(os.WriteFile(filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N202VG-1")), trackJson, 0644))
+	kmzName := "fvk_N335SP_230523203550Z-21002Z_path.kmz"
+	requirer.NoError(os.WriteFile(filepath.Join(dir, kmzName), []byte("kmz"), 0644))
+
+	bundleFilename := filepath.Join(dir, MakeBundleFilename(flightIdsName))
+	requirer.Equal("fvs_N335SP_cutoff-20230523T220000Z.zip", filepath.Base(bundleFilename))
+	requirer.True(IsBundleFilename(bundleFilename))
+
+	// the track of the other flight is missing, and that of another aircraft isn't referenced
+	names, bundleErr := catalog.CreateBundle(flightIdsName, bundleFilename)
+	requirer.NoError(bundleErr)
+	requirer.Equal([]string{flightIdsName, kmzName, "fvt_N335SP-1.json.gz"}, names)
+
+	bundle, openErr := OpenBundle(bundleFilename)
+	requirer.NoError(openErr)
+	bundledFlightIdsName, getNameErr := bundle.GetFlightIdsName()
+	requirer.NoError(getNameErr)
+	requirer.Equal(flightIdsName, bundledFlightIdsName)
+
+	// the bundle serves as the source of the artifacts
+	api := &aeroapi.RetrieverSaverApiImpl{
+		Retriever: &aeroapi.FileAeroApi{
+			FlightIdsFileName: bundledFlightIdsName,
+			FileLoader:        persistence.FileLoader{Reader: bundle.Read},
+		},
+	}
+	flightIds, getFlightIdsErr := api.GetFlightIds(context.Background(), "", aeroapi.TimeRange{})
+	requirer.NoError(getFlightIdsErr)
+	requirer.Equal([]string{"N335SP-1", "N335SP-2"}, flightIds)
+	track, getTrackErr := api.GetTrackForFlightId(context.Background(), "N335SP-1")
+	requirer.NoError(getTrackErr)
+	requirer.Len(track.Positions, 2)
+	_, getTrackErr = api.GetTrackForFlightId(context.Background(), "N335SP-2")
+	requirer.ErrorIs(getTrackErr, os.ErrNotExist)
+
+	_, bundleErr = catalog.CreateBundle(kmzName, bundleFilename)
+	requirer.Error(bundleErr)
+}
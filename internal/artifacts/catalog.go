@@ -0,0 +1,324 @@
+package artifacts
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// Info describes an artifact in the catalog
+type Info struct {
+	Metadata
+	Filename string
+	Size     int64
+	SavedAt  time.Time // when the artifact was saved (per the index), else when it was last modified
+	Indexed  bool
+	Sources  []string // names of the artifacts from which the artifact was made (per the index)
+}
+
+// Problem describes an artifact failing verification
+type Problem struct {
+	Name   string
+	Reason string
+}
+
+// Relation links a KML visualization to the track artifact(s) from which it was made
+type Relation struct {
+	Kmz      string
+	Tracks   []string
+	Inferred bool // true if the relation was inferred from the times of the tracks, rather than indexed
+}
+
+// PruneOptions selects the artifacts removed by Catalog.Prune
+type PruneOptions struct {
+	MaxAge       time.Duration // remove artifacts saved longer ago than this (0=any age)
+	MaxTotalSize int64         // remove the oldest artifacts until the total size is within this (0=unlimited)
+	DryRun       bool          // only report the artifacts which would be removed
+}
+
+// Catalog describes the artifacts saved into a directory
+type Catalog struct {
+	Dir   string
+	Index *Index
+	now   func() time.Time
+}
+
+// NewCatalog returns the catalog of the artifacts saved into the directory
+func NewCatalog(dir string) *Catalog {
+	return &Catalog{Dir: dir, Index: NewIndex(dir)}
+}
+
+// List returns descriptions of the artifacts in the catalog, most recently saved first; if
+// the index can't be read (see Verify), the artifacts are described as if they weren't indexed
+func (c *Catalog) List() ([]Info, error) {
+	entries, entriesErr := c.Index.Entries()
+	var invalidIndexErr *InvalidIndexError
+	if errors.As(entriesErr, &invalidIndexErr) {
+		log.Printf("WARNING: ignoring artifact index: %v\n", invalidIndexErr)
+		entries, entriesErr = nil, nil
+	}
+	if entriesErr != nil {
+		return nil, entriesErr
+	}
+	return c.list(entries)
+}
+
+// list returns descriptions of the artifacts in the catalog, as recorded by the entries of its index
+func (c *Catalog) list(entries map[string]IndexEntry) ([]Info, error) {
+	filenames, globErr := filepath.Glob(filepath.Join(c.Dir, "fv[fkt]_*"))
+	if globErr != nil {
+		return nil, globErr
+	}
+	var infos []Info
+	for _, filename := range filenames {
+		metadata, ok := ParseName(filename)
+		if !ok {
+			continue
+		}
+		fileInfo, statErr := os.Stat(filename)
+		if statErr != nil {
+			return nil, statErr
+		}
+		info := Info{
+			Metadata: metadata,
+			Filename: filename,
+			Size:     fileInfo.Size(),
+			SavedAt:  fileInfo.ModTime().UTC(),
+		}
+		if entry, indexed := entries[metadata.Name]; indexed {
+			info.Indexed = true
+			info.SavedAt = entry.SavedAt
+			info.Sources = entry.Sources
+		}
+		infos = append(infos, info)
+	}
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].SavedAt.After(infos[j].SavedAt)
+	})
+	return infos, nil
+}
+
+// Find returns the description of the named artifact
+func (c *Catalog) Find(name string) (*Info, error) {
+	infos, listErr := c.List()
+	if listErr != nil {
+		return nil, listErr
+	}
+	for _, info := range infos {
+		if info.Name == filepath.Base(name) {
+			return &info, nil
+		}
+	}
+	return nil, fmt.Errorf("artifact(%s) not found in(%s)", name, c.Dir)
+}
+
+// Verify checks the integrity of the artifacts in the catalog (and its index), returning the problems found
+func (c *Catalog) Verify() ([]Problem, error) {
+	var problems []Problem
+	entries, entriesErr := c.Index.Entries()
+	var invalidIndexErr *InvalidIndexError
+	if errors.As(entriesErr, &invalidIndexErr) {
+		// the artifacts can still be checked, though not against their index
+		problems = append(problems, Problem{Name: filepath.Base(invalidIndexErr.Filename), Reason: invalidIndexErr.Err.Error()})
+		entries, entriesErr = nil, nil
+	}
+	if entriesErr != nil {
+		return nil, entriesErr
+	}
+	infos, listErr := c.list(entries)
+	if listErr != nil {
+		return nil, listErr
+	}
+	listed := make(map[string]bool)
+	for _, info := range infos {
+		listed[info.Name] = true
+		contents, readErr := os.ReadFile(info.Filename)
+		if readErr != nil {
+			problems = append(problems, Problem{Name: info.Name, Reason: readErr.Error()})
+			continue
+		}
+		if verifyErr := verifyContents(info.Kind, contents); verifyErr != nil {
+			problems = append(problems, Problem{Name: info.Name, Reason: verifyErr.Error()})
+			continue
+		}
+		if entry, indexed := entries[info.Name]; indexed {
+			digest := sha256.Sum256(contents)
+			if hex.EncodeToString(digest[:]) != entry.Sha256 {
+				problems = append(problems, Problem{Name: info.Name, Reason: "modified since it was saved"})
+			}
+		}
+	}
+	for name := range entries {
+		if !listed[name] {
+			problems = append(problems, Problem{Name: name, Reason: "indexed, but missing"})
+		}
+	}
+	sort.SliceStable(problems, func(i, j int) bool { return problems[i].Name < problems[j].Name })
+	return problems, nil
+}
+
+// Prune removes the artifacts selected by the options, returning their descriptions
+func (c *Catalog) Prune(options PruneOptions) ([]Info, error) {
+	infos, listErr := c.List()
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	// consider the oldest artifacts first
+	sort.SliceStable(infos, func(i, j int) bool {
+		return infos[i].SavedAt.Before(infos[j].SavedAt)
+	})
+	var totalSize int64
+	for _, info := range infos {
+		totalSize += info.Size
+	}
+
+	var pruned []Info
+	for _, info := range infos {
+		tooOld := options.MaxAge > 0 && c.getNow().Sub(info.SavedAt) > options.MaxAge
+		tooBig := options.MaxTotalSize > 0 && totalSize > options.MaxTotalSize
+		if !tooOld && !tooBig {
+			continue
+		}
+		if !options.DryRun {
+			if removeErr := os.Remove(info.Filename); removeErr != nil {
+				return pruned, removeErr
+			}
+			if info.Indexed {
+				if forgetErr := c.Index.Remove(info.Name); forgetErr != nil {
+					return pruned, forgetErr
+				}
+			}
+		}
+		totalSize -= info.Size
+		pruned = append(pruned, info)
+	}
+	return pruned, nil
+}
+
+// Relate links each KML visualization in the catalog back to the track artifact(s) from which
+// it was made; unindexed relations are inferred from the times of the tracks
+func (c *Catalog) Relate() ([]Relation, error) {
+	infos, listErr := c.List()
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	var relations []Relation
+	var tracks []Info
+	for _, info := range infos {
+		switch {
+		case info.Kind == KindTrack:
+			tracks = append(tracks, info)
+		case info.Kind == KindKmz && len(info.Sources) > 0:
+			relations = append(relations, Relation{Kmz: info.Name, Tracks: info.Sources})
+		case info.Kind == KindKmz:
+			relations = append(relations, Relation{Kmz: info.Name, Inferred: true})
+		}
+	}
+
+	trackTimes := make(map[string]aeroapi.TimeRange)
+	for i := range relations {
+		if !relations[i].Inferred {
+			continue
+		}
+		kmzInfo, _ := ParseName(relations[i].Kmz)
+		for _, track := range tracks {
+			timeRange, cached := trackTimes[track.Name]
+			if !cached {
+				timeRange = readTrackTimeRange(track.Filename)
+				trackTimes[track.Name] = timeRange
+			}
+			if !timeRange.IsZero() && coversTrackTimeRange(timeRange, kmzInfo.TimeRange) {
+				relations[i].Tracks = append(relations[i].Tracks, track.Name)
+			}
+		}
+	}
+	sort.SliceStable(relations, func(i, j int) bool { return relations[i].Kmz < relations[j].Kmz })
+	return relations, nil
+}
+
+func (c *Catalog) getNow() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
+
+// verifyContents returns an error if the contents aren't those of a well-formed artifact of the kind
+func verifyContents(kind string, contents []byte) error {
+	if kind != KindKmz {
+		decompressed, decompressErr := persistence.Decompress(contents)
+		if decompressErr != nil {
+			return fmt.Errorf("invalid compressed artifact: %w", decompressErr)
+		}
+		contents = decompressed
+		if !json.Valid(contents) {
+			return fmt.Errorf("invalid JSON")
+		}
+		_, _, unwrapErr := aeroapi.UnwrapArtifact(contents)
+		return unwrapErr
+	}
+	zipReader, zipErr := zip.NewReader(bytes.NewReader(contents), int64(len(contents)))
+	if zipErr != nil {
+		return fmt.Errorf("invalid KMZ: %w", zipErr)
+	}
+	var hasDoc bool
+	for _, file := range zipReader.File {
+		hasDoc = hasDoc || file.Name == "doc.kml"
+		rc, openErr := file.Open()
+		if openErr != nil {
+			return fmt.Errorf("invalid KMZ entry(%s): %w", file.Name, openErr)
+		}
+		_, copyErr := io.Copy(io.Discard, rc)
+		_ = rc.Close()
+		if copyErr != nil {
+			return fmt.Errorf("invalid KMZ entry(%s): %w", file.Name, copyErr)
+		}
+	}
+	if !hasDoc {
+		return fmt.Errorf("KMZ has no doc.kml")
+	}
+	return nil
+}
+
+// readTrackTimeRange returns the times of the first and last positions of the track artifact,
+// or a zero range if it can't be read
+func readTrackTimeRange(filename string) aeroapi.TimeRange {
+	contents, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return aeroapi.TimeRange{}
+	}
+	if contents, readErr = persistence.Decompress(contents); readErr != nil {
+		return aeroapi.TimeRange{}
+	}
+	track, unmarshalErr := aeroapi.TrackFromJson(contents)
+	if unmarshalErr != nil || len(track.Positions) == 0 {
+		return aeroapi.TimeRange{}
+	}
+	return aeroapi.TimeRange{
+		Start: track.Positions[0].Timestamp,
+		End:   track.Positions[len(track.Positions)-1].Timestamp,
+	}
+}
+
+// coversTrackTimeRange returns true if the (second resolution) range of the visualization
+// of a track falls within the times of the track
+func coversTrackTimeRange(trackTimes, kmzTimes aeroapi.TimeRange) bool {
+	start := trackTimes.Start.Truncate(time.Second)
+	end := trackTimes.End.Truncate(time.Second)
+	return !kmzTimes.Start.Before(start) && !kmzTimes.End.After(end) && !kmzTimes.Start.After(kmzTimes.End)
+}
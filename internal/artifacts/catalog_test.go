@@ -0,0 +1,363 @@
+package artifacts
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+func TestParseName(t *testing.T) {
+	start := time.Date(2023, 5, 23, 20, 35, 50, 0, time.UTC)
+	end := time.Date(2023, 5, 23, 22, 10, 2, 0, time.UTC)
+
+	testCases := []struct {
+		name             string
+		expectedMetadata Metadata
+		expectedOk       bool
+	}{
+		{
+			name: "fvt_N335SP-1684874159-adhoc-1864p.json",
+			expectedMetadata: Metadata{
+				Name:     "fvt_N335SP-1684874159-adhoc-1864p.json",
+				Kind:     KindTrack,
+				Ident:    "N335SP",
+				FlightId: "N335SP-1684874159-adhoc-1864p",
+			},
+			expectedOk: true,
+		},
+		{
+			name: "fvf_N335SP_cutoff-20230523T220000Z.json",
+			expectedMetadata: Metadata{
+				Name:      "fvf_N335SP_cutoff-20230523T220000Z.json",
+				Kind:      KindFlightIds,
+				Ident:     "N335SP",
+				TimeRange: aeroapi.TimeRange{End: time.Date(2023, 5, 23, 22, 0, 0, 0, time.UTC)},
+			},
+			expectedOk: true,
+		},
+		{
+			name: MakeKmzFilename("N335SP", start, end, "camera-path"),
+			expectedMetadata: Metadata{
+				Name:      "fvk_N335SP_230523203550Z-21002Z_camera-path.kmz",
+				Kind:      KindKmz,
+				Ident:     "N335SP",
+				TimeRange: aeroapi.TimeRange{Start: start, End: end},
+				Layers:    "camera-path",
+			},
+			expectedOk: true,
+		},
+		{
+			// tracks loaded from artifacts have no label
+			name: "fvk__230531150622Z-201824Z_path.kmz",
+			expectedMetadata: Metadata{
+				Name:  "fvk__230531150622Z-201824Z_path.kmz",
+				Kind:  KindKmz,
+				Ident: "",
+				TimeRange: aeroapi.TimeRange{
+					Start: time.Date(2023, 5, 31, 15, 6, 22, 0, time.UTC),
+					End:   time.Date(2023, 5, 31, 20, 18, 24, 0, time.UTC),
+				},
+				Layers: "path",
+			},
+			expectedOk: true,
+		},
+		{name: "fvk_N335SP_path.kmz"},
+		{name: "notes.txt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			metadata, ok := ParseName(filepath.Join("aDir", tc.name))
+			requirer.Equal(tc.expectedOk, ok)
+			requirer.Equal(tc.expectedMetadata, metadata)
+		})
+	}
+}
+
+func TestCatalog(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	catalog := NewCatalog(dir)
+	saver := &persistence.FileSaver{Indexer: catalog.Index}
+
+	trackStart := time.Date(2023, 5, 23, 20, 35, 50, 500, time.UTC)
+	trackEnd := time.Date(2023, 5, 23, 22, 10, 2, 0, time.UTC)
+	trackJson := `{"positions":[{"timestamp":"2023-05-23T20:35:50.0000005Z"},{"timestamp":"2023-05-23T22:10:02Z"}]}`
+	trackFilename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1684874159-adhoc-1864p"))
+	requirer.NoError(saver.Save(trackFilename, []byte(trackJson)))
+
+	var kmz bytes.Buffer
+	zipWriter := zip.NewWriter(&kmz)
+	docWriter, createErr := zipWriter.Create("doc.kml")
+	requirer.NoError(createErr)
+	_, _ = docWriter.Write([]byte("<kml/>"))
+	requirer.NoError(zipWriter.Close())
+	kmzFilename := filepath.Join(dir, MakeKmzFilename("N335SP", trackStart, trackEnd, "path"))
+	requirer.NoError(saver.Save(kmzFilename, kmz.Bytes()))
+	requirer.NoError(catalog.Index.AddSources(kmzFilename, "", trackFilename))
+	requirer.Error(catalog.Index.AddSources(filepath.Join(dir, "fvk_unsaved.kmz"), "", trackFilename))
+
+	// an unindexed visualization of the same track, and an artifact which isn't one
+	unindexedKmzFilename := filepath.Join(dir, MakeKmzFilename("", trackStart, trackEnd, "camera"))
+	requirer.NoError(os.WriteFile(unindexedKmzFilename, kmz.Bytes(), 0644))
+	requirer.NoError(os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("notes"), 0644))
+
+	infos, listErr := catalog.List()
+	requirer.NoError(listErr)
+	requirer.Len(infos, 3)
+
+	info, findErr := catalog.Find(filepath.Base(kmzFilename))
+	requirer.NoError(findErr)
+	requirer.True(info.Indexed)
+	requirer.Equal(KindKmz, info.Kind)
+	requirer.Equal([]string{filepath.Base(trackFilename)}, info.Sources)
+	_, findErr = catalog.Find("fvt_missing.json")
+	requirer.Error(findErr)
+
+	relations, relateErr := catalog.Relate()
+	requirer.NoError(relateErr)
+	requirer.Equal([]Relation{
+		{Kmz: filepath.Base(kmzFilename), Tracks: []string{filepath.Base(trackFilename)}},
+		{Kmz: filepath.Base(unindexedKmzFilename), Tracks: []string{filepath.Base(trackFilename)}, Inferred: true},
+	}, relations)
+
+	problems, verifyErr := catalog.Verify()
+	requirer.NoError(verifyErr)
+	requirer.Empty(problems)
+
+	// corrupt one artifact, modify another, and remove a third
+	requirer.NoError(os.WriteFile(trackFilename, []byte(`{"positions":[`), 0644))
+	requirer.NoError(os.WriteFile(unindexedKmzFilename, []byte("not a zip"), 0644))
+	requirer.NoError(os.Remove(kmzFilename))
+	problems, verifyErr = catalog.Verify()
+	requirer.NoError(verifyErr)
+	requirer.Len(problems, 3)
+	requirer.Equal(Problem{Name: filepath.Base(kmzFilename), Reason: "indexed, but missing"}, problems[0])
+	requirer.Equal(Problem{Name: filepath.Base(unindexedKmzFilename), Reason: "invalid KMZ: zip: not a valid zip file"}, problems[1])
+	requirer.Equal(Problem{Name: filepath.Base(trackFilename), Reason: "invalid JSON"}, problems[2])
+
+	requirer.NoError(os.WriteFile(trackFilename, []byte(trackJson+" "), 0644))
+	problems, verifyErr = catalog.Verify()
+	requirer.NoError(verifyErr)
+	requirer.Contains(problems, Problem{Name: filepath.Base(trackFilename), Reason: "modified since it was saved"})
+}
+
+func TestCatalog_Prune(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	catalog := NewCatalog(dir)
+	now := time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)
+	catalog.now = func() time.Time { return now }
+
+	// artifacts of 100 bytes each, saved 1, 2 and 3 days ago
+	var filenames []string
+	for age := 1; age <= 3; age++ {
+		filename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename(string(rune('a'+age))))
+		requirer.NoError(os.WriteFile(filename, bytes.Repeat([]byte(" "), 100), 0644))
+		savedAt := now.Add(-time.Duration(age) * 24 * time.Hour)
+		requirer.NoError(os.Chtimes(filename, savedAt, savedAt))
+		filenames = append(filenames, filename)
+	}
+	requirer.NoError(catalog.Index.Index(filenames[0], nil))
+
+	pruned, pruneErr := catalog.Prune(PruneOptions{MaxAge: 36 * time.Hour, DryRun: true})
+	requirer.NoError(pruneErr)
+	requirer.Len(pruned, 2)
+	infos, _ := catalog.List()
+	requirer.Len(infos, 3)
+
+	// by size, the oldest are removed first (the indexed artifact is the newest, having just been saved)
+	pruned, pruneErr = catalog.Prune(PruneOptions{MaxTotalSize: 150})
+	requirer.NoError(pruneErr)
+	requirer.Len(pruned, 2)
+	requirer.Equal(filepath.Base(filenames[2]), pruned[0].Name)
+	requirer.Equal(filepath.Base(filenames[1]), pruned[1].Name)
+
+	catalog.now = func() time.Time { return time.Now().Add(48 * time.Hour) }
+	pruned, pruneErr = catalog.Prune(PruneOptions{MaxAge: 36 * time.Hour})
+	requirer.NoError(pruneErr)
+	requirer.Len(pruned, 1)
+	infos, _ = catalog.List()
+	requirer.Empty(infos)
+	entries, _ := catalog.Index.Entries()
+	requirer.Empty(entries)
+}
+
+func TestCatalog_Migrate(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	catalog := NewCatalog(dir)
+	flightsFilename := filepath.Join(dir, "fvf_N335SP_cutoff-20230523T220000Z.json")
+	trackFilename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1684874159-adhoc-1864p"))
+	requirer.NoError(os.WriteFile(flightsFilename, []byte(`{"flights":[]}`), 0644))
+	requirer.NoError(os.WriteFile(trackFilename, []byte(`{"positions":[]}`), 0644))
+	requirer.NoError(os.WriteFile(filepath.Join(dir, "fvk_N335SP_230523203550Z-21002Z_path.kmz"), nil, 0644))
+
+	provenance := aeroapi.Provenance{Tool: ProvenanceTool, Version: "test"}
+	migrated, migrateErr := catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersionUnknown, true)
+	requirer.NoError(migrateErr)
+	requirer.Len(migrated, 2)
+	contents, _ := os.ReadFile(trackFilename)
+	requirer.False(aeroapi.IsEnvelope(contents))
+
+	_, migrateErr = catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersion+1, false)
+	requirer.Error(migrateErr)
+
+	migrated, migrateErr = catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersionUnknown, false)
+	requirer.NoError(migrateErr)
+	requirer.Len(migrated, 2)
+
+	contents, _ = os.ReadFile(flightsFilename)
+	_, envelope, unwrapErr := aeroapi.UnwrapArtifact(contents)
+	requirer.NoError(unwrapErr)
+	requirer.True(envelope.Migrated)
+	requirer.Equal(aeroapi.EnvelopeSchemaVersionUnknown, envelope.SchemaVersion)
+	requirer.Equal(provenance, envelope.Provenance)
+	requirer.Equal("/flights/N335SP", envelope.Endpoint)
+	requirer.Equal([]string{"2023-05-23T22:00:00Z"}, envelope.Query["end"])
+
+	contents, _ = os.ReadFile(trackFilename)
+	_, envelope, unwrapErr = aeroapi.UnwrapArtifact(contents)
+	requirer.NoError(unwrapErr)
+	requirer.Equal("/flights/N335SP-1684874159-adhoc-1864p/track", envelope.Endpoint)
+
+	// migrated artifacts are indexed, and aren't migrated again
+	entries, _ := catalog.Index.Entries()
+	requirer.Len(entries, 2)
+	migrated, migrateErr = catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersionUnknown, false)
+	requirer.NoError(migrateErr)
+	requirer.Empty(migrated)
+
+	// unless the schema under which they were saved is stated, which is then recorded once
+	migrated, migrateErr = catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersion, false)
+	requirer.NoError(migrateErr)
+	requirer.Len(migrated, 2)
+	contents, _ = os.ReadFile(trackFilename)
+	_, envelope, unwrapErr = aeroapi.UnwrapArtifact(contents)
+	requirer.NoError(unwrapErr)
+	requirer.Equal(aeroapi.EnvelopeSchemaVersion, envelope.SchemaVersion)
+	requirer.True(envelope.Migrated)
+	migrated, migrateErr = catalog.Migrate(provenance, aeroapi.EnvelopeSchemaVersion, false)
+	requirer.NoError(migrateErr)
+	requirer.Empty(migrated)
+}
+
+func TestIndex_Journal(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	index := NewIndex(dir)
+	journalFilename := index.Filename + indexJournalSuffix
+	trackFilename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1684874159-adhoc-1864p"))
+	kmzFilename := filepath.Join(dir, "fvk_N335SP_230523203550Z-21002Z_path.kmz")
+	requirer.NoError(index.Index(kmzFilename, []byte("kmz")))
+	requirer.NoError(index.AddSources(kmzFilename, "settings", trackFilename))
+
+	// changes are journaled, rather than rewriting the index, until the journal outgrows it
+	_, statErr := os.Stat(index.Filename)
+	requirer.ErrorIs(statErr, os.ErrNotExist)
+	var nSaved int
+	for ; ; nSaved++ {
+		if _, statErr = os.Stat(index.Filename); statErr == nil {
+			break
+		}
+		requirer.NoError(index.Index(filepath.Join(dir, fmt.Sprintf("fvt_N%d-1-adhoc-1p.json", nSaved)), nil))
+	}
+	_, statErr = os.Stat(journalFilename)
+	requirer.ErrorIs(statErr, os.ErrNotExist)
+
+	// an append interrupted part way through its line is dropped
+	requirer.NoError(os.WriteFile(journalFilename, []byte(`{"saved":{"name":"fvt_N`), 0644))
+	requirer.NoError(index.Index(kmzFilename, []byte("kmz2")))
+	entries, entriesErr := index.Entries()
+	requirer.NoError(entriesErr)
+	requirer.Len(entries, nSaved+1)
+	entry := entries[filepath.Base(kmzFilename)]
+	requirer.Equal(int64(4), entry.Size)
+
+	// an artifact saved again is related anew to its sources, and settings
+	requirer.Empty(entry.Sources)
+	requirer.Empty(entry.Settings)
+	otherTrackFilename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1684874160-adhoc-1865p"))
+	requirer.NoError(index.AddSources(kmzFilename, "other settings", otherTrackFilename))
+	entries, entriesErr = index.Entries()
+	requirer.NoError(entriesErr)
+	entry = entries[filepath.Base(kmzFilename)]
+	requirer.Equal([]string{filepath.Base(otherTrackFilename)}, entry.Sources)
+	requirer.Equal("other settings", entry.Settings)
+
+	requirer.NoError(index.Remove(filepath.Base(kmzFilename)))
+	entries, entriesErr = index.Entries()
+	requirer.NoError(entriesErr)
+	requirer.Len(entries, nSaved)
+}
+
+func TestIndex_Concurrent(t *testing.T) {
+	requirer := require.New(t)
+
+	// each index stands for that of a different run of fviz, sharing the artifacts directory
+	dir := t.TempDir()
+	const nRuns, nSaves = 4, 50
+	var wg sync.WaitGroup
+	errs := make(chan error, nRuns*nSaves)
+	for run := 0; run < nRuns; run++ {
+		wg.Add(1)
+		go func(run int) {
+			defer wg.Done()
+			index := NewIndex(dir)
+			for i := 0; i < nSaves; i++ {
+				errs <- index.Index(filepath.Join(dir, fmt.Sprintf("fvt_N%d-%d-adhoc-1p.json", run, i)), bytes.Repeat([]byte(" "), 100))
+			}
+		}(run)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		requirer.NoError(err)
+	}
+	entries, entriesErr := NewIndex(dir).Entries()
+	requirer.NoError(entriesErr)
+	requirer.Len(entries, nRuns*nSaves)
+}
+
+func TestCatalog_InvalidIndex(t *testing.T) {
+	requirer := require.New(t)
+
+	dir := t.TempDir()
+	catalog := NewCatalog(dir)
+	trackFilename := filepath.Join(dir, aeroapi.MakeTrackArtifactFilename("N335SP-1684874159-adhoc-1864p"))
+	requirer.NoError(os.WriteFile(trackFilename, []byte(`{"positions":[]}`), 0644))
+	requirer.NoError(os.WriteFile(catalog.Index.Filename, []byte(`{"entries": [`), 0644))
+
+	_, entriesErr := catalog.Index.Entries()
+	var invalidIndexErr *InvalidIndexError
+	requirer.ErrorAs(entriesErr, &invalidIndexErr)
+
+	// the artifacts are still listed (as unindexed), while verification reports the index
+	infos, listErr := catalog.List()
+	requirer.NoError(listErr)
+	requirer.Len(infos, 1)
+	requirer.False(infos[0].Indexed)
+	problems, verifyErr := catalog.Verify()
+	requirer.NoError(verifyErr)
+	requirer.Len(problems, 1)
+	requirer.Equal(IndexFilename, problems[0].Name)
+
+	pruned, pruneErr := catalog.Prune(PruneOptions{MaxTotalSize: 1})
+	requirer.NoError(pruneErr)
+	requirer.Len(pruned, 1)
+}
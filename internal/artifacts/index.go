@@ -0,0 +1,341 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// IndexFilename is the name of the index file maintained in the artifacts directory
+const IndexFilename = ".fviz-index.json"
+
+const (
+	// indexJournalSuffix is added to the name of the index to name the journal of changes made to it
+	// since it was last written, so that each change is recorded by appending to the journal (rather
+	// than rewriting the whole index); the journal is folded into the index once it grows long
+	indexJournalSuffix = ".journal"
+	// indexLockSuffix is added to the name of the index to name the file held (exclusively) by the
+	// process changing it, so that concurrent runs of fviz don't lose each other's changes
+	indexLockSuffix = ".lock"
+	// minIndexCompaction is the least size of the journal folded into the index
+	minIndexCompaction = 16 * 1024
+	// indexLockTimeout is how long to wait for the lock on the index held by another process, after which
+	// (having not been released) it's presumed to have been abandoned (e.g., by a process killed holding it)
+	indexLockTimeout = 10 * time.Second
+)
+
+// IndexEntry records an artifact saved by fviz
+type IndexEntry struct {
+	Name    string    `json:"name"`
+	Size    int64     `json:"size"`
+	Sha256  string    `json:"sha256"`
+	SavedAt time.Time `json:"savedAt"`
+	// Sources are the names of the artifacts from which the artifact was made (e.g., the "fvt_" of a "fvk_")
+	Sources []string `json:"sources,omitempty"`
+	// Settings is a fingerprint of the settings (e.g., the layers and their options) with which it was made
+	Settings string `json:"settings,omitempty"`
+}
+
+type indexDocument struct {
+	Entries []IndexEntry `json:"entries"`
+}
+
+// indexChange is a change to the index, as recorded (on its own line) in its journal
+type indexChange struct {
+	Saved   *IndexEntry `json:"saved,omitempty"`   // the artifact was saved (its sources and settings are kept)
+	Related *IndexEntry `json:"related,omitempty"` // the sources and settings of the artifact were recorded
+	Removed string      `json:"removed,omitempty"` // the artifact was forgotten
+}
+
+// InvalidIndexError reports an index (or its journal) which can't be read
+type InvalidIndexError struct {
+	Filename string
+	Err      error
+}
+
+func (e *InvalidIndexError) Error() string {
+	return fmt.Sprintf("invalid artifact index(%s): %v", e.Filename, e.Err)
+}
+
+func (e *InvalidIndexError) Unwrap() error {
+	return e.Err
+}
+
+// Index maintains the record of the artifacts saved into a directory; it implements
+// persistence.Indexer so that artifacts can be recorded as they're saved
+type Index struct {
+	Filename string
+	mu       sync.Mutex
+}
+
+// NewIndex returns the index of the artifacts saved into the directory
+func NewIndex(dir string) *Index {
+	return &Index{Filename: filepath.Join(dir, IndexFilename)}
+}
+
+// Index records the artifact having been saved with the contents
+func (ix *Index) Index(filePath string, contents []byte) error {
+	name, nameErr := ix.nameOf(filePath)
+	if nameErr != nil {
+		return nameErr
+	}
+	digest := sha256.Sum256(contents)
+	return ix.record(indexChange{
+		Saved: &IndexEntry{
+			Name:    name,
+			Size:    int64(len(contents)),
+			Sha256:  hex.EncodeToString(digest[:]),
+			SavedAt: time.Now().UTC(),
+		},
+	})
+}
+
+// AddSources records the artifact(s) from which the (already indexed) artifact was made, and
+// the fingerprint of the settings with which it was made (if not empty)
+func (ix *Index) AddSources(filePath, settings string, sources ...string) error {
+	name, nameErr := ix.nameOf(filePath)
+	if nameErr != nil {
+		return nameErr
+	}
+	entries, entriesErr := ix.Entries()
+	if entriesErr != nil {
+		return entriesErr
+	}
+	if _, indexed := entries[name]; !indexed {
+		return fmt.Errorf("artifact(%s) not indexed", name)
+	}
+	related := &IndexEntry{Name: name, Settings: settings}
+	for _, source := range sources {
+		related.Sources = append(related.Sources, filepath.Base(source))
+	}
+	return ix.record(indexChange{Related: related})
+}
+
+// Remove forgets the named artifact(s)
+func (ix *Index) Remove(names ...string) error {
+	changes := make([]indexChange, 0, len(names))
+	for _, name := range names {
+		changes = append(changes, indexChange{Removed: name})
+	}
+	return ix.record(changes...)
+}
+
+// Entries returns the recorded artifacts, keyed by name; an error satisfying
+// errors.As(err, &*InvalidIndexError) is returned if the index can't be read
+func (ix *Index) Entries() (map[string]IndexEntry, error) {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	entries, loadErr := ix.load()
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	result := make(map[string]IndexEntry, len(entries))
+	for name, entry := range entries {
+		result[name] = *entry
+	}
+	return result, nil
+}
+
+// nameOf returns the name of the artifact relative to the directory of the index
+func (ix *Index) nameOf(filePath string) (string, error) {
+	absDir, absDirErr := filepath.Abs(filepath.Dir(ix.Filename))
+	if absDirErr != nil {
+		return "", absDirErr
+	}
+	absPath, absPathErr := filepath.Abs(filePath)
+	if absPathErr != nil {
+		return "", absPathErr
+	}
+	name, relErr := filepath.Rel(absDir, absPath)
+	if relErr != nil {
+		return "", relErr
+	}
+	return filepath.ToSlash(name), nil
+}
+
+// record appends the changes to the journal of the index, folding it into the index once it's
+// grown larger than the index (so that, over time, each change costs about the same to record)
+func (ix *Index) record(changes ...indexChange) error {
+	ix.mu.Lock()
+	defer ix.mu.Unlock()
+	unlock, lockErr := ix.lock()
+	if lockErr != nil {
+		return lockErr
+	}
+	defer unlock()
+
+	var lines []byte
+	for _, change := range changes {
+		line, marshalErr := json.Marshal(change)
+		if marshalErr != nil {
+			return marshalErr
+		}
+		lines = append(append(lines, line...), '\n')
+	}
+	journalSize, appendErr := ix.appendJournal(lines)
+	if appendErr != nil {
+		return appendErr
+	}
+	var indexSize int64
+	if fileInfo, statErr := os.Stat(ix.Filename); statErr == nil {
+		indexSize = fileInfo.Size()
+	}
+	if journalSize < indexSize || journalSize < minIndexCompaction {
+		return nil
+	}
+	entries, loadErr := ix.load()
+	if loadErr != nil {
+		// the changes were recorded; an index which can't be read is reported by Catalog.Verify
+		return nil
+	}
+	return ix.compact(entries)
+}
+
+// appendJournal appends the lines to the journal of the index, returning its size
+func (ix *Index) appendJournal(lines []byte) (int64, error) {
+	journalFilename := ix.Filename + indexJournalSuffix
+	if mkdirErr := os.MkdirAll(filepath.Dir(journalFilename), 0755); mkdirErr != nil {
+		return 0, mkdirErr
+	}
+	f, openErr := os.OpenFile(journalFilename, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0644)
+	if openErr != nil {
+		return 0, openErr
+	}
+	defer func() { _ = f.Close() }()
+	fileInfo, statErr := f.Stat()
+	if statErr != nil {
+		return 0, statErr
+	}
+	size := fileInfo.Size()
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, readErr := f.ReadAt(last, size-1); readErr != nil {
+			return 0, readErr
+		}
+		if last[0] != '\n' {
+			// an append was interrupted part way through its line, which is dropped
+			journal, readErr := os.ReadFile(journalFilename)
+			if readErr != nil {
+				return 0, readErr
+			}
+			size = int64(bytes.LastIndexByte(journal, '\n') + 1)
+			if truncateErr := f.Truncate(size); truncateErr != nil {
+				return 0, truncateErr
+			}
+		}
+	}
+	if _, writeErr := f.Write(lines); writeErr != nil {
+		return 0, writeErr
+	}
+	if syncErr := f.Sync(); syncErr != nil {
+		return 0, syncErr
+	}
+	return size + int64(len(lines)), nil
+}
+
+// compact writes the entries into the index (atomically, so that it's never seen part written)
+// and removes its journal, whose changes they include; the lock on the index must be held
+func (ix *Index) compact(entries map[string]*IndexEntry) error {
+	doc := indexDocument{Entries: make([]IndexEntry, 0, len(entries))}
+	for _, entry := range entries {
+		doc.Entries = append(doc.Entries, *entry)
+	}
+	sort.Slice(doc.Entries, func(i, j int) bool { return doc.Entries[i].Name < doc.Entries[j].Name })
+	contents, marshalErr := json.MarshalIndent(doc, "", "  ")
+	if marshalErr != nil {
+		return marshalErr
+	}
+	if writeErr := persistence.WriteFileAtomically(ix.Filename, contents, 0644); writeErr != nil {
+		return writeErr
+	}
+	// were this not done (e.g., the process was killed), the changes would just be re-applied
+	return os.Remove(ix.Filename + indexJournalSuffix)
+}
+
+// lock waits for (then takes) the lock on the index, returning the function releasing it
+func (ix *Index) lock() (func(), error) {
+	return persistence.LockFile(ix.Filename+indexLockSuffix, indexLockTimeout)
+}
+
+// load returns the entries of the index, with the changes in its journal applied
+func (ix *Index) load() (map[string]*IndexEntry, error) {
+	entries := make(map[string]*IndexEntry)
+	contents, readErr := os.ReadFile(ix.Filename)
+	if readErr != nil && !errors.Is(readErr, fs.ErrNotExist) {
+		return nil, readErr
+	}
+	if readErr == nil {
+		var doc indexDocument
+		if unmarshalErr := json.Unmarshal(contents, &doc); unmarshalErr != nil {
+			return nil, &InvalidIndexError{Filename: ix.Filename, Err: unmarshalErr}
+		}
+		for i := range doc.Entries {
+			entries[doc.Entries[i].Name] = &doc.Entries[i]
+		}
+	}
+
+	journalFilename := ix.Filename + indexJournalSuffix
+	journal, readJournalErr := os.ReadFile(journalFilename)
+	if errors.Is(readJournalErr, fs.ErrNotExist) {
+		return entries, nil
+	}
+	if readJournalErr != nil {
+		return nil, readJournalErr
+	}
+	lines := bytes.Split(journal, []byte{'\n'})
+	// the last "line" is empty, unless an append was interrupted part way through it
+	for i, line := range lines[:len(lines)-1] {
+		var change indexChange
+		if unmarshalErr := json.Unmarshal(line, &change); unmarshalErr != nil {
+			return nil, &InvalidIndexError{Filename: journalFilename, Err: fmt.Errorf("line %d: %w", i+1, unmarshalErr)}
+		}
+		change.apply(entries)
+	}
+	return entries, nil
+}
+
+// apply makes the change to the entries
+func (c indexChange) apply(entries map[string]*IndexEntry) {
+	switch {
+	case c.Saved != nil:
+		// an artifact saved again (e.g., a visualization rendered anew) replaces its relations,
+		// which are recorded by the change (if any) following
+		saved := *c.Saved
+		entries[saved.Name] = &saved
+	case c.Related != nil:
+		entry := entries[c.Related.Name]
+		if entry == nil {
+			return
+		}
+		for _, source := range c.Related.Sources {
+			if !contains(entry.Sources, source) {
+				entry.Sources = append(entry.Sources, source)
+			}
+		}
+		if c.Related.Settings != "" {
+			entry.Settings = c.Related.Settings
+		}
+	case c.Removed != "":
+		delete(entries, c.Removed)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
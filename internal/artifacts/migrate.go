@@ -0,0 +1,102 @@
+package artifacts
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// ProvenanceTool identifies fviz as the tool which saved an artifact
+const ProvenanceTool = "fviz"
+
+// Migrate wraps the raw AeroAPI responses saved in the catalog in an aeroapi.Envelope, returning
+// the descriptions of the artifacts migrated.  Since their provenance wasn't recorded, the endpoint
+// is reconstructed from the name of the artifact, and the time it was saved stands for when it was fetched.
+// Neither was their schema recorded, so it's taken to be schemaVersion, which should be
+// aeroapi.EnvelopeSchemaVersionUnknown unless the user states the schema under which they were saved;
+// artifacts migrated earlier under an unknown schema are then stamped with the schema stated.
+func (c *Catalog) Migrate(provenance aeroapi.Provenance, schemaVersion int, dryRun bool) ([]Info, error) {
+	if schemaVersion < aeroapi.EnvelopeSchemaVersionUnknown || schemaVersion > aeroapi.EnvelopeSchemaVersion {
+		return nil, fmt.Errorf("unsupported artifact schema version(%d); expected at most %d",
+			schemaVersion, aeroapi.EnvelopeSchemaVersion)
+	}
+
+	infos, listErr := c.List()
+	if listErr != nil {
+		return nil, listErr
+	}
+
+	saver := &persistence.FileSaver{Indexer: c.Index}
+	var migrated []Info
+	for _, info := range infos {
+		endpoint, hasEndpoint := getEndpoint(info.Metadata)
+		if !hasEndpoint {
+			// e.g., KML visualizations aren't AeroAPI responses
+			continue
+		}
+		contents, readErr := os.ReadFile(info.Filename)
+		if readErr == nil {
+			contents, readErr = persistence.Decompress(contents)
+		}
+		if readErr != nil {
+			return migrated, readErr
+		}
+		envelope, getEnvelopeErr := getMigratedEnvelope(provenance, schemaVersion, endpoint, info, contents)
+		if getEnvelopeErr != nil {
+			return migrated, fmt.Errorf("couldn't migrate artifact(%s): %w", info.Name, getEnvelopeErr)
+		}
+		if envelope == nil {
+			continue
+		}
+		if !dryRun {
+			envelopeBytes, marshalErr := envelope.Marshal()
+			if marshalErr != nil {
+				return migrated, marshalErr
+			}
+			if saveErr := saver.Save(info.Filename, envelopeBytes); saveErr != nil {
+				return migrated, saveErr
+			}
+		}
+		migrated = append(migrated, info)
+	}
+	return migrated, nil
+}
+
+// getMigratedEnvelope returns the envelope wrapping the artifact's contents under the schema version
+// given, or nil if the artifact needn't be migrated (i.e., it's already wrapped in an envelope, under a
+// known schema or one which isn't stated)
+func getMigratedEnvelope(provenance aeroapi.Provenance, schemaVersion int, endpoint string, info Info, contents []byte) (*aeroapi.Envelope, error) {
+	if aeroapi.IsEnvelope(contents) {
+		_, envelope, unwrapErr := aeroapi.UnwrapArtifact(contents)
+		if unwrapErr != nil {
+			return nil, unwrapErr
+		}
+		if !envelope.Migrated || envelope.SchemaVersion != aeroapi.EnvelopeSchemaVersionUnknown || schemaVersion == aeroapi.EnvelopeSchemaVersionUnknown {
+			return nil, nil
+		}
+		envelope.SchemaVersion = schemaVersion
+		return envelope, nil
+	}
+	envelope, newEnvelopeErr := aeroapi.NewEnvelope(provenance, endpoint, info.SavedAt, contents)
+	if newEnvelopeErr != nil {
+		return nil, newEnvelopeErr
+	}
+	envelope.SchemaVersion = schemaVersion
+	envelope.Migrated = true
+	return envelope, nil
+}
+
+// getEndpoint returns the AeroAPI endpoint whose response is held in the artifact, if any
+func getEndpoint(metadata Metadata) (string, bool) {
+	locator := &aeroapi.HttpAeroApi{}
+	switch metadata.Kind {
+	case KindTrack:
+		return locator.GetTrackForFlightRef(metadata.FlightId), true
+	case KindFlightIds:
+		endpoint, getRefErr := locator.GetFlightIdsRef(metadata.Ident, metadata.TimeRange)
+		return endpoint, getRefErr == nil
+	}
+	return "", false
+}
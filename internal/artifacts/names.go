@@ -0,0 +1,110 @@
+// Package artifacts catalogs the artifacts saved by fviz (i.e., the "fvf_" responses listing
+// flights, the "fvt_" track responses and the "fvk_" KML visualizations made from them)
+package artifacts
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// Kinds of artifacts
+const (
+	KindFlightIds = "flights"
+	KindTrack     = "track"
+	KindKmz       = "kml"
+)
+
+const (
+	KmzFilenamePrefix = "fvk_"
+	kmzFilenameSuffix = ".kmz"
+	// kmzTimestampFormat is the format of the times of the track embedded in the names of KMZ artifacts
+	kmzTimestampFormat = "060102150405Z"
+)
+
+// Metadata describes an artifact, as encoded in its name
+type Metadata struct {
+	Name      string
+	Kind      string
+	Ident     string            // tail number (or other ident) of the flight(s), or the label of the visualization
+	FlightId  string            // AeroAPI identifier of the flight whose track is held (track artifacts only)
+	TimeRange aeroapi.TimeRange // range of the query (flight ids), or of the track visualized (KMZ)
+	Layers    string            // layers of the visualization (KMZ artifacts only)
+}
+
+// MakeKmzFilename returns the name of the KMZ artifact visualizing the track
+func MakeKmzFilename(label string, start, end time.Time, layers string) string {
+	return fmt.Sprintf("%s%s_%s_%s%s", KmzFilenamePrefix, label, formatTrackTimeRange(start, end), layers, kmzFilenameSuffix)
+}
+
+// ParseName returns the metadata encoded in the name of the artifact, or false if it isn't one
+func ParseName(fn string) (Metadata, bool) {
+	name := filepath.Base(fn)
+	metadata := Metadata{Name: name}
+
+	if flightId, ok := aeroapi.ParseTrackArtifactFilename(name); ok {
+		metadata.Kind = KindTrack
+		metadata.FlightId = flightId
+		// e.g., "N335SP" from "N335SP-1684874159-adhoc-1864p"
+		metadata.Ident, _, _ = strings.Cut(flightId, "-")
+		return metadata, true
+	}
+
+	if ident, timeRange, ok := aeroapi.ParseFlightIdsArtifactFilename(name); ok {
+		metadata.Kind = KindFlightIds
+		metadata.Ident = ident
+		metadata.TimeRange = timeRange
+		return metadata, true
+	}
+
+	if !strings.HasPrefix(name, KmzFilenamePrefix) || !strings.HasSuffix(name, kmzFilenameSuffix) {
+		return Metadata{}, false
+	}
+	// e.g., "N335SP_230523203550Z-22102Z_camera-path-vector"
+	parts := strings.Split(strings.TrimSuffix(strings.TrimPrefix(name, KmzFilenamePrefix), kmzFilenameSuffix), "_")
+	if len(parts) < 3 {
+		return Metadata{}, false
+	}
+	timeRange, parseErr := parseTrackTimeRange(parts[len(parts)-2])
+	if parseErr != nil {
+		return Metadata{}, false
+	}
+	metadata.Kind = KindKmz
+	metadata.Ident = strings.Join(parts[:len(parts)-2], "_")
+	metadata.TimeRange = timeRange
+	metadata.Layers = parts[len(parts)-1]
+	return metadata, true
+}
+
+// formatTrackTimeRange returns a compact representation of the time range of a track, omitting
+// the leading part of its end time that's common to its start time (e.g., "230523203550Z-22102Z")
+func formatTrackTimeRange(start, end time.Time) string {
+	startFmt := start.Format(kmzTimestampFormat)
+	endFmt := end.Format(kmzTimestampFormat)
+
+	i := 0
+	for i < len(startFmt) && i < len(endFmt) && startFmt[i] == endFmt[i] {
+		i++
+	}
+	return fmt.Sprintf("%s-%s", startFmt, endFmt[i:])
+}
+
+// parseTrackTimeRange parses the representation returned by formatTrackTimeRange
+func parseTrackTimeRange(s string) (aeroapi.TimeRange, error) {
+	startFmt, endSuffix, found := strings.Cut(s, "-")
+	if !found || len(endSuffix) > len(startFmt) {
+		return aeroapi.TimeRange{}, fmt.Errorf("unrecognized time range(%s)", s)
+	}
+	start, parseStartErr := time.Parse(kmzTimestampFormat, startFmt)
+	if parseStartErr != nil {
+		return aeroapi.TimeRange{}, parseStartErr
+	}
+	end, parseEndErr := time.Parse(kmzTimestampFormat, startFmt[:len(startFmt)-len(endSuffix)]+endSuffix)
+	if parseEndErr != nil {
+		return aeroapi.TimeRange{}, parseEndErr
+	}
+	return aeroapi.TimeRange{Start: start, End: end}, nil
+}
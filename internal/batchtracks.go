@@ -0,0 +1,269 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/noodnik2/flightvisualizer/internal/artifacts"
+	"github.com/noodnik2/flightvisualizer/internal/kml"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	persistence2 "github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// batchDateFormat is the format of the dates by which the tracks of a batch are grouped
+const batchDateFormat = "2006-01-02"
+
+// batchTrack is a track artifact found in a batch, along with the visualization made from it
+type batchTrack struct {
+	Filename    string // of the track artifact
+	Tail        string // e.g., "N335SP"
+	Label       string // names the visualization; the tail number, unless it's ambiguous
+	Date        string // (UTC) date of the start of the track, e.g., "2023-05-23"
+	KmzFilename string // of the visualization made from the track
+	track       *aeroapi.Track
+}
+
+// IsArtifactsBatch returns true if the reference to artifacts names a directory, or a
+// pattern (e.g., "archive/fvt_N335SP-*"), rather than a single artifact
+func IsArtifactsBatch(ref string) bool {
+	if strings.ContainsAny(ref, "*?[") {
+		return true
+	}
+	fileInfo, statErr := os.Stat(ref)
+	return statErr == nil && fileInfo.IsDir()
+}
+
+// generateBatch renders the track artifacts found in the directory (or matching the pattern)
+// named by FromArtifacts, grouped by tail number and date, skipping (without loading) those whose
+// visualization is up to date (unless Force is set).  Artifacts which can't be loaded or rendered
+// are reported, and fail the batch once the others have been rendered.
+func (tca TracksCommandArgs) generateBatch(ctx context.Context, kmlGenerator *kml.TrackBuilderEnsemble) error {
+	filenames, findErr := findTrackArtifacts(tca.FromArtifacts)
+	if findErr != nil {
+		return findErr
+	}
+	if tca.SaveResponses {
+		// there's no good reason to save data already coming from local files
+		log.Printf("NOTE: inappropriate 'save responses' option ignored\n")
+	}
+
+	var visualizations batchVisualizations
+	if !tca.Force {
+		visualizations = tca.findBatchVisualizations(kmlGenerator.Settings)
+	}
+	loader := tca.newArtifactLoader()
+	var batchTracks []*batchTrack
+	var nFound, nUpToDate, nFailed int
+	for _, filename := range filenames {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		flightId, _ := aeroapi.ParseTrackArtifactFilename(filename)
+		tail, _, _ := strings.Cut(flightId, "-")
+		if tca.TailNumber != "" && !strings.EqualFold(tca.TailNumber, tail) {
+			continue
+		}
+		nFound++
+		if kmzFilename, upToDate := visualizations.isUpToDate(filename); upToDate {
+			nUpToDate++
+			if tca.IsVerbose() {
+				log.Printf("INFO: visualization(%s) is up to date\n", kmzFilename)
+			}
+			continue
+		}
+		bt, loadErr := tca.loadBatchTrack(ctx, loader, filename, flightId, tail, kmlGenerator.Name)
+		if loadErr != nil {
+			log.Printf("ERROR: couldn't load artifact(%s): %v\n", filename, loadErr)
+			nFailed++
+			continue
+		}
+		if bt != nil {
+			batchTracks = append(batchTracks, bt)
+		}
+	}
+	disambiguateBatchLabels(batchTracks, tca.getArtifactsDir(), kmlGenerator.Name)
+	sort.SliceStable(batchTracks, func(i, j int) bool {
+		if batchTracks[i].Tail != batchTracks[j].Tail {
+			return batchTracks[i].Tail < batchTracks[j].Tail
+		}
+		return batchTracks[i].Date < batchTracks[j].Date
+	})
+
+	// stale visualizations are replaced by those rendered (per the Overwrite policy), each named for
+	// the tail number of its track
+	tca.Ident, tca.TailNumber = "", ""
+	var nRendered int
+	for start := 0; start < len(batchTracks); {
+		end := start
+		for end < len(batchTracks) && batchTracks[end].Tail == batchTracks[start].Tail && batchTracks[end].Date == batchTracks[start].Date {
+			end++
+		}
+		group := batchTracks[start:end]
+		start = end
+
+		var kmlTracks []*kml.Track
+		for _, bt := range group {
+			if tca.DryRun {
+				log.Printf("INFO: would render(%s) from(%s)\n", bt.KmzFilename, bt.Filename)
+				continue
+			}
+			kmlTrack, generateErr := kmlGenerator.Generate(ctx, bt.track)
+			if generateErr != nil {
+				if ctxErr := ctx.Err(); ctxErr != nil {
+					return ctxErr
+				}
+				log.Printf("ERROR: couldn't render artifact(%s): %v\n", bt.Filename, generateErr)
+				nFailed++
+				continue
+			}
+			kmlTrack.Label = bt.Label
+			kmlTracks = append(kmlTracks, kmlTrack)
+		}
+		log.Printf("INFO: %s %s: rendering %d track(s)\n", group[0].Tail, group[0].Date, len(kmlTracks))
+		if _, saveErr := tca.saveKmlTracks(kmlTracks, kmlGenerator); saveErr != nil {
+			return saveErr
+		}
+		nRendered += len(kmlTracks)
+	}
+
+	log.Printf("INFO: rendered %d visualization(s) from %d track artifact(s); %d up to date, %d failed\n", nRendered, nFound, nUpToDate, nFailed)
+	if nFailed > 0 {
+		return fmt.Errorf("%d of %d track artifact(s) couldn't be rendered", nFailed, nFound)
+	}
+	return nil
+}
+
+// loadBatchTrack loads the track artifact, returning nil if it has no positions
+func (tca TracksCommandArgs) loadBatchTrack(ctx context.Context, loader persistence2.FileLoader, filename, flightId, tail, kmlLayersUi string) (*batchTrack, error) {
+	contents, loadErr := loader.Load(ctx, filename)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	track, unmarshalErr := aeroapi.TrackFromJson(contents)
+	if unmarshalErr != nil {
+		return nil, fmt.Errorf("invalid artifact(%s): %w", filename, unmarshalErr)
+	}
+	if len(track.Positions) == 0 {
+		log.Printf("NOTE: ignoring artifact(%s); it has no positions\n", filename)
+		return nil, nil
+	}
+	track.FlightId = flightId
+	bt := &batchTrack{
+		Filename: filename,
+		Tail:     tail,
+		Label:    tail,
+		Date:     track.Positions[0].Timestamp.UTC().Format(batchDateFormat),
+		track:    track,
+	}
+	bt.KmzFilename = bt.makeKmzFilename(tca.getArtifactsDir(), kmlLayersUi)
+	return bt, nil
+}
+
+func (bt *batchTrack) makeKmzFilename(dir, kmlLayersUi string) string {
+	positions := bt.track.Positions
+	kmzName := artifacts.MakeKmzFilename(bt.Label, positions[0].Timestamp, positions[len(positions)-1].Timestamp, kmlLayersUi)
+	return persistence2.JoinRef(dir, kmzName)
+}
+
+// disambiguateBatchLabels names the visualizations of tracks which would otherwise share
+// the same name (e.g., variants of the same flight) using their flight ids
+func disambiguateBatchLabels(batchTracks []*batchTrack, dir, kmlLayersUi string) {
+	counts := make(map[string]int)
+	for _, bt := range batchTracks {
+		counts[bt.KmzFilename]++
+	}
+	for _, bt := range batchTracks {
+		if counts[bt.KmzFilename] > 1 {
+			bt.Label = bt.track.FlightId
+			bt.KmzFilename = bt.makeKmzFilename(dir, kmlLayersUi)
+		}
+	}
+}
+
+// findTrackArtifacts returns the names of the track artifacts in the directory, or matching the pattern;
+// of those saved both with and without compression, only the uncompressed is returned
+func findTrackArtifacts(ref string) ([]string, error) {
+	pattern := ref
+	if !strings.ContainsAny(ref, "*?[") {
+		pattern = filepath.Join(ref, "fvt_*")
+	}
+	matches, globErr := filepath.Glob(pattern)
+	if globErr != nil {
+		return nil, fmt.Errorf("invalid pattern(%s): %w", ref, globErr)
+	}
+	sort.Strings(matches)
+	var filenames []string
+	found := make(map[string]bool)
+	for _, match := range matches {
+		if !aeroapi.IsTrackArtifactFilename(match) || found[persistence2.TrimCompressionSuffix(match)] {
+			continue
+		}
+		found[persistence2.TrimCompressionSuffix(match)] = true
+		filenames = append(filenames, match)
+	}
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("no track artifacts found in(%s)", ref)
+	}
+	return filenames, nil
+}
+
+// batchVisualizations are the visualizations (recorded in the index of the artifacts directory)
+// made with the current settings, keyed by the name of the track artifact from which each was made
+type batchVisualizations map[string][]string
+
+// findBatchVisualizations returns the visualizations made with the settings, as recorded in the index
+// of the artifacts directory; none are found if it's not indexed (e.g., it's in object storage)
+func (tca TracksCommandArgs) findBatchVisualizations(settings string) batchVisualizations {
+	index := tca.getArtifactsIndex()
+	if index == nil {
+		return nil
+	}
+	entries, entriesErr := index.Entries()
+	if entriesErr != nil {
+		log.Printf("NOTE: re-rendering all visualizations; couldn't read artifacts index: %v\n", entriesErr)
+		return nil
+	}
+	visualizations := make(batchVisualizations)
+	for _, entry := range entries {
+		if metadata, ok := artifacts.ParseName(entry.Name); !ok || metadata.Kind != artifacts.KindKmz || entry.Settings != settings {
+			continue
+		}
+		for _, source := range entry.Sources {
+			visualizations[source] = append(visualizations[source], persistence2.JoinRef(tca.getArtifactsDir(), entry.Name))
+		}
+	}
+	return visualizations
+}
+
+// isUpToDate returns true (and its name) if a visualization of the track artifact, made with
+// the current settings, is newer than the artifact
+func (bv batchVisualizations) isUpToDate(filename string) (string, bool) {
+	kmzFilenames := bv[persistence2.TrimCompressionSuffix(filepath.Base(filename))]
+	if len(kmzFilenames) == 0 {
+		return "", false
+	}
+	artifactInfo, statErr := os.Stat(filename)
+	if statErr != nil {
+		return "", false
+	}
+	for _, kmzFilename := range kmzFilenames {
+		kmzInfo, kmzStatErr := os.Stat(kmzFilename)
+		if kmzStatErr != nil {
+			if !errors.Is(kmzStatErr, fs.ErrNotExist) {
+				log.Printf("NOTE: re-rendering visualization(%s): %v\n", kmzFilename, kmzStatErr)
+			}
+			continue
+		}
+		if !kmzInfo.ModTime().Before(artifactInfo.ModTime()) {
+			return kmzFilename, true
+		}
+	}
+	return "", false
+}
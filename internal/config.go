@@ -6,17 +6,75 @@ import (
 	"os"
 	"path/filepath"
 	"runtime/debug"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
 )
 
 type Config struct {
 	AeroApiUrl   string `env:"AEROAPI_API_URL,default=https://aeroapi.flightaware.com/aeroapi"`
 	ArtifactsDir string `env:"ARTIFACTS_DIR,default=."`
+	DemDir       string `env:"DEM_DIR"`
+	AirportsDir  string `env:"AIRPORTS_DIR"`
+	CacheDir     string `env:"CACHE_DIR"`
 	Verbose      bool   `env:"VERBOSE,default=false"`
+	// AeroApiRequestsPerSecond limits the rate of AeroAPI requests (0=unlimited), e.g., to respect the account's quota
+	AeroApiRequestsPerSecond float64 `env:"AEROAPI_REQUESTS_PER_SECOND,default=0"`
+	// AeroApiRequestTimeoutSecs limits the time allowed for each AeroAPI request (0=unlimited)
+	AeroApiRequestTimeoutSecs int `env:"AEROAPI_REQUEST_TIMEOUT_SECS,default=30"`
+	// AeroApiMaxAttempts limits the number of times a failing (e.g., rate limited) AeroAPI request is attempted
+	AeroApiMaxAttempts int `env:"AEROAPI_MAX_ATTEMPTS,default=4"`
+	// AeroApiPrices overrides the estimated cost of AeroAPI requests by endpoint class (e.g., "flights=0.005,track=0.012")
+	AeroApiPrices string `env:"AEROAPI_PRICES"`
+	// AeroApiMonthlyBudget limits the estimated cost of AeroAPI requests made each month (0=unlimited)
+	AeroApiMonthlyBudget float64 `env:"AEROAPI_MONTHLY_BUDGET,default=0"`
+	// LedgerFile records the AeroAPI requests made each month, along with their estimated cost
+	LedgerFile string `env:"LEDGER_FILE"`
+	// ArtifactsS3Endpoint is the base URL of the S3-compatible storage (e.g., "http://localhost:9000" for MinIO)
+	// used when ARTIFACTS_DIR is an "s3://bucket/prefix" URL (""=AWS S3)
+	ArtifactsS3Endpoint        string `env:"ARTIFACTS_S3_ENDPOINT"`
+	ArtifactsS3Region          string `env:"ARTIFACTS_S3_REGION,default=us-east-1"`
+	ArtifactsS3AccessKeyId     string `env:"ARTIFACTS_S3_ACCESS_KEY_ID"`
+	ArtifactsS3SecretAccessKey string `env:"ARTIFACTS_S3_SECRET_ACCESS_KEY" secret:"mask"`
+	// PluginsDir holds programs (e.g., "fviz-layer-contrail") implementing additional layers
+	PluginsDir string `env:"PLUGINS_DIR"`
+	// PluginTimeoutSecs limits the time allowed a plugin to build its layer of each visualization
+	PluginTimeoutSecs int `env:"PLUGIN_TIMEOUT_SECS,default=30"`
 	// "required" fields should come at the end; otherwise, the defaults (above) won't be applied when
 	// the required values aren't found (that error isn't fatal so we want the defaults to be applied)
 	AeroApiKey string `env:"AEROAPI_API_KEY,required" secret:"mask"`
 }
 
+// GetCacheDir returns the directory in which AeroAPI responses are cached
+func (c Config) GetCacheDir() (string, error) {
+	if c.CacheDir != "" {
+		return c.CacheDir, nil
+	}
+	return aeroapi.NewDefaultCacheDir()
+}
+
+// NewArtifactsS3Store returns the store of artifacts located by "s3://bucket/prefix" URLs
+func (c Config) NewArtifactsS3Store() *persistence.S3Store {
+	return &persistence.S3Store{
+		Endpoint:        c.ArtifactsS3Endpoint,
+		Region:          c.ArtifactsS3Region,
+		AccessKeyId:     c.ArtifactsS3AccessKeyId,
+		SecretAccessKey: c.ArtifactsS3SecretAccessKey,
+	}
+}
+
+// GetLedgerFilename returns the name of the file recording the AeroAPI requests made each month
+func (c Config) GetLedgerFilename() (string, error) {
+	if c.LedgerFile != "" {
+		return c.LedgerFile, nil
+	}
+	userConfigDir, getConfigDirErr := os.UserConfigDir()
+	if getConfigDirErr != nil {
+		return "", getConfigDirErr
+	}
+	return filepath.Join(userConfigDir, "fviz-ledger.json"), nil
+}
+
 const (
 	userConfigFilenameEnvVar = "FVIZ_CONFIG_FILE"
 	configFile               = ".config/fviz"
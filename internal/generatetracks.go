@@ -1,30 +1,32 @@
 package internal
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"image/color"
+	"io"
 	"log"
+	"net/http"
+	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"time"
 
 	iaeroapi "github.com/noodnik2/flightvisualizer/internal/aeroapi"
+	"github.com/noodnik2/flightvisualizer/internal/artifacts"
 	"github.com/noodnik2/flightvisualizer/internal/kml"
 	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
 	ios "github.com/noodnik2/flightvisualizer/internal/os"
 	"github.com/noodnik2/flightvisualizer/internal/persistence"
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/airports"
+	"github.com/noodnik2/flightvisualizer/pkg/gpx"
 	persistence2 "github.com/noodnik2/flightvisualizer/pkg/persistence"
-)
-
-const (
-	TracksLayerCamera          = "camera"
-	TracksLayerPath            = "path"
-	TracksLayerPlacemark       = "placemark"
-	TracksLayerVector          = "vector"
-	kmlArtifactsFilenamePrefix = "fvk_"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
 )
 
 type sourceType int
@@ -35,49 +37,128 @@ const (
 	sourceTypeSingleTrackArtifact            // use a recorded "track" artifact as the source document
 	sourceTypeMultiTrackArtifact             // use a recorded "flight ids" artifact as the source document
 	sourceTypeSingleTrackRemote              // pull a remote "flight id" document (e.g., from AeroAPI server)
+	sourceTypeIdentRemote                    // resolve an airline flight identifier (e.g., "UAL123") using AeroAPI
+	sourceTypeBundleArtifact                 // use the "flight ids" artifact held in a session bundle as the source document
+	sourceTypeBatchArtifacts                 // render the "track" artifacts found in a directory (or matching a pattern)
+	sourceTypeGpxFile                        // use a track recorded in a GPX file (e.g., by a flight logging application)
 )
 
-var TracksLayersSupported = []string{TracksLayerCamera, TracksLayerPath, TracksLayerPlacemark, TracksLayerVector}
-
 type TracksCommandArgs struct {
-	Config           Config
-	LaunchFirstKml   bool
-	NoBanking        bool
-	SaveResponses    bool
-	VerboseOperation bool
-	DebugOperation   bool
-	FromArtifacts    string
-	ArtifactsDir     string
-	KmlLayers        string
-	TailNumber       string
-	FlightNumber     string
-	FlightCount      int
-	CutoffTime       time.Time
-}
-
-func (tca TracksCommandArgs) GenerateTracks() error {
-
-	kmlGenerator, getKmlGeneratorErr := tca.newKmlTrackGenerator(strings.Split(tca.KmlLayers, ","))
+	Config            Config
+	LaunchFirstKml    bool
+	NoBanking         bool
+	SaveResponses     bool
+	EnvelopeArtifacts bool
+	Compression       string // format (e.g., "gzip") in which responses are saved (""=uncompressed)
+	Overwrite         string // policy (e.g., "suffix") when a KML visualization of the same name exists
+	Force             bool   // re-render visualizations of a batch of artifacts, even if up to date
+	NoCache           bool
+	DryRun            bool
+	VerboseOperation  bool
+	DebugOperation    bool
+	FromArtifacts     string
+	ArtifactsDir      string
+	RecordFile        string
+	ReplayFile        string
+	DemDir            string
+	AirportsDir       string
+	KmlLayers         string
+	Simplify          string
+	TailNumber        string
+	FlightNumber      string
+	Ident             string
+	FlightCount       int
+	Concurrency       int
+	MaxFeatures       int
+	MaxDocumentBytes  int
+	MinAglFeet        float64
+	MaxCost           float64
+	TimeRangeOptions  []string // names of the options (e.g., "start") giving TimeRange
+	TimeRange         aeroapi.TimeRange
+	FlightFilter      aeroapi.FlightFilter
+	FlightDate        time.Time
+	Timeout           time.Duration
+	ChooseFlight      iaeroapi.FlightChooser
+	Version           string // version of fviz, recorded in the envelope of saved artifacts
+	accounting        *aeroapi.AccountingTransport
+	transport         http.RoundTripper
+}
+
+// GenerateTracks generates the KML visualization(s) requested, abandoning the effort
+// if the context is cancelled (e.g., by the user) or the Timeout (if any) elapses
+func (tca TracksCommandArgs) GenerateTracks(ctx context.Context) error {
+
+	if tca.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, tca.Timeout)
+		defer cancel()
+	}
+
+	kmlLayers := tca.KmlLayers
+	if kmlLayers == "" {
+		kmlLayers = strings.Join(builders.DefaultLayerNames(), ",")
+	}
+	kmlGenerator, getKmlGeneratorErr := tca.newKmlTrackGenerator(splitLayerSpecs(kmlLayers))
 	if getKmlGeneratorErr != nil {
 		return getKmlGeneratorErr
 	}
 
+	if tca.RecordFile != "" && tca.ReplayFile != "" {
+		return errors.New("can't both record and replay a session")
+	}
+
+	if tca.FromArtifacts == "" && tca.ReplayFile != "" {
+		// replaying the AeroAPI responses recorded in a session, rather than making (billed) requests
+		player, loadCassetteErr := aeroapi.LoadCassette(tca.ReplayFile)
+		if loadCassetteErr != nil {
+			return fmt.Errorf("couldn't load session(%s) to replay: %w", tca.ReplayFile, loadCassetteErr)
+		}
+		tca.transport = player
+	} else if tca.FromArtifacts == "" {
+		// account for the cost of the AeroAPI request(s) made
+		var newAccountingErr error
+		if tca.accounting, newAccountingErr = tca.newAccounting(); newAccountingErr != nil {
+			return newAccountingErr
+		}
+		defer tca.reportUsage()
+		tca.transport = tca.accounting
+		if tca.RecordFile != "" {
+			// recording the AeroAPI requests made, and the responses received for them
+			recorder := &aeroapi.Recorder{}
+			tca.accounting.Transport = recorder
+			defer tca.saveRecording(recorder)
+		}
+	}
+
+	if st, _ := tca.getSourceType(); st == sourceTypeBatchArtifacts {
+		return tca.generateBatch(ctx, kmlGenerator)
+	}
+
 	trackFactory, trackFactoryErr := tca.newTrackFactory()
 	if trackFactoryErr != nil {
 		return fmt.Errorf("no KML track factory could be created: %v", trackFactoryErr)
 	}
-	kmlTracks, generateKmlTracksErr := trackFactory(kmlGenerator)
+	kmlTracks, generateKmlTracksErr := trackFactory(ctx, kmlGenerator)
+	if tca.DryRun {
+		// the (empty) responses returned in place of those from AeroAPI aren't expected to produce tracks
+		if generateKmlTracksErr != nil {
+			log.Printf("NOTE: %v\n", generateKmlTracksErr)
+		}
+		return nil
+	}
 	if generateKmlTracksErr != nil {
 		return generateKmlTracksErr
 	}
 
-	firstKmlFilename, saveKmlErr := tca.saveKmlTracks(kmlTracks, kmlGenerator.Name)
+	firstKmlFilename, saveKmlErr := tca.saveKmlTracks(kmlTracks, kmlGenerator)
 	if saveKmlErr != nil {
 		return saveKmlErr
 	}
 
 	// if indicated, "launch" the (first of the) generated KML visualization(s)
-	if tca.LaunchFirstKml && firstKmlFilename != "" {
+	if tca.LaunchFirstKml && persistence2.IsS3Url(firstKmlFilename) {
+		log.Printf("NOTE: not launching '%s'; it's not a local file\n", firstKmlFilename)
+	} else if tca.LaunchFirstKml && firstKmlFilename != "" {
 		log.Printf("INFO: Launching '%s'\n", firstKmlFilename)
 		if openErr := ios.LaunchFile(firstKmlFilename); openErr != nil {
 			return fmt.Errorf("error returned from launching(%s): %v", firstKmlFilename, openErr)
@@ -87,27 +168,50 @@ func (tca TracksCommandArgs) GenerateTracks() error {
 	return nil
 }
 
-func (tca TracksCommandArgs) saveKmlTracks(kmlTracks []*kml.Track, kmlLayersUi string) (string, error) {
+func (tca TracksCommandArgs) saveKmlTracks(kmlTracks []*kml.Track, kmlGenerator *kml.TrackBuilderEnsemble) (string, error) {
+	kmlLayersUi := kmlGenerator.Name
 	nKmlDocs := len(kmlTracks)
 	if tca.IsVerbose() || nKmlDocs > 1 {
 		log.Printf("INFO: writing %d %s KML document(s)\n", nKmlDocs, kmlLayersUi)
 	}
 
 	// save the KML document(s) produced along with their asset(s) as `.kmz` file(s)
+	index := tca.getArtifactsIndex()
 	var firstKmlFilename string
 	for _, aeroKml := range kmlTracks {
 		kmzSaver := &persistence.KmzSaver{
-			Saver:  &persistence2.FileSaver{},
+			Saver:  tca.newKmzSaver(),
 			Assets: aeroKml.KmlAssets,
 		}
-		flightTimeRange := getTsFromTo(*aeroKml.StartTime, *aeroKml.EndTime)
-		kmlFilename := filepath.Join(
+		flightLabel := tca.Ident
+		if flightLabel == "" {
+			flightLabel = tca.TailNumber
+		}
+		if flightLabel == "" {
+			flightLabel = aeroKml.Label
+		}
+		if flightLabel == "" {
+			// e.g., tracks loaded from artifacts don't carry the tail number
+			flightLabel = aeroKml.GetRouteName()
+		}
+		kmlFilename := persistence2.JoinRef(
 			tca.getArtifactsDir(),
-			fmt.Sprintf("%s%s_%s_%s.kmz", kmlArtifactsFilenamePrefix, tca.TailNumber, flightTimeRange, kmlLayersUi),
+			artifacts.MakeKmzFilename(flightLabel, *aeroKml.StartTime, *aeroKml.EndTime, kmlLayersUi),
 		)
 
-		if writeErr := kmzSaver.Save(kmlFilename, aeroKml.KmlDoc); writeErr != nil {
-			return "", fmt.Errorf("couldn't write output artifact(%s): %v", kmlFilename, writeErr)
+		kmlFilename, writeErr := kmzSaver.SaveAs(kmlFilename, aeroKml.KmlDoc)
+		if writeErr != nil {
+			return "", fmt.Errorf("couldn't write output artifact(%s): %w", kmlFilename, writeErr)
+		}
+		if aeroKml.Statistics != nil {
+			log.Printf("INFO: statistics of %s: %s\n", filepath.Base(kmlFilename), aeroKml.Statistics)
+		}
+		if index != nil && aeroKml.FlightId != "" {
+			// relate the visualization to the track (and settings) from which it was made
+			trackFilename := aeroapi.MakeTrackArtifactFilename(aeroKml.FlightId)
+			if relateErr := index.AddSources(kmlFilename, kmlGenerator.Settings, trackFilename); relateErr != nil {
+				log.Printf("WARNING: couldn't relate artifact(%s) to its source: %v\n", kmlFilename, relateErr)
+			}
 		}
 
 		if firstKmlFilename == "" {
@@ -117,50 +221,127 @@ func (tca TracksCommandArgs) saveKmlTracks(kmlTracks []*kml.Track, kmlLayersUi s
 	return firstKmlFilename, nil
 }
 
+// newKmlTrackGenerator returns the generator of the layers specified, each either by its name
+// (e.g., "path") or its name followed by the options of its builder (e.g., "path(width=5)")
 func (tca TracksCommandArgs) newKmlTrackGenerator(kmlLayers []string) (*kml.TrackBuilderEnsemble, error) {
 
+	layerSpecs := make([]layerSpec, len(kmlLayers))
+	for i, kmlLayer := range kmlLayers {
+		var parseErr error
+		if layerSpecs[i], parseErr = parseLayerSpec(kmlLayer); parseErr != nil {
+			return nil, parseErr
+		}
+	}
+
 	// order layer builder(s) for deterministic output
-	sort.Strings(kmlLayers)
+	sort.SliceStable(layerSpecs, func(i, j int) bool { return layerSpecs[i].Name < layerSpecs[j].Name })
 
-	builtLayers := make([]string, 0, len(kmlLayers))
+	elevationProvider := tca.newElevationProvider()
+	simplifyTolerances, parseSimplifyErr := parseSimplifyTolerances(tca.Simplify)
+	if parseSimplifyErr != nil {
+		return nil, parseSimplifyErr
+	}
+
+	builtLayers := make([]string, 0, len(layerSpecs))
+	var builtSpecs []string
 	var kmlBuilders []builders.KmlTrackBuilder
-	for _, kmlLayer := range kmlLayers {
+	for i, spec := range layerSpecs {
+		kmlLayer := spec.Name
 		if len(builtLayers) > 0 && kmlLayer == builtLayers[len(builtLayers)-1] {
-			// ignore duplicates
+			// ignore duplicates, unless they'd configure the layer differently
+			if spec.canonical() != layerSpecs[i-1].canonical() {
+				return nil, fmt.Errorf("conflicting options of layer(%s): %s, %s", kmlLayer, layerSpecs[i-1], spec)
+			}
 			continue
 		}
-		var kmlBuilder builders.KmlTrackBuilder
-		switch kmlLayer {
-		case TracksLayerCamera:
-			kmlBuilder = &builders.CameraBuilder{
-				AddBankAngle: !tca.NoBanking,
-				DebugFlag:    tca.DebugOperation,
-			}
-		case TracksLayerPath:
-			kmlBuilder = &builders.PathBuilder{
-				Extrude: true,
-				Color:   color.RGBA{R: 217, G: 51, B: 255},
+		kmlBuilder, newBuilderErr := tca.newLayerBuilder(kmlLayer, elevationProvider)
+		if newBuilderErr != nil {
+			return nil, newBuilderErr
+		}
+		if setOptionsErr := setLayerOptions(kmlBuilder, spec); setOptionsErr != nil {
+			return nil, setOptionsErr
+		}
+		builtSpec := spec.canonical()
+		if tolerance := simplifyTolerances.getTolerance(kmlLayer); tolerance > 0 {
+			kmlBuilder = &builders.SimplifiedBuilder{
+				KmlTrackBuilder: kmlBuilder,
+				ToleranceMeters: tolerance,
+				DebugFlag:       tca.DebugOperation,
 			}
-		case TracksLayerPlacemark:
-			kmlBuilder = &builders.PlacemarkBuilder{}
-		case TracksLayerVector:
-			kmlBuilder = &builders.VectorBuilder{}
-		default:
-			return nil, fmt.Errorf("unrecognized kmlLayer(%s); supported: %v", kmlLayer,
-				strings.Join(TracksLayersSupported, ","))
+			builtSpec += fmt.Sprintf("/simplify=%g", tolerance)
 		}
 		builtLayers = append(builtLayers, kmlLayer)
+		builtSpecs = append(builtSpecs, builtSpec)
 		kmlBuilders = append(kmlBuilders, kmlBuilder)
 	}
 
+	airportsDb, loadAirportsErr := tca.loadAirports()
+	if loadAirportsErr != nil {
+		return nil, fmt.Errorf("couldn't load airports database: %w", loadAirportsErr)
+	}
+
 	ensemble := &kml.TrackBuilderEnsemble{
-		Name:     strings.Join(builtLayers, "-"),
-		Builders: kmlBuilders,
+		Name:             strings.Join(builtLayers, "-"),
+		Settings:         tca.getSettingsFingerprint(builtSpecs, elevationProvider != nil, airportsDb != nil),
+		Builders:         kmlBuilders,
+		Airports:         airportsDb,
+		Terrain:          elevationProvider,
+		MinAglFeet:       tca.MinAglFeet,
+		MaxFeatures:      tca.MaxFeatures,
+		MaxDocumentBytes: tca.MaxDocumentBytes,
 	}
 	return ensemble, nil
 }
 
-type kmlTrackFactory func(kml.TrackGenerator) ([]*kml.Track, error)
+// getSettingsFingerprint returns a fingerprint of the settings with which tracks are depicted by the
+// layers (each specified along with its options and simplification), telling apart visualizations
+// which are named the same (e.g., "…_camera-path.kmz") but made with different settings
+func (tca TracksCommandArgs) getSettingsFingerprint(layerSpecs []string, hasTerrain, hasAirports bool) string {
+	settings := fmt.Sprintf("layers=%s;minAgl=%g;noBanking=%t;terrain=%t;airports=%t;maxFeatures=%d;maxDocumentBytes=%d",
+		strings.Join(layerSpecs, ","), tca.MinAglFeet, tca.NoBanking, hasTerrain, hasAirports, tca.MaxFeatures, tca.MaxDocumentBytes)
+	digest := sha256.Sum256([]byte(settings))
+	return hex.EncodeToString(digest[:8])
+}
+
+// simplifyTolerances maps layer names to the tolerance (in meters) used to simplify their
+// tracks; the empty layer name maps the tolerance used for layers not otherwise named
+type simplifyTolerances map[string]float64
+
+// parseSimplifyTolerances parses a specification such as "vector=100,camera=50" (simplifying only
+// the named layers) or "100" (simplifying all layers) into simplifyTolerances
+func parseSimplifyTolerances(spec string) (simplifyTolerances, error) {
+	tolerances := make(simplifyTolerances)
+	if spec == "" {
+		return tolerances, nil
+	}
+	for _, item := range strings.Split(spec, ",") {
+		var layer, toleranceText string
+		if equalsAt := strings.Index(item, "="); equalsAt >= 0 {
+			layer, toleranceText = strings.TrimSpace(item[:equalsAt]), item[equalsAt+1:]
+			if !isSupportedLayer(layer) {
+				return nil, fmt.Errorf("unrecognized simplify layer(%s); supported: %v", layer,
+					strings.Join(builders.LayerNames(), ","))
+			}
+		} else {
+			toleranceText = item
+		}
+		tolerance, parseErr := strconv.ParseFloat(strings.TrimSpace(toleranceText), 64)
+		if parseErr != nil || tolerance < 0 {
+			return nil, fmt.Errorf("invalid simplify tolerance(%s)", item)
+		}
+		tolerances[layer] = tolerance
+	}
+	return tolerances, nil
+}
+
+func (st simplifyTolerances) getTolerance(layer string) float64 {
+	if tolerance, ok := st[layer]; ok {
+		return tolerance
+	}
+	return st[""]
+}
+
+type kmlTrackFactory func(context.Context, kml.TrackGenerator) ([]*kml.Track, error)
 
 func (tca TracksCommandArgs) newTrackFactory() (kmlTrackFactory, error) {
 
@@ -179,6 +360,10 @@ func (tca TracksCommandArgs) newTrackFactory() (kmlTrackFactory, error) {
 		// pull single track from a recorded artifact (e.g., using either / both tail number and/or flight id)
 		return singleTrackArtifactFactory(tca), nil
 
+	case sourceTypeIdentRemote:
+		// pull the track(s) of the flight(s) resolved from an airline flight identifier (e.g., and date)
+		return identRemoteFactory(tca), nil
+
 	case sourceTypeMultiTrackRemote:
 		// pull potentially multiple tracks from remote source (e.g., based upon tail number, cutoff time and max flight count)
 		return multiTrackRemoteFactory(tca), nil
@@ -186,28 +371,65 @@ func (tca TracksCommandArgs) newTrackFactory() (kmlTrackFactory, error) {
 	case sourceTypeMultiTrackArtifact:
 		// pull potentially multiple tracks from a recorded artifact (e.g., for tail number potentially having multiple flights)
 		return multiTrackArtifactFactory(tca), nil
+
+	case sourceTypeBundleArtifact:
+		// pull potentially multiple tracks from the artifacts held in a session bundle
+		return bundleArtifactFactory(tca), nil
+
+	case sourceTypeGpxFile:
+		// pull single track from a GPX file
+		return gpxFileFactory(tca), nil
+
+	case sourceTypeBatchArtifacts:
+		// each of the batch's groups of tracks is rendered and saved in turn (see generateBatch)
+		return nil, errors.New("a batch of artifacts isn't rendered by a track factory")
 	}
 
 	return nil, errors.New("can't determine source type")
 }
 
 func multiTrackRemoteFactory(tca TracksCommandArgs) kmlTrackFactory {
-	return func(tracker kml.TrackGenerator) ([]*kml.Track, error) {
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
 		tc := iaeroapi.TracksConverter{
 			Verbose:     tca.IsVerbose(),
 			FlightCount: tca.FlightCount,
-			CutoffTime:  tca.CutoffTime,
+			TimeRange:   tca.TimeRange,
+			Filter:      tca.FlightFilter,
+			Concurrency: tca.Concurrency,
+			Preview:     tca.getFlightsPreview(),
 		}
-		return tc.ConvertForTailNumber(newRemoteAeroApi(tca), tracker, tca.TailNumber)
+		return tc.ConvertForTailNumber(ctx, newRemoteAeroApi(tca), tracker, tca.TailNumber)
+	}
+}
+
+func identRemoteFactory(tca TracksCommandArgs) kmlTrackFactory {
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+		aeroApi := newRemoteAeroApi(tca)
+		ir := iaeroapi.IdentResolver{
+			Verbose:   tca.IsVerbose(),
+			Date:      tca.FlightDate,
+			TimeRange: tca.TimeRange,
+			Filter:    tca.FlightFilter,
+			Choose:    tca.ChooseFlight,
+		}
+		flightIds, resolveErr := ir.ResolveIdent(ctx, aeroApi, tca.Ident)
+		if resolveErr != nil {
+			return nil, resolveErr
+		}
+		tc := iaeroapi.TracksConverter{
+			Verbose:     tca.IsVerbose(),
+			Concurrency: tca.Concurrency,
+		}
+		return tc.ConvertForFlightIds(ctx, aeroApi, tracker, flightIds)
 	}
 }
 
 func singleTrackRemoteFactory(tca TracksCommandArgs) kmlTrackFactory {
-	return func(tracker kml.TrackGenerator) ([]*kml.Track, error) {
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
 		if tca.FlightNumber == "" {
 			return nil, errors.New("no flight number was provided")
 		}
-		kmlTrack, err := iaeroapi.ConvertForFlightId(newRemoteAeroApi(tca), tracker, tca.FlightNumber)
+		kmlTrack, err := iaeroapi.ConvertForFlightId(ctx, newRemoteAeroApi(tca), tracker, tca.FlightNumber)
 		if err != nil {
 			return nil, err
 		}
@@ -216,34 +438,70 @@ func singleTrackRemoteFactory(tca TracksCommandArgs) kmlTrackFactory {
 }
 
 func multiTrackArtifactFactory(tca TracksCommandArgs) kmlTrackFactory {
-	return func(tracker kml.TrackGenerator) ([]*kml.Track, error) {
-		if tca.SaveResponses {
-			// there's no good reason to save data already coming from local files
-			log.Printf("NOTE: inappropriate 'save responses' option ignored\n")
-		}
-		aeroApi := &aeroapi.RetrieverSaverApiImpl{
-			// reading AeroAPI data from saved artifact files
-			Retriever: &aeroapi.FileAeroApi{
-				ArtifactsDir:      tca.getArtifactsDir(),
-				FlightIdsFileName: tca.FromArtifacts,
-			},
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+		// reading AeroAPI data from saved artifact files
+		retriever := &aeroapi.FileAeroApi{
+			ArtifactsDir:      tca.getArtifactsDir(),
+			FlightIdsFileName: tca.FromArtifacts,
+			FileLoader:        tca.newArtifactLoader(),
 		}
-		tc := iaeroapi.TracksConverter{
-			Verbose:     tca.IsVerbose(),
-			FlightCount: tca.FlightCount,
-			CutoffTime:  tca.CutoffTime,
+		return tca.convertFromArtifacts(ctx, retriever, tracker)
+	}
+}
+
+func bundleArtifactFactory(tca TracksCommandArgs) kmlTrackFactory {
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+		bundle, openErr := artifacts.OpenBundle(tca.FromArtifacts)
+		if openErr != nil {
+			return nil, fmt.Errorf("couldn't open bundle(%s): %w", tca.FromArtifacts, openErr)
 		}
-		return tc.ConvertForTailNumber(aeroApi, tracker, tca.TailNumber)
+		flightIdsName, getNameErr := bundle.GetFlightIdsName()
+		if getNameErr != nil {
+			return nil, getNameErr
+		}
+		// reading AeroAPI data from the artifacts held in the bundle
+		retriever := &aeroapi.FileAeroApi{
+			FlightIdsFileName: flightIdsName,
+			FileLoader:        persistence2.FileLoader{Reader: bundle.Read},
+		}
+		return tca.convertFromArtifacts(ctx, retriever, tracker)
+	}
+}
+
+// convertFromArtifacts converts the track(s) of the flight(s) listed in the "flight ids" artifact
+func (tca TracksCommandArgs) convertFromArtifacts(ctx context.Context, retriever aeroapi.ArtifactRetriever, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+	if tca.SaveResponses {
+		// there's no good reason to save data already coming from local files
+		log.Printf("NOTE: inappropriate 'save responses' option ignored\n")
+	}
+	aeroApi := &aeroapi.RetrieverSaverApiImpl{Retriever: retriever}
+	tc := iaeroapi.TracksConverter{
+		Verbose:     tca.IsVerbose(),
+		FlightCount: tca.FlightCount,
+		TimeRange:   tca.TimeRange,
+		Filter:      tca.FlightFilter,
+		Concurrency: tca.Concurrency,
+		Preview:     tca.getFlightsPreview(),
+	}
+	return tc.ConvertForTailNumber(ctx, aeroApi, tracker, tca.TailNumber)
+}
+
+// getFlightsPreview returns where the flights selected are previewed: only when flights are filtered,
+// since otherwise (all) the flights found are selected
+func (tca TracksCommandArgs) getFlightsPreview() io.Writer {
+	if tca.FlightFilter.IsZero() {
+		return nil
 	}
+	return os.Stdout
 }
 
 func singleTrackArtifactFactory(tca TracksCommandArgs) kmlTrackFactory {
-	return func(tracker kml.TrackGenerator) ([]*kml.Track, error) {
-		track, getTfaErr := tca.getTrackFromArtifact()
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+		track, getTfaErr := tca.getTrackFromArtifact(ctx)
 		if getTfaErr != nil {
 			return nil, getTfaErr
 		}
-		kmlTrack, err := tracker.Generate(track)
+		kmlTrack, err := tracker.Generate(ctx, track)
 		if err != nil {
 			return nil, err
 		}
@@ -251,23 +509,97 @@ func singleTrackArtifactFactory(tca TracksCommandArgs) kmlTrackFactory {
 	}
 }
 
+func gpxFileFactory(tca TracksCommandArgs) kmlTrackFactory {
+	return func(ctx context.Context, tracker kml.TrackGenerator) ([]*kml.Track, error) {
+		loader := tca.newArtifactLoader()
+		contents, loadErr := loader.Load(ctx, tca.FromArtifacts)
+		if loadErr != nil {
+			return nil, loadErr
+		}
+		track, name, readErr := gpx.ReadTrack(contents)
+		if readErr != nil {
+			return nil, fmt.Errorf("couldn't read GPX file(%s): %w", tca.FromArtifacts, readErr)
+		}
+		track.FlightId = name
+		if !tca.TimeRange.IsZero() {
+			// e.g., depicting only one of the flights recorded in the file
+			nPositions := len(track.Positions)
+			if track.Positions = tca.TimeRange.TrimPositions(track.Positions); len(track.Positions) == 0 {
+				return nil, fmt.Errorf("none of the %d position(s) of GPX file(%s) are within time range(%s)", nPositions, tca.FromArtifacts, tca.TimeRange)
+			}
+		}
+		kmlTrack, err := tracker.Generate(ctx, track)
+		if err != nil {
+			return nil, err
+		}
+		// the track is named for the file from which it's read
+		kmlTrack.Label = strings.TrimSuffix(filepath.Base(tca.FromArtifacts), filepath.Ext(tca.FromArtifacts))
+		return []*kml.Track{kmlTrack}, nil
+	}
+}
+
 func newRemoteAeroApi(tca TracksCommandArgs) *aeroapi.RetrieverSaverApiImpl {
 	var artifactSaver aeroapi.ArtifactSaver
+	var provenance *aeroapi.Provenance
 	if tca.SaveResponses {
-		artifactSaver = &aeroapi.FileAeroApi{ArtifactsDir: tca.getArtifactsDir()}
+		if tca.DryRun {
+			log.Printf("NOTE: 'save responses' option ignored in 'dry run'\n")
+		} else {
+			artifactSaver = &aeroapi.FileAeroApi{
+				ArtifactsDir: tca.getArtifactsDir(),
+				FileSaver:    *tca.newArtifactSaver(tca.Compression),
+			}
+			if tca.EnvelopeArtifacts {
+				provenance = &aeroapi.Provenance{Tool: artifacts.ProvenanceTool, Version: tca.Version}
+			}
+		}
 	}
 
-	aeroApi := &aeroapi.RetrieverSaverApiImpl{
-		// reading AeroAPI data from live AeroAPI REST API calls
-		Retriever: &aeroapi.HttpAeroApi{
+	// reading AeroAPI data from live AeroAPI REST API calls
+	httpAeroApi := &aeroapi.HttpAeroApi{
+		Verbose:           tca.IsVerbose(),
+		ApiKey:            tca.Config.AeroApiKey,
+		ApiUrl:            tca.Config.AeroApiUrl,
+		RequestsPerSecond: tca.Config.AeroApiRequestsPerSecond,
+		RequestTimeout:    time.Duration(tca.Config.AeroApiRequestTimeoutSecs) * time.Second,
+	}
+	httpAeroApi.Client = &http.Client{
+		Transport: &aeroapi.RetryingTransport{
+			// accounting (or replaying) is beneath retrying so that each attempt is considered
+			Transport:   tca.transport,
+			MaxAttempts: tca.Config.AeroApiMaxAttempts,
+			// retries are issued at the same limited rate as other requests
+			Wait:    httpAeroApi.WaitForTurn,
 			Verbose: tca.IsVerbose(),
-			ApiKey:  tca.Config.AeroApiKey,
-			ApiUrl:  tca.Config.AeroApiUrl,
 		},
+	}
+	var artifactRetriever aeroapi.ArtifactRetriever = httpAeroApi
 
-		Saver: artifactSaver,
+	if tca.RecordFile != "" || tca.ReplayFile != "" {
+		// the session recorded (or replayed) includes every request, so none may be answered from the cache
+		if tca.IsVerbose() {
+			log.Printf("INFO: not using the response cache when recording or replaying a session\n")
+		}
+	} else if !tca.NoCache {
+		// re-using responses previously retrieved, where they're still fresh
+		cacheDir, getCacheDirErr := tca.Config.GetCacheDir()
+		if getCacheDirErr != nil {
+			log.Printf("NOTE: not caching responses: %v\n", getCacheDirErr)
+		} else {
+			artifactRetriever = &aeroapi.CachingRetriever{
+				ArtifactRetriever: artifactRetriever,
+				Cache:             &aeroapi.ResponseCache{Dir: cacheDir, BaseUrl: tca.Config.AeroApiUrl},
+				ReadOnly:          tca.DryRun,
+				Verbose:           tca.IsVerbose(),
+			}
+		}
+	}
+
+	return &aeroapi.RetrieverSaverApiImpl{
+		Retriever:  artifactRetriever,
+		Saver:      artifactSaver,
+		Provenance: provenance,
 	}
-	return aeroApi
 }
 
 func (tca TracksCommandArgs) getSourceType() (sourceType, error) {
@@ -276,13 +608,28 @@ func (tca TracksCommandArgs) getSourceType() (sourceType, error) {
 		if tca.FlightNumber != "" {
 			return sourceTypeSingleTrackRemote, nil
 		}
+		if tca.Ident != "" {
+			return sourceTypeIdentRemote, nil
+		}
 		return sourceTypeMultiTrackRemote, nil
 	}
 
+	if IsArtifactsBatch(tca.FromArtifacts) {
+		return sourceTypeBatchArtifacts, nil
+	}
+
 	if aeroapi.IsTrackArtifactFilename(tca.FromArtifacts) {
 		return sourceTypeSingleTrackArtifact, nil
 	}
 
+	if gpx.IsGpxFilename(tca.FromArtifacts) {
+		return sourceTypeGpxFile, nil
+	}
+
+	if artifacts.IsBundleFilename(tca.FromArtifacts) {
+		return sourceTypeBundleArtifact, nil
+	}
+
 	if aeroapi.IsFlightIdsArtifactFilename(tca.FromArtifacts) {
 		return sourceTypeMultiTrackArtifact, nil
 	}
@@ -290,12 +637,19 @@ func (tca TracksCommandArgs) getSourceType() (sourceType, error) {
 	return sourceTypeUnrecognized, fmt.Errorf("unrecognized artifact(%s)", tca.FromArtifacts)
 }
 
-func (tca TracksCommandArgs) getTrackFromArtifact() (*aeroapi.Track, error) {
-	contents, loadErr := (&persistence2.FileLoader{}).Load(tca.FromArtifacts)
+func (tca TracksCommandArgs) getTrackFromArtifact(ctx context.Context) (*aeroapi.Track, error) {
+	loader := tca.newArtifactLoader()
+	contents, loadErr := loader.Load(ctx, tca.FromArtifacts)
 	if loadErr != nil {
 		return nil, loadErr
 	}
-	return aeroapi.TrackFromJson(contents)
+	track, unmarshalErr := aeroapi.TrackFromJson(contents)
+	if unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	// the flight id is recorded in the artifact's name
+	track.FlightId, _ = aeroapi.ParseTrackArtifactFilename(tca.FromArtifacts)
+	return track, nil
 }
 
 func (tca TracksCommandArgs) getArtifactsDir() string {
@@ -305,24 +659,147 @@ func (tca TracksCommandArgs) getArtifactsDir() string {
 	return tca.Config.ArtifactsDir
 }
 
+// getArtifactsIndex returns the index of the artifacts saved into the artifacts directory,
+// or nil if they're saved into object storage (which isn't indexed)
+func (tca TracksCommandArgs) getArtifactsIndex() *artifacts.Index {
+	if persistence2.IsS3Url(tca.getArtifactsDir()) {
+		return nil
+	}
+	return artifacts.NewIndex(tca.getArtifactsDir())
+}
+
+// newArtifactSaver returns the saver of artifacts into the artifacts directory (or S3 prefix),
+// compressing them using the format
+func (tca TracksCommandArgs) newArtifactSaver(compression string) *persistence2.FileSaver {
+	saver := &persistence2.FileSaver{Compression: compression}
+	if persistence2.IsS3Url(tca.getArtifactsDir()) {
+		store := tca.Config.NewArtifactsS3Store()
+		saver.Writer = store.Write
+		saver.Exister = store.Exists
+	} else {
+		saver.Indexer = tca.getArtifactsIndex()
+	}
+	return saver
+}
+
+// newKmzSaver returns the saver of KML visualizations, applying their overwrite policy
+func (tca TracksCommandArgs) newKmzSaver() *persistence2.FileSaver {
+	saver := tca.newArtifactSaver(persistence2.CompressionNone)
+	saver.Overwrite = tca.Overwrite
+	return saver
+}
+
+// newArtifactLoader returns the loader of artifacts, which may be files or objects located by "s3://" URLs
+func (tca TracksCommandArgs) newArtifactLoader() persistence2.FileLoader {
+	store := tca.Config.NewArtifactsS3Store()
+	return persistence2.FileLoader{
+		Reader: func(ref string) ([]byte, error) {
+			if persistence2.IsS3Url(ref) {
+				return store.Read(ref)
+			}
+			return os.ReadFile(ref)
+		},
+	}
+}
+
+// newElevationProvider returns the source of terrain elevation data, or nil if none is configured
+func (tca TracksCommandArgs) newElevationProvider() terrain.ElevationProvider {
+	demDir := tca.DemDir
+	if demDir == "" {
+		demDir = tca.Config.DemDir
+	}
+	if demDir == "" {
+		return nil
+	}
+	return &terrain.HgtDirectory{Dir: demDir, Verbose: tca.IsVerbose()}
+}
+
+// loadAirports returns the database of airports used to label departures and arrivals,
+// or nil if none is configured
+func (tca TracksCommandArgs) loadAirports() (*airports.Database, error) {
+	airportsDir := tca.AirportsDir
+	if airportsDir == "" {
+		airportsDir = tca.Config.AirportsDir
+	}
+	if airportsDir == "" {
+		return nil, nil
+	}
+	db, loadErr := airports.LoadOurAirportsDir(airportsDir)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if tca.IsVerbose() {
+		log.Printf("INFO: loaded %d airport(s) from(%s)\n", db.Len(), airportsDir)
+	}
+	return db, nil
+}
+
 func (tca TracksCommandArgs) IsVerbose() bool {
 	return tca.VerboseOperation || tca.Config.Verbose
 }
 
-const fnPrefixTimestampFormat = "20060102150405Z"
+// newAccounting returns the transport which accounts for the cost of AeroAPI requests,
+// limiting it to the configured budget(s)
+func (tca TracksCommandArgs) newAccounting() (*aeroapi.AccountingTransport, error) {
+	prices, parsePricesErr := aeroapi.ParsePriceTable(tca.Config.AeroApiPrices)
+	if parsePricesErr != nil {
+		return nil, fmt.Errorf("invalid AeroAPI prices: %w", parsePricesErr)
+	}
+	accounting := &aeroapi.AccountingTransport{
+		Prices:        prices,
+		MaxCost:       tca.MaxCost,
+		MonthlyBudget: tca.Config.AeroApiMonthlyBudget,
+		DryRun:        tca.DryRun,
+	}
+	if ledger, getLedgerErr := tca.getLedger(); getLedgerErr != nil {
+		log.Printf("NOTE: AeroAPI usage won't be recorded: %v\n", getLedgerErr)
+	} else {
+		months, loadLedgerErr := ledger.Load()
+		if loadLedgerErr != nil {
+			return nil, loadLedgerErr
+		}
+		accounting.MonthToDate = months[aeroapi.GetLedgerMonth(time.Now())]
+	}
+	return accounting, nil
+}
+
+// reportUsage summarizes the AeroAPI requests made and records them in the ledger
+func (tca TracksCommandArgs) reportUsage() {
+	usage := tca.accounting.Usage()
+	if tca.DryRun {
+		log.Printf("DRY RUN: planned AeroAPI usage: %s\n", usage)
+		return
+	}
+	log.Printf("INFO: AeroAPI usage: %s\n", usage)
+	if len(usage.Requests) == 0 {
+		return
+	}
+	ledger, getLedgerErr := tca.getLedger()
+	if getLedgerErr != nil {
+		return
+	}
+	monthUsage, recordErr := ledger.Record(aeroapi.GetLedgerMonth(time.Now()), usage)
+	if recordErr != nil {
+		log.Printf("WARNING: couldn't record AeroAPI usage in ledger(%s): %v\n", ledger.Filename, recordErr)
+		return
+	}
+	log.Printf("INFO: AeroAPI usage this month: %s\n", monthUsage)
+}
 
-// GetTsFromTo returns a string representation of a time range using fnPrefixTimestampFormat
-// to format the "from" time, and a subsequence of that for the "to" time, with leading common
-// prefix removed.  Example:
-//
-// { 2023010203040506Z, 2023010203050506Z } => "23010203040506Z-50506Z" ('5' differs with '4' in tsBase)
-func getTsFromTo(from, to time.Time) string {
-	fromFmt := from.Format(fnPrefixTimestampFormat)[2:]
-	toFmt := to.Format(fnPrefixTimestampFormat)[2:]
+// saveRecording saves the AeroAPI requests recorded, along with their responses, to the session file
+func (tca TracksCommandArgs) saveRecording(recorder *aeroapi.Recorder) {
+	nInteractions, saveErr := recorder.Save(tca.RecordFile)
+	if saveErr != nil {
+		log.Printf("WARNING: couldn't save recorded session(%s): %v\n", tca.RecordFile, saveErr)
+		return
+	}
+	log.Printf("INFO: recorded %d AeroAPI request(s) to session(%s)\n", nInteractions, tca.RecordFile)
+}
 
-	i := 0
-	for i < len(fromFmt) && i < len(toFmt) && fromFmt[i] == toFmt[i] {
-		i++
+func (tca TracksCommandArgs) getLedger() (*aeroapi.Ledger, error) {
+	ledgerFilename, getLedgerFilenameErr := tca.Config.GetLedgerFilename()
+	if getLedgerFilenameErr != nil {
+		return nil, getLedgerFilenameErr
 	}
-	return fmt.Sprintf("%s-%s", fromFmt, toFmt[i:])
+	return &aeroapi.Ledger{Filename: ledgerFilename}, nil
 }
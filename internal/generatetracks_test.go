@@ -1,12 +1,22 @@
 package internal
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
 	"reflect"
 	"runtime"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml"
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi/aeroapitest"
 )
 
 func TestTracksCommandArgs_GenerateTracks(t *testing.T) {
@@ -22,22 +32,22 @@ func TestTracksCommandArgs_GenerateTracks(t *testing.T) {
 		},
 		{
 			name:             "single layer",
-			layers:           []string{TracksLayerCamera},
-			expectedEnsemble: []string{TracksLayerCamera},
+			layers:           []string{"camera"},
+			expectedEnsemble: []string{"camera"},
 		},
 		{
 			name:             "all layers, random order",
-			layers:           []string{TracksLayerPath, TracksLayerVector, TracksLayerPlacemark, TracksLayerCamera},
-			expectedEnsemble: []string{TracksLayerCamera, TracksLayerPath, TracksLayerPlacemark, TracksLayerVector},
+			layers:           []string{"path", "wind", "vector", "terrain", "placemark", "camera"},
+			expectedEnsemble: []string{"camera", "path", "placemark", "terrain", "vector", "wind"},
 		},
 		{
 			name:             "all layers - with duplicates",
-			layers:           []string{TracksLayerPlacemark, TracksLayerCamera, TracksLayerPath, TracksLayerPlacemark, TracksLayerPath, TracksLayerVector},
-			expectedEnsemble: []string{TracksLayerCamera, TracksLayerPath, TracksLayerPlacemark, TracksLayerVector},
+			layers:           []string{"placemark", "camera", "path", "placemark", "path", "vector"},
+			expectedEnsemble: []string{"camera", "path", "placemark", "vector"},
 		},
 		{
 			name:           "unrecognized layer",
-			layers:         []string{TracksLayerPlacemark, TracksLayerCamera, "unrecognized", TracksLayerPlacemark, TracksLayerPath, TracksLayerVector},
+			layers:         []string{"placemark", "camera", "unrecognized", "placemark", "path", "vector"},
 			expectedErrors: []string{"unrecognized kmlLayer(unrecognized)"},
 		},
 	}
@@ -83,6 +93,11 @@ func TestTracksCommandArgs_NewTrackFactory(t *testing.T) {
 			artifactsFilename: "fvt_file.json",
 			expectedFnName:    "singleTrackArtifactFactory",
 		},
+		{
+			name:              "GPX file",
+			artifactsFilename: "flights/lanai.GPX",
+			expectedFnName:    "gpxFileFactory",
+		},
 		{
 			name:              "unrecognized artifact",
 			artifactsFilename: "unknown_artifact.json",
@@ -128,9 +143,334 @@ func TestTracksCommandArgs_SaveKmlTracks(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			requirer := require.New(t)
 			tca := TracksCommandArgs{}
-			tracks, err := tca.saveKmlTracks(nil, "")
+			tracks, err := tca.saveKmlTracks(nil, &kml.TrackBuilderEnsemble{})
 			requirer.NoError(err)
 			requirer.Empty(tracks)
 		})
 	}
 }
+
+func TestTracksCommandArgs_Simplify(t *testing.T) {
+
+	testCases := []struct {
+		name               string
+		simplify           string
+		expectedSimplified []bool
+		expectedErrors     []string
+	}{
+		{
+			name:               "no simplification",
+			expectedSimplified: []bool{false, false, false},
+		},
+		{
+			name:               "all layers",
+			simplify:           "100",
+			expectedSimplified: []bool{true, true, true},
+		},
+		{
+			name:               "selected layers",
+			simplify:           "vector=100, camera=50.5",
+			expectedSimplified: []bool{true, false, true},
+		},
+		{
+			name:               "zero tolerance for selected layer",
+			simplify:           "100,path=0",
+			expectedSimplified: []bool{true, false, true},
+		},
+		{
+			name:           "unrecognized layer",
+			simplify:       "vector=100,nolayer=50",
+			expectedErrors: []string{"unrecognized simplify layer(nolayer)"},
+		},
+		{
+			name:           "invalid tolerance",
+			simplify:       "vector=lots",
+			expectedErrors: []string{"invalid simplify tolerance(vector=lots)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			tca := TracksCommandArgs{Simplify: tc.simplify}
+			generator, err := tca.newKmlTrackGenerator([]string{"camera", "path", "vector"})
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedErr := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedErr)
+				}
+				return
+			}
+			requirer.NoError(err)
+			requirer.Equal(len(tc.expectedSimplified), len(generator.Builders))
+			for i, expectedSimplified := range tc.expectedSimplified {
+				_, isSimplified := generator.Builders[i].(*builders.SimplifiedBuilder)
+				requirer.Equal(expectedSimplified, isSimplified, "builder %d", i)
+			}
+		})
+	}
+}
+
+func TestTracksCommandArgs_GenerateTracks_Remote(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		tailNumber       string
+		timeRange        aeroapi.TimeRange
+		options          aeroapitest.Options
+		expectedRequests []string
+		expectedKmzFiles int
+		expectedErrors   []string
+	}{
+		{
+			name:       "tail number",
+			tailNumber: "N335SP",
+			options:    aeroapitest.Options{ApiKey: "test-key"},
+			expectedRequests: []string{
+				"/flights/N335SP",
+				"/flights/N335SP-1684874159-adhoc-1864p/track",
+			},
+			expectedKmzFiles: 1,
+		},
+		{
+			name:       "time range in a time zone ahead of UTC",
+			tailNumber: "N335SP",
+			timeRange:  aeroapi.TimeRange{Start: time.Date(2023, 5, 23, 0, 0, 0, 0, time.FixedZone("CEST", 2*60*60))},
+			options:    aeroapitest.Options{ApiKey: "test-key"},
+			expectedRequests: []string{
+				"/flights/N335SP?&start=2023-05-22T22:00:00Z",
+				"/flights/N335SP-1684874159-adhoc-1864p/track",
+			},
+			expectedKmzFiles: 1,
+		},
+		{
+			name:             "rejected API key",
+			tailNumber:       "N335SP",
+			options:          aeroapitest.Options{ApiKey: "other-key"},
+			expectedRequests: []string{"/flights/N335SP"},
+			expectedErrors:   []string{"statusCode(401)"},
+		},
+		{
+			name:             "rate limited, then retried",
+			tailNumber:       "N335SP",
+			options:          aeroapitest.Options{ApiKey: "test-key", RateLimit: 1, RateLimitPeriod: 100 * time.Millisecond},
+			expectedRequests: []string{"/flights/N335SP", "/flights/N335SP-1684874159-adhoc-1864p/track", "/flights/N335SP-1684874159-adhoc-1864p/track"},
+			expectedKmzFiles: 1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			options := tc.options
+			options.ArtifactsDir = filepath.Join("..", "artifacts")
+			server := aeroapitest.NewServer(options)
+			defer server.Close()
+
+			outputDir := t.TempDir()
+			tca := TracksCommandArgs{
+				Config: Config{
+					AeroApiUrl:         server.URL,
+					AeroApiKey:         "test-key",
+					AeroApiMaxAttempts: 2,
+					ArtifactsDir:       outputDir,
+					CacheDir:           filepath.Join(outputDir, "cache"),
+					LedgerFile:         filepath.Join(outputDir, "ledger.json"),
+				},
+				TailNumber: tc.tailNumber,
+				TimeRange:  tc.timeRange,
+				KmlLayers:  "path",
+				NoCache:    true,
+			}
+			generateErr := tca.GenerateTracks(context.Background())
+			requirer.Equal(tc.expectedRequests, server.Requests())
+			if tc.expectedErrors != nil {
+				requirer.Error(generateErr)
+				for _, expectedErr := range tc.expectedErrors {
+					requirer.Contains(generateErr.Error(), expectedErr)
+				}
+				return
+			}
+			requirer.NoError(generateErr)
+			kmzFiles, globErr := filepath.Glob(filepath.Join(outputDir, "fvk_*.kmz"))
+			requirer.NoError(globErr)
+			requirer.Len(kmzFiles, tc.expectedKmzFiles)
+		})
+	}
+}
+
+func TestTracksCommandArgs_GenerateTracks_Batch(t *testing.T) {
+	requirer := require.New(t)
+
+	// a batch of the saved track artifacts
+	artifactsDir := t.TempDir()
+	trackFilenames, globErr := filepath.Glob(filepath.Join("..", "artifacts", "fvt_*"))
+	requirer.NoError(globErr)
+	requirer.NotEmpty(trackFilenames)
+	for _, trackFilename := range trackFilenames {
+		contents, readErr := os.ReadFile(trackFilename)
+		requirer.NoError(readErr)
+		requirer.NoError(os.WriteFile(filepath.Join(artifactsDir, filepath.Base(trackFilename)), contents, 0644))
+	}
+
+	outputDir := t.TempDir()
+	tca := TracksCommandArgs{
+		Config:        Config{ArtifactsDir: outputDir},
+		FromArtifacts: artifactsDir,
+		KmlLayers:     "path",
+	}
+	// getKmzModTimes returns the modification times of the visualizations rendered, after backdating them
+	getKmzModTimes := func() map[string]time.Time {
+		kmzFilenames, kmzGlobErr := filepath.Glob(filepath.Join(outputDir, "fvk_*.kmz"))
+		requirer.NoError(kmzGlobErr)
+		modTimes := make(map[string]time.Time)
+		for _, kmzFilename := range kmzFilenames {
+			fileInfo, statErr := os.Stat(kmzFilename)
+			requirer.NoError(statErr)
+			modTimes[filepath.Base(kmzFilename)] = fileInfo.ModTime()
+			backdated := time.Now().Add(-time.Hour)
+			requirer.NoError(os.Chtimes(kmzFilename, backdated, backdated))
+		}
+		return modTimes
+	}
+
+	requirer.NoError(tca.GenerateTracks(context.Background()))
+	rendered := getKmzModTimes()
+	requirer.Len(rendered, len(trackFilenames))
+	for kmzName := range rendered {
+		// visualizations are named for the ident (e.g., tail number) of the track, or its flight id if ambiguous
+		requirer.Regexp(`^fvk_[0-9A-Z]+(-[0-9a-z-]+)?_[0-9]+Z-[0-9]+Z_path\.kmz$`, kmzName)
+	}
+
+	// visualizations newer than their tracks are up to date
+	for _, trackFilename := range trackFilenames {
+		backdated := time.Now().Add(-2 * time.Hour)
+		requirer.NoError(os.Chtimes(filepath.Join(artifactsDir, filepath.Base(trackFilename)), backdated, backdated))
+	}
+	requirer.NoError(tca.GenerateTracks(context.Background()))
+	unchanged := getKmzModTimes()
+	for kmzName, modTime := range unchanged {
+		requirer.True(modTime.Before(time.Now().Add(-time.Minute)), kmzName)
+	}
+
+	// only the visualizations of the tracks (and pattern) selected are re-rendered, when forced
+	tca.Force = true
+	tca.FromArtifacts = filepath.Join(artifactsDir, "fvt_N335SP-*")
+	requirer.NoError(tca.GenerateTracks(context.Background()))
+	var nRerendered int
+	for kmzName, modTime := range getKmzModTimes() {
+		if modTime.After(time.Now().Add(-time.Minute)) {
+			requirer.Contains(kmzName, "N335SP")
+			nRerendered++
+		}
+	}
+	requirer.Positive(nRerendered)
+	requirer.Len(unchanged, len(trackFilenames))
+
+	// up to date artifacts aren't even loaded
+	tca.Force = false
+	tca.FromArtifacts = artifactsDir
+	corruptFilename := filepath.Join(artifactsDir, filepath.Base(trackFilenames[0]))
+	requirer.NoError(os.WriteFile(corruptFilename, []byte(`{"positions": [`), 0644))
+	backdated := time.Now().Add(-2 * time.Hour)
+	requirer.NoError(os.Chtimes(corruptFilename, backdated, backdated))
+	requirer.NoError(tca.GenerateTracks(context.Background()))
+	for kmzName, modTime := range getKmzModTimes() {
+		requirer.True(modTime.Before(time.Now().Add(-time.Minute)), kmzName)
+	}
+
+	// visualizations made with other settings (though named the same) aren't up to date, while
+	// an artifact which can't be loaded fails the batch only once the others have been rendered
+	tca.KmlLayers = "path(width=9)"
+	requirer.EqualError(tca.GenerateTracks(context.Background()), fmt.Sprintf("1 of %d track artifact(s) couldn't be rendered", len(trackFilenames)))
+	var nRestyled int
+	for _, modTime := range getKmzModTimes() {
+		if modTime.After(time.Now().Add(-time.Minute)) {
+			nRestyled++
+		}
+	}
+	requirer.Equal(len(trackFilenames)-1, nRestyled)
+
+	tca.FromArtifacts = filepath.Join(artifactsDir, "fvt_NOSUCH-*")
+	requirer.EqualError(tca.GenerateTracks(context.Background()), "no track artifacts found in("+tca.FromArtifacts+")")
+}
+
+func TestGpxFileFactory_TimeRange(t *testing.T) {
+	const lanaiGpx = `<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>N335SP Lanai</name>
+    <trkseg>
+      <trkpt lat="20.9" lon="-156.4"><ele>30.48</ele><time>2023-05-23T20:00:00Z</time></trkpt>
+      <trkpt lat="20.8" lon="-156.6"><ele>304.8</ele><time>2023-05-23T20:30:00Z</time></trkpt>
+      <trkpt lat="20.9" lon="-156.4"><ele>30.48</ele><time>2023-05-23T23:00:00Z</time></trkpt>
+      <trkpt lat="21.0" lon="-156.2"><ele>304.8</ele><time>2023-05-23T23:30:00Z</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+	at := func(hour, minute int) time.Time {
+		return time.Date(2023, 5, 23, hour, minute, 0, 0, time.UTC)
+	}
+
+	testCases := []struct {
+		name           string
+		timeRange      aeroapi.TimeRange
+		expectedTimes  []time.Time
+		expectedErrors []string
+	}{
+		{
+			name:          "no range",
+			expectedTimes: []time.Time{at(20, 0), at(20, 30), at(23, 0), at(23, 30)},
+		},
+		{
+			name:          "second flight",
+			timeRange:     aeroapi.TimeRange{Start: at(22, 0)},
+			expectedTimes: []time.Time{at(23, 0), at(23, 30)},
+		},
+		{
+			name:          "first flight",
+			timeRange:     aeroapi.TimeRange{Start: at(19, 0), End: at(21, 0)},
+			expectedTimes: []time.Time{at(20, 0), at(20, 30)},
+		},
+		{
+			name:           "outside range",
+			timeRange:      aeroapi.TimeRange{End: at(19, 0)},
+			expectedErrors: []string{"none of the 4 position(s) of GPX file(", "are within time range(*..2023-05-23T19:00:00Z)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			filename := filepath.Join(t.TempDir(), "lanai.gpx")
+			requirer.NoError(os.WriteFile(filename, []byte(lanaiGpx), 0644))
+			tca := TracksCommandArgs{FromArtifacts: filename, TimeRange: tc.timeRange}
+			tracker := &recordingTracker{}
+			kmlTracks, generateErr := gpxFileFactory(tca)(context.Background(), tracker)
+			if tc.expectedErrors != nil {
+				requirer.Error(generateErr)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(generateErr.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(generateErr)
+			requirer.Len(kmlTracks, 1)
+			requirer.Equal("lanai", kmlTracks[0].Label)
+			var times []time.Time
+			for _, position := range tracker.track.Positions {
+				times = append(times, position.Timestamp)
+			}
+			requirer.Equal(tc.expectedTimes, times)
+		})
+	}
+}
+
+// recordingTracker records the track from which it's asked to generate a KML visualization
+type recordingTracker struct {
+	track *aeroapi.Track
+}
+
+func (rt *recordingTracker) Generate(_ context.Context, track *aeroapi.Track) (*kml.Track, error) {
+	rt.track = track
+	return &kml.Track{FlightId: track.FlightId}, nil
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+)
+
+// Job declares a visualization to be made; it's equivalent to an invocation of "fviz tracks"
+// with the options corresponding to its settings.  Job files hold one or more jobs, each in its
+// own YAML document (i.e., separated by "---" lines).
+type Job struct {
+	Name       string        `yaml:"name"`       // identifies the job (default "job N", its position in the file)
+	Source     JobSource     `yaml:"source"`     // where the flight(s) are found
+	Filters    JobFilters    `yaml:"filters"`    // which flight(s) are visualized
+	Transforms JobTransforms `yaml:"transforms"` // how the track(s) are prepared
+	Layers     JobLayers     `yaml:"layers"`     // what the visualizations depict (default camera, path and vector)
+	Outputs    JobOutputs    `yaml:"outputs"`    // where (and how) the results are saved
+}
+
+// JobSource declares where the flight(s) of a Job are found; only one of the tail number,
+// flight id, ident, artifacts or GPX file may be given
+type JobSource struct {
+	TailNumber  string        `yaml:"tailNumber"`
+	FlightId    string        `yaml:"flightId"`
+	Ident       string        `yaml:"ident"`
+	Date        string        `yaml:"date"`      // of departure of the ident flight (e.g., "2023-05-23")
+	Artifacts   string        `yaml:"artifacts"` // an artifact, a bundle, or a directory or pattern of tracks
+	Gpx         string        `yaml:"gpx"`
+	Replay      string        `yaml:"replay"` // session file whose recorded AeroAPI responses are used
+	NoCache     bool          `yaml:"noCache"`
+	MaxCost     float64       `yaml:"maxCost"`
+	Concurrency int           `yaml:"concurrency"`
+	Timeout     time.Duration `yaml:"timeout"`
+}
+
+// JobFilters declare which of the flights found are visualized
+type JobFilters struct {
+	Start        string        `yaml:"start"` // e.g., "-2d" or "2023-05-18T08:00:00Z"
+	End          string        `yaml:"end"`
+	TimeZone     string        `yaml:"timeZone"`
+	Origin       []string      `yaml:"origin"`
+	Destination  []string      `yaml:"destination"`
+	AircraftType []string      `yaml:"aircraftType"`
+	MinDuration  time.Duration `yaml:"minDuration"`
+	MaxDuration  time.Duration `yaml:"maxDuration"`
+	MinDistance  int           `yaml:"minDistance"`
+	Exclude      []string      `yaml:"exclude"` // e.g., "cancelled"
+	FlightCount  int           `yaml:"flightCount"`
+}
+
+// JobTransforms declare how the tracks are prepared for depiction
+type JobTransforms struct {
+	Simplify    float64 `yaml:"simplify"` // tolerance (meters) for all layers
+	NoBanking   bool    `yaml:"noBanking"`
+	DemDir      string  `yaml:"demDir"`
+	AirportsDir string  `yaml:"airportsDir"`
+}
+
+// JobLayers maps the names of the layers of the visualizations to their options
+type JobLayers map[string]JobLayer
+
+// JobLayer declares the options of a layer of the visualizations
+type JobLayer struct {
+	Simplify float64           `yaml:"simplify"` // tolerance (meters), overriding that of the transforms
+	Options  map[string]string `yaml:",inline"`  // of the layer's builder (e.g., "width" of the path layer)
+}
+
+// UnmarshalYAML decodes the layers, rejecting (at their lines) unrecognized layers and options
+func (jl *JobLayers) UnmarshalYAML(node *yaml.Node) error {
+	var layers map[string]JobLayer
+	if decodeErr := node.Decode(&layers); decodeErr != nil {
+		return decodeErr
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		layer := node.Content[i].Value
+		if !isSupportedLayer(layer) {
+			return fmt.Errorf("line %d: unrecognized layer(%s); expected one of {%s}", node.Content[i].Line, layer,
+				strings.Join(builders.LayerNames(), ", "))
+		}
+		if validateErr := validateLayerOptions(layer, layers[layer].Options); validateErr != nil {
+			return fmt.Errorf("line %d: %w", node.Content[i].Line, validateErr)
+		}
+	}
+	*jl = layers
+	return nil
+}
+
+// JobOutputs declare where (and how) the results of a Job are saved
+type JobOutputs struct {
+	ArtifactsDir  string `yaml:"artifactsDir"`
+	SaveArtifacts bool   `yaml:"saveArtifacts"`
+	Envelope      bool   `yaml:"envelope"`
+	Compress      string `yaml:"compress"`
+	Overwrite     string `yaml:"overwrite"`
+	Force         bool   `yaml:"force"`
+	Launch        bool   `yaml:"launch"`
+	Record        string `yaml:"record"`
+	MaxFeatures   int    `yaml:"maxFeatures"`
+	MaxDocSize    int    `yaml:"maxDocSize"`
+}
+
+// ParseJobs returns the jobs declared in the contents of a job file, which must conform to the
+// schema of Job; unrecognized settings, as well as contradictory ones, are rejected
+func ParseJobs(contents []byte) ([]Job, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+
+	var jobs []Job
+	names := make(map[string]bool)
+	for {
+		var job Job
+		if decodeErr := decoder.Decode(&job); errors.Is(decodeErr, io.EOF) {
+			break
+		} else if decodeErr != nil {
+			return nil, fmt.Errorf("invalid job(%d): %w", len(jobs)+1, decodeErr)
+		}
+		if job.Name == "" {
+			job.Name = fmt.Sprintf("job %d", len(jobs)+1)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("duplicate job(%s)", job.Name)
+		}
+		names[job.Name] = true
+		if validateErr := job.Validate(); validateErr != nil {
+			return nil, fmt.Errorf("invalid job(%s): %w", job.Name, validateErr)
+		}
+		jobs = append(jobs, job)
+	}
+	if len(jobs) == 0 {
+		return nil, errors.New("no jobs found")
+	}
+	return jobs, nil
+}
+
+// Validate returns an error if the settings of the job are incomplete or contradictory
+func (j Job) Validate() error {
+	var sources []string
+	for name, value := range map[string]string{
+		"tailNumber": j.Source.TailNumber,
+		"flightId":   j.Source.FlightId,
+		"ident":      j.Source.Ident,
+		"artifacts":  j.Source.Artifacts,
+		"gpx":        j.Source.Gpx,
+	} {
+		if value != "" {
+			sources = append(sources, name)
+		}
+	}
+	if len(sources) != 1 {
+		return fmt.Errorf("expected one source of {tailNumber, flightId, ident, artifacts, gpx}; found %d", len(sources))
+	}
+	if j.Source.Date != "" && j.Source.Ident == "" {
+		return errors.New("source date applies only to an ident")
+	}
+	for layer, options := range j.Layers {
+		if !isSupportedLayer(layer) {
+			return fmt.Errorf("unrecognized layer(%s); expected one of {%s}", layer, strings.Join(builders.LayerNames(), ", "))
+		}
+		if options.Simplify < 0 {
+			return fmt.Errorf("invalid simplify tolerance(%v) of layer(%s)", options.Simplify, layer)
+		}
+		if validateErr := validateLayerOptions(layer, options.Options); validateErr != nil {
+			return validateErr
+		}
+	}
+	if j.Transforms.Simplify < 0 {
+		return fmt.Errorf("invalid simplify tolerance(%v)", j.Transforms.Simplify)
+	}
+	return nil
+}
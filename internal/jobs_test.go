@@ -0,0 +1,140 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testJobs = `
+name: daily
+source:
+  tailNumber: N335SP
+  timeout: 2m
+filters:
+  start: -1d
+  origin: [PHOG, PHNY]
+  minDuration: 30m
+transforms:
+  simplify: 100
+layers:
+  path: {simplify: 50, color: "#00ff00", width: 5}
+  terrain: {minAgl: 300}
+  vector:
+outputs:
+  saveArtifacts: true
+  compress: zstd
+---
+source:
+  gpx: flights/lanai.gpx
+`
+
+func TestParseJobs(t *testing.T) {
+	testCases := []struct {
+		name           string
+		contents       string
+		expectedNames  []string
+		expectedErrors []string
+	}{
+		{
+			name:          "valid",
+			contents:      testJobs,
+			expectedNames: []string{"daily", "job 2"},
+		},
+		{
+			name:           "empty",
+			contents:       "# no jobs\n",
+			expectedErrors: []string{"no jobs found"},
+		},
+		{
+			name:           "unrecognized setting",
+			contents:       "source: {tailNumber: N335SP}\nfilters: {origen: PHOG}\n",
+			expectedErrors: []string{"invalid job(1)", "line 2: field origen not found"},
+		},
+		{
+			name:           "unrecognized layer option",
+			contents:       "source: {tailNumber: N335SP}\nlayers:\n  path: {colour: red}\n",
+			expectedErrors: []string{"invalid job(1)", "line 3: layer(path): unrecognized option(colour); expected one of {color, width, extrude}"},
+		},
+		{
+			name:           "invalid layer option",
+			contents:       "source: {tailNumber: N335SP}\nlayers:\n  camera: {tilt: 120}\n",
+			expectedErrors: []string{"line 3: layer(camera): invalid tilt(120); expected a number from 0 to 90"},
+		},
+		{
+			name:           "invalid duration",
+			contents:       "source: {tailNumber: N335SP}\nfilters: {minDuration: forever}\n",
+			expectedErrors: []string{"invalid job(1)", "line 2"},
+		},
+		{
+			name:           "no source",
+			contents:       "name: nothing\nlayers: {path: }\n",
+			expectedErrors: []string{"invalid job(nothing): expected one source of {tailNumber, flightId, ident, artifacts, gpx}; found 0"},
+		},
+		{
+			name:           "two sources",
+			contents:       "source: {tailNumber: N335SP, gpx: lanai.gpx}\n",
+			expectedErrors: []string{"invalid job(job 1): expected one source", "found 2"},
+		},
+		{
+			name:           "date without ident",
+			contents:       "source: {tailNumber: N335SP, date: 2023-05-23}\n",
+			expectedErrors: []string{"source date applies only to an ident"},
+		},
+		{
+			name:           "unrecognized layer",
+			contents:       "source: {tailNumber: N335SP}\nlayers: {contrail: }\n",
+			expectedErrors: []string{"line 2: unrecognized layer(contrail)"},
+		},
+		{
+			name:           "layer option of another layer",
+			contents:       "source: {tailNumber: N335SP}\nlayers: {path: {minAgl: 300}}\n",
+			expectedErrors: []string{"layer(path): unrecognized option(minAgl)"},
+		},
+		{
+			name:           "duplicate names",
+			contents:       "name: a\nsource: {tailNumber: N335SP}\n---\nname: a\nsource: {tailNumber: N202VG}\n",
+			expectedErrors: []string{"duplicate job(a)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			jobs, err := ParseJobs([]byte(tc.contents))
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(err)
+			var names []string
+			for _, job := range jobs {
+				names = append(names, job.Name)
+			}
+			requirer.Equal(tc.expectedNames, names)
+		})
+	}
+}
+
+func TestParseJobs_Settings(t *testing.T) {
+	requirer := require.New(t)
+	jobs, err := ParseJobs([]byte(testJobs))
+	requirer.NoError(err)
+
+	daily := jobs[0]
+	requirer.Equal(2*time.Minute, daily.Source.Timeout)
+	requirer.Equal([]string{"PHOG", "PHNY"}, daily.Filters.Origin)
+	requirer.Equal(30*time.Minute, daily.Filters.MinDuration)
+	requirer.Equal(100.0, daily.Transforms.Simplify)
+	requirer.Len(daily.Layers, 3)
+	requirer.Equal(50.0, daily.Layers["path"].Simplify)
+	requirer.Equal(map[string]string{"color": "#00ff00", "width": "5"}, daily.Layers["path"].Options)
+	requirer.Equal(map[string]string{"minAgl": "300"}, daily.Layers["terrain"].Options)
+	requirer.Equal(JobLayer{}, daily.Layers["vector"])
+	requirer.Equal(JobOutputs{SaveArtifacts: true, Compress: "zstd"}, daily.Outputs)
+	requirer.Equal("flights/lanai.gpx", jobs[1].Source.Gpx)
+}
@@ -0,0 +1,68 @@
+package kml
+
+import (
+	"fmt"
+
+	gokml "github.com/twpayne/go-kml/v3"
+
+	"github.com/noodnik2/flightvisualizer/pkg/airports"
+)
+
+const airportIconHref = "https://maps.google.com/mapfiles/kml/shapes/airports.png"
+
+// newAirportsFolder returns a folder of placemarks labeling the departure and
+// arrival airports, or nil if neither was identified
+func newAirportsFolder(origin, destination airports.Endpoint) gokml.Element {
+	if origin.Airport == nil && destination.Airport == nil {
+		return nil
+	}
+
+	folder := gokml.Folder(
+		gokml.Name("Airports"),
+		gokml.Description("Departure and arrival airports"),
+		gokml.Style(
+			gokml.IconStyle(gokml.Icon(gokml.Href(airportIconHref))),
+		).WithID("AirportStyle"),
+	)
+	for _, endpoint := range []struct {
+		role string
+		airports.Endpoint
+	}{
+		{role: "Departure", Endpoint: origin},
+		{role: "Arrival", Endpoint: destination},
+	} {
+		if endpoint.Airport == nil {
+			continue
+		}
+		folder.Append(newAirportPlacemark(endpoint.role, endpoint.Endpoint))
+	}
+	return folder
+}
+
+func newAirportPlacemark(role string, endpoint airports.Endpoint) gokml.Element {
+	airport := endpoint.Airport
+	runway := "not identified"
+	if endpoint.Runway != "" {
+		runway = endpoint.Runway
+	}
+	return gokml.Placemark(
+		gokml.Name(airport.Ident),
+		gokml.Description(fmt.Sprintf(`<h1>%s</h1>
+		<ul>
+			<li>Airport: %s</li>
+			<li>Location: %s</li>
+			<li>Elevation: %.0f'</li>
+			<li>Runway: %s</li>
+		</ul>`,
+			role,
+			airport.Name,
+			airport.Municipality,
+			airport.ElevationFt,
+			runway,
+		)),
+		gokml.StyleURL("#AirportStyle"),
+		gokml.Point(
+			gokml.Coordinates(gokml.Coordinate{Lon: airport.Longitude, Lat: airport.Latitude}),
+		),
+	)
+}
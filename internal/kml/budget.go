@@ -0,0 +1,77 @@
+package kml
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	gokml "github.com/twpayne/go-kml/v3"
+)
+
+// documentBudget tracks the features and bytes spent so far by the layers of a KML document
+type documentBudget struct {
+	features int
+	bytes    int
+}
+
+// kmlFeatureElements are the names of the KML elements which are "features"
+// See https://developers.google.com/kml/documentation/kmlreference#feature
+var kmlFeatureElements = map[string]bool{
+	"Document":      true,
+	"Folder":        true,
+	"Placemark":     true,
+	"NetworkLink":   true,
+	"GroundOverlay": true,
+	"ScreenOverlay": true,
+	"PhotoOverlay":  true,
+	"Tour":          true,
+}
+
+// spend adds the cost of the element to the budget, unless doing so would exceed
+// "maxFeatures" features or "maxBytes" bytes (0=unlimited), in which case an error
+// is returned and the budget is left unchanged
+func (db *documentBudget) spend(element gokml.Element, maxFeatures, maxBytes int) error {
+	if maxFeatures <= 0 && maxBytes <= 0 {
+		return nil
+	}
+
+	var encoded bytes.Buffer
+	encoder := xml.NewEncoder(&encoded)
+	if encodeErr := encoder.Encode(element); encodeErr != nil {
+		return encodeErr
+	}
+	features, countErr := countFeatures(encoded.Bytes())
+	if countErr != nil {
+		return countErr
+	}
+
+	if maxFeatures > 0 && db.features+features > maxFeatures {
+		return fmt.Errorf("its %d feature(s) would exceed the budget of %d (%d already used)",
+			features, maxFeatures, db.features)
+	}
+	if maxBytes > 0 && db.bytes+encoded.Len() > maxBytes {
+		return fmt.Errorf("its %d byte(s) would exceed the budget of %d (%d already used)",
+			encoded.Len(), maxBytes, db.bytes)
+	}
+	db.features += features
+	db.bytes += encoded.Len()
+	return nil
+}
+
+func countFeatures(encoded []byte) (int, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(encoded))
+	var features int
+	for {
+		token, tokenErr := decoder.Token()
+		if tokenErr == io.EOF {
+			return features, nil
+		}
+		if tokenErr != nil {
+			return 0, tokenErr
+		}
+		if startElement, ok := token.(xml.StartElement); ok && kmlFeatureElements[startElement.Name.Local] {
+			features++
+		}
+	}
+}
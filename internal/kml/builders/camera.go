@@ -8,15 +8,60 @@ import (
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
 )
 
+// Default values of the options of CameraBuilder
+const (
+	DefaultCameraTilt         = 80 // degrees from vertical (i.e., looking nearly straight ahead)
+	DefaultCameraHeightOffset = 2  // meters above the wheels
+)
+
+// CameraBuilder builds a first-person "tour" of the flight, as seen from the cockpit
 type CameraBuilder struct {
-	AddBankAngle bool
-	DebugFlag    bool
+	AddBankAngle       bool
+	DebugFlag          bool
+	Tilt               float64
+	HeightOffsetMeters float64
+}
+
+func init() {
+	RegisterLayer(Layer{
+		Name:        "camera",
+		Description: "First-person view of the flight, as a tour",
+		Default:     true,
+		New: func(settings Settings) KmlTrackBuilder {
+			return &CameraBuilder{
+				AddBankAngle:       !settings.NoBanking,
+				DebugFlag:          settings.Debug,
+				Tilt:               DefaultCameraTilt,
+				HeightOffsetMeters: DefaultCameraHeightOffset,
+			}
+		},
+	})
 }
 
 func (ctb *CameraBuilder) Name() string {
 	return "Camera"
 }
 
+func (ctb *CameraBuilder) Options() []Option {
+	return []Option{
+		{Name: "tilt", Description: "degrees of the view from vertical (0-90)", Value: formatFloat(ctb.Tilt)},
+		{Name: "heightOffset", Description: "meters of the camera above the reported altitude", Value: formatFloat(ctb.HeightOffsetMeters)},
+	}
+}
+
+func (ctb *CameraBuilder) SetOption(name, value string) error {
+	var err error
+	switch name {
+	case "tilt":
+		ctb.Tilt, err = parseFloatOption(name, value, 0, 90)
+	case "heightOffset":
+		ctb.HeightOffsetMeters, err = parseFloatOption(name, value, -1000, 10000)
+	default:
+		err = unrecognizedOptionError(name, ctb.Options())
+	}
+	return err
+}
+
 func (ctb *CameraBuilder) Build(positions []aeroapi.Position) (*KmlProduct, error) {
 	var frames []gokml.Element
 	nPositions := len(positions)
@@ -41,7 +86,6 @@ func (ctb *CameraBuilder) Build(positions []aeroapi.Position) (*KmlProduct, erro
 		}
 
 		deltaT := nextPosition.Timestamp.Sub(thisPosition.Timestamp)
-		const cameraHeightFromWheels = 2
 		frames = append(frames, gokml.GxFlyTo(
 			gokml.GxDuration(deltaT),
 			gokml.GxFlyToMode(flyToMode),
@@ -52,9 +96,9 @@ func (ctb *CameraBuilder) Build(positions []aeroapi.Position) (*KmlProduct, erro
 				),
 				gokml.Longitude(thisPosition.Longitude),
 				gokml.Latitude(thisPosition.Latitude),
-				gokml.Altitude(aeroAlt2Meters(thisPosition.AltMslD100)+cameraHeightFromWheels),
+				gokml.Altitude(aeroAlt2Meters(thisPosition.AltMslD100)+ctb.HeightOffsetMeters),
 				gokml.Heading(thisPosition.Heading),
-				gokml.Tilt(80),
+				gokml.Tilt(ctb.Tilt),
 				gokml.Roll(-bankAngle),
 				gokml.AltitudeMode(gokml.AltitudeModeAbsolute),
 			)))
@@ -1,6 +1,9 @@
 package builders
 
 import (
+	"context"
+	"time"
+
 	gokml "github.com/twpayne/go-kml/v3"
 
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
@@ -18,6 +21,30 @@ type KmlTrackBuilder interface {
 	Build(positions []aeroapi.Position) (*KmlProduct, error)
 }
 
+// Flight describes the flight whose track is depicted
+type Flight struct {
+	FlightId    string     `json:"flightId,omitempty"`
+	Origin      string     `json:"origin,omitempty"`      // code of the departure airport, if identified
+	Destination string     `json:"destination,omitempty"` // code of the arrival airport, if identified
+	StartTime   *time.Time `json:"startTime,omitempty"`
+	EndTime     *time.Time `json:"endTime,omitempty"`
+}
+
+// FlightBuilder is a KmlTrackBuilder which can also be told of the flight whose track it builds,
+// and whose building (e.g., by an external program) can be cancelled using the context
+type FlightBuilder interface {
+	KmlTrackBuilder
+	BuildFlight(ctx context.Context, flight Flight, positions []aeroapi.Position) (*KmlProduct, error)
+}
+
+// BuildFlight builds the product of the builder, telling it of the flight if it's a FlightBuilder
+func BuildFlight(ctx context.Context, builder KmlTrackBuilder, flight Flight, positions []aeroapi.Position) (*KmlProduct, error) {
+	if flightBuilder, ok := builder.(FlightBuilder); ok {
+		return flightBuilder.BuildFlight(ctx, flight, positions)
+	}
+	return builder.Build(positions)
+}
+
 const feetPerMeter = 3.28084
 
 // AeroAlt2Meters converts altitude values emitted by AeroAPI,
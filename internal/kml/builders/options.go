@@ -0,0 +1,72 @@
+package builders
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// Option describes an option of a builder, as given in the specification of its layer
+// (e.g., "width" in "path(width=5)")
+type Option struct {
+	Name        string
+	Description string
+	Value       string // current value of the option (i.e., its default, unless it's been set)
+}
+
+// ConfigurableBuilder is a KmlTrackBuilder having options
+type ConfigurableBuilder interface {
+	KmlTrackBuilder
+	Options() []Option
+	SetOption(name, value string) error
+}
+
+// unrecognizedOptionError returns the error reporting that the named option isn't among those given
+func unrecognizedOptionError(name string, options []Option) error {
+	names := make([]string, len(options))
+	for i, option := range options {
+		names[i] = option.Name
+	}
+	return fmt.Errorf("unrecognized option(%s); expected one of {%s}", name, strings.Join(names, ", "))
+}
+
+// parseFloatOption parses the value of a numeric option, which must lie within [min, max]
+func parseFloatOption(name, value string, min, max float64) (float64, error) {
+	number, parseErr := strconv.ParseFloat(value, 64)
+	if parseErr != nil || number < min || number > max {
+		return 0, fmt.Errorf("invalid %s(%s); expected a number from %s to %s", name, value, formatFloat(min), formatFloat(max))
+	}
+	return number, nil
+}
+
+// parseBoolOption parses the value of a boolean option (e.g., "true" or "false")
+func parseBoolOption(name, value string) (bool, error) {
+	flag, parseErr := strconv.ParseBool(value)
+	if parseErr != nil {
+		return false, fmt.Errorf("invalid %s(%s); expected true or false", name, value)
+	}
+	return flag, nil
+}
+
+// parseColorOption parses the value of a color option given as "#rrggbb"
+func parseColorOption(name, value string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(value, "#")
+	rgb, parseErr := strconv.ParseUint(hex, 16, 32)
+	if parseErr != nil || len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid %s(%s); expected #rrggbb", name, value)
+	}
+	return color.RGBA{R: uint8(rgb >> 16), G: uint8(rgb >> 8), B: uint8(rgb), A: 255}, nil
+}
+
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func formatColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", uint8(r>>8), uint8(g>>8), uint8(b>>8))
+}
@@ -2,22 +2,67 @@ package builders
 
 import (
 	"image/color"
+	"strconv"
 
 	gokml "github.com/twpayne/go-kml/v3"
 
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
 )
 
+// Default values of the options of PathBuilder
+var DefaultPathColor = color.RGBA{R: 217, G: 51, B: 255, A: 255}
+
+const DefaultPathWidth = 3 // pixels
+
 // PathBuilder builds the visible "path" track, and optionally its extrusion to the ground
 type PathBuilder struct {
 	Color   color.Color
+	Width   float64
 	Extrude bool
 }
 
+func init() {
+	RegisterLayer(Layer{
+		Name:        "path",
+		Description: "Visible flight path, optionally extruded to the ground",
+		Default:     true,
+		New: func(Settings) KmlTrackBuilder {
+			return &PathBuilder{
+				Color:   DefaultPathColor,
+				Width:   DefaultPathWidth,
+				Extrude: true,
+			}
+		},
+	})
+}
+
 func (pb *PathBuilder) Name() string {
 	return "Path"
 }
 
+func (pb *PathBuilder) Options() []Option {
+	return []Option{
+		{Name: "color", Description: "color of the path (#rrggbb)", Value: formatColor(pb.Color)},
+		{Name: "width", Description: "pixels wide the path is drawn", Value: formatFloat(pb.Width)},
+		{Name: "extrude", Description: "whether the path is extruded to the ground", Value: strconv.FormatBool(pb.Extrude)},
+	}
+}
+
+func (pb *PathBuilder) SetOption(name, value string) error {
+	var err error
+	switch name {
+	case "color":
+		pb.Color, err = parseColorOption(name, value)
+	case "width":
+		pb.Width, err = parseFloatOption(name, value, 1, 100)
+	case "extrude":
+		pb.Extrude, err = parseBoolOption(name, value)
+	default:
+		err = unrecognizedOptionError(name, pb.Options())
+	}
+	return err
+}
+
 func (pb *PathBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProduct, error) {
 
 	var coordinates []gokml.Coordinate
@@ -37,7 +82,7 @@ func (pb *PathBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProduct
 	flightStyle := gokml.Style(
 		gokml.LineStyle(
 			gokml.Color(lc(127)),
-			gokml.Width(3),
+			gokml.Width(pb.Width),
 		),
 		gokml.PolyStyle(gokml.Color(lc(63))),
 	).WithID("FlightStyle")
@@ -8,6 +8,14 @@ import (
 
 type PlacemarkBuilder struct{}
 
+func init() {
+	RegisterLayer(Layer{
+		Name:        "placemark",
+		Description: "Flight path track across the ground in a single placemark",
+		New:         func(Settings) KmlTrackBuilder { return &PlacemarkBuilder{} },
+	})
+}
+
 func (*PlacemarkBuilder) Name() string {
 	return "Placemark"
 }
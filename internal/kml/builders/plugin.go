@@ -0,0 +1,244 @@
+package builders
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// DefaultPluginTimeout is the time allowed a plugin to build its layer, unless configured otherwise
+const DefaultPluginTimeout = 30 * time.Second
+
+// pluginWaitDelay limits the wait for the output of a plugin which has exited (or been killed)
+const pluginWaitDelay = time.Second
+
+// maxPluginErrorBytes limits how much of what a failing plugin writes to stderr is reported
+const maxPluginErrorBytes = 1024
+
+// PluginRequest is the JSON document written to the stdin of a plugin: the flight whose track it's
+// to depict, the positions of the track, and the options given in the specification of its layer
+type PluginRequest struct {
+	Layer     string             `json:"layer"`
+	Options   map[string]string  `json:"options"`
+	Flight    Flight             `json:"flight"`
+	Positions []aeroapi.Position `json:"positions"`
+}
+
+// PluginResponse is the JSON document a plugin writes to its stdout: a KML fragment (e.g., a Folder
+// element) added to the document, and the assets (e.g., images) it references by (relative) name
+type PluginResponse struct {
+	Kml    string            `json:"kml"`
+	Assets map[string][]byte `json:"assets"` // encoded in JSON as base64
+}
+
+// PluginError indicates that a plugin failed to build its layer (e.g., it exited with an error, timed
+// out or wrote an invalid response), such that the track of the flight can't be depicted as requested
+type PluginError struct {
+	Err error
+}
+
+func (e *PluginError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PluginError) Unwrap() error {
+	return e.Err
+}
+
+func newPluginError(format string, args ...any) error {
+	return &PluginError{Err: fmt.Errorf(format, args...)}
+}
+
+// PluginBuilder builds a layer using an external program (e.g., a Python script), which reads
+// a PluginRequest from its stdin, and writes a PluginResponse to its stdout
+type PluginBuilder struct {
+	LayerName string
+	Command   string            // name of the program's file
+	Timeout   time.Duration     // time allowed the program (0=DefaultPluginTimeout)
+	Values    map[string]string // options of the layer, validated by the program
+}
+
+func (pb *PluginBuilder) Name() string {
+	return pb.LayerName
+}
+
+// Options returns none, since those of the program aren't known in advance
+func (pb *PluginBuilder) Options() []Option {
+	return nil
+}
+
+// SetOption records the option, which is passed to (and validated by) the program
+func (pb *PluginBuilder) SetOption(name, value string) error {
+	if pb.Values == nil {
+		pb.Values = make(map[string]string)
+	}
+	pb.Values[name] = value
+	return nil
+}
+
+func (pb *PluginBuilder) Build(positions []aeroapi.Position) (*KmlProduct, error) {
+	return pb.BuildFlight(context.Background(), Flight{}, positions)
+}
+
+// BuildFlight runs the program, which is killed if the context is done (e.g., the run is
+// interrupted) or its Timeout elapses first
+func (pb *PluginBuilder) BuildFlight(ctx context.Context, flight Flight, positions []aeroapi.Position) (*KmlProduct, error) {
+
+	request, marshalErr := json.Marshal(PluginRequest{
+		Layer:     pb.LayerName,
+		Options:   pb.Values,
+		Flight:    flight,
+		Positions: positions,
+	})
+	if marshalErr != nil {
+		return nil, marshalErr
+	}
+
+	timeout := pb.Timeout
+	if timeout <= 0 {
+		timeout = DefaultPluginTimeout
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(runCtx, pb.Command)
+	cmd.Stdin = bytes.NewReader(request)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// don't wait (long) for processes started by the program, which may hold its output open once it's killed
+	cmd.WaitDelay = pluginWaitDelay
+	if runErr := cmd.Run(); runErr != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			// e.g., the run was interrupted
+			return nil, newPluginError("plugin(%s) was stopped: %w", pb.Command, ctxErr)
+		}
+		if errors.Is(runCtx.Err(), context.DeadlineExceeded) {
+			return nil, newPluginError("plugin(%s) timed out after %s", pb.Command, timeout)
+		}
+		return nil, newPluginError("plugin(%s) failed: %w%s", pb.Command, runErr, formatPluginStderr(stderr.Bytes()))
+	}
+
+	var response PluginResponse
+	if unmarshalErr := json.Unmarshal(stdout.Bytes(), &response); unmarshalErr != nil {
+		return nil, newPluginError("plugin(%s) wrote an invalid response: %w", pb.Command, unmarshalErr)
+	}
+	root, parseErr := newRawElement(response.Kml)
+	if parseErr != nil {
+		return nil, newPluginError("plugin(%s) wrote invalid KML: %w", pb.Command, parseErr)
+	}
+	assets := make(map[string]any, len(response.Assets))
+	for name, contents := range response.Assets {
+		if !isValidAssetName(name) {
+			return nil, newPluginError("plugin(%s) wrote an invalid asset name(%s)", pb.Command, name)
+		}
+		assets[name] = contents
+	}
+	return &KmlProduct{Root: root, Assets: assets}, nil
+}
+
+// formatPluginStderr returns (the end of) what a plugin wrote to stderr, e.g., to explain its failure
+func formatPluginStderr(stderr []byte) string {
+	text := strings.TrimSpace(string(stderr))
+	if text == "" {
+		return ""
+	}
+	if len(text) > maxPluginErrorBytes {
+		text = "..." + text[len(text)-maxPluginErrorBytes:]
+	}
+	return ": " + text
+}
+
+// isValidAssetName returns true if the asset would be stored within the KMZ file, and not replace its document
+func isValidAssetName(name string) bool {
+	cleaned := path.Clean(name)
+	return name != "" && cleaned == name && !path.IsAbs(name) && !strings.HasPrefix(name, "../") &&
+		name != ".." && name != "." && name != "doc.kml" && !strings.Contains(name, `\`)
+}
+
+// rawElement is a well-formed KML fragment, included in the document as written
+type rawElement struct {
+	tokens []xml.Token
+}
+
+// newRawElement parses the KML fragment, which must contain exactly one (root) element; namespace
+// prefixes (e.g., "gx:") are retained as written
+func newRawElement(fragment string) (*rawElement, error) {
+	decoder := xml.NewDecoder(strings.NewReader(fragment))
+	var element rawElement
+	var open []xml.Name
+	var roots int
+	for {
+		token, tokenErr := decoder.RawToken()
+		if errors.Is(tokenErr, io.EOF) {
+			break
+		} else if tokenErr != nil {
+			return nil, tokenErr
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(open) == 0 {
+				roots++
+			}
+			t.Name = prefixedName(t.Name)
+			open = append(open, t.Name)
+			attrs := make([]xml.Attr, len(t.Attr))
+			for i, attr := range t.Attr {
+				attrs[i] = xml.Attr{Name: prefixedName(attr.Name), Value: attr.Value}
+			}
+			t.Attr = attrs
+			token = t
+		case xml.EndElement:
+			t.Name = prefixedName(t.Name)
+			if len(open) == 0 || open[len(open)-1] != t.Name {
+				return nil, fmt.Errorf("unexpected end element(%s)", t.Name.Local)
+			}
+			open = open[:len(open)-1]
+			token = t
+		case xml.CharData:
+			if len(open) == 0 {
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, errors.New("text outside of the root element")
+				}
+				continue
+			}
+		case xml.ProcInst, xml.Directive:
+			// e.g., an XML declaration
+			continue
+		}
+		element.tokens = append(element.tokens, xml.CopyToken(token))
+	}
+	if len(open) > 0 {
+		return nil, fmt.Errorf("unclosed element(%s)", open[len(open)-1].Local)
+	}
+	if roots != 1 {
+		return nil, fmt.Errorf("expected one root element; found %d", roots)
+	}
+	return &element, nil
+}
+
+func prefixedName(name xml.Name) xml.Name {
+	if name.Space == "" {
+		return name
+	}
+	return xml.Name{Local: name.Space + ":" + name.Local}
+}
+
+func (re *rawElement) MarshalXML(encoder *xml.Encoder, _ xml.StartElement) error {
+	for _, token := range re.tokens {
+		if encodeErr := encoder.EncodeToken(token); encodeErr != nil {
+			return encodeErr
+		}
+	}
+	return nil
+}
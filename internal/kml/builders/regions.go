@@ -0,0 +1,131 @@
+package builders
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	gokml "github.com/twpayne/go-kml/v3"
+)
+
+// levels of detail (in pixels of the region's projected size on screen) above which
+// the "overview" and "detail" placemarks of a tile are revealed
+const (
+	overviewMinLodPixels = 64
+	detailMinLodPixels   = 512
+	overviewStride       = 4
+	regionPaddingDegrees = 0.001 // ensures even a tile of coincident placemarks has some size
+)
+
+// locatedPlacemark is a placemark with the location it depicts
+type locatedPlacemark struct {
+	latitude  float64
+	longitude float64
+	placemark gokml.Element
+}
+
+// regionalize distributes placemarks into "tiles" of (up to) "tileSize" consecutive placemarks, each having
+// a Region so that it's rendered only when within view.  Within each tile, only every "overviewStride"th
+// placemark is revealed when zoomed out; the remainder appear once zoomed in closer.  If "tileSize" isn't
+// positive, the placemarks are returned as-is.
+// See https://developers.google.com/kml/documentation/regions
+func regionalize(placemarks []locatedPlacemark, tileSize int) []gokml.Element {
+	if tileSize <= 0 {
+		elements := make([]gokml.Element, 0, len(placemarks))
+		for _, lp := range placemarks {
+			elements = append(elements, lp.placemark)
+		}
+		return elements
+	}
+
+	var tiles []gokml.Element
+	for first := 0; first < len(placemarks); first += tileSize {
+		last := first + tileSize
+		if last > len(placemarks) {
+			last = len(placemarks)
+		}
+		tile := placemarks[first:last]
+
+		var overview, detail []gokml.Element
+		for i, lp := range tile {
+			if i%overviewStride == 0 {
+				overview = append(overview, lp.placemark)
+			} else {
+				detail = append(detail, lp.placemark)
+			}
+		}
+
+		tileBox := getLatLonBox(tile)
+		tileFolder := gokml.Folder(
+			gokml.Name(fmt.Sprintf("Tile %d-%d", first+1, last)),
+			gokml.Folder(
+				gokml.Name("Overview"),
+				gokml.Region(tileBox, gokml.LOD(gokml.MinLODPixels(overviewMinLodPixels), gokml.MaxLODPixels(-1))),
+			).Append(overview...),
+		)
+		if len(detail) > 0 {
+			tileFolder.Append(gokml.Folder(
+				gokml.Name("Detail"),
+				gokml.Region(tileBox, gokml.LOD(gokml.MinLODPixels(detailMinLodPixels), gokml.MaxLODPixels(-1))),
+			).Append(detail...))
+		}
+		tiles = append(tiles, tileFolder)
+	}
+	return tiles
+}
+
+// getLatLonBox returns the box bounding the placemarks.  If they straddle the antimeridian (i.e., the
+// narrowest range of longitudes spanning them crosses it), the box wraps around it, with its West
+// boundary east of its East boundary, as KML allows.
+func getLatLonBox(placemarks []locatedPlacemark) gokml.Element {
+	north, south := -90.0, 90.0
+	longitudes := make([]float64, 0, len(placemarks))
+	for _, lp := range placemarks {
+		north = math.Max(north, lp.latitude)
+		south = math.Min(south, lp.latitude)
+		longitudes = append(longitudes, lp.longitude)
+	}
+	west, east := getLongitudeRange(longitudes)
+	if west <= east {
+		west = math.Max(west-regionPaddingDegrees, -180)
+		east = math.Min(east+regionPaddingDegrees, 180)
+	} else {
+		west = wrapLongitude(west - regionPaddingDegrees)
+		east = wrapLongitude(east + regionPaddingDegrees)
+	}
+	return gokml.LatLonAltBox(
+		gokml.North(math.Min(north+regionPaddingDegrees, 90)),
+		gokml.South(math.Max(south-regionPaddingDegrees, -90)),
+		gokml.East(east),
+		gokml.West(west),
+	)
+}
+
+// getLongitudeRange returns the western and eastern bounds of the narrowest range of longitudes spanning
+// those given, which crosses the antimeridian (such that west > east) if that's narrower than not.
+// It's the complement of the widest gap between consecutive longitudes, going around the globe.
+func getLongitudeRange(longitudes []float64) (west, east float64) {
+	sorted := append([]float64(nil), longitudes...)
+	sort.Float64s(sorted)
+	last := len(sorted) - 1
+	// the gap across the antimeridian, between the easternmost and westernmost longitudes
+	west, east = sorted[0], sorted[last]
+	widestGap := sorted[0] + 360 - sorted[last]
+	for i := 0; i < last; i++ {
+		if gap := sorted[i+1] - sorted[i]; gap > widestGap {
+			west, east, widestGap = sorted[i+1], sorted[i], gap
+		}
+	}
+	return west, east
+}
+
+// wrapLongitude returns the longitude within [-180, 180]
+func wrapLongitude(longitude float64) float64 {
+	switch {
+	case longitude > 180:
+		return longitude - 360
+	case longitude < -180:
+		return longitude + 360
+	}
+	return longitude
+}
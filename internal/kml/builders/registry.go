@@ -0,0 +1,104 @@
+package builders
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
+)
+
+// Settings holds the (command's) settings with which the builders of layers are made
+type Settings struct {
+	Terrain    terrain.ElevationProvider // terrain elevation data, if configured
+	NoBanking  bool                      // disable the banking heuristic
+	MinAglFeet float64                   // height above ground below which flight is flagged
+	Debug      bool
+}
+
+// Layer describes a layer of the KML depiction, made by the builder returned by its factory
+type Layer struct {
+	Name        string // e.g., "path"; given to the "--layers" option
+	Description string
+	Default     bool // depicted unless the layers are chosen explicitly
+	New         func(settings Settings) KmlTrackBuilder
+}
+
+// Options returns the options (with their default values) of the layer's builder, if any
+func (l Layer) Options(settings Settings) []Option {
+	if configurable, ok := l.New(settings).(ConfigurableBuilder); ok {
+		return configurable.Options()
+	}
+	return nil
+}
+
+var layerNameRe = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+
+var registry = struct {
+	sync.RWMutex
+	layers map[string]Layer
+}{layers: make(map[string]Layer)}
+
+// RegisterLayer makes a layer available by its name.  Like database/sql.Register, it's meant
+// to be called from init functions, and panics if the layer is incomplete, its name is invalid
+// (it must be alphanumeric, starting with a lowercase letter) or already registered.
+func RegisterLayer(layer Layer) {
+	if !IsValidLayerName(layer.Name) {
+		panic(fmt.Sprintf("builders: invalid layer name(%s)", layer.Name))
+	}
+	if layer.New == nil {
+		panic(fmt.Sprintf("builders: layer(%s) has no factory", layer.Name))
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.layers[layer.Name]; dup {
+		panic(fmt.Sprintf("builders: layer(%s) registered twice", layer.Name))
+	}
+	registry.layers[layer.Name] = layer
+}
+
+// IsValidLayerName returns true if the name can be registered as that of a layer
+func IsValidLayerName(name string) bool {
+	return layerNameRe.MatchString(name)
+}
+
+// LookupLayer returns the layer registered by the name, if any
+func LookupLayer(name string) (Layer, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	layer, ok := registry.layers[name]
+	return layer, ok
+}
+
+// Layers returns the registered layers, in order of their names
+func Layers() []Layer {
+	registry.RLock()
+	defer registry.RUnlock()
+	layers := make([]Layer, 0, len(registry.layers))
+	for _, layer := range registry.layers {
+		layers = append(layers, layer)
+	}
+	sort.Slice(layers, func(i, j int) bool { return layers[i].Name < layers[j].Name })
+	return layers
+}
+
+// LayerNames returns the names of the registered layers, in order
+func LayerNames() []string {
+	var names []string
+	for _, layer := range Layers() {
+		names = append(names, layer.Name)
+	}
+	return names
+}
+
+// DefaultLayerNames returns the names of the layers depicted by default, in order
+func DefaultLayerNames() []string {
+	var names []string
+	for _, layer := range Layers() {
+		if layer.Default {
+			names = append(names, layer.Name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,27 @@
+package builders
+
+import (
+	"context"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// SimplifiedBuilder decorates a KmlTrackBuilder so that it builds from only those
+// positions needed to represent the track within a tolerance (e.g., to reduce the
+// number of placemarks or camera frames generated for long flights)
+type SimplifiedBuilder struct {
+	KmlTrackBuilder
+	ToleranceMeters float64
+	DebugFlag       bool
+}
+
+func (sb *SimplifiedBuilder) Build(positions []aeroapi.Position) (*KmlProduct, error) {
+	return sb.BuildFlight(context.Background(), Flight{}, positions)
+}
+
+func (sb *SimplifiedBuilder) BuildFlight(ctx context.Context, flight Flight, positions []aeroapi.Position) (*KmlProduct, error) {
+	aeroApiMathUtil := &aeroapi.Math{
+		Debug: sb.DebugFlag,
+	}
+	return BuildFlight(ctx, sb.KmlTrackBuilder, flight, aeroApiMathUtil.Simplify(positions, sb.ToleranceMeters))
+}
@@ -0,0 +1,53 @@
+package builders
+
+import (
+	"fmt"
+	"image/color"
+	"math"
+
+	gokml "github.com/twpayne/go-kml/v3"
+)
+
+// bucket widths used to share the styles of arrows having similar headings and speeds
+const (
+	headingBucketDegrees = 5
+	speedBucketKnots     = 10
+)
+
+// styleSheet accumulates the distinct styles shared by the placemarks of a layer, so that
+// a style is written once per "bucket" (e.g., of similar heading and speed) rather than once
+// per placemark
+type styleSheet struct {
+	ids    map[string]bool
+	styles []gokml.Element
+}
+
+// getArrowStyleUrl returns a reference to the shared style drawing an arrow icon in the
+// given color, pointing in the direction of "heading" and sized according to "speed"
+func (ss *styleSheet) getArrowStyleUrl(kind string, styleColor color.Color, iconImageUrl string, heading, speed float64) string {
+	headingBucket := int(math.Round(math.Mod(heading+360, 360)/headingBucketDegrees)) * headingBucketDegrees % 360
+	speedBucket := int(math.Round(speed/speedBucketKnots)) * speedBucketKnots
+	styleId := fmt.Sprintf("%s-h%03d-s%d", kind, headingBucket, speedBucket)
+	if !ss.ids[styleId] {
+		if ss.ids == nil {
+			ss.ids = make(map[string]bool)
+		}
+		ss.ids[styleId] = true
+		ss.styles = append(ss.styles, gokml.Style(
+			gokml.IconStyle(
+				gokml.Color(styleColor),
+				gokml.Icon(gokml.Href(iconImageUrl)),
+				gokml.Heading(float64(headingBucket)-90),
+				gokml.Scale(float64(speedBucket)/100),
+			),
+			// reveal the description of the placemark (only) within its balloon
+			gokml.BalloonStyle(gokml.Text("$[description]")),
+		).WithID(styleId))
+	}
+	return "#" + styleId
+}
+
+// getStyles returns the styles accumulated so far
+func (ss *styleSheet) getStyles() []gokml.Element {
+	return ss.styles
+}
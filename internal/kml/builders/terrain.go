@@ -0,0 +1,166 @@
+package builders
+
+import (
+	"errors"
+	"fmt"
+	"image/color"
+	"time"
+
+	gokml "github.com/twpayne/go-kml/v3"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
+)
+
+// TerrainBuilder builds a KML folder highlighting the segments of the flight
+// flown closer to the terrain than a given height above ground level (AGL)
+type TerrainBuilder struct {
+	Terrain    terrain.ElevationProvider
+	MinAglFeet float64
+}
+
+func init() {
+	RegisterLayer(Layer{
+		Name:        "terrain",
+		Description: "Segments of the flight flown low above the terrain (requires terrain elevation data)",
+		New: func(settings Settings) KmlTrackBuilder {
+			return &TerrainBuilder{
+				Terrain:    settings.Terrain,
+				MinAglFeet: settings.MinAglFeet,
+			}
+		},
+	})
+}
+
+func (tb *TerrainBuilder) Name() string {
+	return "Terrain"
+}
+
+func (tb *TerrainBuilder) Options() []Option {
+	return []Option{
+		{Name: "minAgl", Description: "feet above ground level below which the flight is highlighted", Value: formatFloat(tb.MinAglFeet)},
+	}
+}
+
+func (tb *TerrainBuilder) SetOption(name, value string) error {
+	var err error
+	switch name {
+	case "minAgl":
+		tb.MinAglFeet, err = parseFloatOption(name, value, 0, 60000)
+	default:
+		err = unrecognizedOptionError(name, tb.Options())
+	}
+	return err
+}
+
+func (tb *TerrainBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProduct, error) {
+
+	if tb.Terrain == nil {
+		return nil, errors.New("no terrain elevation data is configured")
+	}
+
+	nPositions := len(aeroTrackPositions)
+	aglFeet := make([]float64, nPositions)
+	isBelow := make([]bool, nPositions)
+	var lowestIndex, nKnown int
+	for i, position := range aeroTrackPositions {
+		agl, ok, getAglErr := terrain.GetAglFeet(tb.Terrain, position.Latitude, position.Longitude, position.AltMslD100*100)
+		if getAglErr != nil {
+			return nil, getAglErr
+		}
+		if !ok {
+			continue
+		}
+		aglFeet[i] = agl
+		isBelow[i] = agl < tb.MinAglFeet
+		if nKnown == 0 || agl < aglFeet[lowestIndex] {
+			lowestIndex = i
+		}
+		nKnown++
+	}
+
+	warningStyle := gokml.Style(
+		gokml.LineStyle(
+			gokml.Color(color.RGBA{R: 255, A: 255}),
+			gokml.Width(6),
+		),
+	).WithID("TerrainWarningStyle")
+
+	var warnings []gokml.Element
+	for i := 0; i < nPositions; i++ {
+		if !isBelow[i] {
+			continue
+		}
+		j := i
+		lowest := i
+		for j+1 < nPositions && isBelow[j+1] {
+			j++
+			if aglFeet[j] < aglFeet[lowest] {
+				lowest = j
+			}
+		}
+
+		// include the neighboring positions so that even a single low position is drawn as a line
+		var coordinates []gokml.Coordinate
+		for k := maxInt(i-1, 0); k <= minInt(j+1, nPositions-1); k++ {
+			coordinates = append(coordinates, gokml.Coordinate{
+				Lon: aeroTrackPositions[k].Longitude,
+				Lat: aeroTrackPositions[k].Latitude,
+				Alt: aeroAlt2Meters(aeroTrackPositions[k].AltMslD100),
+			})
+		}
+
+		warnings = append(warnings, gokml.Placemark(
+			gokml.Name(fmt.Sprintf("%.0f' AGL", aglFeet[lowest])),
+			gokml.Description(fmt.Sprintf("Below %.0f' AGL from %s to %s; lowest was %.0f' AGL at %s",
+				tb.MinAglFeet,
+				aeroTrackPositions[i].Timestamp.Format(time.RFC3339),
+				aeroTrackPositions[j].Timestamp.Format(time.RFC3339),
+				aglFeet[lowest],
+				aeroTrackPositions[lowest].Timestamp.Format(time.RFC3339),
+			)),
+			gokml.StyleURL("#TerrainWarningStyle"),
+			gokml.LineString(
+				gokml.AltitudeMode(gokml.AltitudeModeAbsolute),
+				gokml.Coordinates(coordinates...),
+			),
+		))
+		i = j
+	}
+
+	var summary string
+	if nKnown == 0 {
+		summary = "No terrain elevation data covers this flight"
+	} else {
+		summary = fmt.Sprintf("%d segment(s) below %.0f' AGL; lowest was %.0f' AGL at %s (terrain known for %d of %d positions)",
+			len(warnings),
+			tb.MinAglFeet,
+			aglFeet[lowestIndex],
+			aeroTrackPositions[lowestIndex].Timestamp.Format(time.RFC3339),
+			nKnown,
+			nPositions,
+		)
+	}
+
+	root := gokml.Folder(
+		gokml.Name("Terrain Clearance"),
+		gokml.Description(summary),
+		warningStyle,
+	).Append(warnings...)
+
+	return &KmlProduct{Root: root}, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
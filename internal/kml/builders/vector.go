@@ -12,6 +12,7 @@ import (
 	gokml "github.com/twpayne/go-kml/v3"
 
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
 )
 
 // VectorBuilder - builds a KML folder of Placemarks revealing significant
@@ -23,11 +24,34 @@ import (
 // => Groundspeed - reflected by magnitude / size of the arrow
 //
 // Additional sets of Placemarks are used to reveal secondary information
-// calculated from the track data (e.g., "imputed" values).
-type VectorBuilder struct{}
+// calculated from the track data (e.g., "imputed" values).  When terrain
+// elevation data is available, the height above ground level is also shown.
+//
+// Placemarks share styles bucketed by heading and speed, and are grouped into
+// Region-based tiles of RegionTileSize (0=DefaultRegionTileSize, <0=none)
+// placemarks, so that only a subset of them is revealed when zoomed out.
+type VectorBuilder struct {
+	Terrain        terrain.ElevationProvider
+	RegionTileSize int
+}
+
+const DefaultRegionTileSize = 32
 
 const vectorArrowRelPath = "images/blue_fast_arrow.png"
 
+func init() {
+	RegisterLayer(Layer{
+		Name:        "vector",
+		Description: "Vectors along the flight path reflecting performance data",
+		Default:     true,
+		New: func(settings Settings) KmlTrackBuilder {
+			return &VectorBuilder{
+				Terrain: settings.Terrain,
+			}
+		},
+	})
+}
+
 func (vb *VectorBuilder) Name() string {
 	return "Vector"
 }
@@ -50,16 +74,26 @@ func (vb *VectorBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProdu
 	}
 
 	aeroapiMathUtil := &aeroapi.Math{}
-	var positionReferences []gokml.Element
+	styles := &styleSheet{}
+	var reportedPlacemarks, imputedPlacemarks []locatedPlacemark
 
 	for i := 0; i < len(aeroTrackPositions)-1; i++ {
 		thisPosition := aeroTrackPositions[i]
 		nextPosition := aeroTrackPositions[i+1]
 
+		thisAgl, thisAglErr := vb.getAglItem(thisPosition)
+		if thisAglErr != nil {
+			return nil, thisAglErr
+		}
+		nextAgl, nextAglErr := vb.getAglItem(nextPosition)
+		if nextAglErr != nil {
+			return nil, nextAglErr
+		}
+
 		// the "reported" placemarks plot info received directly from AeroAPI
-		reportedDescription := getReportedDescription(thisPosition, nextPosition)
+		reportedDescription := getReportedDescription(thisPosition, nextPosition, thisAgl, nextAgl)
 		reportedPlacemark := styledPlacemark{
-			styleName:    fmt.Sprintf("heading-icon%d", i),
+			kind:         "heading-icon",
 			balloonText:  fmt.Sprintf("<h1>Reported</h1>%s", reportedDescription),
 			styleColor:   color.RGBA{R: 255, G: 255, B: 0, A: 255},
 			heading:      thisPosition.Heading,
@@ -67,13 +101,13 @@ func (vb *VectorBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProdu
 			iconImageUrl: vectorArrowHref,
 			position:     thisPosition,
 		}
-		positionReferences = append(positionReferences, reportedPlacemark.getElements()...)
+		reportedPlacemarks = append(reportedPlacemarks, reportedPlacemark.getLocatedPlacemark(styles))
 
 		// the "imputed" placemarks plot info calculated indirectly from AeroAPI data
 		geoHeading := aeroapiMathUtil.GetGeoBearing(thisPosition, nextPosition)
 		geoGsKnots := aeroapiMathUtil.GetGeoGsKnots(thisPosition, nextPosition)
 		imputedPlacemark := styledPlacemark{
-			styleName:    fmt.Sprintf("bearing-icon%d", i),
+			kind:         "bearing-icon",
 			balloonText:  fmt.Sprintf("<h1>Imputed</h1>%s%s", getImputedDescription(geoHeading, geoGsKnots), reportedDescription),
 			styleColor:   color.RGBA{R: 0, G: 255, B: 255, A: 255},
 			heading:      float64(geoHeading),
@@ -81,14 +115,22 @@ func (vb *VectorBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProdu
 			iconImageUrl: vectorArrowHref,
 			position:     thisPosition,
 		}
-		positionReferences = append(positionReferences, imputedPlacemark.getElements()...)
+		imputedPlacemarks = append(imputedPlacemarks, imputedPlacemark.getLocatedPlacemark(styles))
 	}
 
+	tileSize := vb.RegionTileSize
+	if tileSize == 0 {
+		tileSize = DefaultRegionTileSize
+	}
 	thing.Root = gokml.Folder(
 		gokml.Name("Vector Track"),
 		gokml.Description("Vectors along flight path reflecting performance data"),
 	).
-		Append(positionReferences...)
+		Append(styles.getStyles()...).
+		Append(
+			gokml.Folder(gokml.Name("Reported")).Append(regionalize(reportedPlacemarks, tileSize)...),
+			gokml.Folder(gokml.Name("Imputed")).Append(regionalize(imputedPlacemarks, tileSize)...),
+		)
 
 	return thing, nil
 }
@@ -108,8 +150,9 @@ func getEmbeddedFileContents(fs fs.FS, fileName string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
+// styledPlacemark describes a placemark drawn as an arrow using a style shared with similar placemarks
 type styledPlacemark struct {
-	styleName    string
+	kind         string
 	balloonText  string
 	styleColor   color.Color
 	heading      float64
@@ -118,19 +161,13 @@ type styledPlacemark struct {
 	position     aeroapi.Position
 }
 
-func (sp *styledPlacemark) getElements() []gokml.Element {
-	return []gokml.Element{
-		gokml.Style(
-			gokml.IconStyle(
-				gokml.Color(sp.styleColor),
-				gokml.Icon(gokml.Href(sp.iconImageUrl)),
-				gokml.Heading(sp.heading-90),
-				gokml.Scale(sp.gs/100),
-			),
-			gokml.BalloonStyle(gokml.Text(sp.balloonText)),
-		).WithID(sp.styleName),
-		gokml.Placemark(
-			gokml.StyleURL("#"+sp.styleName),
+func (sp *styledPlacemark) getLocatedPlacemark(styles *styleSheet) locatedPlacemark {
+	return locatedPlacemark{
+		latitude:  sp.position.Latitude,
+		longitude: sp.position.Longitude,
+		placemark: gokml.Placemark(
+			gokml.Description(sp.balloonText),
+			gokml.StyleURL(styles.getArrowStyleUrl(sp.kind, sp.styleColor, sp.iconImageUrl, sp.heading, sp.gs)),
 			gokml.Point(
 				gokml.AltitudeMode(gokml.AltitudeModeAbsolute),
 				gokml.Coordinates(
@@ -156,13 +193,23 @@ func getImputedDescription(geoHeading aeroapi.Degrees, geoGsKnots float64) strin
 	)
 }
 
-func getReportedDescription(thisPosition aeroapi.Position, nextPosition aeroapi.Position) string {
+// getAglItem returns a list item revealing the height above ground level of the position,
+// or an empty string if it can't be determined
+func (vb *VectorBuilder) getAglItem(position aeroapi.Position) (string, error) {
+	aglFeet, ok, err := terrain.GetAglFeet(vb.Terrain, position.Latitude, position.Longitude, position.AltMslD100*100)
+	if err != nil || !ok {
+		return "", err
+	}
+	return fmt.Sprintf("<li>Height Above Ground: %.0f'</li>", aglFeet), nil
+}
+
+func getReportedDescription(thisPosition aeroapi.Position, nextPosition aeroapi.Position, thisAgl, nextAgl string) string {
 	return fmt.Sprintf(`<h2>Reported by AeroAPI</h2>
 		<h3>This Location</h3>
 		<ul>
 			<li>Time: %v</li>
 			<li>Location: %v</li>
-			<li>Altitude: %.0f'</li>
+			<li>Altitude: %.0f'</li>%s
 			<li>Heading: %.1fº</li>
 			<li>Groundspeed: %.1fkt</li>
 		</ul>
@@ -170,18 +217,20 @@ func getReportedDescription(thisPosition aeroapi.Position, nextPosition aeroapi.
 		<ul>
 			<li>Time: %v</li>
 			<li>Location: %v</li>
-			<li>Altitude: %.0f'</li>
+			<li>Altitude: %.0f'</li>%s
 			<li>Heading: %.1fº</li>
 			<li>Groundspeed: %.1fkt</li>
 		</ul>`,
 		thisPosition.Timestamp.Format(time.RFC3339),
 		[]float64{thisPosition.Latitude, thisPosition.Longitude},
 		thisPosition.AltMslD100*100,
+		thisAgl,
 		thisPosition.Heading,
 		thisPosition.GsKnots,
 		thisPosition.Timestamp.Format(time.RFC3339),
 		[]float64{nextPosition.Latitude, nextPosition.Longitude},
 		nextPosition.AltMslD100*100,
+		nextAgl,
 		thisPosition.Heading,
 		thisPosition.GsKnots,
 	)
@@ -0,0 +1,105 @@
+package builders
+
+import (
+	"fmt"
+	"image/color"
+	"path/filepath"
+	"time"
+
+	gokml "github.com/twpayne/go-kml/v3"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// WindBuilder - builds a KML folder of Placemarks depicting the winds aloft
+// encountered by the aircraft, as estimated from segments of the track during
+// which it was turning (e.g., circling, or doglegs).  Each wind is drawn as an arrow pointing
+// in the direction the wind is blowing, sized according to its speed.
+type WindBuilder struct {
+	WindowSize int     // number of consecutive positions examined per estimate (0=default)
+	MinTurn    float64 // minimum change in ground track needed for an estimate (0=default)
+}
+
+func init() {
+	RegisterLayer(Layer{
+		Name:        "wind",
+		Description: "Winds aloft estimated from turning (e.g., circling, or doglegs) segments of the flight",
+		New:         func(Settings) KmlTrackBuilder { return &WindBuilder{} },
+	})
+}
+
+func (wb *WindBuilder) Name() string {
+	return "Wind"
+}
+
+func (wb *WindBuilder) Build(aeroTrackPositions []aeroapi.Position) (*KmlProduct, error) {
+
+	vectorArrowPngBytes, getErr := getEmbeddedFileContents(embeddedImages, vectorArrowRelPath)
+	if getErr != nil {
+		return nil, fmt.Errorf("can't get embedded file: %v", getErr)
+	}
+
+	vectorArrowHref := filepath.Base(vectorArrowRelPath)
+	thing := &KmlProduct{
+		Assets: map[string]any{
+			vectorArrowHref: vectorArrowPngBytes,
+		},
+	}
+
+	aeroapiMathUtil := &aeroapi.Math{}
+	estimates := aeroapiMathUtil.EstimateWinds(aeroTrackPositions, wb.WindowSize, aeroapi.Degrees(wb.MinTurn))
+
+	styles := &styleSheet{}
+	var windPlacemarks []locatedPlacemark
+	for _, estimate := range estimates {
+		windPlacemark := styledPlacemark{
+			kind:         "wind-icon",
+			balloonText:  fmt.Sprintf("<h1>Wind</h1>%s", getWindDescription(estimate)),
+			styleColor:   color.RGBA{R: 255, G: 255, B: 255, A: 255},
+			heading:      float64(estimate.FromDeg) + 180,
+			gs:           estimate.SpeedKnots * 5, // magnify, since winds are much slower than aircraft
+			iconImageUrl: vectorArrowHref,
+			position:     estimate.Position,
+		}
+		windPlacemarks = append(windPlacemarks, windPlacemark.getLocatedPlacemark(styles))
+	}
+
+	thing.Root = gokml.Folder(
+		gokml.Name("Wind Track"),
+		gokml.Description(getWindSummary(aeroapiMathUtil, estimates)),
+	).
+		Append(styles.getStyles()...).
+		Append(regionalize(windPlacemarks, 0)...)
+
+	return thing, nil
+}
+
+func getWindSummary(aeroapiMathUtil *aeroapi.Math, estimates []aeroapi.WindEstimate) string {
+	mean, ok := aeroapiMathUtil.GetMeanWind(estimates)
+	if !ok {
+		return "Winds aloft estimated from turning segments of the flight; none qualified"
+	}
+	return fmt.Sprintf("Winds aloft estimated from %d turning segment(s) of the flight; mean wind from %03.0fº at %.1fkt",
+		len(estimates),
+		mean.FromDeg,
+		mean.SpeedKnots,
+	)
+}
+
+func getWindDescription(estimate aeroapi.WindEstimate) string {
+	return fmt.Sprintf(`<h2>Estimated From Ground Track</h2>
+		<ul>
+			<li>Time: %v</li>
+			<li>Altitude: %.0f'</li>
+			<li>Wind: from %03.0fº at %.1fkt</li>
+			<li>Airspeed: %.1fkt</li>
+			<li>Samples: %d</li>
+		</ul>`,
+		estimate.Position.Timestamp.Format(time.RFC3339),
+		estimate.Position.AltMslD100*100,
+		estimate.FromDeg,
+		estimate.SpeedKnots,
+		estimate.TasKnots,
+		estimate.Samples,
+	)
+}
@@ -2,6 +2,8 @@ package kml
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -10,29 +12,57 @@ import (
 
 	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/airports"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
 )
 
 // Track contains the fully-rendered KML document representing a flight,
 // assets referenced by that KML document, and some relevant metadata
 type Track struct {
-	KmlDoc    []byte
-	KmlAssets map[string]any
-	StartTime *time.Time
-	EndTime   *time.Time
+	FlightId    string // AeroAPI identifier of the flight whose track is depicted
+	Label       string // name of the flight (e.g., its tail number) used to name the files depicting it, if known
+	KmlDoc      []byte
+	KmlAssets   map[string]any
+	StartTime   *time.Time
+	EndTime     *time.Time
+	Origin      string      // code of the departure airport, if identified
+	Destination string      // code of the arrival airport, if identified
+	Statistics  *Statistics // of the flight, also described in the KML document
+}
+
+// GetRouteName returns a name for the route flown (e.g., "KLAX-PHOG") based upon the
+// identified departure and arrival airports, or an empty string if neither was identified
+func (t *Track) GetRouteName() string {
+	if t.Origin == "" && t.Destination == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s-%s", t.Origin, t.Destination)
 }
 
 // TrackGenerator can generate a Track from raw flight position data
 type TrackGenerator interface {
-	Generate(*aeroapi.Track) (*Track, error)
+	Generate(context.Context, *aeroapi.Track) (*Track, error)
 }
 
-// TrackBuilderEnsemble is a named set of KmlTrackBuilder instances
+// TrackBuilderEnsemble is a named set of KmlTrackBuilder instances, optionally
+// labeling the departure and arrival airports found in the Airports database.
+// Layers which would cause the generated document to exceed its budget of
+// MaxFeatures features or MaxDocumentBytes bytes (0=unlimited) are omitted,
+// while a layer which can't be built (e.g., its plugin failed) fails the track.
+// The statistics of each flight (see GetStatistics) are described in its document,
+// including its height above the Terrain, if its elevation data is configured.
 type TrackBuilderEnsemble struct {
-	Name     string
-	Builders []builders.KmlTrackBuilder
+	Name             string
+	Settings         string // fingerprint of the settings (e.g., layers and their options) with which tracks are depicted
+	Builders         []builders.KmlTrackBuilder
+	Airports         *airports.Database
+	Terrain          terrain.ElevationProvider
+	MinAglFeet       float64 // height above the terrain below which time spent is counted in the statistics
+	MaxFeatures      int
+	MaxDocumentBytes int
 }
 
-func (gxt *TrackBuilderEnsemble) Generate(aeroTrack *aeroapi.Track) (*Track, error) {
+func (gxt *TrackBuilderEnsemble) Generate(ctx context.Context, aeroTrack *aeroapi.Track) (*Track, error) {
 
 	const cantGenerateTrackForFlightError = "can't generate KML track for flightId(%s)"
 
@@ -49,20 +79,61 @@ func (gxt *TrackBuilderEnsemble) Generate(aeroTrack *aeroapi.Track) (*Track, err
 	fromTime = &positions[0].Timestamp
 	toTime = &positions[nPositions-1].Timestamp
 
+	kmlTrack := Track{
+		FlightId:  aeroTrack.FlightId,
+		StartTime: fromTime,
+		EndTime:   toTime,
+	}
+
+	var origin, destination airports.Endpoint
+	if gxt.Airports != nil {
+		first, last := positions[0], positions[nPositions-1]
+		origin, _ = gxt.Airports.IdentifyEndpoint(first.Latitude, first.Longitude, first.Heading, 0)
+		destination, _ = gxt.Airports.IdentifyEndpoint(last.Latitude, last.Longitude, last.Heading, 0)
+		kmlTrack.Origin = origin.Code()
+		kmlTrack.Destination = destination.Code()
+	}
+
 	var layerNames []string
 	for _, kmlBuilder := range gxt.Builders {
 		layerNames = append(layerNames, kmlBuilder.Name())
 	}
+	statistics, statisticsErr := GetStatistics(positions, gxt.Terrain, gxt.MinAglFeet)
+	if statisticsErr != nil {
+		return nil, fmt.Errorf(cantGenerateTrackForFlightError+"; statistics: %w", aeroTrack.FlightId, statisticsErr)
+	}
+	kmlTrack.Statistics = statistics
 	mainDocument := gokml.Document(
-		gokml.Name(fmt.Sprintf("AeroAPI Flight %s", aeroTrack.FlightId)),
-		gokml.Description(fmt.Sprintf("Layers: %s", strings.Join(layerNames, ", "))),
+		gokml.Name(getDocumentName(aeroTrack.FlightId, kmlTrack.GetRouteName())),
+		gokml.Description(fmt.Sprintf("Layers: %s%s", strings.Join(layerNames, ", "), statistics.Description())),
 	)
+	if airportsFolder := newAirportsFolder(origin, destination); airportsFolder != nil {
+		mainDocument.Append(airportsFolder)
+	}
 
+	flight := builders.Flight{
+		FlightId:    aeroTrack.FlightId,
+		Origin:      kmlTrack.Origin,
+		Destination: kmlTrack.Destination,
+		StartTime:   fromTime,
+		EndTime:     toTime,
+	}
 	kmlAssets := make(map[string]any)
+	var budget documentBudget
 	for _, kb := range gxt.Builders {
-		kmlThing, buildErr := kb.Build(positions)
+		kmlThing, buildErr := builders.BuildFlight(ctx, kb, flight, positions)
 		if buildErr != nil {
-			fmt.Printf("NOTE: %s\n", buildErr)
+			var pluginErr *builders.PluginError
+			if errors.As(buildErr, &pluginErr) {
+				// e.g., a layer plugin failed or timed out
+				return nil, fmt.Errorf(cantGenerateTrackForFlightError+"; %s layer: %w", aeroTrack.FlightId, kb.Name(), buildErr)
+			}
+			// e.g., the terrain layer without terrain elevation data; the other layers are still depicted
+			fmt.Printf("NOTE: omitting %s layer: %s\n", kb.Name(), buildErr)
+			continue
+		}
+		if budgetErr := budget.spend(kmlThing.Root, gxt.MaxFeatures, gxt.MaxDocumentBytes); budgetErr != nil {
+			fmt.Printf("NOTE: omitting %s layer: %s\n", kb.Name(), budgetErr)
 			continue
 		}
 		mainDocument.Append(kmlThing.Root)
@@ -76,11 +147,17 @@ func (gxt *TrackBuilderEnsemble) Generate(aeroTrack *aeroapi.Track) (*Track, err
 	if err := gxKMLElement.Write(&kmlBuilder); err != nil {
 		return nil, err
 	}
-	kmlTrack := Track{
-		KmlDoc:    kmlBuilder.Bytes(),
-		KmlAssets: kmlAssets,
-		StartTime: fromTime,
-		EndTime:   toTime,
-	}
+	kmlTrack.KmlDoc = kmlBuilder.Bytes()
+	kmlTrack.KmlAssets = kmlAssets
 	return &kmlTrack, nil
 }
+
+func getDocumentName(flightId, routeName string) string {
+	switch {
+	case routeName == "":
+		return fmt.Sprintf("AeroAPI Flight %s", flightId)
+	case flightId == "":
+		return fmt.Sprintf("AeroAPI Flight %s", routeName)
+	}
+	return fmt.Sprintf("AeroAPI Flight %s (%s)", flightId, routeName)
+}
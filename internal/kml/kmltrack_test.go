@@ -1,14 +1,19 @@
 package kml
 
 import (
+	"context"
 	"fmt"
 	"image/color"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 
 	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
 	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/airports"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
 	"github.com/noodnik2/flightvisualizer/testfixtures"
 )
 
@@ -39,6 +44,15 @@ func TestNewKmlTrack(t *testing.T) {
 			input:        newMockTestAeroApiTrack(),
 			expectAssets: true,
 		},
+		{
+			tracker:      &TrackBuilderEnsemble{Builders: []builders.KmlTrackBuilder{&builders.WindBuilder{}}},
+			input:        newMockTestAeroApiTrack(),
+			expectAssets: true,
+		},
+		{
+			tracker: &TrackBuilderEnsemble{Builders: []builders.KmlTrackBuilder{&builders.TerrainBuilder{Terrain: &terrain.HgtDirectory{Dir: "no-such-dir"}}}},
+			input:   newMockTestAeroApiTrack(),
+		},
 		{
 			tracker:        &TrackBuilderEnsemble{},
 			input:          &aeroapi.Track{FlightId: "xyz321"},
@@ -54,7 +68,7 @@ func TestNewKmlTrack(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(fmt.Sprintf("%T", tc.tracker), func(t *testing.T) {
 			requirer := require.New(t)
-			kmlTrack, newKmlTrackErr := tc.tracker.Generate(tc.input)
+			kmlTrack, newKmlTrackErr := tc.tracker.Generate(context.Background(), tc.input)
 			if tc.expectedErrors != nil {
 				requirer.Error(newKmlTrackErr)
 				for _, expectedErr := range tc.expectedErrors {
@@ -80,3 +94,133 @@ func newMockTestAeroApiTrack() *aeroapi.Track {
 	track, _ := aeroapi.TrackFromJson([]byte(testfixtures.NewMockTestAeroApiTrackResponse()))
 	return track
 }
+
+func TestTrackBuilderEnsemble_Airports(t *testing.T) {
+	requirer := require.New(t)
+
+	airportsDb, loadErr := airports.LoadOurAirports(strings.NewReader(`ident,type,name,latitude_deg,longitude_deg
+KLGB,medium_airport,Long Beach Airport,33.816523,-118.149891
+KSNA,large_airport,John Wayne Airport,33.675701,-117.867996
+`), nil)
+	requirer.NoError(loadErr)
+
+	tracker := &TrackBuilderEnsemble{
+		Builders: []builders.KmlTrackBuilder{&builders.PlacemarkBuilder{}},
+		Airports: airportsDb,
+	}
+	kmlTrack, generateErr := tracker.Generate(context.Background(), newMockTestAeroApiTrack())
+	requirer.NoError(generateErr)
+	requirer.Equal("KLGB", kmlTrack.Origin)
+	requirer.Equal("KSNA", kmlTrack.Destination)
+	requirer.Equal("KLGB-KSNA", kmlTrack.GetRouteName())
+	requirer.Contains(string(kmlTrack.KmlDoc), "<name>AeroAPI Flight KLGB-KSNA</name>")
+	requirer.Contains(string(kmlTrack.KmlDoc), "<name>KSNA</name>")
+
+	requirer.Empty((&Track{}).GetRouteName())
+	requirer.Equal("-KSNA", (&Track{Destination: "KSNA"}).GetRouteName())
+}
+
+func TestTrackBuilderEnsemble_Budget(t *testing.T) {
+
+	testCases := []struct {
+		name               string
+		maxFeatures        int
+		maxDocumentBytes   int
+		expectedPlacemarks bool
+		expectedVector     bool
+	}{
+		{
+			name:               "unlimited",
+			expectedPlacemarks: true,
+			expectedVector:     true,
+		},
+		{
+			name:               "features only for placemarks",
+			maxFeatures:        3,
+			expectedPlacemarks: true,
+		},
+		{
+			name:             "bytes for nothing",
+			maxDocumentBytes: 10,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			tracker := &TrackBuilderEnsemble{
+				Builders: []builders.KmlTrackBuilder{
+					&builders.PlacemarkBuilder{},
+					&builders.VectorBuilder{},
+				},
+				MaxFeatures:      tc.maxFeatures,
+				MaxDocumentBytes: tc.maxDocumentBytes,
+			}
+			kmlTrack, generateErr := tracker.Generate(context.Background(), newMockTestAeroApiTrack())
+			requirer.NoError(generateErr)
+			kmlDoc := string(kmlTrack.KmlDoc)
+			requirer.Equal(tc.expectedPlacemarks, strings.Contains(kmlDoc, "<name>Placemark Track</name>"))
+			requirer.Equal(tc.expectedVector, strings.Contains(kmlDoc, "<name>Vector Track</name>"))
+			if tc.expectedVector {
+				requirer.Contains(kmlDoc, "<Region>")
+				requirer.Contains(kmlDoc, "<styleUrl>#")
+				requirer.NotEmpty(kmlTrack.KmlAssets)
+			} else {
+				requirer.Empty(kmlTrack.KmlAssets)
+			}
+		})
+	}
+}
+
+func TestTrackBuilderEnsemble_RegionsAcrossAntimeridian(t *testing.T) {
+
+	// vectors depict each position but the last, whose longitude therefore doesn't affect the region
+	testCases := []struct {
+		name             string
+		longitudes       []float64
+		expectedWestEast string
+	}{
+		{
+			name:             "crossing eastbound",
+			longitudes:       []float64{179.8, 179.9, -179.9, -179.8, 0},
+			expectedWestEast: "<east>-179.799</east><west>179.799</west>",
+		},
+		{
+			name:             "crossing westbound",
+			longitudes:       []float64{-179.8, 179.9, 179.8, 0},
+			expectedWestEast: "<east>-179.799</east><west>179.799</west>",
+		},
+		{
+			name:             "not crossing",
+			longitudes:       []float64{-179.8, -179.9, -179.95, 0},
+			expectedWestEast: "<east>-179.799</east><west>-179.951</west>",
+		},
+		{
+			name:             "not crossing, though spanning more than half the globe",
+			longitudes:       []float64{-100, 0, 100, 0},
+			expectedWestEast: "<east>100.001</east><west>-100.001</west>",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			track := &aeroapi.Track{FlightId: "N12345-1"}
+			startTime := newMockTestAeroApiTrack().Positions[0].Timestamp
+			for i, longitude := range tc.longitudes {
+				track.Positions = append(track.Positions, aeroapi.Position{
+					AltMslD100: 350,
+					GsKnots:    450,
+					Heading:    90,
+					Latitude:   52,
+					Longitude:  longitude,
+					Timestamp:  startTime.Add(time.Duration(i) * time.Minute),
+				})
+			}
+			tracker := &TrackBuilderEnsemble{Builders: []builders.KmlTrackBuilder{&builders.VectorBuilder{}}}
+			kmlTrack, generateErr := tracker.Generate(context.Background(), track)
+			requirer.NoError(generateErr)
+			requirer.Contains(strings.Join(strings.Fields(string(kmlTrack.KmlDoc)), ""), tc.expectedWestEast)
+		})
+	}
+}
@@ -0,0 +1,150 @@
+package kml
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
+)
+
+// Statistics summarizes a flight, including the winds aloft estimated from its turning
+// segments and (if terrain elevation data is configured) its height above the terrain
+type Statistics struct {
+	Positions     int // number of positions reported
+	Duration      time.Duration
+	DistanceNm    float64 // along the track
+	MaxAltMslFeet float64
+	MaxGsKnots    float64 // as reported
+
+	Winds         int                   // number of winds estimated
+	MeanWind      *aeroapi.WindEstimate // vector average of the winds estimated, if any
+	StrongestWind *aeroapi.WindEstimate // if any were estimated
+
+	AglKnown     int               // number of positions whose height above the terrain is known
+	MinAgl       *aeroapi.Position // position flown lowest above the terrain, if any is known
+	MinAglFeet   float64           // height above the terrain of MinAgl
+	WarnAglFeet  float64           // height above the terrain below which flight is flagged
+	BelowWarnAgl time.Duration     // time spent below WarnAglFeet (between positions both below it)
+}
+
+// GetStatistics returns the statistics of the flight whose track is given by the positions
+func GetStatistics(positions []aeroapi.Position, elevation terrain.ElevationProvider, warnAglFeet float64) (*Statistics, error) {
+	stats := &Statistics{Positions: len(positions), WarnAglFeet: warnAglFeet}
+	if len(positions) == 0 {
+		return stats, nil
+	}
+	stats.Duration = positions[len(positions)-1].Timestamp.Sub(positions[0].Timestamp)
+
+	aeroapiMathUtil := &aeroapi.Math{}
+	var wasBelow bool
+	for i, position := range positions {
+		stats.MaxAltMslFeet = maxFloat(stats.MaxAltMslFeet, position.AltMslD100*100)
+		stats.MaxGsKnots = maxFloat(stats.MaxGsKnots, position.GsKnots)
+		if i > 0 {
+			elapsedHours := float64(position.Timestamp.Sub(positions[i-1].Timestamp)) / float64(time.Hour)
+			if elapsedHours > 0 {
+				stats.DistanceNm += aeroapiMathUtil.GetGeoGsKnots(positions[i-1], position) * elapsedHours
+			}
+		}
+
+		aglFeet, ok, getAglErr := terrain.GetAglFeet(elevation, position.Latitude, position.Longitude, position.AltMslD100*100)
+		if getAglErr != nil {
+			return nil, getAglErr
+		}
+		isBelow := ok && aglFeet < warnAglFeet
+		if isBelow && wasBelow {
+			stats.BelowWarnAgl += position.Timestamp.Sub(positions[i-1].Timestamp)
+		}
+		wasBelow = isBelow
+		if !ok {
+			continue
+		}
+		if stats.AglKnown == 0 || aglFeet < stats.MinAglFeet {
+			stats.MinAgl = &positions[i]
+			stats.MinAglFeet = aglFeet
+		}
+		stats.AglKnown++
+	}
+
+	estimates := aeroapiMathUtil.EstimateWinds(positions, 0, 0)
+	stats.Winds = len(estimates)
+	if mean, ok := aeroapiMathUtil.GetMeanWind(estimates); ok {
+		stats.MeanWind = &mean
+	}
+	for i := range estimates {
+		if stats.StrongestWind == nil || estimates[i].SpeedKnots > stats.StrongestWind.SpeedKnots {
+			stats.StrongestWind = &estimates[i]
+		}
+	}
+	return stats, nil
+}
+
+// String returns a one-line summary of the statistics
+func (s *Statistics) String() string {
+	items := []string{
+		s.Duration.Round(time.Second).String(),
+		fmt.Sprintf("%.1fnm", s.DistanceNm),
+		fmt.Sprintf("max %.0f' MSL", s.MaxAltMslFeet),
+		fmt.Sprintf("max %.0fkt", s.MaxGsKnots),
+		"winds: " + s.getWindSummary(),
+	}
+	if terrainSummary := s.getTerrainSummary(); terrainSummary != "" {
+		items = append(items, terrainSummary)
+	}
+	return strings.Join(items, "; ")
+}
+
+// Description returns the statistics as (HTML) text describing a KML element
+func (s *Statistics) Description() string {
+	items := []string{
+		fmt.Sprintf("Duration: %s", s.Duration.Round(time.Second)),
+		fmt.Sprintf("Distance: %.1fnm", s.DistanceNm),
+		fmt.Sprintf("Maximum altitude: %.0f' MSL", s.MaxAltMslFeet),
+		fmt.Sprintf("Maximum groundspeed: %.0fkt", s.MaxGsKnots),
+		fmt.Sprintf("Winds: %s", s.getWindSummary()),
+	}
+	if s.StrongestWind != nil {
+		items = append(items, fmt.Sprintf("Strongest wind: from %03.0fº at %.1fkt at %.0f' (%s)",
+			s.StrongestWind.FromDeg,
+			s.StrongestWind.SpeedKnots,
+			s.StrongestWind.Position.AltMslD100*100,
+			s.StrongestWind.Position.Timestamp.Format(time.RFC3339),
+		))
+	}
+	if terrainSummary := s.getTerrainSummary(); terrainSummary != "" {
+		items = append(items, fmt.Sprintf("Terrain: %s", terrainSummary))
+	}
+	return fmt.Sprintf("<h2>Statistics</h2><ul><li>%s</li></ul>", strings.Join(items, "</li><li>"))
+}
+
+func (s *Statistics) getWindSummary() string {
+	if s.MeanWind == nil {
+		return "none estimated"
+	}
+	return fmt.Sprintf("mean from %03.0fº at %.1fkt (%d estimate(s))", s.MeanWind.FromDeg, s.MeanWind.SpeedKnots, s.Winds)
+}
+
+// getTerrainSummary returns the summary of the flight's height above the terrain, or an
+// empty string if it isn't known (e.g., no terrain elevation data is configured)
+func (s *Statistics) getTerrainSummary() string {
+	if s.MinAgl == nil {
+		return ""
+	}
+	return fmt.Sprintf("lowest %.0f' AGL at %s; %s below %.0f' AGL (known for %d of %d positions)",
+		s.MinAglFeet,
+		s.MinAgl.Timestamp.Format(time.RFC3339),
+		s.BelowWarnAgl.Round(time.Second),
+		s.WarnAglFeet,
+		s.AglKnown,
+		s.Positions,
+	)
+}
+
+func maxFloat(a, b float64) float64 {
+	if a > b {
+		return a
+	}
+	return b
+}
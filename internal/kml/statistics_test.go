@@ -0,0 +1,103 @@
+package kml
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
+)
+
+func TestGetStatistics(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		terrain          terrain.ElevationProvider
+		expectedTerrain  bool
+		expectedBelowAgl time.Duration
+		expectedContains []string
+	}{
+		{
+			name:             "without terrain",
+			expectedContains: []string{"Winds: mean from 270º at 20.0kt", "Strongest wind: from 2"},
+		},
+		{
+			name:             "low over terrain",
+			terrain:          &flatTerrain{elevationMeters: 1000},
+			expectedTerrain:  true,
+			expectedBelowAgl: 470 * time.Second,
+			expectedContains: []string{"Terrain: lowest 1219' AGL", "7m50s below 1500' AGL (known for 48 of 48 positions)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			positions := newCirclingPositions(100, 270, 20, 30, 48)
+			statistics, statisticsErr := GetStatistics(positions, tc.terrain, 1500)
+			requirer.NoError(statisticsErr)
+			requirer.Equal(48, statistics.Positions)
+			requirer.Equal(470*time.Second, statistics.Duration)
+			requirer.InDelta(4500, statistics.MaxAltMslFeet, 0.1)
+			requirer.NotNil(statistics.MeanWind)
+			requirer.InDelta(270, float64(statistics.MeanWind.FromDeg), 3)
+			requirer.InDelta(20, statistics.MeanWind.SpeedKnots, 1)
+			requirer.Positive(statistics.Winds)
+			requirer.NotNil(statistics.StrongestWind)
+			// circling at 100kt for 470s covers about 13nm through the air, less over the ground
+			requirer.InDelta(13, statistics.DistanceNm, 2)
+			requirer.Equal(tc.expectedTerrain, statistics.MinAgl != nil)
+			requirer.Equal(tc.expectedBelowAgl, statistics.BelowWarnAgl)
+			for _, expected := range tc.expectedContains {
+				requirer.Contains(statistics.Description(), expected)
+			}
+			requirer.Contains(statistics.String(), "winds: mean from 270º")
+		})
+	}
+}
+
+// flatTerrain is terrain of the same elevation everywhere
+type flatTerrain struct {
+	elevationMeters float64
+}
+
+func (ft *flatTerrain) GetElevation(float64, float64) (float64, bool, error) {
+	return ft.elevationMeters, true, nil
+}
+
+// newCirclingPositions simulates a flight at a constant airspeed through a steady wind,
+// changing heading by "turnRate" degrees between each of "n" positions reported at 10s intervals
+func newCirclingPositions(tasKnots, windFromDeg, windKnots, turnRate float64, n int) []aeroapi.Position {
+	const interval = 10 * time.Second
+	toVector := func(direction, magnitude float64) (east, north float64) {
+		radians := direction * math.Pi / 180
+		return magnitude * math.Sin(radians), magnitude * math.Cos(radians)
+	}
+	windEast, windNorth := toVector(windFromDeg+180, windKnots)
+	lat, lon := 20.8, -156.3
+	var heading float64
+	var positions []aeroapi.Position
+	for i := 0; i < n; i++ {
+		airEast, airNorth := toVector(heading, tasKnots)
+		// (as does AeroAPI) the track over the ground is reported as the "heading"
+		track := math.Atan2(airEast+windEast, airNorth+windNorth) * 180 / math.Pi
+		positions = append(positions, aeroapi.Position{
+			AltMslD100: 45,
+			GsKnots:    math.Hypot(airEast+windEast, airNorth+windNorth),
+			Heading:    math.Mod(track+360, 360),
+			Latitude:   lat,
+			Longitude:  lon,
+			Timestamp:  time.Date(2023, 5, 23, 20, 0, 0, 0, time.UTC).Add(time.Duration(i) * interval),
+		})
+		// the track flown while turning steadily is (about) that of the heading half way through
+		airEast, airNorth = toVector(heading+turnRate/2, tasKnots)
+		hours := float64(interval) / float64(time.Hour)
+		lat += (airNorth + windNorth) * hours / 60
+		lon += (airEast + windEast) * hours / (60 * math.Cos(lat*math.Pi/180))
+		heading += turnRate
+	}
+	return positions
+}
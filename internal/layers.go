@@ -0,0 +1,157 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+	"github.com/noodnik2/flightvisualizer/pkg/terrain"
+)
+
+// layerSpec specifies a layer of the KML depiction, and the options of its builder,
+// such as "path(color=#00ff00,width=5)"
+type layerSpec struct {
+	Name    string
+	Options []layerOption
+}
+
+type layerOption struct {
+	Name  string
+	Value string
+}
+
+func (ls layerSpec) String() string {
+	if len(ls.Options) == 0 {
+		return ls.Name
+	}
+	options := make([]string, len(ls.Options))
+	for i, option := range ls.Options {
+		options[i] = option.Name + "=" + option.Value
+	}
+	return fmt.Sprintf("%s(%s)", ls.Name, strings.Join(options, ","))
+}
+
+// canonical returns the specification with its options in order of their names, so that
+// specifications configuring the layer the same way are the same
+func (ls layerSpec) canonical() string {
+	options := append([]layerOption(nil), ls.Options...)
+	sort.SliceStable(options, func(i, j int) bool { return options[i].Name < options[j].Name })
+	return layerSpec{Name: ls.Name, Options: options}.String()
+}
+
+// splitLayerSpecs splits a list of layer specifications such as "path(color=#00ff00,width=5),camera"
+// at the commas outside the parentheses enclosing their options
+func splitLayerSpecs(specs string) []string {
+	var items []string
+	var depth, start int
+	for i, c := range specs {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, specs[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(items, specs[start:])
+}
+
+// parseLayerSpec parses a layer specification such as "camera" or "camera(tilt=70,heightOffset=3)"
+func parseLayerSpec(item string) (layerSpec, error) {
+	item = strings.TrimSpace(item)
+	openAt := strings.Index(item, "(")
+	if openAt < 0 {
+		if strings.Contains(item, ")") {
+			return layerSpec{}, fmt.Errorf("invalid layer(%s); unbalanced parentheses", item)
+		}
+		return layerSpec{Name: item}, nil
+	}
+	if !strings.HasSuffix(item, ")") || strings.Count(item, "(") != 1 || strings.Count(item, ")") != 1 {
+		return layerSpec{}, fmt.Errorf("invalid layer(%s); unbalanced parentheses", item)
+	}
+	spec := layerSpec{Name: strings.TrimSpace(item[:openAt])}
+	optionsText := item[openAt+1 : len(item)-1]
+	if strings.TrimSpace(optionsText) == "" {
+		return spec, nil
+	}
+	for _, optionText := range strings.Split(optionsText, ",") {
+		name, value, found := strings.Cut(optionText, "=")
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if !found || name == "" {
+			return layerSpec{}, fmt.Errorf("invalid option(%s) of layer(%s); expected name=value", optionText, spec.Name)
+		}
+		spec.Options = append(spec.Options, layerOption{Name: name, Value: value})
+	}
+	return spec, nil
+}
+
+// newLayerBuilder returns the builder of the named (registered) layer, configured by the command's options
+func (tca TracksCommandArgs) newLayerBuilder(layer string, elevationProvider terrain.ElevationProvider) (builders.KmlTrackBuilder, error) {
+	registered, ok := builders.LookupLayer(layer)
+	if !ok {
+		return nil, fmt.Errorf("unrecognized kmlLayer(%s); supported: %v", layer, strings.Join(builders.LayerNames(), ","))
+	}
+	return registered.New(tca.layerSettings(elevationProvider)), nil
+}
+
+func (tca TracksCommandArgs) layerSettings(elevationProvider terrain.ElevationProvider) builders.Settings {
+	return builders.Settings{
+		Terrain:    elevationProvider,
+		NoBanking:  tca.NoBanking,
+		MinAglFeet: tca.MinAglFeet,
+		Debug:      tca.DebugOperation,
+	}
+}
+
+func isSupportedLayer(layer string) bool {
+	_, ok := builders.LookupLayer(layer)
+	return ok
+}
+
+// setLayerOptions sets the options of the layer's builder, each of which it validates
+func setLayerOptions(kmlBuilder builders.KmlTrackBuilder, spec layerSpec) error {
+	if len(spec.Options) == 0 {
+		return nil
+	}
+	configurable, ok := kmlBuilder.(builders.ConfigurableBuilder)
+	if !ok {
+		return fmt.Errorf("layer(%s) has no options", spec.Name)
+	}
+	for _, option := range spec.Options {
+		if setErr := configurable.SetOption(option.Name, option.Value); setErr != nil {
+			return fmt.Errorf("layer(%s): %w", spec.Name, setErr)
+		}
+	}
+	return nil
+}
+
+// validateLayerOptions returns an error unless the options are recognized by the named layer
+func validateLayerOptions(layer string, options map[string]string) error {
+	kmlBuilder, newBuilderErr := TracksCommandArgs{}.newLayerBuilder(layer, nil)
+	if newBuilderErr != nil {
+		return newBuilderErr
+	}
+	spec := layerSpec{Name: layer}
+	for name, value := range options {
+		spec.Options = append(spec.Options, layerOption{Name: name, Value: value})
+	}
+	sort.Slice(spec.Options, func(i, j int) bool { return spec.Options[i].Name < spec.Options[j].Name })
+	return setLayerOptions(kmlBuilder, spec)
+}
+
+// LayerOptionsUsage describes the options of the layers, with their defaults as configured
+// by the command's options (e.g., for help text)
+func (tca TracksCommandArgs) LayerOptionsUsage() string {
+	var usage strings.Builder
+	for _, layer := range builders.Layers() {
+		for _, option := range layer.Options(tca.layerSettings(nil)) {
+			fmt.Fprintf(&usage, "  %s(%s=%s): %s\n", layer.Name, option.Name, option.Value, option.Description)
+		}
+	}
+	return usage.String()
+}
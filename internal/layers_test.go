@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+)
+
+func TestSplitLayerSpecs(t *testing.T) {
+	requirer := require.New(t)
+	requirer.Equal([]string{"camera", "path", "vector"}, splitLayerSpecs("camera,path,vector"))
+	requirer.Equal(
+		[]string{"path(color=#00ff00,width=5,extrude=false)", "camera(tilt=70,heightOffset=3)"},
+		splitLayerSpecs("path(color=#00ff00,width=5,extrude=false),camera(tilt=70,heightOffset=3)"),
+	)
+}
+
+func TestParseLayerSpec(t *testing.T) {
+	testCases := []struct {
+		name           string
+		item           string
+		expectedSpec   layerSpec
+		expectedErrors []string
+	}{
+		{
+			name:         "name only",
+			item:         "path",
+			expectedSpec: layerSpec{Name: "path"},
+		},
+		{
+			name:         "empty options",
+			item:         "path()",
+			expectedSpec: layerSpec{Name: "path"},
+		},
+		{
+			name: "options",
+			item: " camera(tilt=70, heightOffset = 3)",
+			expectedSpec: layerSpec{Name: "camera", Options: []layerOption{
+				{Name: "tilt", Value: "70"},
+				{Name: "heightOffset", Value: "3"},
+			}},
+		},
+		{
+			name:           "unclosed",
+			item:           "path(width=5",
+			expectedErrors: []string{"invalid layer(path(width=5); unbalanced parentheses"},
+		},
+		{
+			name:           "unopened",
+			item:           "pathwidth=5)",
+			expectedErrors: []string{"unbalanced parentheses"},
+		},
+		{
+			name:           "no value",
+			item:           "path(width)",
+			expectedErrors: []string{"invalid option(width) of layer(path); expected name=value"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			spec, err := parseLayerSpec(tc.item)
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(err)
+			requirer.Equal(tc.expectedSpec, spec)
+		})
+	}
+}
+
+func TestTracksCommandArgs_NewKmlTrackGenerator_Options(t *testing.T) {
+	testCases := []struct {
+		name           string
+		layers         string
+		expectedName   string
+		expectedErrors []string
+	}{
+		{
+			name:         "defaults",
+			layers:       "path,camera",
+			expectedName: "camera-path",
+		},
+		{
+			name:         "options",
+			layers:       "path(color=#00ff00,width=5,extrude=false),camera(tilt=70,heightOffset=3)",
+			expectedName: "camera-path",
+		},
+		{
+			name:         "same options given twice",
+			layers:       "path(width=5),path(width=5)",
+			expectedName: "path",
+		},
+		{
+			name:         "same options given twice, in another order",
+			layers:       "path(width=5,color=#00ff00),path(color=#00ff00,width=5)",
+			expectedName: "path",
+		},
+		{
+			name:           "conflicting options",
+			layers:         "path(width=5),path",
+			expectedErrors: []string{"conflicting options of layer(path): path(width=5), path"},
+		},
+		{
+			name:           "unrecognized option",
+			layers:         "camera(zoom=2)",
+			expectedErrors: []string{"layer(camera): unrecognized option(zoom); expected one of {tilt, heightOffset}"},
+		},
+		{
+			name:           "invalid color",
+			layers:         "path(color=green)",
+			expectedErrors: []string{"layer(path): invalid color(green); expected #rrggbb"},
+		},
+		{
+			name:           "invalid flag",
+			layers:         "path(extrude=maybe)",
+			expectedErrors: []string{"layer(path): invalid extrude(maybe); expected true or false"},
+		},
+		{
+			name:           "layer without options",
+			layers:         "wind(speed=10)",
+			expectedErrors: []string{"layer(wind) has no options"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			generator, err := TracksCommandArgs{}.newKmlTrackGenerator(splitLayerSpecs(tc.layers))
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(err)
+			requirer.Equal(tc.expectedName, generator.Name)
+		})
+	}
+}
+
+func TestSetLayerOptions(t *testing.T) {
+	requirer := require.New(t)
+	kmlBuilder, err := TracksCommandArgs{}.newLayerBuilder("path", nil)
+	requirer.NoError(err)
+	pathBuilder := kmlBuilder.(*builders.PathBuilder)
+	requirer.Equal(builders.DefaultPathColor, pathBuilder.Color)
+	requirer.True(pathBuilder.Extrude)
+
+	spec, _ := parseLayerSpec("path(color=#00FF80,width=5,extrude=false)")
+	requirer.NoError(setLayerOptions(kmlBuilder, spec))
+	requirer.Equal(color.RGBA{R: 0, G: 255, B: 128, A: 255}, pathBuilder.Color)
+	requirer.Equal(5.0, pathBuilder.Width)
+	requirer.False(pathBuilder.Extrude)
+	requirer.Equal([]builders.Option{
+		{Name: "color", Description: "color of the path (#rrggbb)", Value: "#00ff80"},
+		{Name: "width", Description: "pixels wide the path is drawn", Value: "5"},
+		{Name: "extrude", Description: "whether the path is extruded to the ground", Value: "false"},
+	}, pathBuilder.Options())
+}
+
+func TestTracksCommandArgs_LayerOptionsUsage(t *testing.T) {
+	requirer := require.New(t)
+	usage := TracksCommandArgs{MinAglFeet: 500}.LayerOptionsUsage()
+	requirer.Contains(usage, "  camera(tilt=80): ")
+	requirer.Contains(usage, "  path(color=#d933ff): ")
+	requirer.Contains(usage, "  terrain(minAgl=500): ")
+	requirer.NotContains(usage, "wind")
+}
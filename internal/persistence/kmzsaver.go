@@ -14,6 +14,13 @@ type KmzSaver struct {
 }
 
 func (rs *KmzSaver) Save(fnFragment string, contents []byte) error {
+	_, err := rs.SaveAs(fnFragment, contents)
+	return err
+}
+
+// SaveAs saves the KMZ file, returning its name, which may differ from the one requested
+// if the underlying Saver is a persistence.NamingSaver
+func (rs *KmzSaver) SaveAs(fnFragment string, contents []byte) (string, error) {
 	files := make(map[string]any)
 	for assetKey, assetValue := range rs.Assets {
 		files[assetKey] = assetValue
@@ -22,8 +29,11 @@ func (rs *KmzSaver) Save(fnFragment string, contents []byte) error {
 
 	memoryWriter := &bytes.Buffer{}
 	if writeErr := gokml.WriteKMZ(memoryWriter, files); writeErr != nil {
-		return writeErr
+		return "", writeErr
 	}
 
-	return rs.Saver.Save(fnFragment, memoryWriter.Bytes())
+	if namingSaver, ok := rs.Saver.(persistence.NamingSaver); ok {
+		return namingSaver.SaveAs(fnFragment, memoryWriter.Bytes())
+	}
+	return fnFragment, rs.Saver.Save(fnFragment, memoryWriter.Bytes())
 }
@@ -0,0 +1,72 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+)
+
+// LayerPluginPrefix begins the names of the programs (in the configured plugins directory) implementing
+// layers; e.g., "fviz-layer-contrail" (or "fviz-layer-contrail.py") implements the "contrail" layer
+const LayerPluginPrefix = "fviz-layer-"
+
+// RegisterLayerPlugins registers the layers implemented by the programs found in the configured plugins
+// directory (if any); programs not named as valid layers, or as those already registered, are ignored
+func (c Config) RegisterLayerPlugins() error {
+	if c.PluginsDir == "" {
+		return nil
+	}
+	dir, absErr := filepath.Abs(c.PluginsDir)
+	if absErr != nil {
+		return absErr
+	}
+	entries, readDirErr := os.ReadDir(dir)
+	if readDirErr != nil {
+		return fmt.Errorf("couldn't read plugins directory(%s): %w", c.PluginsDir, readDirErr)
+	}
+	timeout := time.Duration(c.PluginTimeoutSecs) * time.Second
+	for _, entry := range entries {
+		filename := entry.Name()
+		if !strings.HasPrefix(filename, LayerPluginPrefix) || !isExecutableFile(entry) {
+			continue
+		}
+		layerName := strings.TrimPrefix(filename, LayerPluginPrefix)
+		layerName = strings.TrimSuffix(layerName, filepath.Ext(layerName))
+		if !builders.IsValidLayerName(layerName) {
+			log.Printf("NOTE: ignoring plugin(%s); invalid layer name(%s)\n", filename, layerName)
+			continue
+		}
+		if _, registered := builders.LookupLayer(layerName); registered {
+			log.Printf("NOTE: ignoring plugin(%s); layer(%s) is already registered\n", filename, layerName)
+			continue
+		}
+		command := filepath.Join(dir, filename)
+		builders.RegisterLayer(builders.Layer{
+			Name:        layerName,
+			Description: fmt.Sprintf("Plugin(%s)", command),
+			New: func(builders.Settings) builders.KmlTrackBuilder {
+				return &builders.PluginBuilder{
+					LayerName: layerName,
+					Command:   command,
+					Timeout:   timeout,
+				}
+			},
+		})
+	}
+	return nil
+}
+
+func isExecutableFile(entry os.DirEntry) bool {
+	info, infoErr := entry.Info()
+	if infoErr != nil || !info.Mode().IsRegular() {
+		return false
+	}
+	// on Windows, programs are recognized by their extensions (e.g., ".exe") instead
+	return runtime.GOOS == "windows" || info.Mode().Perm()&0111 != 0
+}
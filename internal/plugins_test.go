@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/internal/kml"
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// writePlugin writes a (shell script) plugin program into the directory, returning its name
+func writePlugin(t *testing.T, dir, filename, script string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("plugin scripts require a POSIX shell")
+	}
+	command := filepath.Join(dir, filename)
+	require.NoError(t, os.WriteFile(command, []byte("#!/bin/sh\n"+script), 0755))
+	return command
+}
+
+func TestPluginBuilder_BuildFlight(t *testing.T) {
+	testCases := []struct {
+		name           string
+		script         string
+		timeout        time.Duration
+		cancelAfter    time.Duration
+		expectedKml    string
+		expectedErrors []string
+	}{
+		{
+			name: "valid",
+			script: `cat > "$(dirname "$0")/request.json"
+printf '%s' '{"kml": "<?xml version=\"1.0\"?>\n<Folder><name>Contrail</name><gx:Tour/></Folder>", "assets": {"images/dot.png": "aGk="}}'`,
+			expectedKml: "<Folder><name>Contrail</name><gx:Tour></gx:Tour></Folder>",
+		},
+		{
+			name:           "failure",
+			script:         "echo 'invalid width(x)' >&2\nexit 3",
+			expectedErrors: []string{"failed: exit status 3: invalid width(x)"},
+		},
+		{
+			name:           "timeout",
+			script:         "exec sleep 5",
+			timeout:        100 * time.Millisecond,
+			expectedErrors: []string{"timed out after 100ms"},
+		},
+		{
+			name:           "cancelled",
+			script:         "exec sleep 5",
+			cancelAfter:    100 * time.Millisecond,
+			expectedErrors: []string{"was stopped: context canceled"},
+		},
+		{
+			name:           "not JSON",
+			script:         "echo '<Folder/>'",
+			expectedErrors: []string{"wrote an invalid response"},
+		},
+		{
+			name:           "unclosed element",
+			script:         `echo '{"kml": "<Folder><name>Contrail</name>"}'`,
+			expectedErrors: []string{"wrote invalid KML: unclosed element(Folder)"},
+		},
+		{
+			name:           "mismatched element",
+			script:         `echo '{"kml": "<Folder><name>Contrail</Folder></name>"}'`,
+			expectedErrors: []string{"wrote invalid KML: unexpected end element(Folder)"},
+		},
+		{
+			name:           "two roots",
+			script:         `echo '{"kml": "<Folder/><Folder/>"}'`,
+			expectedErrors: []string{"expected one root element; found 2"},
+		},
+		{
+			name:           "no KML",
+			script:         `echo '{}'`,
+			expectedErrors: []string{"expected one root element; found 0"},
+		},
+		{
+			name:           "escaping asset",
+			script:         `echo '{"kml": "<Folder/>", "assets": {"../dot.png": "aGk="}}'`,
+			expectedErrors: []string{"wrote an invalid asset name(../dot.png)"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			dir := t.TempDir()
+			pluginBuilder := &builders.PluginBuilder{
+				LayerName: "contrail",
+				Command:   writePlugin(t, dir, "fviz-layer-contrail", tc.script),
+				Timeout:   tc.timeout,
+			}
+			requirer.NoError(pluginBuilder.SetOption("width", "7"))
+
+			startTime := time.Date(2023, 5, 23, 20, 0, 0, 0, time.UTC)
+			flight := builders.Flight{FlightId: "N335SP-1", Origin: "PHOG", StartTime: &startTime}
+			positions := []aeroapi.Position{{Latitude: 20.9, Longitude: -156.4, AltMslD100: 10, Timestamp: startTime}}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelAfter > 0 {
+				time.AfterFunc(tc.cancelAfter, cancel)
+			}
+			product, err := pluginBuilder.BuildFlight(ctx, flight, positions)
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(err)
+
+			var encoded bytes.Buffer
+			requirer.NoError(xml.NewEncoder(&encoded).Encode(product.Root))
+			requirer.Equal(tc.expectedKml, encoded.String())
+			requirer.Equal(map[string]any{"images/dot.png": []byte("hi")}, product.Assets)
+
+			contents, readErr := os.ReadFile(filepath.Join(dir, "request.json"))
+			requirer.NoError(readErr)
+			var request builders.PluginRequest
+			requirer.NoError(json.Unmarshal(contents, &request))
+			requirer.Equal(builders.PluginRequest{
+				Layer:     "contrail",
+				Options:   map[string]string{"width": "7"},
+				Flight:    flight,
+				Positions: positions,
+			}, request)
+		})
+	}
+}
+
+func TestTrackBuilderEnsemble_PluginFailure(t *testing.T) {
+	requirer := require.New(t)
+	dir := t.TempDir()
+	generator := &kml.TrackBuilderEnsemble{
+		Builders: []builders.KmlTrackBuilder{
+			&builders.PathBuilder{Color: builders.DefaultPathColor, Width: builders.DefaultPathWidth},
+			&builders.PluginBuilder{LayerName: "contrail", Command: writePlugin(t, dir, "fviz-layer-contrail", "exit 3")},
+		},
+	}
+	startTime := time.Date(2023, 5, 23, 20, 0, 0, 0, time.UTC)
+	track := &aeroapi.Track{
+		FlightId:  "N335SP-1",
+		Positions: []aeroapi.Position{{Latitude: 20.9, Longitude: -156.4, AltMslD100: 10, Timestamp: startTime}},
+	}
+
+	// the track isn't generated without one of its layers
+	_, generateErr := generator.Generate(context.Background(), track)
+	requirer.ErrorContains(generateErr, "can't generate KML track for flightId(N335SP-1); contrail layer: plugin(")
+	requirer.ErrorContains(generateErr, "failed: exit status 3")
+	var pluginErr *builders.PluginError
+	requirer.ErrorAs(generateErr, &pluginErr)
+
+	// whereas a built-in layer which can't be built (e.g., for lack of terrain elevation data) is omitted
+	generator.Builders[1] = &builders.TerrainBuilder{}
+	kmlTrack, generateErr := generator.Generate(context.Background(), track)
+	requirer.NoError(generateErr)
+	requirer.Contains(string(kmlTrack.KmlDoc), "<name>Path Track</name>")
+	requirer.NotContains(string(kmlTrack.KmlDoc), "<name>Terrain Clearance</name>")
+}
+
+func TestConfig_RegisterLayerPlugins(t *testing.T) {
+	requirer := require.New(t)
+	dir := t.TempDir()
+
+	// layers are registered once (per process), so are named uniquely for each run of the test
+	layerName := fmt.Sprintf("discovered%d", time.Now().UnixNano())
+	writePlugin(t, dir, LayerPluginPrefix+layerName+".py", `echo '{"kml": "<Folder/>"}'`)
+	writePlugin(t, dir, LayerPluginPrefix+"path", "exit 1")
+	writePlugin(t, dir, LayerPluginPrefix+"in-valid", "exit 1")
+	requirer.NoError(os.WriteFile(filepath.Join(dir, LayerPluginPrefix+"readme"), []byte("not executable"), 0644))
+
+	requirer.NoError(Config{PluginsDir: dir, PluginTimeoutSecs: 5}.RegisterLayerPlugins())
+
+	layer, ok := builders.LookupLayer(layerName)
+	requirer.True(ok)
+	requirer.False(layer.Default)
+	_, ok = builders.LookupLayer("readme")
+	requirer.False(ok)
+	pathLayer, _ := builders.LookupLayer("path")
+	_, isPlugin := pathLayer.New(builders.Settings{}).(*builders.PluginBuilder)
+	requirer.False(isPlugin)
+
+	generator, err := TracksCommandArgs{}.newKmlTrackGenerator([]string{layerName + "(width=7)"})
+	requirer.NoError(err)
+	requirer.Equal(layerName, generator.Name)
+	pluginBuilder := generator.Builders[0].(*builders.PluginBuilder)
+	requirer.Equal(filepath.Join(dir, LayerPluginPrefix+layerName+".py"), pluginBuilder.Command)
+	requirer.Equal(5*time.Second, pluginBuilder.Timeout)
+	requirer.Equal(map[string]string{"width": "7"}, pluginBuilder.Values)
+
+	requirer.ErrorContains(Config{PluginsDir: filepath.Join(dir, "missing")}.RegisterLayerPlugins(), "couldn't read plugins directory")
+}
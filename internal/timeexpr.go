@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// relativeTimeRegexp matches relative time expressions such as "-2d", "+90m" or "-1w"
+var relativeTimeRegexp = regexp.MustCompile(`^([+-])(\d+)([mhdw])$`)
+
+var relativeTimeUnits = map[string]time.Duration{
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// formats of absolute times lacking a time zone, which are interpreted in the location given
+var localTimeFormats = []string{
+	time.DateOnly,
+	"2006-01-02T15:04",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	time.DateTime,
+}
+
+// ParseTimeExpression parses the time denoted by the expression, which may be
+//   - an RFC3339 time (e.g., "2023-05-18T20:40:00-04:00")
+//   - a date, optionally with a time of day (e.g., "2023-05-18" or "2023-05-18 20:40")
+//   - "now", or a time relative to it (e.g., "-2d", "-36h", "+90m" or "-1w")
+//   - "today", "yesterday" or "tomorrow", optionally with a time of day (e.g., "today 08:00")
+//
+// Times lacking a time zone are interpreted in the location given, unless followed by "utc"
+// (e.g., "today 08:00 utc"); "local" (e.g., "today 08:00 local") affirms the location given.
+// Dates lacking a time of day denote the start of the day.
+func ParseTimeExpression(expression string, now time.Time, location *time.Location) (time.Time, error) {
+	words := strings.Fields(strings.ToLower(expression))
+	if len(words) == 0 {
+		return time.Time{}, fmt.Errorf("empty time expression")
+	}
+
+	// a trailing zone word selects the location in which the expression is interpreted
+	switch words[len(words)-1] {
+	case "local":
+		words = words[:len(words)-1]
+	case "utc", "z":
+		location = time.UTC
+		words = words[:len(words)-1]
+	}
+	if len(words) == 0 || len(words) > 2 {
+		return time.Time{}, fmt.Errorf("unrecognized time expression(%s)", expression)
+	}
+	now = now.In(location)
+
+	if len(words) == 1 {
+		if words[0] == "now" {
+			return now, nil
+		}
+		if matches := relativeTimeRegexp.FindStringSubmatch(words[0]); matches != nil {
+			n, _ := strconv.Atoi(matches[2])
+			offset := time.Duration(n) * relativeTimeUnits[matches[3]]
+			if matches[1] == "-" {
+				offset = -offset
+			}
+			return now.Add(offset), nil
+		}
+		if t, parseErr := time.Parse(time.RFC3339, strings.ToUpper(words[0])); parseErr == nil {
+			return t, nil
+		}
+	}
+
+	var day time.Time
+	switch words[0] {
+	case "today":
+		day = now
+	case "yesterday":
+		day = now.AddDate(0, 0, -1)
+	case "tomorrow":
+		day = now.AddDate(0, 0, 1)
+	default:
+		for _, format := range localTimeFormats {
+			if t, parseErr := time.ParseInLocation(format, strings.ToUpper(strings.Join(words, " ")), location); parseErr == nil {
+				return t, nil
+			}
+		}
+		return time.Time{}, fmt.Errorf("unrecognized time expression(%s)", expression)
+	}
+
+	var clock time.Time
+	if len(words) == 2 {
+		var parseErr error
+		if clock, parseErr = parseClock(words[1]); parseErr != nil {
+			return time.Time{}, fmt.Errorf("unrecognized time of day in expression(%s)", expression)
+		}
+	}
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, location), nil
+}
+
+// parseClock parses a time of day such as "08:00" or "08:00:30"
+func parseClock(clock string) (time.Time, error) {
+	for _, format := range []string{"15:04", "15:04:05"} {
+		if t, parseErr := time.Parse(format, clock); parseErr == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized time of day(%s)", clock)
+}
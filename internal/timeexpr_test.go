@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTimeExpression(t *testing.T) {
+
+	honolulu, loadErr := time.LoadLocation("Pacific/Honolulu")
+	require.NoError(t, loadErr)
+	// 2023-05-23T02:30:00Z is still the 22nd in Honolulu
+	now := time.Date(2023, 5, 23, 2, 30, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name           string
+		expression     string
+		location       *time.Location
+		expectedTime   time.Time
+		expectedErrors []string
+	}{
+		{
+			name:         "RFC3339",
+			expression:   "2023-05-18T20:40:00-04:00",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 19, 0, 40, 0, 0, time.UTC),
+		},
+		{
+			name:         "date in location",
+			expression:   "2023-05-18",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 18, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "date and time in location",
+			expression:   "2023-05-18T08:15",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 18, 18, 15, 0, 0, time.UTC),
+		},
+		{
+			name:         "date and time in UTC",
+			expression:   "2023-05-18 08:15 utc",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 18, 8, 15, 0, 0, time.UTC),
+		},
+		{
+			name:         "now",
+			expression:   "now",
+			location:     honolulu,
+			expectedTime: now,
+		},
+		{
+			name:         "days ago",
+			expression:   "-2d",
+			location:     honolulu,
+			expectedTime: now.Add(-48 * time.Hour),
+		},
+		{
+			name:         "minutes ahead",
+			expression:   "+90m",
+			location:     time.UTC,
+			expectedTime: now.Add(90 * time.Minute),
+		},
+		{
+			name:         "today in location",
+			expression:   "today",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 22, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "yesterday in UTC",
+			expression:   "yesterday",
+			location:     time.UTC,
+			expectedTime: time.Date(2023, 5, 22, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "today at local time",
+			expression:   "today 08:00 local",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 22, 18, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "tomorrow at UTC time",
+			expression:   "Tomorrow 08:00:30 UTC",
+			location:     honolulu,
+			expectedTime: time.Date(2023, 5, 24, 8, 0, 30, 0, time.UTC),
+		},
+		{
+			name:           "bad time of day",
+			expression:     "today 25:00",
+			location:       time.UTC,
+			expectedErrors: []string{"unrecognized time of day in expression(today 25:00)"},
+		},
+		{
+			name:           "unrecognized",
+			expression:     "last tuesday",
+			location:       time.UTC,
+			expectedErrors: []string{"unrecognized time expression(last tuesday)"},
+		},
+		{
+			name:           "empty",
+			expression:     " ",
+			location:       time.UTC,
+			expectedErrors: []string{"empty time expression"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			parsedTime, parseErr := ParseTimeExpression(tc.expression, now, tc.location)
+			if tc.expectedErrors != nil {
+				requirer.Error(parseErr)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(parseErr.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(parseErr)
+			requirer.True(tc.expectedTime.Equal(parsedTime), "expected %s, got %s", tc.expectedTime, parsedTime)
+		})
+	}
+}
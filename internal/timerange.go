@@ -0,0 +1,40 @@
+package internal
+
+import (
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+// Sources of the flight(s) considered which make any time range given moot
+const (
+	TimeRangeOverriddenByNone         = ""
+	TimeRangeOverriddenByArtifacts    = "artifacts"     // the saved track artifact(s) are of known flight(s)
+	TimeRangeOverriddenByFlightNumber = "flight number" // the flight number identifies the flight
+	TimeRangeOverriddenByDate         = "date"          // the date selects the flight(s) matching the ident
+)
+
+// GetIgnoredTimeRangeOptions returns the names of the options (TimeRangeOptions) giving the time range
+// which are ignored, along with what determines the flight(s) considered instead (if any are ignored)
+func (tca TracksCommandArgs) GetIgnoredTimeRangeOptions() ([]string, string) {
+	override := tca.getTimeRangeOverride()
+	if override == TimeRangeOverriddenByNone {
+		return nil, override
+	}
+	return tca.TimeRangeOptions, override
+}
+
+// getTimeRangeOverride returns what, if anything, determines the flight(s) considered such that the
+// time range (TimeRange) given is ignored
+func (tca TracksCommandArgs) getTimeRangeOverride() string {
+	if tca.TimeRange.IsZero() {
+		return TimeRangeOverriddenByNone
+	}
+	switch {
+	case tca.FromArtifacts != "" && (aeroapi.IsTrackArtifactFilename(tca.FromArtifacts) || IsArtifactsBatch(tca.FromArtifacts)):
+		return TimeRangeOverriddenByArtifacts
+	case tca.FromArtifacts == "" && tca.FlightNumber != "":
+		return TimeRangeOverriddenByFlightNumber
+	case tca.FromArtifacts == "" && tca.Ident != "" && !tca.FlightDate.IsZero():
+		return TimeRangeOverriddenByDate
+	}
+	return TimeRangeOverriddenByNone
+}
@@ -0,0 +1,84 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+func TestTracksCommandArgs_GetIgnoredTimeRangeOptions(t *testing.T) {
+
+	timeRange := aeroapi.TimeRange{Start: time.Date(2023, 5, 18, 0, 0, 0, 0, time.UTC)}
+	flightDate := time.Date(2023, 5, 23, 0, 0, 0, 0, time.UTC)
+
+	testCases := []struct {
+		name             string
+		tca              TracksCommandArgs
+		expectedIgnored  []string
+		expectedOverride string
+	}{
+		{
+			name:             "no time range",
+			tca:              TracksCommandArgs{FlightNumber: "N12345-1"},
+			expectedOverride: TimeRangeOverriddenByNone,
+		},
+		{
+			name:             "tail number",
+			tca:              TracksCommandArgs{TailNumber: "N12345", TimeRange: timeRange},
+			expectedOverride: TimeRangeOverriddenByNone,
+		},
+		{
+			name:             "track artifact",
+			tca:              TracksCommandArgs{FromArtifacts: aeroapi.MakeTrackArtifactFilename("N12345-1"), TimeRange: timeRange, TimeRangeOptions: []string{"start"}},
+			expectedIgnored:  []string{"start"},
+			expectedOverride: TimeRangeOverriddenByArtifacts,
+		},
+		{
+			name:             "batch of track artifacts",
+			tca:              TracksCommandArgs{FromArtifacts: filepath.Join(t.TempDir(), "fvt_*"), TimeRange: timeRange, TimeRangeOptions: []string{"cutoffTime"}},
+			expectedIgnored:  []string{"cutoffTime"},
+			expectedOverride: TimeRangeOverriddenByArtifacts,
+		},
+		{
+			name:             "flight ids artifact",
+			tca:              TracksCommandArgs{FromArtifacts: aeroapi.MakeFlightIdsArtifactFilename("N12345"), FlightNumber: "N12345-1", TimeRange: timeRange},
+			expectedOverride: TimeRangeOverriddenByNone,
+		},
+		{
+			name:             "flight number",
+			tca:              TracksCommandArgs{FlightNumber: "N12345-1", TimeRange: timeRange, TimeRangeOptions: []string{"start", "end"}},
+			expectedIgnored:  []string{"start", "end"},
+			expectedOverride: TimeRangeOverriddenByFlightNumber,
+		},
+		{
+			name:             "flight number and ident with date",
+			tca:              TracksCommandArgs{FlightNumber: "N12345-1", Ident: "UAL123", FlightDate: flightDate, TimeRange: timeRange, TimeRangeOptions: []string{"end"}},
+			expectedIgnored:  []string{"end"},
+			expectedOverride: TimeRangeOverriddenByFlightNumber,
+		},
+		{
+			name:             "ident with date",
+			tca:              TracksCommandArgs{Ident: "UAL123", FlightDate: flightDate, TimeRange: timeRange, TimeRangeOptions: []string{"start"}},
+			expectedIgnored:  []string{"start"},
+			expectedOverride: TimeRangeOverriddenByDate,
+		},
+		{
+			name:             "ident without date",
+			tca:              TracksCommandArgs{Ident: "UAL123", TimeRange: timeRange},
+			expectedOverride: TimeRangeOverriddenByNone,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			ignored, override := tc.tca.GetIgnoredTimeRangeOptions()
+			requirer.Equal(tc.expectedIgnored, ignored)
+			requirer.Equal(tc.expectedOverride, override)
+		})
+	}
+}
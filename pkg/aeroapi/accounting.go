@@ -0,0 +1,283 @@
+package aeroapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// Classes of AeroAPI endpoints, which are priced differently
+const (
+	EndpointClassFlights = "flights"
+	EndpointClassTrack   = "track"
+	EndpointClassOther   = "other"
+)
+
+// PriceTable maps endpoint classes to the estimated cost (in USD) of each request made to them
+type PriceTable map[string]float64
+
+// DefaultPriceTable holds the estimated cost of requests, per AeroAPI's published "personal" pricing
+// See https://flightaware.com/aeroapi/portal/pricing
+var DefaultPriceTable = PriceTable{
+	EndpointClassFlights: 0.005,
+	EndpointClassTrack:   0.012,
+	EndpointClassOther:   0.005,
+}
+
+// ParsePriceTable parses a specification such as "flights=0.005,track=0.012" into a PriceTable
+// overriding the DefaultPriceTable for the endpoint class(es) specified
+func ParsePriceTable(spec string) (PriceTable, error) {
+	prices := make(PriceTable)
+	for class, price := range DefaultPriceTable {
+		prices[class] = price
+	}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		class, priceString, found := strings.Cut(item, "=")
+		if !found {
+			return nil, fmt.Errorf("price(%s) isn't in the form 'class=price'", item)
+		}
+		class = strings.TrimSpace(class)
+		if _, known := DefaultPriceTable[class]; !known {
+			return nil, fmt.Errorf("unrecognized endpoint class(%s) in price(%s)", class, item)
+		}
+		price, parseErr := strconv.ParseFloat(strings.TrimSpace(priceString), 64)
+		if parseErr != nil || price < 0 {
+			return nil, fmt.Errorf("invalid price in (%s)", item)
+		}
+		prices[class] = price
+	}
+	return prices, nil
+}
+
+// GetEndpointClass returns the class of the AeroAPI endpoint having the given (URL) path
+func GetEndpointClass(path string) string {
+	switch {
+	case strings.HasSuffix(path, "/track"):
+		return EndpointClassTrack
+	case strings.Contains(path, "/flights/"):
+		return EndpointClassFlights
+	}
+	return EndpointClassOther
+}
+
+// BudgetExceededError indicates that a request was refused since its cost would exceed the budget
+type BudgetExceededError struct {
+	Budget   string  // name of the budget (e.g., "per run")
+	Limit    float64 // maximum cost allowed
+	Expected float64 // cost had the request been made
+}
+
+// NonRetryable marks the error as one which retrying the request wouldn't resolve
+func (e *BudgetExceededError) NonRetryable() {}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("request refused since the estimated cost($%.3f) would exceed the %s budget($%.3f)",
+		e.Expected, e.Budget, e.Limit)
+}
+
+// Usage counts the requests made to each class of endpoint, along with their estimated cost
+type Usage struct {
+	Requests map[string]int `json:"requests"`
+	Cost     float64        `json:"cost"`
+	// UnknownLists counts the flights lists requested in a "dry run", whose (unknown) count
+	// of flights determines the further (e.g., track) requests which would follow them
+	UnknownLists int `json:"-"`
+}
+
+func (u *Usage) add(class string, n int, cost float64) {
+	if u.Requests == nil {
+		u.Requests = make(map[string]int)
+	}
+	u.Requests[class] += n
+	if u.Requests[class] == 0 {
+		delete(u.Requests, class)
+	}
+	u.Cost += cost
+}
+
+func (u Usage) String() string {
+	var classes []string
+	for class := range u.Requests {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+	var counts []string
+	for _, class := range classes {
+		counts = append(counts, fmt.Sprintf("%d %s", u.Requests[class], class))
+	}
+	if counts == nil {
+		counts = []string{"no"}
+	}
+	summary := fmt.Sprintf("%s request(s) costing an estimated $%.3f", strings.Join(counts, ", "), u.Cost)
+	if u.UnknownLists > 0 {
+		summary += fmt.Sprintf(", plus those following %d flights list(s) of unknown count", u.UnknownLists)
+	}
+	return summary
+}
+
+// AccountingTransport is an http.RoundTripper which counts the (successful, and therefore billed)
+// AeroAPI requests made through it and estimates their cost, refusing requests which would cause
+// the cost of the run to exceed MaxCost, or that of the month (including any recorded in the
+// MonthToDate usage) to exceed MonthlyBudget.  If DryRun is set, the requests are logged but not
+// made, and an empty response is returned in their place; since the empty response to a request
+// for a flights list lists no flights, that list is marked as being of unknown count (the requests
+// for the tracks of its flights can't be planned).
+type AccountingTransport struct {
+	Transport     http.RoundTripper // underlying transport (nil=http.DefaultTransport)
+	Prices        PriceTable        // nil=DefaultPriceTable
+	MaxCost       float64           // maximum cost of the requests made through the transport (0=unlimited)
+	MonthlyBudget float64           // maximum cost of the month's requests (0=unlimited)
+	MonthToDate   Usage             // usage recorded earlier in the month
+	DryRun        bool
+	mu            sync.Mutex
+	usage         Usage
+}
+
+func (at *AccountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	class := GetEndpointClass(req.URL.Path)
+	prices := at.Prices
+	if prices == nil {
+		prices = DefaultPriceTable
+	}
+	price := prices[class]
+
+	// reserve the cost of the request, so that concurrent requests can't together exceed the budget
+	if reserveErr := at.reserve(class, price); reserveErr != nil {
+		return nil, reserveErr
+	}
+
+	if at.DryRun {
+		log.Printf("DRY RUN: would request %s %s (%s, $%.3f)\n", req.Method, req.URL, class, price)
+		if class == EndpointClassFlights && !strings.HasSuffix(req.URL.Path, "/position") {
+			log.Printf("DRY RUN: flights list: unknown count; the requests for the tracks of its flights aren't planned\n")
+			at.mu.Lock()
+			at.usage.UnknownLists++
+			at.mu.Unlock()
+		}
+		return &http.Response{
+			Status:     "200 OK (dry run)",
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(bytes.NewReader([]byte("{}"))),
+			Request:    req,
+		}, nil
+	}
+
+	transport := at.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, roundTripErr := transport.RoundTrip(req)
+	if roundTripErr != nil || resp.StatusCode >= http.StatusBadRequest {
+		// unsuccessful requests aren't billed
+		at.mu.Lock()
+		at.usage.add(class, -1, -price)
+		at.mu.Unlock()
+	}
+	return resp, roundTripErr
+}
+
+// Usage returns the requests made through the transport so far, along with their estimated cost
+func (at *AccountingTransport) Usage() Usage {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	usage := Usage{Cost: at.usage.Cost, Requests: make(map[string]int), UnknownLists: at.usage.UnknownLists}
+	for class, n := range at.usage.Requests {
+		usage.Requests[class] = n
+	}
+	return usage
+}
+
+func (at *AccountingTransport) reserve(class string, price float64) error {
+	at.mu.Lock()
+	defer at.mu.Unlock()
+	// allow for the imprecision of summing (e.g., decimal) prices
+	const epsilon = 1e-9
+	runCost := at.usage.Cost + price
+	if at.MaxCost > 0 && runCost > at.MaxCost+epsilon {
+		return &BudgetExceededError{Budget: "per run", Limit: at.MaxCost, Expected: runCost}
+	}
+	if monthCost := at.MonthToDate.Cost + runCost; at.MonthlyBudget > 0 && monthCost > at.MonthlyBudget+epsilon {
+		return &BudgetExceededError{Budget: "monthly", Limit: at.MonthlyBudget, Expected: monthCost}
+	}
+	at.usage.add(class, 1, price)
+	return nil
+}
+
+// Ledger is a file recording the usage of AeroAPI in each month
+type Ledger struct {
+	Filename string
+}
+
+const (
+	// ledgerLockSuffix is added to the name of the ledger to name the file held (exclusively) by the
+	// process recording its usage, so that concurrent runs of fviz don't lose each other's usage
+	ledgerLockSuffix = ".lock"
+	// ledgerLockTimeout is how long the lock on the ledger may be held before it's presumed abandoned
+	ledgerLockTimeout = 10 * time.Second
+)
+
+// GetLedgerMonth returns the key of the ledger's entry for the month containing the time
+func GetLedgerMonth(t time.Time) string {
+	return t.UTC().Format("2006-01")
+}
+
+// Load returns the usage recorded in the ledger, by month
+func (l *Ledger) Load() (map[string]Usage, error) {
+	months := make(map[string]Usage)
+	ledgerBytes, readErr := os.ReadFile(l.Filename)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		return months, nil
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	if unmarshalErr := json.Unmarshal(ledgerBytes, &months); unmarshalErr != nil {
+		return nil, fmt.Errorf("couldn't read ledger(%s): %w", l.Filename, unmarshalErr)
+	}
+	return months, nil
+}
+
+// Record adds the usage to that recorded in the ledger for the given month, returning the updated total
+func (l *Ledger) Record(month string, usage Usage) (Usage, error) {
+	unlock, lockErr := persistence.LockFile(l.Filename+ledgerLockSuffix, ledgerLockTimeout)
+	if lockErr != nil {
+		return Usage{}, lockErr
+	}
+	defer unlock()
+
+	months, loadErr := l.Load()
+	if loadErr != nil {
+		return Usage{}, loadErr
+	}
+	monthUsage := months[month]
+	for class, n := range usage.Requests {
+		monthUsage.add(class, n, 0)
+	}
+	monthUsage.Cost += usage.Cost
+	months[month] = monthUsage
+
+	ledgerBytes, marshalErr := json.MarshalIndent(months, "", "  ")
+	if marshalErr != nil {
+		return Usage{}, marshalErr
+	}
+	// replace the ledger atomically, so that an interrupted write can't lose the usage recorded earlier
+	return monthUsage, persistence.WriteFileAtomically(l.Filename, ledgerBytes, 0644)
+}
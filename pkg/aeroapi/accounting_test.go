@@ -0,0 +1,163 @@
+package aeroapi
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountingTransport(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		transport        *AccountingTransport
+		status           int
+		endpoints        []string
+		expectedRequests map[string]int
+		expectedServed   int
+		expectedUnknown  int
+		expectedErr      string
+	}{
+		{
+			name:             "counts by endpoint class",
+			endpoints:        []string{"/flights/N12345", "/flights/N12345-1/track", "/flights/N12345-2/track"},
+			expectedRequests: map[string]int{EndpointClassFlights: 1, EndpointClassTrack: 2},
+			expectedServed:   3,
+		},
+		{
+			name:             "doesn't count unsuccessful requests",
+			status:           http.StatusNotFound,
+			endpoints:        []string{"/flights/N12345"},
+			expectedRequests: map[string]int{},
+			expectedServed:   1,
+		},
+		{
+			name:             "refuses to exceed cost per run",
+			transport:        &AccountingTransport{MaxCost: 0.017},
+			endpoints:        []string{"/flights/N12345", "/flights/N12345-1/track", "/flights/N12345-2/track"},
+			expectedRequests: map[string]int{EndpointClassFlights: 1, EndpointClassTrack: 1},
+			expectedServed:   2,
+			expectedErr:      "per run budget($0.017)",
+		},
+		{
+			name:             "refuses to exceed monthly budget",
+			transport:        &AccountingTransport{MonthlyBudget: 10, MonthToDate: Usage{Cost: 9.999}},
+			endpoints:        []string{"/flights/N12345"},
+			expectedRequests: map[string]int{},
+			expectedErr:      "monthly budget($10.000)",
+		},
+		{
+			name:             "dry run",
+			transport:        &AccountingTransport{DryRun: true, Prices: PriceTable{EndpointClassTrack: 1}},
+			endpoints:        []string{"/flights/N12345-1/track"},
+			expectedRequests: map[string]int{EndpointClassTrack: 1},
+		},
+		{
+			name:             "dry run of flights list",
+			transport:        &AccountingTransport{DryRun: true},
+			endpoints:        []string{"/flights/N12345", "/flights/N12345-1/position"},
+			expectedRequests: map[string]int{EndpointClassFlights: 2},
+			expectedUnknown:  1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			var served int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				served++
+				if tc.status != 0 {
+					w.WriteHeader(tc.status)
+				}
+			}))
+			defer svr.Close()
+
+			transport := tc.transport
+			if transport == nil {
+				transport = &AccountingTransport{}
+			}
+			api := &HttpAeroApi{ApiUrl: svr.URL, Client: &http.Client{Transport: transport}}
+			var lastErr error
+			for _, endpoint := range tc.endpoints {
+				if _, loadErr := api.Load(context.Background(), endpoint); loadErr != nil {
+					lastErr = loadErr
+				}
+			}
+			requirer.Equal(tc.expectedServed, served)
+			requirer.Equal(tc.expectedRequests, transport.Usage().Requests)
+			requirer.Equal(tc.expectedUnknown, transport.Usage().UnknownLists)
+			if tc.expectedErr != "" {
+				requirer.Error(lastErr)
+				requirer.Contains(lastErr.Error(), tc.expectedErr)
+				requirer.True(IsFatal(lastErr))
+			}
+		})
+	}
+}
+
+func TestParsePriceTable(t *testing.T) {
+	requirer := require.New(t)
+
+	prices, parseErr := ParsePriceTable("")
+	requirer.NoError(parseErr)
+	requirer.Equal(DefaultPriceTable, prices)
+
+	prices, parseErr = ParsePriceTable("track=0.02, flights = 0")
+	requirer.NoError(parseErr)
+	requirer.Equal(0.02, prices[EndpointClassTrack])
+	requirer.Equal(0.0, prices[EndpointClassFlights])
+	requirer.Equal(DefaultPriceTable[EndpointClassOther], prices[EndpointClassOther])
+
+	for _, badSpec := range []string{"track", "runways=1", "track=free", "track=-1"} {
+		_, parseErr = ParsePriceTable(badSpec)
+		requirer.Error(parseErr, badSpec)
+	}
+}
+
+func TestLedger(t *testing.T) {
+	requirer := require.New(t)
+
+	ledger := &Ledger{Filename: filepath.Join(t.TempDir(), "dir", "ledger.json")}
+	months, loadErr := ledger.Load()
+	requirer.NoError(loadErr)
+	requirer.Empty(months)
+
+	_, recordErr := ledger.Record("2023-05", Usage{Requests: map[string]int{EndpointClassTrack: 2}, Cost: 0.024})
+	requirer.NoError(recordErr)
+	monthUsage, recordErr := ledger.Record("2023-05", Usage{Requests: map[string]int{EndpointClassTrack: 1, EndpointClassFlights: 1}, Cost: 0.017})
+	requirer.NoError(recordErr)
+	requirer.Equal(map[string]int{EndpointClassTrack: 3, EndpointClassFlights: 1}, monthUsage.Requests)
+	requirer.InDelta(0.041, monthUsage.Cost, 1e-9)
+	requirer.Equal("1 flights, 3 track request(s) costing an estimated $0.041", monthUsage.String())
+	monthUsage.UnknownLists = 1
+	requirer.Equal("1 flights, 3 track request(s) costing an estimated $0.041, plus those following 1 flights list(s) of unknown count", monthUsage.String())
+	monthUsage.UnknownLists = 0
+
+	months, loadErr = ledger.Load()
+	requirer.NoError(loadErr)
+	requirer.Len(months, 1)
+	requirer.Equal(monthUsage, months["2023-05"])
+
+	// concurrent recorders (e.g., runs of fviz) don't lose each other's usage
+	const nRecorders = 8
+	var wg sync.WaitGroup
+	for i := 0; i < nRecorders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, concurrentRecordErr := ledger.Record("2023-06", Usage{Requests: map[string]int{EndpointClassFlights: 1}, Cost: 0.005})
+			requirer.NoError(concurrentRecordErr)
+		}()
+	}
+	wg.Wait()
+	months, loadErr = ledger.Load()
+	requirer.NoError(loadErr)
+	requirer.Equal(nRecorders, months["2023-06"].Requests[EndpointClassFlights])
+	requirer.NoFileExists(ledger.Filename + ledgerLockSuffix)
+}
@@ -0,0 +1,371 @@
+// Package aeroapitest provides a fake AeroAPI server, serving responses from an
+// artifacts directory (see "fviz tracks --saveArtifacts"), for use in tests and demos.
+package aeroapitest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+// DefaultRateLimitPeriod is the default period within which at most RateLimit requests are allowed
+const DefaultRateLimitPeriod = time.Minute
+
+// Options configure the behavior of the fake AeroAPI server
+type Options struct {
+	ArtifactsDir    string                    // directory containing the "fvf_" and "fvt_" artifacts served
+	ApiKey          string                    // value required of the "x-apikey" header (""=any)
+	Latency         time.Duration             // delay before each response
+	PageSize        int                       // maximum number of flights per page (0=unlimited)
+	RateLimit       int                       // maximum number of requests per RateLimitPeriod (0=unlimited)
+	RateLimitPeriod time.Duration             // 0=DefaultRateLimitPeriod
+	FailWith        func(r *http.Request) int // returns the status with which to fail the request (0=none)
+	Now             func() time.Time          // source of the current time (nil=time.Now)
+}
+
+// Server is a fake AeroAPI server, serving the following endpoints:
+//
+//	/flights/{ident}               - flights found in the "fvf_{ident}*.json" artifacts
+//	/flights/{id}/track            - the track found in the "fvt_{id}.json" artifact
+//	/flights/{id}/position         - the last position of that track
+//
+// Artifacts may be compressed (e.g., "fvt_{id}.json.gz") and/or wrapped in an aeroapi.Envelope;
+// the AeroAPI responses they hold are served as they were originally received.
+type Server struct {
+	*httptest.Server
+	Options
+	mu          sync.Mutex
+	requests    []string
+	windowStart time.Time
+	windowCount int
+}
+
+// NewServer starts and returns a new fake AeroAPI server, which should be closed when no longer needed
+func NewServer(options Options) *Server {
+	s := &Server{Options: options}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHttp))
+	return s
+}
+
+// Requests returns the request URIs received by the server, in the order received
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.requests...)
+}
+
+func (s *Server) serveHttp(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests = append(s.requests, r.URL.RequestURI())
+	s.mu.Unlock()
+
+	if s.Latency > 0 {
+		select {
+		case <-time.After(s.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if s.ApiKey != "" && r.Header.Get("x-apikey") != s.ApiKey {
+		writeError(w, http.StatusUnauthorized, "invalid API key")
+		return
+	}
+	if retryAfter, limited := s.isRateLimited(); limited {
+		w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds()+0.5)))
+		writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+		return
+	}
+	if s.FailWith != nil {
+		if status := s.FailWith(r); status != 0 {
+			writeError(w, status, "injected failure")
+			return
+		}
+	}
+
+	// e.g., "/aeroapi/flights/N12345/track" => ["N12345", "track"]
+	_, resource, found := strings.Cut(r.URL.Path, "/flights/")
+	if !found || r.Method != http.MethodGet {
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+		return
+	}
+	parts := strings.Split(strings.Trim(resource, "/"), "/")
+	switch {
+	case len(parts) == 1:
+		s.serveFlights(w, r, parts[0])
+	case len(parts) == 2 && parts[1] == "track":
+		s.serveTrack(w, parts[0])
+	case len(parts) == 2 && parts[1] == "position":
+		s.servePosition(w, parts[0])
+	default:
+		writeError(w, http.StatusNotFound, "unknown endpoint")
+	}
+}
+
+func (s *Server) serveFlights(w http.ResponseWriter, r *http.Request, ident string) {
+	artifactFilenames, findErr := s.findFlightIdsArtifacts(ident)
+	if findErr != nil || len(artifactFilenames) == 0 {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("no flights found for ident(%s)", ident))
+		return
+	}
+
+	start, startErr := parseTimeParameter(r, "start")
+	end, endErr := parseTimeParameter(r, "end")
+	if startErr != nil || endErr != nil {
+		writeError(w, http.StatusBadRequest, "invalid 'start' or 'end' parameter")
+		return
+	}
+
+	// merge the flights found in the artifacts, most recent first as does AeroAPI
+	flightsById := make(map[string]map[string]any)
+	for _, artifactFilename := range artifactFilenames {
+		var response struct {
+			Flights []map[string]any `json:"flights"`
+		}
+		if readErr := readJson(artifactFilename, &response); readErr != nil {
+			writeError(w, http.StatusInternalServerError, readErr.Error())
+			return
+		}
+		for _, flight := range response.Flights {
+			if isFlightInRange(flight, start, end) {
+				flightsById[fmt.Sprintf("%v", flight["fa_flight_id"])] = flight
+			}
+		}
+	}
+	flights := make([]map[string]any, 0, len(flightsById))
+	for _, flight := range flightsById {
+		flights = append(flights, flight)
+	}
+	sort.Slice(flights, func(i, j int) bool {
+		return getFlightTime(flights[i]).After(getFlightTime(flights[j]))
+	})
+
+	// serve (up to "max_pages" of) the pages starting at the cursor, if paginating
+	cursor, cursorErr := parseIntParameter(r, "cursor", 0)
+	if cursorErr != nil || cursor < 0 || cursor > len(flights) {
+		writeError(w, http.StatusBadRequest, "invalid 'cursor' parameter")
+		return
+	}
+	maxPages, maxPagesErr := parseIntParameter(r, "max_pages", 1)
+	if maxPagesErr != nil || maxPages < 1 {
+		writeError(w, http.StatusBadRequest, "invalid 'max_pages' parameter")
+		return
+	}
+	flights = flights[cursor:]
+	numPages := 1
+	var links any
+	if s.PageSize > 0 {
+		numPages = maxInt(1, (len(flights)+s.PageSize-1)/s.PageSize)
+		if numPages > maxPages {
+			numPages = maxPages
+			flights = flights[:numPages*s.PageSize]
+			nextQuery := r.URL.Query()
+			nextQuery.Set("cursor", strconv.Itoa(cursor+len(flights)))
+			links = map[string]string{"next": fmt.Sprintf("/flights/%s?%s", ident, nextQuery.Encode())}
+		}
+	}
+
+	writeJson(w, map[string]any{
+		"flights":   flights,
+		"links":     links,
+		"num_pages": numPages,
+	})
+}
+
+// findFlightIdsArtifacts returns the names of the artifacts (e.g., "fvf_{ident}_cutoff-{time}.json") holding flights
+// of the ident, without the suffix denoting their compression, if any (see readArtifact)
+func (s *Server) findFlightIdsArtifacts(ident string) ([]string, error) {
+	candidates, globErr := filepath.Glob(filepath.Join(s.ArtifactsDir, aeroapi.MakeFlightIdsArtifactFilename(ident+"*")+"*"))
+	if globErr != nil {
+		return nil, globErr
+	}
+	// e.g., "fvf_{ident}_" but not "fvf_{ident}X"
+	qualifiedPrefix := strings.TrimSuffix(aeroapi.MakeFlightIdsArtifactFilename(ident+"_"), ".json")
+	var artifactFilenames []string
+	found := make(map[string]bool)
+	for _, candidate := range candidates {
+		candidate = persistence.TrimCompressionSuffix(candidate)
+		base := filepath.Base(candidate)
+		if found[candidate] || filepath.Ext(base) != ".json" {
+			continue
+		}
+		if base == aeroapi.MakeFlightIdsArtifactFilename(ident) || strings.HasPrefix(base, qualifiedPrefix) {
+			artifactFilenames = append(artifactFilenames, candidate)
+			found[candidate] = true
+		}
+	}
+	return artifactFilenames, nil
+}
+
+func (s *Server) serveTrack(w http.ResponseWriter, flightId string) {
+	track, found := s.readTrack(w, flightId)
+	if found {
+		writeJson(w, track)
+	}
+}
+
+func (s *Server) servePosition(w http.ResponseWriter, flightId string) {
+	track, found := s.readTrack(w, flightId)
+	if !found {
+		return
+	}
+	var lastPosition any
+	if len(track.Positions) > 0 {
+		lastPosition = track.Positions[len(track.Positions)-1]
+	}
+	writeJson(w, map[string]any{
+		"fa_flight_id":  flightId,
+		"last_position": lastPosition,
+	})
+}
+
+func (s *Server) readTrack(w http.ResponseWriter, flightId string) (*rawTrack, bool) {
+	var track rawTrack
+	artifactFilename := filepath.Join(s.ArtifactsDir, aeroapi.MakeTrackArtifactFilename(filepath.Base(flightId)))
+	if readErr := readJson(artifactFilename, &track); readErr != nil {
+		if os.IsNotExist(readErr) {
+			writeError(w, http.StatusNotFound, fmt.Sprintf("no track found for flight(%s)", flightId))
+		} else {
+			writeError(w, http.StatusInternalServerError, readErr.Error())
+		}
+		return nil, false
+	}
+	return &track, true
+}
+
+// rawTrack is an AeroAPI track, retaining all the attributes of its positions
+type rawTrack struct {
+	Positions []map[string]any `json:"positions"`
+}
+
+// isRateLimited returns true (along with the time until more requests are allowed)
+// if the request exceeds the rate limit
+func (s *Server) isRateLimited() (time.Duration, bool) {
+	if s.RateLimit <= 0 {
+		return 0, false
+	}
+	period := s.RateLimitPeriod
+	if period <= 0 {
+		period = DefaultRateLimitPeriod
+	}
+	now := s.getNow()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if now.Sub(s.windowStart) >= period {
+		s.windowStart, s.windowCount = now, 0
+	}
+	s.windowCount++
+	if s.windowCount > s.RateLimit {
+		return s.windowStart.Add(period).Sub(now), true
+	}
+	return 0, false
+}
+
+func (s *Server) getNow() time.Time {
+	if s.Now != nil {
+		return s.Now()
+	}
+	return time.Now()
+}
+
+func isFlightInRange(flight map[string]any, start, end time.Time) bool {
+	flightTime := getFlightTime(flight)
+	if flightTime.IsZero() {
+		return true
+	}
+	return (start.IsZero() || !flightTime.Before(start)) && (end.IsZero() || !flightTime.After(end))
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+// getFlightTime returns the (scheduled) departure time of the flight, if known
+func getFlightTime(flight map[string]any) time.Time {
+	for _, attribute := range []string{"actual_off", "scheduled_out", "scheduled_off"} {
+		if value, ok := flight[attribute].(string); ok {
+			if flightTime, parseErr := time.Parse(time.RFC3339, value); parseErr == nil {
+				return flightTime
+			}
+		}
+	}
+	return time.Time{}
+}
+
+func parseIntParameter(r *http.Request, name string, defaultValue int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return defaultValue, nil
+	}
+	return strconv.Atoi(value)
+}
+
+func parseTimeParameter(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// readJson reads the AeroAPI response held in the artifact into v
+func readJson(filename string, v any) error {
+	contents, readErr := readArtifact(filename)
+	if readErr != nil {
+		return readErr
+	}
+	body, _, unwrapErr := aeroapi.UnwrapArtifact(contents)
+	if unwrapErr != nil {
+		return fmt.Errorf("artifact(%s): %w", filename, unwrapErr)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// readArtifact returns the decompressed contents of the named artifact, or of its compressed
+// counterpart (e.g., "{filename}.gz") if it doesn't exist
+func readArtifact(filename string) ([]byte, error) {
+	var contents []byte
+	var readErr error
+	for _, compression := range []string{persistence.CompressionNone, persistence.CompressionZstd, persistence.CompressionGzip} {
+		if contents, readErr = os.ReadFile(filename + persistence.CompressionSuffix(compression)); !os.IsNotExist(readErr) {
+			break
+		}
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return persistence.Decompress(contents)
+}
+
+func writeJson(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	if encodeErr := json.NewEncoder(w).Encode(v); encodeErr != nil {
+		http.Error(w, encodeErr.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeError writes an error response in the form used by AeroAPI
+func writeError(w http.ResponseWriter, status int, detail string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(map[string]any{
+		"title":  http.StatusText(status),
+		"reason": http.StatusText(status),
+		"detail": detail,
+		"status": status,
+	})
+}
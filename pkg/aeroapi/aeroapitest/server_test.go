@@ -0,0 +1,329 @@
+package aeroapitest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+	"github.com/noodnik2/flightvisualizer/testfixtures"
+)
+
+func TestServer(t *testing.T) {
+
+	artifactsDir := newTestArtifactsDir(t)
+
+	testCases := []struct {
+		name              string
+		options           Options
+		apiKey            string
+		endpoint          string
+		requestTimeout    time.Duration
+		priorRequests     int
+		expectedFlights   int
+		expectedNext      string
+		expectedNumPages  int
+		expectedPositions int
+		expectedErrAs     func(error) bool
+	}{
+		{
+			name:            "flights",
+			endpoint:        "/flights/N5322J",
+			expectedFlights: 14,
+		},
+		{
+			name:            "flights before end",
+			endpoint:        "/flights/N5322J?&end=2023-05-08T00:00:00Z",
+			expectedFlights: 10,
+		},
+		{
+			name:             "flights, paginated",
+			options:          Options{PageSize: 5},
+			endpoint:         "/flights/N5322J?cursor=5",
+			expectedFlights:  5,
+			expectedNext:     "/flights/N5322J?cursor=10",
+			expectedNumPages: 1,
+		},
+		{
+			name:             "flights, paginated, multiple pages",
+			options:          Options{PageSize: 5},
+			endpoint:         "/flights/N5322J?max_pages=2",
+			expectedFlights:  10,
+			expectedNext:     "/flights/N5322J?cursor=10&max_pages=2",
+			expectedNumPages: 2,
+		},
+		{
+			name:             "flights, paginated, last pages",
+			options:          Options{PageSize: 5},
+			endpoint:         "/flights/N5322J?cursor=5&max_pages=3",
+			expectedFlights:  9,
+			expectedNumPages: 2,
+		},
+		{
+			name:          "flights, invalid max_pages",
+			options:       Options{PageSize: 5},
+			endpoint:      "/flights/N5322J?max_pages=0",
+			expectedErrAs: errorAs[*aeroapi.ResponseError],
+		},
+		{
+			name:          "flights of unknown ident",
+			endpoint:      "/flights/N5322",
+			expectedErrAs: errorAs[*aeroapi.NotFoundError],
+		},
+		{
+			name:              "track",
+			endpoint:          "/flights/N5322J-1683690340-adhoc-1256p/track",
+			expectedPositions: 19,
+		},
+		{
+			name:              "position",
+			endpoint:          "/flights/N5322J-1683690340-adhoc-1256p/position",
+			expectedPositions: 1,
+		},
+		{
+			name:          "wrong API key",
+			options:       Options{ApiKey: "secret"},
+			apiKey:        "guess",
+			endpoint:      "/flights/N5322J",
+			expectedErrAs: errorAs[*aeroapi.AuthError],
+		},
+		{
+			name:          "rate limited",
+			options:       Options{RateLimit: 2},
+			priorRequests: 2,
+			endpoint:      "/flights/N5322J",
+			expectedErrAs: errorAs[*aeroapi.RateLimitedError],
+		},
+		{
+			name:          "injected failure",
+			options:       Options{FailWith: func(*http.Request) int { return http.StatusServiceUnavailable }},
+			endpoint:      "/flights/N5322J",
+			expectedErrAs: errorAs[*aeroapi.ServerError],
+		},
+		{
+			name:           "latency",
+			options:        Options{Latency: time.Second},
+			endpoint:       "/flights/N5322J",
+			requestTimeout: 10 * time.Millisecond,
+			expectedErrAs:  func(err error) bool { return errors.Is(err, context.DeadlineExceeded) },
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			options := tc.options
+			options.ArtifactsDir = artifactsDir
+			server := NewServer(options)
+			defer server.Close()
+
+			// each page served is examined, rather than those following it being loaded too
+			api := &aeroapi.HttpAeroApi{ApiUrl: server.URL, ApiKey: tc.apiKey, RequestTimeout: tc.requestTimeout, MaxPages: 1}
+			for i := 0; i < tc.priorRequests; i++ {
+				_, priorLoadErr := api.Load(context.Background(), tc.endpoint)
+				requirer.NoError(priorLoadErr)
+			}
+			response, loadErr := api.Load(context.Background(), tc.endpoint)
+			if tc.expectedErrAs != nil {
+				requirer.Error(loadErr)
+				requirer.True(tc.expectedErrAs(loadErr), loadErr.Error())
+				return
+			}
+			requirer.NoError(loadErr)
+			requirer.Len(server.Requests(), tc.priorRequests+1)
+
+			var parsed struct {
+				Flights      []json.RawMessage `json:"flights"`
+				Links        map[string]string `json:"links"`
+				NumPages     int               `json:"num_pages"`
+				Positions    []json.RawMessage `json:"positions"`
+				LastPosition json.RawMessage   `json:"last_position"`
+			}
+			requirer.NoError(json.Unmarshal(response, &parsed))
+			requirer.Equal(tc.expectedFlights, len(parsed.Flights))
+			requirer.Equal(tc.expectedNext, parsed.Links["next"])
+			if tc.expectedNumPages != 0 {
+				requirer.Equal(tc.expectedNumPages, parsed.NumPages)
+			}
+			if parsed.LastPosition != nil {
+				parsed.Positions = append(parsed.Positions, parsed.LastPosition)
+			}
+			requirer.Equal(tc.expectedPositions, len(parsed.Positions))
+		})
+	}
+}
+
+func TestServer_Pages(t *testing.T) {
+
+	artifactsDir := newTestArtifactsDir(t)
+
+	testCases := []struct {
+		name             string
+		maxPages         int
+		endpoint         string
+		expectedRequests []string
+		expectedFlights  int
+		expectedNext     string
+		expectedNumPages int
+	}{
+		{
+			name:             "all pages",
+			endpoint:         "/flights/N5322J",
+			expectedRequests: []string{"/flights/N5322J", "/flights/N5322J?cursor=5", "/flights/N5322J?cursor=10"},
+			expectedFlights:  14,
+			expectedNumPages: 3,
+		},
+		{
+			name:             "pages within the limit",
+			maxPages:         2,
+			endpoint:         "/flights/N5322J",
+			expectedRequests: []string{"/flights/N5322J", "/flights/N5322J?cursor=5"},
+			expectedFlights:  10,
+			expectedNext:     "/flights/N5322J?cursor=10",
+			expectedNumPages: 2,
+		},
+		{
+			name:             "pages of several pages",
+			endpoint:         "/flights/N5322J?max_pages=2",
+			expectedRequests: []string{"/flights/N5322J?max_pages=2", "/flights/N5322J?cursor=10&max_pages=2"},
+			expectedFlights:  14,
+			expectedNumPages: 3,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			server := NewServer(Options{ArtifactsDir: artifactsDir, PageSize: 5})
+			defer server.Close()
+
+			api := &aeroapi.HttpAeroApi{ApiUrl: server.URL, MaxPages: tc.maxPages}
+			response, loadErr := api.Load(context.Background(), tc.endpoint)
+			requirer.NoError(loadErr)
+			requirer.Equal(tc.expectedRequests, server.Requests())
+
+			flights, flightsErr := aeroapi.FlightsFromJson(response)
+			requirer.NoError(flightsErr)
+			requirer.Len(flights.Flights, tc.expectedFlights)
+			flightIds := make(map[string]bool)
+			for _, flight := range flights.Flights {
+				flightIds[flight.FlightId] = true
+			}
+			requirer.Len(flightIds, tc.expectedFlights)
+
+			var parsed struct {
+				Links    map[string]string `json:"links"`
+				NumPages int               `json:"num_pages"`
+			}
+			requirer.NoError(json.Unmarshal(response, &parsed))
+			requirer.Equal(tc.expectedNext, parsed.Links["next"])
+			requirer.Equal(tc.expectedNumPages, parsed.NumPages)
+		})
+	}
+}
+
+func TestServer_RetryAfter(t *testing.T) {
+	requirer := require.New(t)
+
+	now := time.Date(2023, 5, 18, 20, 40, 0, 0, time.UTC)
+	server := NewServer(Options{
+		ArtifactsDir:    newTestArtifactsDir(t),
+		RateLimit:       1,
+		RateLimitPeriod: time.Minute,
+		Now:             func() time.Time { return now },
+	})
+	defer server.Close()
+
+	api := &aeroapi.HttpAeroApi{ApiUrl: server.URL}
+	_, loadErr := api.Load(context.Background(), "/flights/N5322J")
+	requirer.NoError(loadErr)
+	now = now.Add(15 * time.Second)
+	_, loadErr = api.Load(context.Background(), "/flights/N5322J")
+	var rateLimitedErr *aeroapi.RateLimitedError
+	requirer.ErrorAs(loadErr, &rateLimitedErr)
+	requirer.Equal(45*time.Second, rateLimitedErr.RetryAfter)
+	now = now.Add(45 * time.Second)
+	_, loadErr = api.Load(context.Background(), "/flights/N5322J")
+	requirer.NoError(loadErr)
+}
+
+func TestServer_StoredArtifacts(t *testing.T) {
+
+	flightIdsContents, readErr := os.ReadFile(filepath.Join("..", "..", "..", "testfixtures", "aeroapi-flight-id.json"))
+	require.NoError(t, readErr)
+	trackContents := []byte(testfixtures.NewMockTestAeroApiTrackResponse())
+
+	testCases := []struct {
+		name        string
+		compression string
+		enveloped   bool
+	}{
+		{name: "raw"},
+		{name: "enveloped", enveloped: true},
+		{name: "gzip", compression: persistence.CompressionGzip},
+		{name: "zstd, enveloped", compression: persistence.CompressionZstd, enveloped: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			artifactsDir := t.TempDir()
+			store := func(filename, endpoint string, contents []byte) {
+				if tc.enveloped {
+					envelope, newEnvelopeErr := aeroapi.NewEnvelope(aeroapi.Provenance{Tool: "test"}, endpoint, time.Now(), contents)
+					requirer.NoError(newEnvelopeErr)
+					var marshalErr error
+					contents, marshalErr = envelope.Marshal()
+					requirer.NoError(marshalErr)
+				}
+				compressed, compressErr := persistence.Compress(tc.compression, contents)
+				requirer.NoError(compressErr)
+				filename += persistence.CompressionSuffix(tc.compression)
+				requirer.NoError(os.WriteFile(filepath.Join(artifactsDir, filename), compressed, 0644))
+			}
+			store(aeroapi.MakeFlightIdsArtifactFilename("N5322J"), "/flights/N5322J", flightIdsContents)
+			store(aeroapi.MakeTrackArtifactFilename("N5322J-1683690340-adhoc-1256p"), "/flights/N5322J-1683690340-adhoc-1256p/track", trackContents)
+
+			server := NewServer(Options{ArtifactsDir: artifactsDir})
+			defer server.Close()
+			api := &aeroapi.HttpAeroApi{ApiUrl: server.URL}
+
+			var parsed struct {
+				Flights   []json.RawMessage `json:"flights"`
+				Positions []json.RawMessage `json:"positions"`
+			}
+			for _, endpoint := range []string{"/flights/N5322J", "/flights/N5322J-1683690340-adhoc-1256p/track"} {
+				response, loadErr := api.Load(context.Background(), endpoint)
+				requirer.NoError(loadErr)
+				requirer.NoError(json.Unmarshal(response, &parsed))
+			}
+			requirer.Len(parsed.Flights, 14)
+			requirer.Len(parsed.Positions, 19)
+		})
+	}
+}
+
+// newTestArtifactsDir returns a directory holding the artifacts of a tail number's flights, and the track of one of them
+func newTestArtifactsDir(t *testing.T) string {
+	artifactsDir := t.TempDir()
+	flightIdsContents, readErr := os.ReadFile(filepath.Join("..", "..", "..", "testfixtures", "aeroapi-flight-id.json"))
+	require.NoError(t, readErr)
+	require.NoError(t, os.WriteFile(filepath.Join(artifactsDir, aeroapi.MakeFlightIdsArtifactFilename("N5322J")), flightIdsContents, 0644))
+	trackContents := []byte(testfixtures.NewMockTestAeroApiTrackResponse())
+	require.NoError(t, os.WriteFile(filepath.Join(artifactsDir, aeroapi.MakeTrackArtifactFilename("N5322J-1683690340-adhoc-1256p")), trackContents, 0644))
+	return artifactsDir
+}
+
+// errorAs returns true if the error (or one that it wraps) is of type T
+func errorAs[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
@@ -1,6 +1,7 @@
 package aeroapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -15,7 +16,100 @@ type FlightsResponse struct {
 }
 
 type Flight struct {
-	FlightId string `json:"fa_flight_id"`
+	FlightId       string         `json:"fa_flight_id"`
+	Ident          string         `json:"ident"`
+	IdentIcao      string         `json:"ident_icao"`
+	IdentIata      string         `json:"ident_iata"`
+	OperatorIcao   string         `json:"operator_icao"`
+	FlightNumber   string         `json:"flight_number"`
+	Registration   string         `json:"registration"`
+	Codeshares     []string       `json:"codeshares"`
+	CodesharesIata []string       `json:"codeshares_iata"`
+	Cancelled      bool           `json:"cancelled"`
+	Diverted       bool           `json:"diverted"`
+	PositionOnly   bool           `json:"position_only"`
+	Status         string         `json:"status"`
+	AircraftType   string         `json:"aircraft_type"`
+	RouteDistance  int            `json:"route_distance"` // statute miles
+	FiledEte       int            `json:"filed_ete"`      // seconds
+	Origin         *FlightAirport `json:"origin"`
+	Destination    *FlightAirport `json:"destination"`
+	ScheduledOut   *time.Time     `json:"scheduled_out"`
+	ActualOut      *time.Time     `json:"actual_out"`
+	ScheduledOff   *time.Time     `json:"scheduled_off"`
+	ActualOff      *time.Time     `json:"actual_off"`
+	ScheduledOn    *time.Time     `json:"scheduled_on"`
+	ActualOn       *time.Time     `json:"actual_on"`
+}
+
+// FlightAirport is the origin or destination of a flight
+type FlightAirport struct {
+	Code     string `json:"code"`
+	CodeIcao string `json:"code_icao"`
+	CodeIata string `json:"code_iata"`
+	CodeLid  string `json:"code_lid"`
+	Timezone string `json:"timezone"`
+	Name     string `json:"name"`
+}
+
+// GetDepartureTime returns the (actual, if known, else scheduled) time of the flight's
+// departure, or the zero time if unknown
+func (f *Flight) GetDepartureTime() time.Time {
+	for _, departureTime := range []*time.Time{f.ActualOut, f.ActualOff, f.ScheduledOut, f.ScheduledOff} {
+		if departureTime != nil {
+			return *departureTime
+		}
+	}
+	return time.Time{}
+}
+
+// GetScheduledDepartureTime returns the scheduled time of the flight's departure (as used by AeroAPI
+// to select the flights within a time range), or its actual departure time if not scheduled
+func (f *Flight) GetScheduledDepartureTime() time.Time {
+	for _, departureTime := range []*time.Time{f.ScheduledOut, f.ScheduledOff} {
+		if departureTime != nil {
+			return *departureTime
+		}
+	}
+	return f.GetDepartureTime()
+}
+
+// GetDuration returns the (actual, if known, else scheduled or filed) time from the flight's
+// departure (i.e., takeoff) until its arrival (i.e., landing), or 0 if unknown
+func (f *Flight) GetDuration() time.Duration {
+	if f.ActualOff != nil && f.ActualOn != nil {
+		return f.ActualOn.Sub(*f.ActualOff)
+	}
+	if f.ScheduledOff != nil && f.ScheduledOn != nil {
+		return f.ScheduledOn.Sub(*f.ScheduledOff)
+	}
+	return time.Duration(f.FiledEte) * time.Second
+}
+
+// GetDepartureDate returns the (civil) date of the flight's departure, local to its origin
+// (e.g., "2023-05-23"), or "" if unknown
+func (f *Flight) GetDepartureDate() string {
+	departureTime := f.GetDepartureTime()
+	if departureTime.IsZero() {
+		return ""
+	}
+	if f.Origin != nil && f.Origin.Timezone != "" {
+		if location, loadErr := time.LoadLocation(f.Origin.Timezone); loadErr == nil {
+			departureTime = departureTime.In(location)
+		}
+	}
+	return departureTime.Format(time.DateOnly)
+}
+
+// GetRoute returns a description of the flight's route (e.g., "PHOG-PHNL"), using "?" for unknown airports
+func (f *Flight) GetRoute() string {
+	airportCode := func(airport *FlightAirport) string {
+		if airport == nil || airport.Code == "" {
+			return "?"
+		}
+		return airport.Code
+	}
+	return fmt.Sprintf("%s-%s", airportCode(f.Origin), airportCode(f.Destination))
 }
 
 type Track struct {
@@ -37,14 +131,15 @@ type Position struct {
 }
 
 type Api interface {
-	GetFlightIds(tailNumber string, cutoffTime time.Time) ([]string, error)
-	GetTrackForFlightId(flightId string) (*Track, error)
+	GetFlights(ctx context.Context, ident string, timeRange TimeRange) ([]Flight, error)
+	GetFlightIds(ctx context.Context, tailNumber string, timeRange TimeRange) ([]string, error)
+	GetTrackForFlightId(ctx context.Context, flightId string) (*Track, error)
 }
 
 type ArtifactLocator interface {
 	// GetFlightIdsRef returns a reference used to obtain the flight identifier(s) for the desired track(s).
 	// The return value is an address (such as a URL or file name) used within context to obtain the desired list.
-	GetFlightIdsRef(tailNumber string, cutoffTime time.Time) (string, error)
+	GetFlightIdsRef(tailNumber string, timeRange TimeRange) (string, error)
 	// GetTrackForFlightRef returns a reference (such as a URL or file name) used to obtain the desired track data.
 	GetTrackForFlightRef(flightId string) string
 }
@@ -62,26 +157,43 @@ type ArtifactSaver interface {
 type RetrieverSaverApiImpl struct {
 	Retriever ArtifactRetriever
 	Saver     ArtifactSaver
+	// Provenance, if set, identifies the tool saving responses, which are then wrapped in an Envelope
+	Provenance *Provenance
 }
 
 // GetFlightIds returns the AeroAPI identifier(s) of the flight(s) specified by the parameters
-// cutoffTime (optional) - most recent time for a flight to be considered
-func (a *RetrieverSaverApiImpl) GetFlightIds(tailNumber string, cutoffTime time.Time) ([]string, error) {
-	endpoint, getFidsErr := a.Retriever.GetFlightIdsRef(tailNumber, cutoffTime)
+// timeRange (optional) - range of times within which a flight must depart to be considered
+func (a *RetrieverSaverApiImpl) GetFlightIds(ctx context.Context, tailNumber string, timeRange TimeRange) ([]string, error) {
+	flights, getFlightsErr := a.GetFlights(ctx, tailNumber, timeRange)
+	if getFlightsErr != nil {
+		return nil, getFlightsErr
+	}
+	var flightIds []string
+	for _, flight := range flights {
+		flightIds = append(flightIds, flight.FlightId)
+	}
+	return flightIds, nil
+}
+
+// GetFlights returns the flight(s) known for the ident, which may be a registration (tail number)
+// or an airline flight identifier (e.g., "UAL123"), most recent first
+// timeRange (optional) - range of times within which a flight must depart to be considered
+func (a *RetrieverSaverApiImpl) GetFlights(ctx context.Context, ident string, timeRange TimeRange) ([]Flight, error) {
+	endpoint, getFidsErr := a.Retriever.GetFlightIdsRef(ident, timeRange)
 	if getFidsErr != nil {
 		return nil, newFlightApiError("get endpoint", "retrieving flight IDs", getFidsErr)
 	}
-	responseBytes, getErr := a.Retriever.Load(endpoint)
+	responseBytes, getErr := a.Retriever.Load(ctx, endpoint)
 	if getErr != nil {
 		return nil, newFlightApiError("get", endpoint, getErr)
 	}
 
 	if a.Saver != nil {
-		saveUri, getSaveFidsErr := a.Saver.GetFlightIdsRef(tailNumber, cutoffTime)
+		saveUri, getSaveFidsErr := a.Saver.GetFlightIdsRef(ident, timeRange)
 		if getSaveFidsErr != nil {
 			return nil, newFlightApiError("get URI", "saving flight IDs", getSaveFidsErr)
 		}
-		if getSaveErr := a.Saver.Save(saveUri, responseBytes); getSaveErr != nil {
+		if getSaveErr := a.saveArtifact(saveUri, endpoint, responseBytes); getSaveErr != nil {
 			return nil, newFlightApiError("save get flight ids response", endpoint, getSaveErr)
 		}
 	}
@@ -91,24 +203,30 @@ func (a *RetrieverSaverApiImpl) GetFlightIds(tailNumber string, cutoffTime time.
 		return nil, newFlightApiError("unmarshal", endpoint, flightsErr)
 	}
 
-	var flightIds []string
+	if timeRange.IsZero() {
+		return flights.Flights, nil
+	}
+	// the flights of an artifact (e.g., saved from an earlier query) may not all be within the range
+	var flightsInRange []Flight
 	for _, flight := range flights.Flights {
-		flightIds = append(flightIds, flight.FlightId)
+		if timeRange.Contains(flight.GetScheduledDepartureTime()) {
+			flightsInRange = append(flightsInRange, flight)
+		}
 	}
-	return flightIds, nil
+	return flightsInRange, nil
 }
 
 // GetTrackForFlightId retrieves the track for the given flight given its AeroAPI identifier
-func (a *RetrieverSaverApiImpl) GetTrackForFlightId(flightId string) (*Track, error) {
+func (a *RetrieverSaverApiImpl) GetTrackForFlightId(ctx context.Context, flightId string) (*Track, error) {
 	endpoint := a.Retriever.GetTrackForFlightRef(flightId)
-	responseBytes, getErr := a.Retriever.Load(endpoint)
+	responseBytes, getErr := a.Retriever.Load(ctx, endpoint)
 	if getErr != nil {
 		return nil, newFlightApiError("get", endpoint, getErr)
 	}
 
 	if a.Saver != nil {
 		saveUri := a.Saver.GetTrackForFlightRef(flightId)
-		if getSaveErr := a.Saver.Save(saveUri, responseBytes); getSaveErr != nil {
+		if getSaveErr := a.saveArtifact(saveUri, endpoint, responseBytes); getSaveErr != nil {
 			return nil, newFlightApiError("save get track response", endpoint, getSaveErr)
 		}
 	}
@@ -122,17 +240,44 @@ func (a *RetrieverSaverApiImpl) GetTrackForFlightId(flightId string) (*Track, er
 	return track, nil
 }
 
+// saveArtifact saves the response obtained from the endpoint, wrapping it in an Envelope if requested
+func (a *RetrieverSaverApiImpl) saveArtifact(saveUri, endpoint string, responseBytes []byte) error {
+	if a.Provenance == nil || IsEnvelope(responseBytes) {
+		// e.g., re-saving an artifact loaded in its envelope
+		return a.Saver.Save(saveUri, responseBytes)
+	}
+	envelope, newEnvelopeErr := NewEnvelope(*a.Provenance, endpoint, time.Now(), responseBytes)
+	if newEnvelopeErr != nil {
+		return newEnvelopeErr
+	}
+	envelopeBytes, marshalErr := envelope.Marshal()
+	if marshalErr != nil {
+		return marshalErr
+	}
+	return a.Saver.Save(saveUri, envelopeBytes)
+}
+
+// FlightsFromJson parses a "flights" response, which may be wrapped in an Envelope
 func FlightsFromJson(flightsBytes []byte) (*FlightsResponse, error) {
+	body, _, unwrapErr := UnwrapArtifact(flightsBytes)
+	if unwrapErr != nil {
+		return nil, unwrapErr
+	}
 	var flights FlightsResponse
-	if unmarshallErr := json.Unmarshal(flightsBytes, &flights); unmarshallErr != nil {
+	if unmarshallErr := json.Unmarshal(body, &flights); unmarshallErr != nil {
 		return nil, unmarshallErr
 	}
 	return &flights, nil
 }
 
+// TrackFromJson parses a "track" response, which may be wrapped in an Envelope
 func TrackFromJson(aeroApiTrackJson []byte) (*Track, error) {
+	body, _, unwrapErr := UnwrapArtifact(aeroApiTrackJson)
+	if unwrapErr != nil {
+		return nil, unwrapErr
+	}
 	var track Track
-	if unmarshallErr := json.Unmarshal(aeroApiTrackJson, &track); unmarshallErr != nil {
+	if unmarshallErr := json.Unmarshal(body, &track); unmarshallErr != nil {
 		return nil, unmarshallErr
 	}
 	return &track, nil
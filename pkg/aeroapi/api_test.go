@@ -1,6 +1,7 @@
 package aeroapi
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -21,7 +22,7 @@ func TestGetFlightIdsRequestGeneration(t *testing.T) {
 	retriever := &MockArtifactRetriever{
 		Contents: []byte(`{}`),
 	}
-	_, _ = (&RetrieverSaverApiImpl{Retriever: retriever}).GetFlightIds("tail#", time.Time{})
+	_, _ = (&RetrieverSaverApiImpl{Retriever: retriever}).GetFlightIds(context.Background(), "tail#", TimeRange{})
 
 	requirer := require.New(t)
 	requirer.Equal([]string{"/fl/tail#"}, retriever.RequestedEndpoints)
@@ -34,7 +35,7 @@ func TestGetFlightIdsResponseProcessing(t *testing.T) {
 		name              string
 		retriever         ArtifactRetriever
 		assertions        func(*require.Assertions, *testResponseSaver)
-		cutoffTime        time.Time
+		timeRange         TimeRange
 		flightCount       int
 		expectedFlightIds []string
 		expectedErrors    []string
@@ -85,7 +86,7 @@ func TestGetFlightIdsResponseProcessing(t *testing.T) {
 				ArtifactsDir: "adir",
 				FileSaver:    persistence.FileSaver{Writer: responseSaver.Save},
 			}
-			flightIds, err := api.GetFlightIds("irrelevant", tc.cutoffTime)
+			flightIds, err := api.GetFlightIds(context.Background(), "irrelevant", tc.timeRange)
 			requirer := require.New(t)
 			if len(tc.expectedErrors) > 0 {
 				requirer.Error(err)
@@ -158,7 +159,7 @@ func TestGetTrackForFlightId(t *testing.T) {
 			api.Saver = &FileAeroApi{
 				FileSaver: persistence.FileSaver{Writer: responseSaver.Save},
 			}
-			track, err := api.GetTrackForFlightId("irrelevant")
+			track, err := api.GetTrackForFlightId(context.Background(), "irrelevant")
 			requirer := require.New(t)
 			if len(tc.expectedErrors) > 0 {
 				requirer.Error(err)
@@ -0,0 +1,240 @@
+package aeroapi
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+const (
+	// DefaultCacheTtl is the default time for which cached responses which may change
+	// (e.g., the list of a tail number's flights) are considered fresh
+	DefaultCacheTtl = 10 * time.Minute
+	// completedTrackAge is the time since its last reported position after which a flight
+	// is presumed to have completed, so that its track won't change
+	completedTrackAge        = 2 * time.Hour
+	cacheEntryFilenameSuffix = ".json"
+)
+
+// ResponseCache stores AeroAPI responses in files within Dir, keyed by their endpoint and the
+// base URL of the API from which they were loaded
+type ResponseCache struct {
+	Dir     string
+	BaseUrl string        // of the API whose responses are cached, so as not to confuse them with another's
+	Ttl     time.Duration // time for which mutable responses are fresh (0=DefaultCacheTtl)
+	now     func() time.Time
+}
+
+// CacheEntry is a response stored in the cache
+type CacheEntry struct {
+	BaseUrl   string    `json:"baseUrl"`
+	Endpoint  string    `json:"endpoint"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Immutable bool      `json:"immutable"`
+	Response  []byte    `json:"response"`
+}
+
+// CacheEntryInfo describes an entry in the cache
+type CacheEntryInfo struct {
+	CacheEntry
+	Filename string
+	Size     int64
+	Expired  bool
+	Err      error // if set, the entry couldn't be read (e.g., it's corrupt), so is otherwise undescribed
+}
+
+// NewDefaultCacheDir returns the default directory of the response cache, within the user's cache directory
+func NewDefaultCacheDir() (string, error) {
+	userCacheDir, getCacheDirErr := os.UserCacheDir()
+	if getCacheDirErr != nil {
+		return "", getCacheDirErr
+	}
+	return filepath.Join(userCacheDir, "fviz"), nil
+}
+
+// Get returns the cached response for the endpoint, or false if there's no fresh response cached for it
+func (rc *ResponseCache) Get(endpoint string) (*CacheEntry, bool, error) {
+	filename := rc.getEntryFilename(endpoint)
+	entry, readErr := rc.readEntry(filename)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		return nil, false, nil
+	}
+	if readErr != nil {
+		return nil, false, fmt.Errorf("couldn't read cache entry(%s): %w", filename, readErr)
+	}
+	if entry.BaseUrl != rc.BaseUrl || entry.Endpoint != endpoint || rc.isExpired(entry) {
+		return nil, false, nil
+	}
+	return entry, true, nil
+}
+
+// Put stores the response for the endpoint in the cache
+func (rc *ResponseCache) Put(endpoint string, response []byte) error {
+	entry := CacheEntry{
+		BaseUrl:   rc.BaseUrl,
+		Endpoint:  endpoint,
+		FetchedAt: rc.getNow(),
+		Immutable: isCompletedTrack(endpoint, response, rc.getNow()),
+		Response:  response,
+	}
+	entryBytes, marshalErr := json.Marshal(entry)
+	if marshalErr != nil {
+		return marshalErr
+	}
+	// replaced atomically, since another run may be reading it
+	return persistence.WriteFileAtomically(rc.getEntryFilename(endpoint), entryBytes, 0644)
+}
+
+// List returns descriptions of the entries in the cache, most recently fetched first, followed by
+// those which couldn't be read (e.g., corrupt), described only by their file names and errors
+func (rc *ResponseCache) List() ([]CacheEntryInfo, error) {
+	filenames, globErr := filepath.Glob(filepath.Join(rc.Dir, "*"+cacheEntryFilenameSuffix))
+	if globErr != nil {
+		return nil, globErr
+	}
+	var infos, unreadable []CacheEntryInfo
+	for _, filename := range filenames {
+		fileInfo, statErr := os.Stat(filename)
+		if errors.Is(statErr, fs.ErrNotExist) {
+			// e.g., purged by another run
+			continue
+		}
+		if statErr != nil {
+			unreadable = append(unreadable, CacheEntryInfo{Filename: filename, Err: statErr})
+			continue
+		}
+		entry, readErr := rc.readEntry(filename)
+		if readErr != nil {
+			unreadable = append(unreadable, CacheEntryInfo{Filename: filename, Size: fileInfo.Size(), Err: readErr})
+			continue
+		}
+		infos = append(infos, CacheEntryInfo{
+			CacheEntry: *entry,
+			Filename:   filename,
+			Size:       fileInfo.Size(),
+			Expired:    rc.isExpired(entry),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].FetchedAt.After(infos[j].FetchedAt)
+	})
+	return append(infos, unreadable...), nil
+}
+
+// Purge removes the expired and unreadable entries (or all entries, if "all" is true) from the
+// cache, returning the number of entries removed
+func (rc *ResponseCache) Purge(all bool) (int, error) {
+	infos, listErr := rc.List()
+	if listErr != nil {
+		return 0, listErr
+	}
+	var nPurged int
+	for _, info := range infos {
+		if !all && !info.Expired && info.Err == nil {
+			continue
+		}
+		if removeErr := os.Remove(info.Filename); removeErr != nil && !errors.Is(removeErr, fs.ErrNotExist) {
+			return nPurged, removeErr
+		}
+		nPurged++
+	}
+	return nPurged, nil
+}
+
+func (rc *ResponseCache) readEntry(filename string) (*CacheEntry, error) {
+	entryBytes, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var entry CacheEntry
+	if unmarshalErr := json.Unmarshal(entryBytes, &entry); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	return &entry, nil
+}
+
+func (rc *ResponseCache) isExpired(entry *CacheEntry) bool {
+	if entry.Immutable {
+		return false
+	}
+	ttl := rc.Ttl
+	if ttl <= 0 {
+		ttl = DefaultCacheTtl
+	}
+	return rc.getNow().Sub(entry.FetchedAt) > ttl
+}
+
+func (rc *ResponseCache) getEntryFilename(endpoint string) string {
+	hash := sha256.Sum256([]byte(rc.BaseUrl + " " + endpoint))
+	return filepath.Join(rc.Dir, hex.EncodeToString(hash[:12])+cacheEntryFilenameSuffix)
+}
+
+func (rc *ResponseCache) getNow() time.Time {
+	if rc.now != nil {
+		return rc.now()
+	}
+	return time.Now()
+}
+
+// isCompletedTrack returns true if the response is the track of a flight which has completed
+func isCompletedTrack(endpoint string, response []byte, now time.Time) bool {
+	if !strings.HasSuffix(strings.SplitN(endpoint, "?", 2)[0], "/track") {
+		return false
+	}
+	track, unmarshalErr := TrackFromJson(response)
+	if unmarshalErr != nil || len(track.Positions) == 0 {
+		return false
+	}
+	lastPosition := track.Positions[len(track.Positions)-1]
+	return now.Sub(lastPosition.Timestamp) > completedTrackAge
+}
+
+// CachingRetriever is an ArtifactRetriever which returns the responses held in its Cache,
+// if fresh, instead of loading them again from the underlying ArtifactRetriever
+type CachingRetriever struct {
+	ArtifactRetriever
+	Cache    *ResponseCache
+	ReadOnly bool // if set, responses loaded aren't added to the cache
+	Verbose  bool
+}
+
+func (cr *CachingRetriever) Load(ctx context.Context, endpoint string) ([]byte, error) {
+	entry, found, getErr := cr.Cache.Get(endpoint)
+	if getErr != nil {
+		// a damaged cache shouldn't prevent the response from being loaded
+		log.Printf("WARNING: ignoring unreadable cache entry for endpoint(%s): %v\n", endpoint, getErr)
+	}
+	if found {
+		if cr.Verbose {
+			log.Printf("INFO: cache hit for endpoint(%s) fetched at %s\n", endpoint, entry.FetchedAt.Format(time.RFC3339))
+		}
+		return entry.Response, nil
+	}
+	if cr.Verbose {
+		log.Printf("INFO: cache miss for endpoint(%s)\n", endpoint)
+	}
+
+	response, loadErr := cr.ArtifactRetriever.Load(ctx, endpoint)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	if cr.ReadOnly {
+		return response, nil
+	}
+	if putErr := cr.Cache.Put(endpoint, response); putErr != nil {
+		log.Printf("WARNING: couldn't cache response for endpoint(%s): %v\n", endpoint, putErr)
+	}
+	return response, nil
+}
@@ -0,0 +1,162 @@
+package aeroapi
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingRetriever(t *testing.T) {
+
+	now := time.Date(2023, 5, 18, 20, 40, 0, 0, time.UTC)
+	newTrackResponse := func(lastPositionTime time.Time) []byte {
+		return []byte(fmt.Sprintf(`{"positions": [{"timestamp": %q}]}`, lastPositionTime.Format(time.RFC3339)))
+	}
+
+	testCases := []struct {
+		name              string
+		endpoint          string
+		response          []byte
+		elapsed           time.Duration
+		expectedImmutable bool
+		expectedLoads     int
+	}{
+		{
+			name:          "fresh flights",
+			endpoint:      "/flights/N12345",
+			response:      []byte(`{"flights": []}`),
+			elapsed:       time.Minute,
+			expectedLoads: 1,
+		},
+		{
+			name:          "expired flights",
+			endpoint:      "/flights/N12345",
+			response:      []byte(`{"flights": []}`),
+			elapsed:       time.Hour,
+			expectedLoads: 2,
+		},
+		{
+			name:              "completed track",
+			endpoint:          "/flights/N12345-1684452005-adhoc-1107p/track",
+			response:          newTrackResponse(now.Add(-24 * time.Hour)),
+			elapsed:           365 * 24 * time.Hour,
+			expectedImmutable: true,
+			expectedLoads:     1,
+		},
+		{
+			name:          "track in progress",
+			endpoint:      "/flights/N12345-1684452005-adhoc-1107p/track",
+			response:      newTrackResponse(now.Add(-time.Minute)),
+			elapsed:       time.Hour,
+			expectedLoads: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			clock := now
+			cache := &ResponseCache{Dir: filepath.Join(t.TempDir(), "cache"), now: func() time.Time { return clock }}
+			mockRetriever := &MockArtifactRetriever{Contents: tc.response}
+			retriever := &CachingRetriever{ArtifactRetriever: mockRetriever, Cache: cache}
+
+			for i := 0; i < 2; i++ {
+				response, loadErr := retriever.Load(context.Background(), tc.endpoint)
+				requirer.NoError(loadErr)
+				requirer.Equal(tc.response, response)
+				clock = clock.Add(tc.elapsed)
+			}
+			requirer.Equal(tc.expectedLoads, len(mockRetriever.RequestedEndpoints))
+
+			infos, listErr := cache.List()
+			requirer.NoError(listErr)
+			requirer.Len(infos, 1)
+			requirer.Equal(tc.endpoint, infos[0].Endpoint)
+			requirer.Equal(tc.expectedImmutable, infos[0].Immutable)
+		})
+	}
+}
+
+func TestResponseCache_Purge(t *testing.T) {
+	requirer := require.New(t)
+
+	clock := time.Date(2023, 5, 18, 20, 40, 0, 0, time.UTC)
+	cache := &ResponseCache{Dir: t.TempDir(), Ttl: time.Minute, now: func() time.Time { return clock }}
+	requirer.NoError(cache.Put("/flights/OLD", []byte("old")))
+	clock = clock.Add(time.Hour)
+	requirer.NoError(cache.Put("/flights/NEW", []byte("new")))
+
+	_, found, getErr := cache.Get("/flights/OLD")
+	requirer.NoError(getErr)
+	requirer.False(found)
+	entry, found, getErr := cache.Get("/flights/NEW")
+	requirer.NoError(getErr)
+	requirer.True(found)
+	requirer.Equal("new", string(entry.Response))
+
+	nPurged, purgeErr := cache.Purge(false)
+	requirer.NoError(purgeErr)
+	requirer.Equal(1, nPurged)
+	nPurged, purgeErr = cache.Purge(true)
+	requirer.NoError(purgeErr)
+	requirer.Equal(1, nPurged)
+
+	files, readDirErr := os.ReadDir(cache.Dir)
+	requirer.NoError(readDirErr)
+	requirer.Empty(files)
+}
+
+func TestResponseCache_BaseUrl(t *testing.T) {
+	requirer := require.New(t)
+	dir := t.TempDir()
+	liveCache := &ResponseCache{Dir: dir, BaseUrl: "https://aeroapi.flightaware.com/aeroapi"}
+	fakeCache := &ResponseCache{Dir: dir, BaseUrl: "http://localhost:8080"}
+	requirer.NoError(liveCache.Put("/flights/N12345", []byte("live")))
+
+	_, found, getErr := fakeCache.Get("/flights/N12345")
+	requirer.NoError(getErr)
+	requirer.False(found)
+	requirer.NoError(fakeCache.Put("/flights/N12345", []byte("fake")))
+
+	entry, found, getErr := liveCache.Get("/flights/N12345")
+	requirer.NoError(getErr)
+	requirer.True(found)
+	requirer.Equal("live", string(entry.Response))
+	infos, listErr := liveCache.List()
+	requirer.NoError(listErr)
+	requirer.Len(infos, 2)
+}
+
+func TestResponseCache_Unreadable(t *testing.T) {
+	requirer := require.New(t)
+	cache := &ResponseCache{Dir: t.TempDir()}
+	requirer.NoError(cache.Put("/flights/N12345", []byte("good")))
+	corruptFilename := cache.getEntryFilename("/flights/CORRUPT")
+	requirer.NoError(os.WriteFile(corruptFilename, []byte(`{"endpoint": "/fli`), 0644))
+
+	_, found, getErr := cache.Get("/flights/CORRUPT")
+	requirer.False(found)
+	requirer.ErrorContains(getErr, "couldn't read cache entry")
+
+	infos, listErr := cache.List()
+	requirer.NoError(listErr)
+	requirer.Len(infos, 2)
+	requirer.Equal("/flights/N12345", infos[0].Endpoint)
+	requirer.NoError(infos[0].Err)
+	requirer.Equal(corruptFilename, infos[1].Filename)
+	requirer.Error(infos[1].Err)
+
+	// unreadable entries are purged with those expired
+	nPurged, purgeErr := cache.Purge(false)
+	requirer.NoError(purgeErr)
+	requirer.Equal(1, nPurged)
+	infos, listErr = cache.List()
+	requirer.NoError(listErr)
+	requirer.Len(infos, 1)
+	requirer.NoError(infos[0].Err)
+}
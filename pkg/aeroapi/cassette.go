@@ -0,0 +1,229 @@
+package aeroapi
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// CassetteVersion identifies the format of the cassettes written by Recorder
+const CassetteVersion = 1
+
+// redactedRequestHeaders are the request headers which aren't recorded, since they're secret
+var redactedRequestHeaders = []string{"x-apikey", "Authorization"}
+
+// unmatchedQueryParameters are the query parameters disregarded when replaying a request, since
+// they give the time range resolved from expressions (e.g., "yesterday") relative to when it's made
+var unmatchedQueryParameters = []string{"start", "end"}
+
+// Cassette is a recording of the HTTP interactions of a session, in the order they occurred
+type Cassette struct {
+	Version      int           `json:"version"`
+	RecordedAt   time.Time     `json:"recordedAt"`
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is an HTTP request and the response received for it
+type Interaction struct {
+	Request  RecordedRequest  `json:"request"`
+	Response RecordedResponse `json:"response"`
+}
+
+type RecordedRequest struct {
+	Method string      `json:"method"`
+	Url    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+}
+
+type RecordedResponse struct {
+	StatusCode int         `json:"statusCode"`
+	Status     string      `json:"status"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       string      `json:"body"`
+	// BodyEncoding is "base64" if the body isn't (UTF-8) text, and so is encoded; otherwise empty
+	BodyEncoding string `json:"bodyEncoding,omitempty"`
+}
+
+// Recorder is an http.RoundTripper which records the requests made through it, along
+// with the responses received, so they can be saved to a cassette and replayed by Player
+type Recorder struct {
+	Transport http.RoundTripper // underlying transport (nil=http.DefaultTransport)
+	mu        sync.Mutex
+	cassette  Cassette
+}
+
+func (r *Recorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := r.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	resp, roundTripErr := transport.RoundTrip(req)
+	if roundTripErr != nil {
+		return nil, roundTripErr
+	}
+
+	body, readErr := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if readErr != nil {
+		return nil, readErr
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+
+	interaction := Interaction{
+		Request: RecordedRequest{
+			Method: req.Method,
+			Url:    req.URL.String(),
+			Header: req.Header.Clone(),
+		},
+		Response: RecordedResponse{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Header:     resp.Header.Clone(),
+		},
+	}
+	for _, header := range redactedRequestHeaders {
+		interaction.Request.Header.Del(header)
+	}
+	if utf8.Valid(body) {
+		interaction.Response.Body = string(body)
+	} else {
+		interaction.Response.Body = base64.StdEncoding.EncodeToString(body)
+		interaction.Response.BodyEncoding = "base64"
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, interaction)
+	return resp, nil
+}
+
+// Save writes the interactions recorded so far to the cassette file, returning their number
+func (r *Recorder) Save(filename string) (int, error) {
+	r.mu.Lock()
+	cassette := r.cassette
+	r.mu.Unlock()
+
+	cassette.Version = CassetteVersion
+	cassette.RecordedAt = time.Now().UTC()
+	cassetteBytes, marshalErr := json.MarshalIndent(cassette, "", "  ")
+	if marshalErr != nil {
+		return 0, marshalErr
+	}
+	return len(cassette.Interactions), os.WriteFile(filename, cassetteBytes, 0644)
+}
+
+// Player is an http.RoundTripper which replays the responses recorded in a cassette, rather
+// than making the requests.  Each recorded interaction is replayed once, in the order recorded,
+// for requests having the same method, path and query (i.e., regardless of the host), but for
+// their time range (so that a session is replayed the same on any day, see unmatchedQueryParameters).
+type Player struct {
+	cassette Cassette
+	mu       sync.Mutex
+	replayed []bool
+}
+
+// LoadCassette returns a Player replaying the interactions recorded in the cassette file
+func LoadCassette(filename string) (*Player, error) {
+	cassetteBytes, readErr := os.ReadFile(filename)
+	if readErr != nil {
+		return nil, readErr
+	}
+	var cassette Cassette
+	if unmarshalErr := json.Unmarshal(cassetteBytes, &cassette); unmarshalErr != nil {
+		return nil, fmt.Errorf("couldn't read cassette(%s): %w", filename, unmarshalErr)
+	}
+	if cassette.Version != CassetteVersion {
+		return nil, fmt.Errorf("unsupported version(%d) of cassette(%s)", cassette.Version, filename)
+	}
+	return &Player{cassette: cassette, replayed: make([]bool, len(cassette.Interactions))}, nil
+}
+
+// ReplayMissError is returned by Player for a request having no (more) recorded interactions,
+// which retrying the request wouldn't change
+type ReplayMissError struct {
+	Method     string
+	RequestURI string
+}
+
+func (e *ReplayMissError) NonRetryable() {}
+
+func (e *ReplayMissError) Error() string {
+	return fmt.Sprintf("no (more) interactions recorded for request(%s %s)", e.Method, e.RequestURI)
+}
+
+func (p *Player) RoundTrip(req *http.Request) (*http.Response, error) {
+	interaction, found := p.next(req)
+	if !found {
+		return nil, &ReplayMissError{Method: req.Method, RequestURI: req.URL.RequestURI()}
+	}
+
+	body := []byte(interaction.Response.Body)
+	if interaction.Response.BodyEncoding == "base64" {
+		var decodeErr error
+		if body, decodeErr = base64.StdEncoding.DecodeString(interaction.Response.Body); decodeErr != nil {
+			return nil, fmt.Errorf("couldn't decode recorded response body: %w", decodeErr)
+		}
+	}
+	return &http.Response{
+		Status:        interaction.Response.Status,
+		StatusCode:    interaction.Response.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        interaction.Response.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// Remaining returns the number of recorded interactions not yet replayed
+func (p *Player) Remaining() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var remaining int
+	for _, replayed := range p.replayed {
+		if !replayed {
+			remaining++
+		}
+	}
+	return remaining
+}
+
+func (p *Player) next(req *http.Request) (*Interaction, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for i := range p.cassette.Interactions {
+		if p.replayed[i] {
+			continue
+		}
+		interaction := &p.cassette.Interactions[i]
+		recordedUrl, parseErr := req.URL.Parse(interaction.Request.Url)
+		if parseErr != nil || interaction.Request.Method != req.Method || getReplayKey(recordedUrl) != getReplayKey(req.URL) {
+			continue
+		}
+		p.replayed[i] = true
+		return interaction, true
+	}
+	return nil, false
+}
+
+// getReplayKey returns the path and query (less its unmatchedQueryParameters) of the request URL
+func getReplayKey(u *url.URL) string {
+	query := u.Query()
+	for _, parameter := range unmatchedQueryParameters {
+		query.Del(parameter)
+	}
+	if len(query) == 0 {
+		return u.EscapedPath()
+	}
+	return u.EscapedPath() + "?" + query.Encode()
+}
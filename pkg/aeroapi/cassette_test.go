@@ -0,0 +1,168 @@
+package aeroapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCassette_RecordAndReplay(t *testing.T) {
+	requirer := require.New(t)
+
+	binaryBody := string([]byte{0xff, 0xfe, 0x00, 0x01})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-Path", r.URL.Path)
+		switch r.URL.Path {
+		case "/flights/N12345":
+			_, _ = w.Write([]byte(`{"flights":[]}`))
+		case "/flights/N12345-1/track":
+			_, _ = w.Write([]byte(binaryBody))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"detail":"not found"}`))
+		}
+	}))
+	defer server.Close()
+
+	endpoints := []string{"/flights/N12345?end=2023-05-18T20:40:00Z", "/flights/N12345-1/track", "/flights/unknown"}
+	get := func(client *http.Client, baseUrl, endpoint string) (*http.Response, string, error) {
+		req, newRequestErr := http.NewRequest(http.MethodGet, baseUrl+endpoint, nil)
+		requirer.NoError(newRequestErr)
+		req.Header.Set("x-apikey", "secret")
+		resp, doErr := client.Do(req)
+		if doErr != nil {
+			return nil, "", doErr
+		}
+		defer func() { _ = resp.Body.Close() }()
+		body, readErr := io.ReadAll(resp.Body)
+		requirer.NoError(readErr)
+		return resp, string(body), nil
+	}
+
+	recorder := &Recorder{}
+	var recordedBodies []string
+	var recordedStatuses []int
+	for _, endpoint := range endpoints {
+		resp, body, getErr := get(&http.Client{Transport: recorder}, server.URL, endpoint)
+		requirer.NoError(getErr)
+		recordedBodies = append(recordedBodies, body)
+		recordedStatuses = append(recordedStatuses, resp.StatusCode)
+	}
+	requirer.Equal(binaryBody, recordedBodies[1])
+	requirer.Equal([]int{http.StatusOK, http.StatusOK, http.StatusNotFound}, recordedStatuses)
+
+	cassetteFilename := filepath.Join(t.TempDir(), "session.json")
+	nInteractions, saveErr := recorder.Save(cassetteFilename)
+	requirer.NoError(saveErr)
+	requirer.Equal(len(endpoints), nInteractions)
+	cassetteBytes, readErr := os.ReadFile(cassetteFilename)
+	requirer.NoError(readErr)
+	requirer.NotContains(string(cassetteBytes), "secret")
+
+	player, loadErr := LoadCassette(cassetteFilename)
+	requirer.NoError(loadErr)
+	requirer.Equal(len(endpoints), player.Remaining())
+
+	// replay against a different host, as when reproducing a session recorded elsewhere
+	client := &http.Client{Transport: player}
+	for i, endpoint := range endpoints {
+		resp, body, getErr := get(client, "http://replay.invalid", endpoint)
+		requirer.NoError(getErr)
+		requirer.Equal(recordedStatuses[i], resp.StatusCode)
+		requirer.Equal(recordedBodies[i], body)
+		requirer.Equal(strings.SplitN(endpoint, "?", 2)[0], resp.Header.Get("X-Served-Path"))
+	}
+	requirer.Equal(0, player.Remaining())
+
+	// each interaction is replayed only once
+	_, _, getErr := get(client, "http://replay.invalid", endpoints[0])
+	requirer.Error(getErr)
+	requirer.Contains(getErr.Error(), "no (more) interactions recorded for request(GET /flights/N12345?end=2023-05-18T20:40:00Z)")
+	requirer.True(errorAs[*ReplayMissError](getErr))
+
+	// nor is a request lacking an interaction retried
+	var attempts int
+	retryingClient := &http.Client{Transport: &RetryingTransport{
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			attempts++
+			return player.RoundTrip(req)
+		}),
+		BaseDelay: time.Millisecond,
+	}}
+	_, _, getErr = get(retryingClient, "http://replay.invalid", endpoints[0])
+	requirer.Error(getErr)
+	requirer.Equal(1, attempts)
+}
+
+func TestPlayer_TimeRange(t *testing.T) {
+	requirer := require.New(t)
+
+	// recorded for flights "yesterday", a day before replayed
+	player := &Player{
+		cassette: Cassette{Interactions: []Interaction{
+			{
+				Request:  RecordedRequest{Method: http.MethodGet, Url: "https://aeroapi.invalid/flights/N12345?&start=2023-05-17T00:00:00Z&end=2023-05-18T00:00:00Z"},
+				Response: RecordedResponse{StatusCode: http.StatusOK, Status: "200 OK", Body: `{"flights":[]}`},
+			},
+		}},
+		replayed: make([]bool, 1),
+	}
+	client := &http.Client{Transport: player}
+
+	_, otherFlightsErr := client.Get("http://replay.invalid/flights/N54321?&start=2023-05-18T00:00:00Z&end=2023-05-19T00:00:00Z")
+	requirer.Error(otherFlightsErr)
+	requirer.True(errorAs[*ReplayMissError](otherFlightsErr))
+
+	resp, getErr := client.Get("http://replay.invalid/flights/N12345?&start=2023-05-18T00:00:00Z&end=2023-05-19T00:00:00Z")
+	requirer.NoError(getErr)
+	defer func() { _ = resp.Body.Close() }()
+	requirer.Equal(http.StatusOK, resp.StatusCode)
+	requirer.Equal(0, player.Remaining())
+}
+
+func TestLoadCassette(t *testing.T) {
+
+	testCases := []struct {
+		name          string
+		contents      string
+		expectedError string
+	}{
+		{
+			name:          "not json",
+			contents:      "not json",
+			expectedError: "couldn't read cassette",
+		},
+		{
+			name:          "unsupported version",
+			contents:      `{"version":99,"interactions":[]}`,
+			expectedError: "unsupported version(99)",
+		},
+		{
+			name:     "empty",
+			contents: `{"version":1,"interactions":[]}`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			cassetteFilename := filepath.Join(t.TempDir(), "session.json")
+			requirer.NoError(os.WriteFile(cassetteFilename, []byte(tc.contents), 0644))
+			player, loadErr := LoadCassette(cassetteFilename)
+			if tc.expectedError != "" {
+				requirer.Error(loadErr)
+				requirer.Contains(loadErr.Error(), tc.expectedError)
+				return
+			}
+			requirer.NoError(loadErr)
+			requirer.Equal(0, player.Remaining())
+		})
+	}
+}
@@ -0,0 +1,101 @@
+package aeroapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// EnvelopeSchemaVersion is the version of the schema of the artifacts saved in an Envelope.
+// Bump it whenever the interpretation of saved bodies changes (as it did when altitudes were
+// reinterpreted as MSL rather than AGL), so that artifacts saved under earlier versions can be
+// recognized and migrated.
+//
+// Version history:
+//
+//	0 - unknown; a raw response saved before its schema was recorded (see EnvelopeSchemaVersionUnknown)
+//	1 - AeroAPI v4 responses; position altitudes are feet / 100 MSL
+const EnvelopeSchemaVersion = 1
+
+// EnvelopeSchemaVersionUnknown marks an artifact whose schema wasn't recorded when it was saved (e.g.,
+// a raw response since wrapped in an Envelope), such that the interpretation of its body is uncertain
+const EnvelopeSchemaVersionUnknown = 0
+
+// envelopeMarker is the key identifying an Envelope, distinguishing it from a raw AeroAPI response
+const envelopeMarker = "fvizSchemaVersion"
+
+// Envelope wraps a saved AeroAPI response, describing where and when it was obtained
+type Envelope struct {
+	SchemaVersion int             `json:"fvizSchemaVersion"`
+	Provenance    Provenance      `json:"provenance"`
+	FetchedAt     time.Time       `json:"fetchedAt"`
+	Endpoint      string          `json:"endpoint"`        // e.g., "/flights/N12345"
+	Query         url.Values      `json:"query,omitempty"` // e.g., {"end": ["2023-05-23T22:00:00Z"]}
+	Migrated      bool            `json:"migrated,omitempty"`
+	Body          json.RawMessage `json:"body"`
+}
+
+// Provenance identifies the tool which saved an artifact
+type Provenance struct {
+	Tool    string `json:"tool"`
+	Version string `json:"version"`
+}
+
+// NewEnvelope wraps the response obtained from the endpoint (which may include a query)
+func NewEnvelope(provenance Provenance, endpoint string, fetchedAt time.Time, body []byte) (*Envelope, error) {
+	if !json.Valid(body) {
+		return nil, fmt.Errorf("response from(%s) isn't valid JSON", endpoint)
+	}
+	envelope := &Envelope{
+		SchemaVersion: EnvelopeSchemaVersion,
+		Provenance:    provenance,
+		FetchedAt:     fetchedAt.UTC(),
+		Endpoint:      endpoint,
+		Body:          body,
+	}
+	if endpointUrl, parseErr := url.Parse(endpoint); parseErr == nil {
+		envelope.Endpoint = endpointUrl.Path
+		if query := endpointUrl.Query(); len(query) > 0 {
+			envelope.Query = query
+		}
+	}
+	return envelope, nil
+}
+
+// Marshal returns the JSON representation of the envelope
+func (e *Envelope) Marshal() ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+// IsEnvelope returns true if the artifact's contents are wrapped in an Envelope
+func IsEnvelope(contents []byte) bool {
+	trimmed := bytes.TrimSpace(contents)
+	if len(trimmed) == 0 || trimmed[0] != '{' {
+		return false
+	}
+	var probe map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal(trimmed, &probe); unmarshalErr != nil {
+		return false
+	}
+	_, hasMarker := probe[envelopeMarker]
+	return hasMarker
+}
+
+// UnwrapArtifact returns the AeroAPI response held in an artifact, along with the Envelope
+// describing it (or nil, if the artifact is a raw response saved without one)
+func UnwrapArtifact(contents []byte) ([]byte, *Envelope, error) {
+	if !IsEnvelope(contents) {
+		return contents, nil, nil
+	}
+	var envelope Envelope
+	if unmarshalErr := json.Unmarshal(contents, &envelope); unmarshalErr != nil {
+		return nil, nil, fmt.Errorf("invalid artifact envelope: %w", unmarshalErr)
+	}
+	if envelope.SchemaVersion < EnvelopeSchemaVersionUnknown || envelope.SchemaVersion > EnvelopeSchemaVersion {
+		return nil, nil, fmt.Errorf("unsupported artifact schema version(%d); expected at most %d",
+			envelope.SchemaVersion, EnvelopeSchemaVersion)
+	}
+	return envelope.Body, &envelope, nil
+}
@@ -0,0 +1,91 @@
+package aeroapi
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/persistence"
+)
+
+func TestEnvelope(t *testing.T) {
+	requirer := require.New(t)
+
+	provenance := Provenance{Tool: "fviz", Version: "v1.2.3"}
+	fetchedAt := time.Date(2023, 5, 23, 22, 0, 0, 0, time.FixedZone("HST", -10*60*60))
+	body := []byte(`{"positions":[{"altitude":12}]}`)
+	envelope, newEnvelopeErr := NewEnvelope(provenance, "/flights/N12345?&end=2023-05-23T22:00:00Z", fetchedAt, body)
+	requirer.NoError(newEnvelopeErr)
+	requirer.Equal("/flights/N12345", envelope.Endpoint)
+	requirer.Equal(url.Values{"end": []string{"2023-05-23T22:00:00Z"}}, envelope.Query)
+	requirer.Equal(time.UTC, envelope.FetchedAt.Location())
+
+	envelopeBytes, marshalErr := envelope.Marshal()
+	requirer.NoError(marshalErr)
+	requirer.True(IsEnvelope(envelopeBytes))
+	requirer.False(IsEnvelope(body))
+	requirer.False(IsEnvelope([]byte(`[]`)))
+
+	unwrapped, unwrappedEnvelope, unwrapErr := UnwrapArtifact(envelopeBytes)
+	requirer.NoError(unwrapErr)
+	requirer.JSONEq(string(body), string(unwrapped))
+	requirer.Equal(provenance, unwrappedEnvelope.Provenance)
+	requirer.Equal(EnvelopeSchemaVersion, unwrappedEnvelope.SchemaVersion)
+	requirer.True(fetchedAt.Equal(unwrappedEnvelope.FetchedAt))
+
+	// raw responses (i.e., artifacts saved before the envelope existed) are accepted as they are
+	unwrapped, unwrappedEnvelope, unwrapErr = UnwrapArtifact(body)
+	requirer.NoError(unwrapErr)
+	requirer.Equal(body, unwrapped)
+	requirer.Nil(unwrappedEnvelope)
+
+	// both forms are accepted by the loaders
+	for _, contents := range [][]byte{body, envelopeBytes} {
+		track, trackErr := TrackFromJson(contents)
+		requirer.NoError(trackErr)
+		requirer.Len(track.Positions, 1)
+		requirer.Equal(12.0, track.Positions[0].AltMslD100)
+	}
+
+	// artifacts whose schema is unknown (e.g., migrated raw responses) are accepted
+	_, unwrappedEnvelope, unwrapErr = UnwrapArtifact([]byte(`{"fvizSchemaVersion": 0, "body": {}}`))
+	requirer.NoError(unwrapErr)
+	requirer.Equal(EnvelopeSchemaVersionUnknown, unwrappedEnvelope.SchemaVersion)
+
+	_, _, unwrapErr = UnwrapArtifact([]byte(`{"fvizSchemaVersion": -1, "body": {}}`))
+	requirer.Error(unwrapErr)
+	_, _, unwrapErr = UnwrapArtifact([]byte(`{"fvizSchemaVersion": 99, "body": {}}`))
+	requirer.Error(unwrapErr)
+	requirer.Contains(unwrapErr.Error(), "unsupported artifact schema version(99)")
+
+	_, newEnvelopeErr = NewEnvelope(provenance, "/flights/N12345", fetchedAt, []byte("<html>"))
+	requirer.Error(newEnvelopeErr)
+}
+
+func TestRetrieverSaverApiImpl_SaveEnvelope(t *testing.T) {
+	requirer := require.New(t)
+
+	trackJson := []byte(`{"positions":[]}`)
+	responseSaver := &testResponseSaver{}
+	api := &RetrieverSaverApiImpl{
+		Retriever:  &MockArtifactRetriever{Contents: trackJson},
+		Saver:      &FileAeroApi{FileSaver: persistence.FileSaver{Writer: responseSaver.Save}},
+		Provenance: &Provenance{Tool: "fviz", Version: "test"},
+	}
+	_, getTrackErr := api.GetTrackForFlightId(context.Background(), "N12345-1")
+	requirer.NoError(getTrackErr)
+	requirer.Len(responseSaver.responses, 1)
+	_, envelope, unwrapErr := UnwrapArtifact(responseSaver.responses[0].contents)
+	requirer.NoError(unwrapErr)
+	requirer.NotNil(envelope)
+	requirer.Equal("/fli/N12345-1/track", envelope.Endpoint)
+
+	// an artifact loaded in its envelope is re-saved as it is, rather than wrapped again
+	api.Retriever = &MockArtifactRetriever{Contents: responseSaver.responses[0].contents}
+	_, getTrackErr = api.GetTrackForFlightId(context.Background(), "N12345-1")
+	requirer.NoError(getTrackErr)
+	requirer.Equal(responseSaver.responses[0].contents, responseSaver.responses[1].contents)
+}
@@ -0,0 +1,95 @@
+package aeroapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ResponseError describes an unsuccessful response from AeroAPI
+type ResponseError struct {
+	StatusCode int
+	Status     string
+	Body       string
+	RetryAfter time.Duration // delay requested by the server before retrying (0=unspecified)
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("statusCode(%d), status(%s), body(%s)", e.StatusCode, e.Status, e.Body)
+}
+
+// AuthError indicates that AeroAPI rejected the credentials (e.g., the API key) presented
+type AuthError struct{ *ResponseError }
+
+// NotFoundError indicates that AeroAPI has no record of the requested resource (e.g., flight)
+type NotFoundError struct{ *ResponseError }
+
+// RateLimitedError indicates that AeroAPI refused the request because too many were made
+type RateLimitedError struct{ *ResponseError }
+
+// ServerError indicates that AeroAPI failed to process the request (e.g., it's overloaded)
+type ServerError struct{ *ResponseError }
+
+// Unwrap returns the ResponseError, so that errors.As finds it in any of the errors classifying it
+func (e *AuthError) Unwrap() error { return e.ResponseError }
+
+func (e *NotFoundError) Unwrap() error { return e.ResponseError }
+
+func (e *RateLimitedError) Unwrap() error { return e.ResponseError }
+
+func (e *ServerError) Unwrap() error { return e.ResponseError }
+
+// newResponseError returns the error, of a type which classifies it, describing the unsuccessful response
+func newResponseError(resp *http.Response, body []byte) error {
+	responseErr := &ResponseError{
+		StatusCode: resp.StatusCode,
+		Status:     resp.Status,
+		Body:       string(body),
+		RetryAfter: getRetryAfter(resp, time.Now()),
+	}
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return &AuthError{responseErr}
+	case resp.StatusCode == http.StatusNotFound:
+		return &NotFoundError{responseErr}
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return &RateLimitedError{responseErr}
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return &ServerError{responseErr}
+	}
+	return responseErr
+}
+
+// IsRetryable returns true if the request which failed with the error might succeed if retried
+func IsRetryable(err error) bool {
+	var rateLimitedErr *RateLimitedError
+	var serverErr *ServerError
+	return errors.As(err, &rateLimitedErr) || errors.As(err, &serverErr)
+}
+
+// IsFatal returns true if the error would prevent any further request from succeeding,
+// such that there's no point in making them
+func IsFatal(err error) bool {
+	var authErr *AuthError
+	var rateLimitedErr *RateLimitedError
+	var budgetExceededErr *BudgetExceededError
+	return errors.As(err, &authErr) || errors.As(err, &rateLimitedErr) || errors.As(err, &budgetExceededErr)
+}
+
+// getRetryAfter returns the delay requested by the response's "Retry-After" header, if any,
+// which may be given either in seconds or as an HTTP date
+func getRetryAfter(resp *http.Response, now time.Time) time.Duration {
+	retryAfter := resp.Header.Get("Retry-After")
+	if retryAfter == "" {
+		return 0
+	}
+	if seconds, parseErr := strconv.Atoi(retryAfter); parseErr == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if retryTime, parseErr := http.ParseTime(retryAfter); parseErr == nil && retryTime.After(now) {
+		return retryTime.Sub(now)
+	}
+	return 0
+}
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"github.com/noodnik2/flightvisualizer/pkg/persistence"
 )
@@ -26,7 +25,7 @@ func MakeTrackArtifactFilename(flightId string) string {
 }
 
 func IsTrackArtifactFilename(fn string) bool {
-	base := filepath.Base(fn)
+	base := persistence.TrimCompressionSuffix(filepath.Base(fn))
 	return strings.HasPrefix(base, trackArtifactFilenamePrefix) && strings.HasSuffix(base, trackArtifactFilenameSuffix)
 }
 
@@ -35,38 +34,56 @@ func MakeFlightIdsArtifactFilename(queryId string) string {
 }
 
 func IsFlightIdsArtifactFilename(fn string) bool {
-	base := filepath.Base(fn)
+	base := persistence.TrimCompressionSuffix(filepath.Base(fn))
 	return strings.HasPrefix(base, flightIdsArtifactFilenamePrefix) && strings.HasSuffix(base, flightIdsArtifactFilenameSuffix)
 }
 
-func (c *FileAeroApi) GetFlightIdsRef(tailNumber string, cutoffTime time.Time) (string, error) {
+// ParseTrackArtifactFilename returns the flight id of the track held in the "track" artifact
+func ParseTrackArtifactFilename(fn string) (string, bool) {
+	if !IsTrackArtifactFilename(fn) {
+		return "", false
+	}
+	base := persistence.TrimCompressionSuffix(filepath.Base(fn))
+	return strings.TrimSuffix(strings.TrimPrefix(base, trackArtifactFilenamePrefix), trackArtifactFilenameSuffix), true
+}
+
+// ParseFlightIdsArtifactFilename returns the ident (e.g., tail number) and time range
+// of the query whose response is held in the "flight ids" artifact
+func ParseFlightIdsArtifactFilename(fn string) (string, TimeRange, bool) {
+	if !IsFlightIdsArtifactFilename(fn) {
+		return "", TimeRange{}, false
+	}
+	base := persistence.TrimCompressionSuffix(filepath.Base(fn))
+	queryId := strings.TrimSuffix(strings.TrimPrefix(base, flightIdsArtifactFilenamePrefix), flightIdsArtifactFilenameSuffix)
+	timeRange, rangeLength, parseErr := parseTimeRangeQueryId(queryId)
+	if parseErr != nil {
+		return "", TimeRange{}, false
+	}
+	return queryId[:len(queryId)-rangeLength], timeRange, true
+}
+
+func (c *FileAeroApi) GetFlightIdsRef(tailNumber string, timeRange TimeRange) (string, error) {
 	var fileName string
 	if c.FlightIdsFileName != "" {
 		fileName = c.FlightIdsFileName
 	} else {
-		var queryId string
-		if cutoffTime.IsZero() {
-			queryId = tailNumber
-		} else {
-			queryId = fmt.Sprintf("%s_cutoff-%s", tailNumber, cutoffTime.Format("20060102T150405Z0700"))
-		}
-		fileName = MakeFlightIdsArtifactFilename(queryId)
+		fileName = MakeFlightIdsArtifactFilename(tailNumber + timeRange.getQueryId())
 	}
 	if !IsFlightIdsArtifactFilename(filepath.Base(fileName)) {
 		return "", fmt.Errorf("unrecognized flight ids filename(%s)", fileName)
 	}
-	if filepath.Dir(fileName) == "." {
+	if persistence.DirRef(fileName) == "." {
 		// use the artifacts directory if not specified
-		return filepath.Join(c.ArtifactsDir, fileName), nil
+		return persistence.JoinRef(c.ArtifactsDir, fileName), nil
 	}
 	return fileName, nil
 }
 
 func (c *FileAeroApi) GetTrackForFlightRef(flightId string) string {
-	artifactDir := filepath.Dir(c.FlightIdsFileName)
+	artifactDir := persistence.DirRef(c.FlightIdsFileName)
 	if artifactDir == "." {
 		// use the artifacts directory if not specified
 		artifactDir = c.ArtifactsDir
 	}
-	return filepath.Join(artifactDir, MakeTrackArtifactFilename(flightId))
+	return persistence.JoinRef(artifactDir, MakeTrackArtifactFilename(flightId))
 }
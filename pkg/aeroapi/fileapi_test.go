@@ -1,6 +1,7 @@
 package aeroapi
 
 import (
+	"context"
 	"path/filepath"
 	"testing"
 	"time"
@@ -45,7 +46,7 @@ func TestFileAeroApi_Save(t *testing.T) {
 			saveErr := fileAeroApi.Save(saveFilename, []byte(saveContents))
 			requirer.NoError(saveErr)
 
-			actuallyLoadedContents, readErr := fileAeroApi.Load(loadFilename)
+			actuallyLoadedContents, readErr := fileAeroApi.Load(context.Background(), loadFilename)
 			requirer.NoError(readErr)
 
 			requirer.Equal(loadFilename, actuallyLoadedFilename)
@@ -67,7 +68,7 @@ func TestFileAeroApi_GetUris(t *testing.T) {
 		flightIdsFileName    string
 		flightId             string
 		tailNumber           string
-		cutoffTime           time.Time
+		timeRange            TimeRange
 		expectedFlightIdsUri string
 		expectedTrackUri     string
 		expectedErrors       []string
@@ -92,7 +93,7 @@ func TestFileAeroApi_GetUris(t *testing.T) {
 			artifactsDir:         "cDir",
 			flightId:             "cFid",
 			tailNumber:           "cT#",
-			cutoffTime:           time.Date(2023, 5, 24, 14, 2, 3, 4, time.UTC),
+			timeRange:            TimeRange{End: time.Date(2023, 5, 24, 14, 2, 3, 4, time.UTC)},
 			expectedFlightIdsUri: filepath.Join("cDir", MakeFlightIdsArtifactFilename("cT#_cutoff-20230524T140203Z")),
 			expectedTrackUri:     filepath.Join("cDir", MakeTrackArtifactFilename("cFid")),
 		},
@@ -101,10 +102,28 @@ func TestFileAeroApi_GetUris(t *testing.T) {
 			artifactsDir:         "dDir",
 			flightId:             "dFid",
 			tailNumber:           "dT#",
-			cutoffTime:           time.Date(2023, 5, 24, 14, 2, 3, 4, time.FixedZone("PDT", -7*60*60)),
+			timeRange:            TimeRange{End: time.Date(2023, 5, 24, 14, 2, 3, 4, time.FixedZone("PDT", -7*60*60))},
 			expectedFlightIdsUri: filepath.Join("dDir", MakeFlightIdsArtifactFilename("dT#_cutoff-20230524T140203-0700")),
 			expectedTrackUri:     filepath.Join("dDir", MakeTrackArtifactFilename("dFid")),
 		},
+		{
+			name:                 "with start time",
+			artifactsDir:         "gDir",
+			flightId:             "gFid",
+			tailNumber:           "gT#",
+			timeRange:            TimeRange{Start: time.Date(2023, 5, 22, 8, 0, 0, 0, time.UTC)},
+			expectedFlightIdsUri: filepath.Join("gDir", MakeFlightIdsArtifactFilename("gT#_from-20230522T080000Z")),
+			expectedTrackUri:     filepath.Join("gDir", MakeTrackArtifactFilename("gFid")),
+		},
+		{
+			name:                 "with time range",
+			artifactsDir:         "hDir",
+			flightId:             "hFid",
+			tailNumber:           "hT#",
+			timeRange:            TimeRange{Start: time.Date(2023, 5, 22, 8, 0, 0, 0, time.UTC), End: time.Date(2023, 5, 24, 14, 2, 3, 4, time.UTC)},
+			expectedFlightIdsUri: filepath.Join("hDir", MakeFlightIdsArtifactFilename("hT#_range-20230522T080000Z_20230524T140203Z")),
+			expectedTrackUri:     filepath.Join("hDir", MakeTrackArtifactFilename("hFid")),
+		},
 		{
 			name:                 "with FlightIdsFileName",
 			artifactsDir:         "eDir",
@@ -129,7 +148,7 @@ func TestFileAeroApi_GetUris(t *testing.T) {
 			requirer := require.New(t)
 
 			fileAeroApi := &FileAeroApi{ArtifactsDir: tc.artifactsDir, FlightIdsFileName: tc.flightIdsFileName}
-			fidsRef, getFidsRefErr := fileAeroApi.GetFlightIdsRef(tc.tailNumber, tc.cutoffTime)
+			fidsRef, getFidsRefErr := fileAeroApi.GetFlightIdsRef(tc.tailNumber, tc.timeRange)
 			if tc.expectedErrors != nil {
 				requirer.Error(getFidsRefErr)
 				for _, expectedErr := range tc.expectedErrors {
@@ -143,3 +162,48 @@ func TestFileAeroApi_GetUris(t *testing.T) {
 		})
 	}
 }
+
+func TestParseArtifactFilenames(t *testing.T) {
+	requirer := require.New(t)
+
+	flightId, isTrack := ParseTrackArtifactFilename(filepath.Join("aDir", MakeTrackArtifactFilename("N335SP-1684874159-adhoc-1864p")))
+	requirer.True(isTrack)
+	requirer.Equal("N335SP-1684874159-adhoc-1864p", flightId)
+	_, isTrack = ParseTrackArtifactFilename("fvk_N335SP.kmz")
+	requirer.False(isTrack)
+	flightId, isTrack = ParseTrackArtifactFilename(MakeTrackArtifactFilename("N335SP-1") + ".zst")
+	requirer.True(isTrack)
+	requirer.Equal("N335SP-1", flightId)
+
+	testCases := []struct {
+		queryId           string
+		expectedIdent     string
+		expectedTimeRange TimeRange
+		expectedOk        bool
+	}{
+		{queryId: "N335SP", expectedIdent: "N335SP", expectedOk: true},
+		{
+			queryId:           "N335SP_cutoff-20230523T220000Z",
+			expectedIdent:     "N335SP",
+			expectedTimeRange: TimeRange{End: time.Date(2023, 5, 23, 22, 0, 0, 0, time.UTC)},
+			expectedOk:        true,
+		},
+		{
+			queryId:           "N335SP_range-20230522T080000Z_20230523T220000-0700",
+			expectedIdent:     "N335SP",
+			expectedTimeRange: TimeRange{Start: time.Date(2023, 5, 22, 8, 0, 0, 0, time.UTC), End: time.Date(2023, 5, 24, 5, 0, 0, 0, time.UTC)},
+			expectedOk:        true,
+		},
+		{queryId: "N335SP_from-yesterday"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.queryId, func(t *testing.T) {
+			requirer := require.New(t)
+			ident, timeRange, ok := ParseFlightIdsArtifactFilename(MakeFlightIdsArtifactFilename(tc.queryId))
+			requirer.Equal(tc.expectedOk, ok)
+			requirer.Equal(tc.expectedIdent, ident)
+			requirer.True(tc.expectedTimeRange.Start.Equal(timeRange.Start))
+			requirer.True(tc.expectedTimeRange.End.Equal(timeRange.End))
+		})
+	}
+}
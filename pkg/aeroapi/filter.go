@@ -0,0 +1,118 @@
+package aeroapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Kinds of flights which can be excluded by a FlightFilter
+const (
+	FlightKindCancelled    = "cancelled"
+	FlightKindDiverted     = "diverted"
+	FlightKindPositionOnly = "positionOnly"
+)
+
+// FlightFilter selects the flights meeting all of its criteria; zero-valued criteria select all flights
+type FlightFilter struct {
+	Origins       []string      // codes (e.g., ICAO, IATA or LID) of the airports from which flights may depart
+	Destinations  []string      // codes of the airports to which flights may be destined
+	AircraftTypes []string      // ICAO aircraft type designators (e.g., "C172") of the flights
+	MinDuration   time.Duration // minimum time from takeoff to landing
+	MaxDuration   time.Duration // maximum time from takeoff to landing (0=unlimited)
+	MinDistance   int           // minimum route distance (statute miles, as reported by AeroAPI)
+	Exclude       []string      // kinds of flights (e.g., FlightKindCancelled) excluded
+}
+
+// ParseFlightKinds parses a list such as "cancelled,diverted" of the kinds of flights to exclude
+func ParseFlightKinds(spec string) ([]string, error) {
+	var kinds []string
+	for _, kind := range splitList(spec) {
+		switch kind {
+		case FlightKindCancelled, FlightKindDiverted, FlightKindPositionOnly:
+			kinds = append(kinds, kind)
+		default:
+			return nil, fmt.Errorf("unrecognized kind of flight(%s); expected one of {%s, %s, %s}",
+				kind, FlightKindCancelled, FlightKindDiverted, FlightKindPositionOnly)
+		}
+	}
+	return kinds, nil
+}
+
+// ParseCodes parses a list of codes, such as "KSFO,KOAK", into a slice
+func ParseCodes(spec string) []string {
+	return splitList(spec)
+}
+
+// IsZero returns true if the filter has no criteria (i.e., selects all flights)
+func (ff *FlightFilter) IsZero() bool {
+	return len(ff.Origins) == 0 && len(ff.Destinations) == 0 && len(ff.AircraftTypes) == 0 &&
+		ff.MinDuration == 0 && ff.MaxDuration == 0 && ff.MinDistance == 0 && len(ff.Exclude) == 0
+}
+
+// Apply returns the flights selected by the filter, in their original order
+func (ff *FlightFilter) Apply(flights []Flight) []Flight {
+	var selected []Flight
+	for _, flight := range flights {
+		if ff.Matches(&flight) {
+			selected = append(selected, flight)
+		}
+	}
+	return selected
+}
+
+// Matches returns true if the flight meets all the criteria of the filter
+func (ff *FlightFilter) Matches(flight *Flight) bool {
+	for _, kind := range ff.Exclude {
+		if (kind == FlightKindCancelled && flight.Cancelled) ||
+			(kind == FlightKindDiverted && flight.Diverted) ||
+			(kind == FlightKindPositionOnly && flight.PositionOnly) {
+			return false
+		}
+	}
+	if len(ff.Origins) > 0 && !isAirportOneOf(flight.Origin, ff.Origins) {
+		return false
+	}
+	if len(ff.Destinations) > 0 && !isAirportOneOf(flight.Destination, ff.Destinations) {
+		return false
+	}
+	if len(ff.AircraftTypes) > 0 && !isOneOf(flight.AircraftType, ff.AircraftTypes) {
+		return false
+	}
+	duration := flight.GetDuration()
+	if (ff.MinDuration > 0 && duration < ff.MinDuration) || (ff.MaxDuration > 0 && duration > ff.MaxDuration) {
+		return false
+	}
+	return ff.MinDistance <= 0 || flight.RouteDistance >= ff.MinDistance
+}
+
+func isAirportOneOf(airport *FlightAirport, codes []string) bool {
+	if airport == nil {
+		return false
+	}
+	for _, code := range []string{airport.Code, airport.CodeIcao, airport.CodeIata, airport.CodeLid} {
+		if code != "" && isOneOf(code, codes) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOneOf(value string, candidates []string) bool {
+	for _, candidate := range candidates {
+		if strings.EqualFold(value, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+func splitList(spec string) []string {
+	var items []string
+	for _, item := range strings.Split(spec, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
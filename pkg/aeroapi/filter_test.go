@@ -0,0 +1,96 @@
+package aeroapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFlightFilter_Apply(t *testing.T) {
+
+	takeoff := time.Date(2023, 5, 23, 18, 0, 0, 0, time.UTC)
+	landing := func(d time.Duration) *time.Time {
+		landingTime := takeoff.Add(d)
+		return &landingTime
+	}
+	sfo := &FlightAirport{Code: "KSFO", CodeIcao: "KSFO", CodeIata: "SFO", CodeLid: "SFO"}
+	ogg := &FlightAirport{Code: "PHOG", CodeIcao: "PHOG", CodeIata: "OGG", CodeLid: "OGG"}
+	flights := []Flight{
+		{FlightId: "long", Origin: sfo, Destination: ogg, AircraftType: "B738", RouteDistance: 2350, ActualOff: &takeoff, ActualOn: landing(5*time.Hour + 30*time.Minute)},
+		{FlightId: "local", Origin: ogg, Destination: ogg, AircraftType: "C172", ActualOff: &takeoff, ActualOn: landing(90 * time.Minute)},
+		{FlightId: "filed", Origin: ogg, Destination: sfo, AircraftType: "B738", RouteDistance: 2350, FiledEte: 5 * 60 * 60, Diverted: true},
+		{FlightId: "cancelled", Origin: sfo, Destination: ogg, AircraftType: "B738", RouteDistance: 2350, Cancelled: true},
+		{FlightId: "positionOnly", PositionOnly: true, ActualOff: &takeoff, ActualOn: landing(time.Hour)},
+	}
+
+	testCases := []struct {
+		name              string
+		filter            FlightFilter
+		expectedFlightIds []string
+	}{
+		{
+			name:              "no criteria",
+			expectedFlightIds: []string{"long", "local", "filed", "cancelled", "positionOnly"},
+		},
+		{
+			name:              "origin by any code",
+			filter:            FlightFilter{Origins: []string{"ogg", "KBOS"}},
+			expectedFlightIds: []string{"local", "filed"},
+		},
+		{
+			name:              "destination",
+			filter:            FlightFilter{Destinations: []string{"PHOG"}},
+			expectedFlightIds: []string{"long", "local", "cancelled"},
+		},
+		{
+			name:              "aircraft type",
+			filter:            FlightFilter{AircraftTypes: []string{"c172"}},
+			expectedFlightIds: []string{"local"},
+		},
+		{
+			name:              "duration, actual or filed",
+			filter:            FlightFilter{MinDuration: time.Hour, MaxDuration: 5 * time.Hour},
+			expectedFlightIds: []string{"local", "filed", "positionOnly"},
+		},
+		{
+			name:              "distance",
+			filter:            FlightFilter{MinDistance: 100},
+			expectedFlightIds: []string{"long", "filed", "cancelled"},
+		},
+		{
+			name:              "excluded kinds",
+			filter:            FlightFilter{Exclude: []string{FlightKindCancelled, FlightKindDiverted, FlightKindPositionOnly}},
+			expectedFlightIds: []string{"long", "local"},
+		},
+		{
+			name:   "combined criteria",
+			filter: FlightFilter{Origins: []string{"SFO"}, MinDistance: 100, Exclude: []string{FlightKindCancelled}},
+			// "filed" departs OGG, and "cancelled" is excluded
+			expectedFlightIds: []string{"long"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			var flightIds []string
+			for _, flight := range tc.filter.Apply(flights) {
+				flightIds = append(flightIds, flight.FlightId)
+			}
+			requirer.Equal(tc.expectedFlightIds, flightIds)
+		})
+	}
+}
+
+func TestParseFlightKinds(t *testing.T) {
+	requirer := require.New(t)
+
+	kinds, parseErr := ParseFlightKinds(" cancelled, positionOnly,")
+	requirer.NoError(parseErr)
+	requirer.Equal([]string{FlightKindCancelled, FlightKindPositionOnly}, kinds)
+
+	_, parseErr = ParseFlightKinds("cancelled,late")
+	requirer.Error(parseErr)
+	requirer.Contains(parseErr.Error(), "unrecognized kind of flight(late)")
+}
@@ -1,24 +1,45 @@
 package aeroapi
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
 	"strings"
+	"sync"
 	"time"
 )
 
+// DefaultMaxPages is the default number of pages of a (paginated) response loaded
+const DefaultMaxPages = 10
+
 type HttpAeroApi struct {
-	Verbose bool
-	ApiKey  string
-	ApiUrl  string
+	Verbose           bool
+	ApiKey            string
+	ApiUrl            string
+	RequestsPerSecond float64       // maximum rate at which requests are issued (0=unlimited)
+	RequestTimeout    time.Duration // maximum time allowed for each request (0=unlimited)
+	Client            *http.Client  // client used to issue requests (nil=http.DefaultClient)
+	MaxPages          int           // maximum number of pages of a response loaded (0=DefaultMaxPages)
+	mu                sync.Mutex
+	nextRequestTime   time.Time
 }
 
-func (c *HttpAeroApi) GetFlightIdsRef(tailNumber string, cutoffTime time.Time) (string, error) {
+// GetFlightIdsRef returns the endpoint listing the flights of the tail number within the time range,
+// whose times are given in UTC, since the "+" of a positive offset (e.g., "+02:00") would be decoded
+// from the query as a space
+func (c *HttpAeroApi) GetFlightIdsRef(tailNumber string, timeRange TimeRange) (string, error) {
 	endpoint := fmt.Sprintf("/flights/%s", tailNumber)
-	if !cutoffTime.IsZero() {
-		endpoint += fmt.Sprintf("?&end=%s", cutoffTime.Format(time.RFC3339))
+	if !timeRange.IsZero() {
+		endpoint += "?"
+	}
+	if !timeRange.Start.IsZero() {
+		endpoint += fmt.Sprintf("&start=%s", timeRange.Start.UTC().Format(time.RFC3339))
+	}
+	if !timeRange.End.IsZero() {
+		endpoint += fmt.Sprintf("&end=%s", timeRange.End.UTC().Format(time.RFC3339))
 	}
 	return endpoint, nil
 }
@@ -27,18 +48,61 @@ func (c *HttpAeroApi) GetTrackForFlightRef(flightId string) string {
 	return fmt.Sprintf("/flights/%s/track", flightId)
 }
 
-func (c *HttpAeroApi) Load(endpoint string) ([]byte, error) {
+// Load returns the response from the endpoint; if it's paginated (e.g., a list of flights), the
+// pages following (see "links.next") are loaded too, up to MaxPages, and returned as one response
+func (c *HttpAeroApi) Load(ctx context.Context, endpoint string) ([]byte, error) {
+	response, loadErr := c.loadPage(ctx, endpoint)
+	if loadErr != nil {
+		return nil, loadErr
+	}
+	maxPages := c.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	for nPages := 1; ; nPages++ {
+		next := getNextPage(response)
+		if next == "" {
+			return response, nil
+		}
+		if nPages >= maxPages {
+			log.Printf("WARNING: only the first %d page(s) of the response from endpoint(%s) were loaded\n", maxPages, endpoint)
+			return response, nil
+		}
+		page, loadPageErr := c.loadPage(ctx, next)
+		if loadPageErr != nil {
+			return nil, loadPageErr
+		}
+		var appendErr error
+		if response, appendErr = appendPage(response, page); appendErr != nil {
+			return nil, newApiError("append page", next, appendErr)
+		}
+	}
+}
+
+// loadPage returns the response (or, if paginated, the page of it) from the endpoint
+func (c *HttpAeroApi) loadPage(ctx context.Context, endpoint string) ([]byte, error) {
 	const pathSep = "/"
 	requestUrl := fmt.Sprintf("%s%s%s", strings.TrimRight(c.ApiUrl, pathSep), pathSep, strings.TrimLeft(endpoint, pathSep))
 	if c.Verbose {
 		log.Printf("INFO: requesting from endpoint(%s)\n", endpoint)
 	}
-	req, buildReqErr := http.NewRequest("GET", requestUrl, nil)
+	if c.RequestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.RequestTimeout)
+		defer cancel()
+	}
+	req, buildReqErr := http.NewRequestWithContext(ctx, "GET", requestUrl, nil)
 	if buildReqErr != nil {
 		return nil, newApiError("create request", requestUrl, buildReqErr)
 	}
 
-	client := &http.Client{}
+	if waitErr := c.WaitForTurn(ctx); waitErr != nil {
+		return nil, newApiError("wait to issue request", requestUrl, waitErr)
+	}
+	client := c.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
 	req.Header.Set("Accept", "application/json")
 	req.Header.Set("x-apikey", c.ApiKey)
 	resp, issueReqErr := client.Do(req)
@@ -54,8 +118,7 @@ func (c *HttpAeroApi) Load(endpoint string) ([]byte, error) {
 
 	if resp.StatusCode != http.StatusOK {
 		responsePayload, _ := io.ReadAll(resp.Body)
-		responseErr := fmt.Errorf("statusCode(%d), status(%s), body(%s)", resp.StatusCode, resp.Status, string(responsePayload))
-		return nil, newApiError("get successful response", requestUrl, responseErr)
+		return nil, newApiError("get successful response", requestUrl, newResponseError(resp, responsePayload))
 	}
 
 	responsePayload, readResponseBodyErr := io.ReadAll(resp.Body)
@@ -66,6 +129,91 @@ func (c *HttpAeroApi) Load(endpoint string) ([]byte, error) {
 	return responsePayload, nil
 }
 
+// WaitForTurn blocks until the next request (or retry of one; see RetryingTransport.Wait) can be
+// issued without exceeding RequestsPerSecond, or until the context is done
+func (c *HttpAeroApi) WaitForTurn(ctx context.Context) error {
+	if c.RequestsPerSecond <= 0 {
+		return nil
+	}
+	c.mu.Lock()
+	now := time.Now()
+	requestTime := c.nextRequestTime
+	if requestTime.Before(now) {
+		requestTime = now
+	}
+	c.nextRequestTime = requestTime.Add(time.Duration(float64(time.Second) / c.RequestsPerSecond))
+	c.mu.Unlock()
+
+	timer := time.NewTimer(requestTime.Sub(now))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// getNextPage returns the endpoint of the page following the (paginated) response, if any
+func getNextPage(response []byte) string {
+	var paginated struct {
+		Links struct {
+			Next string `json:"next"`
+		} `json:"links"`
+	}
+	if unmarshalErr := json.Unmarshal(response, &paginated); unmarshalErr != nil {
+		// e.g., not a list
+		return ""
+	}
+	return paginated.Links.Next
+}
+
+// appendPage returns the (paginated) response with the flights of the page following it appended,
+// along with the page's reference to the page (if any) following it
+func appendPage(response, page []byte) ([]byte, error) {
+	var merged, next map[string]json.RawMessage
+	if unmarshalErr := json.Unmarshal(response, &merged); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	if unmarshalErr := json.Unmarshal(page, &next); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	var flights, nextFlights []json.RawMessage
+	if unmarshalErr := unmarshalField(merged["flights"], &flights); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	if unmarshalErr := unmarshalField(next["flights"], &nextFlights); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	numPages, nextNumPages := 1, 1
+	if unmarshalErr := unmarshalField(merged["num_pages"], &numPages); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	if unmarshalErr := unmarshalField(next["num_pages"], &nextNumPages); unmarshalErr != nil {
+		return nil, unmarshalErr
+	}
+	var marshalErr error
+	if merged["flights"], marshalErr = json.Marshal(append(flights, nextFlights...)); marshalErr != nil {
+		return nil, marshalErr
+	}
+	if merged["num_pages"], marshalErr = json.Marshal(numPages + nextNumPages); marshalErr != nil {
+		return nil, marshalErr
+	}
+	merged["links"] = next["links"]
+	if merged["links"] == nil {
+		merged["links"] = json.RawMessage("null")
+	}
+	return json.Marshal(merged)
+}
+
+// unmarshalField unmarshals the field of a response into v, unless the field is absent
+func unmarshalField(field json.RawMessage, v any) error {
+	if field == nil {
+		return nil
+	}
+	return json.Unmarshal(field, v)
+}
+
 func newApiError(what, where string, err error) error {
 	return fmt.Errorf("couldn't %s for %s: %w", what, where, err)
 }
@@ -1,9 +1,14 @@
 package aeroapi
 
 import (
+    "context"
+    "io"
     "net/http"
     "net/http/httptest"
+    "strings"
+    "sync"
     "testing"
+    "time"
 
     "github.com/stretchr/testify/require"
 )
@@ -43,7 +48,7 @@ func TestNewHttpAeroApi(t *testing.T) {
             defer svr.Close()
 
             api := &HttpAeroApi{ApiUrl: svr.URL}
-            actualResponse, errGet := api.Load("irrelevant")
+            actualResponse, errGet := api.Load(context.Background(), "irrelevant")
             requirer.Equal(tc.response, actualResponse)
             if len(tc.expectedErrs) > 0 {
                 // expecting an error
@@ -59,3 +64,103 @@ func TestNewHttpAeroApi(t *testing.T) {
     }
 
 }
+
+func TestHttpAeroApi_RequestsPerSecond(t *testing.T) {
+    requirer := require.New(t)
+    svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+    defer svr.Close()
+
+    api := &HttpAeroApi{ApiUrl: svr.URL, RequestsPerSecond: 20}
+    start := time.Now()
+    var wg sync.WaitGroup
+    for i := 0; i < 5; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            _, errGet := api.Load(context.Background(), "irrelevant")
+            requirer.NoError(errGet)
+        }()
+    }
+    wg.Wait()
+    // the first request is issued immediately; the others at intervals of 1/20th of a second
+    requirer.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+}
+
+func TestHttpAeroApi_Context(t *testing.T) {
+
+    type testCaseDef struct {
+        name           string
+        requestTimeout time.Duration
+        cancel         bool
+        expectedErrs   []string
+    }
+
+    testCases := []testCaseDef{
+        {
+            name:           "request times out",
+            requestTimeout: 10 * time.Millisecond,
+            expectedErrs:   []string{"couldn't issue request", context.DeadlineExceeded.Error()},
+        },
+        {
+            name:         "request is cancelled",
+            cancel:       true,
+            expectedErrs: []string{"couldn't", context.Canceled.Error()},
+        },
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            requirer := require.New(t)
+            stalled := make(chan struct{})
+            svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+                select {
+                case <-stalled:
+                case <-r.Context().Done():
+                }
+            }))
+            defer svr.Close()
+            defer close(stalled)
+
+            ctx, cancel := context.WithCancel(context.Background())
+            defer cancel()
+            if tc.cancel {
+                time.AfterFunc(10*time.Millisecond, cancel)
+            }
+            api := &HttpAeroApi{ApiUrl: svr.URL, RequestTimeout: tc.requestTimeout}
+            _, errGet := api.Load(ctx, "irrelevant")
+            requirer.Error(errGet)
+            for _, errText := range tc.expectedErrs {
+                requirer.Contains(errGet.Error(), errText)
+            }
+        })
+    }
+}
+
+func TestHttpAeroApi_Client(t *testing.T) {
+    requirer := require.New(t)
+    var requestedUrl string
+    api := &HttpAeroApi{
+        ApiUrl: "https://aeroapi.example.com/api",
+        ApiKey: "key",
+        Client: &http.Client{
+            Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+                requestedUrl = req.URL.String()
+                requirer.Equal("key", req.Header.Get("x-apikey"))
+                return &http.Response{
+                    StatusCode: http.StatusOK,
+                    Body:       io.NopCloser(strings.NewReader("injected")),
+                }, nil
+            }),
+        },
+    }
+    response, errGet := api.Load(context.Background(), "/flights/N12345")
+    requirer.NoError(errGet)
+    requirer.Equal("injected", string(response))
+    requirer.Equal("https://aeroapi.example.com/api/flights/N12345", requestedUrl)
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+    return f(req)
+}
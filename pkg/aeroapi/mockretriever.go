@@ -1,17 +1,19 @@
 package aeroapi
 
 import (
+	"context"
 	"fmt"
-	"time"
+	"sync"
 )
 
 type MockArtifactRetriever struct {
 	Err                error
 	Contents           []byte
 	RequestedEndpoints []string
+	mu                 sync.Mutex
 }
 
-func (*MockArtifactRetriever) GetFlightIdsRef(tailNumber string, _ time.Time) (string, error) {
+func (*MockArtifactRetriever) GetFlightIdsRef(tailNumber string, _ TimeRange) (string, error) {
 	return "/fl/" + tailNumber, nil
 }
 
@@ -19,7 +21,9 @@ func (*MockArtifactRetriever) GetTrackForFlightRef(flightId string) string {
 	return fmt.Sprintf("/fli/%s/track", flightId)
 }
 
-func (r *MockArtifactRetriever) Load(requestEndpoint string) ([]byte, error) {
+func (r *MockArtifactRetriever) Load(_ context.Context, requestEndpoint string) ([]byte, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.RequestedEndpoints = append(r.RequestedEndpoints, requestEndpoint)
 	return r.Contents, r.Err
 }
@@ -0,0 +1,146 @@
+package aeroapi
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of times a request is attempted before giving up
+	DefaultMaxAttempts = 4
+	// DefaultRetryBaseDelay is the default delay before the first retry, which doubles with each subsequent retry
+	DefaultRetryBaseDelay = 500 * time.Millisecond
+	// DefaultRetryMaxDelay is the default upper limit of the delay between attempts
+	DefaultRetryMaxDelay = 30 * time.Second
+)
+
+// RetryingTransport is an http.RoundTripper which retries requests failing in a way that might
+// be transient (e.g., rate limited, server overloaded, network error), using exponential backoff
+// with jitter, or the delay requested by the server via its "Retry-After" header.
+type RetryingTransport struct {
+	Transport   http.RoundTripper // underlying transport (nil=http.DefaultTransport)
+	MaxAttempts int               // 0=DefaultMaxAttempts
+	BaseDelay   time.Duration     // 0=DefaultRetryBaseDelay
+	MaxDelay    time.Duration     // 0=DefaultRetryMaxDelay
+	// Wait, if set, is called before each retry (e.g., HttpAeroApi.WaitForTurn, so that retries
+	// are counted against its rate limit, as is each request's first attempt)
+	Wait    func(ctx context.Context) error
+	Verbose bool
+}
+
+func (rt *RetryingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := rt.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	maxAttempts := rt.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = DefaultMaxAttempts
+	}
+	if req.Body != nil && req.GetBody == nil {
+		// the request's body can't be replayed, so it can't be retried
+		maxAttempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		if attempt > 1 && req.GetBody != nil {
+			body, getBodyErr := req.GetBody()
+			if getBodyErr != nil {
+				return nil, getBodyErr
+			}
+			req.Body = body
+		}
+		resp, roundTripErr := transport.RoundTrip(req)
+		retryAfter, retryable := isRetryableResponse(resp, roundTripErr)
+		if !retryable || attempt >= maxAttempts || req.Context().Err() != nil {
+			return resp, roundTripErr
+		}
+
+		delay := rt.getDelay(attempt, retryAfter)
+		if rt.Verbose {
+			log.Printf("INFO: retrying request(%s) in %v after attempt %d of %d failed: %s\n",
+				req.URL, delay.Round(time.Millisecond), attempt, maxAttempts, describeFailure(resp, roundTripErr))
+		}
+		if resp != nil {
+			// allow the underlying connection to be reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			_ = resp.Body.Close()
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		if rt.Wait != nil {
+			if waitErr := rt.Wait(req.Context()); waitErr != nil {
+				return nil, waitErr
+			}
+		}
+	}
+}
+
+// getDelay returns the delay before the next attempt, honoring the delay requested by the
+// server if any, otherwise choosing a random ("jittered") delay of up to exponentially
+// increasing length so that concurrent clients don't retry in lockstep
+func (rt *RetryingTransport) getDelay(attempt int, retryAfter time.Duration) time.Duration {
+	maxDelay := rt.MaxDelay
+	if maxDelay <= 0 {
+		maxDelay = DefaultRetryMaxDelay
+	}
+	if retryAfter > 0 {
+		if retryAfter > maxDelay {
+			return maxDelay
+		}
+		return retryAfter
+	}
+	baseDelay := rt.BaseDelay
+	if baseDelay <= 0 {
+		baseDelay = DefaultRetryBaseDelay
+	}
+	delay := maxDelay
+	if attempt < 32 && baseDelay<<(attempt-1) < maxDelay {
+		delay = baseDelay << (attempt - 1)
+	}
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+// NonRetryableError is implemented by errors raised in place of a response (e.g., the refusal of a
+// request by the budget) which would recur were the request retried, so the request isn't retried
+type NonRetryableError interface {
+	error
+	NonRetryable()
+}
+
+// isRetryableResponse returns true (along with any delay requested by the server)
+// if the response or error indicates that the request might succeed if retried
+func isRetryableResponse(resp *http.Response, roundTripErr error) (time.Duration, bool) {
+	var nonRetryableErr NonRetryableError
+	if errors.As(roundTripErr, &nonRetryableErr) {
+		return 0, false
+	}
+	if roundTripErr != nil {
+		// network errors may be transient, but not those due to the request's context being done
+		return 0, !errors.Is(roundTripErr, context.Canceled) && !errors.Is(roundTripErr, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return getRetryAfter(resp, time.Now()), true
+	}
+	return 0, false
+}
+
+func describeFailure(resp *http.Response, roundTripErr error) string {
+	if roundTripErr != nil {
+		return roundTripErr.Error()
+	}
+	return resp.Status
+}
@@ -0,0 +1,188 @@
+package aeroapi
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryingTransport(t *testing.T) {
+
+	testCases := []struct {
+		name             string
+		statuses         []int
+		retryAfter       string
+		maxAttempts      int
+		expectedAttempts int
+		expectedMinDelay time.Duration
+		expectedErrAs    func(error) bool
+		expectedFatal    bool
+	}{
+		{
+			name:             "succeeds after server errors",
+			statuses:         []int{http.StatusServiceUnavailable, http.StatusBadGateway, http.StatusOK},
+			expectedAttempts: 3,
+		},
+		{
+			name:             "honors retry after",
+			statuses:         []int{http.StatusTooManyRequests, http.StatusOK},
+			retryAfter:       "1",
+			expectedAttempts: 2,
+			expectedMinDelay: time.Second,
+		},
+		{
+			name:             "gives up when rate limited",
+			statuses:         []int{http.StatusTooManyRequests},
+			maxAttempts:      3,
+			expectedAttempts: 3,
+			expectedErrAs:    errorAs[*RateLimitedError],
+			expectedFatal:    true,
+		},
+		{
+			name:             "gives up on server errors",
+			statuses:         []int{http.StatusInternalServerError},
+			maxAttempts:      2,
+			expectedAttempts: 2,
+			expectedErrAs:    errorAs[*ServerError],
+		},
+		{
+			name:             "doesn't retry not found",
+			statuses:         []int{http.StatusNotFound},
+			expectedAttempts: 1,
+			expectedErrAs:    errorAs[*NotFoundError],
+		},
+		{
+			name:             "doesn't retry unauthorized",
+			statuses:         []int{http.StatusUnauthorized},
+			expectedAttempts: 1,
+			expectedErrAs:    errorAs[*AuthError],
+			expectedFatal:    true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			var attempts int
+			svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				status := tc.statuses[len(tc.statuses)-1]
+				if attempts < len(tc.statuses) {
+					status = tc.statuses[attempts]
+				}
+				attempts++
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(status)
+			}))
+			defer svr.Close()
+
+			api := &HttpAeroApi{
+				ApiUrl: svr.URL,
+				Client: &http.Client{
+					Transport: &RetryingTransport{MaxAttempts: tc.maxAttempts, BaseDelay: time.Millisecond},
+				},
+			}
+			start := time.Now()
+			_, loadErr := api.Load(context.Background(), "irrelevant")
+			requirer.Equal(tc.expectedAttempts, attempts)
+			requirer.GreaterOrEqual(time.Since(start), tc.expectedMinDelay)
+			if tc.expectedErrAs == nil {
+				requirer.NoError(loadErr)
+				return
+			}
+			requirer.Error(loadErr)
+			requirer.True(tc.expectedErrAs(loadErr))
+			requirer.Equal(tc.expectedFatal, IsFatal(loadErr))
+		})
+	}
+}
+
+func TestRetryingTransport_NonRetryable(t *testing.T) {
+	requirer := require.New(t)
+	var attempts int
+	accounting := &AccountingTransport{MonthlyBudget: 10, MonthToDate: Usage{Cost: 9.999}}
+	api := &HttpAeroApi{
+		ApiUrl: "http://aeroapi.invalid",
+		Client: &http.Client{
+			Transport: &RetryingTransport{
+				Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+					attempts++
+					return accounting.RoundTrip(req)
+				}),
+				BaseDelay: time.Millisecond,
+			},
+		},
+	}
+	_, loadErr := api.Load(context.Background(), "/flights/N12345")
+	requirer.Equal(1, attempts)
+	requirer.True(errorAs[*BudgetExceededError](loadErr))
+	requirer.False(IsRetryable(loadErr))
+}
+
+func TestRetryingTransport_Wait(t *testing.T) {
+	requirer := require.New(t)
+	var attempts int
+	svr := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer svr.Close()
+
+	api := &HttpAeroApi{ApiUrl: svr.URL, RequestsPerSecond: 10}
+	api.Client = &http.Client{
+		Transport: &RetryingTransport{BaseDelay: time.Millisecond, MaxDelay: time.Millisecond, Wait: api.WaitForTurn},
+	}
+	start := time.Now()
+	_, loadErr := api.Load(context.Background(), "irrelevant")
+	requirer.NoError(loadErr)
+	requirer.Equal(3, attempts)
+	// the retries wait their turns (at intervals of 1/10th of a second) after the first attempt
+	requirer.GreaterOrEqual(time.Since(start), 200*time.Millisecond)
+}
+
+func TestResponseError_As(t *testing.T) {
+	requirer := require.New(t)
+	for _, status := range []int{http.StatusUnauthorized, http.StatusNotFound, http.StatusTooManyRequests, http.StatusBadGateway, http.StatusTeapot} {
+		resp := &http.Response{StatusCode: status, Status: http.StatusText(status)}
+		err := newApiError("get successful response", "irrelevant", newResponseError(resp, []byte("body")))
+		var responseErr *ResponseError
+		requirer.True(errors.As(err, &responseErr), status)
+		requirer.Equal(status, responseErr.StatusCode)
+		requirer.Equal("body", responseErr.Body)
+	}
+}
+
+func TestGetRetryAfter(t *testing.T) {
+	requirer := require.New(t)
+	now := time.Date(2023, 5, 18, 20, 40, 0, 0, time.UTC)
+	newResponse := func(retryAfter string) *http.Response {
+		return &http.Response{Header: http.Header{"Retry-After": []string{retryAfter}}}
+	}
+	requirer.Equal(2*time.Minute, getRetryAfter(newResponse("120"), now))
+	requirer.Equal(90*time.Second, getRetryAfter(newResponse(now.Add(90*time.Second).Format(http.TimeFormat)), now))
+	requirer.Zero(getRetryAfter(newResponse(now.Add(-time.Minute).Format(http.TimeFormat)), now))
+	requirer.Zero(getRetryAfter(newResponse("soon"), now))
+	requirer.Zero(getRetryAfter(&http.Response{}, now))
+}
+
+func TestIsRetryable(t *testing.T) {
+	requirer := require.New(t)
+	requirer.True(IsRetryable(newFlightApiError("get", "here", &ServerError{})))
+	requirer.True(IsRetryable(&RateLimitedError{}))
+	requirer.False(IsRetryable(&NotFoundError{}))
+	requirer.False(IsRetryable(errors.New("other")))
+}
+
+// errorAs returns true if the error (or one that it wraps) is of type T
+func errorAs[T error](err error) bool {
+	var target T
+	return errors.As(err, &target)
+}
@@ -0,0 +1,95 @@
+package aeroapi
+
+import (
+	"log"
+	"math"
+)
+
+// Simplify returns the subset of the positions needed to represent the track within
+// "toleranceMeters", using the Ramer-Douglas-Peucker algorithm in three dimensions so
+// that significant turns, climbs and descents are retained.  The first and last positions
+// are always retained.  If "toleranceMeters" isn't positive, the positions are returned as-is.
+// See https://en.wikipedia.org/wiki/Ramer%E2%80%93Douglas%E2%80%93Peucker_algorithm
+func (u *Math) Simplify(positions []Position, toleranceMeters float64) []Position {
+	nPositions := len(positions)
+	if toleranceMeters <= 0 || nPositions < 3 {
+		return positions
+	}
+
+	keep := make([]bool, nPositions)
+	keep[0], keep[nPositions-1] = true, true
+
+	type span struct{ first, last int }
+	spans := []span{{0, nPositions - 1}}
+	for len(spans) > 0 {
+		s := spans[len(spans)-1]
+		spans = spans[:len(spans)-1]
+
+		// project each span separately, so that distances are measured near where they lie
+		points := projectPositions(positions[s.first : s.last+1])
+		last := len(points) - 1
+		farthest, farthestDistance := -1, toleranceMeters
+		for i := 1; i < last; i++ {
+			if distance := points[i].distanceToSegment(points[0], points[last]); distance > farthestDistance {
+				farthest, farthestDistance = s.first+i, distance
+			}
+		}
+		if farthest < 0 {
+			continue
+		}
+		keep[farthest] = true
+		spans = append(spans, span{s.first, farthest}, span{farthest, s.last})
+	}
+
+	simplified := make([]Position, 0, nPositions)
+	for i, position := range positions {
+		if keep[i] {
+			simplified = append(simplified, position)
+		}
+	}
+	if u.Debug {
+		log.Printf("simplified %d position(s) to %d using tolerance(%.1fm)\n", nPositions, len(simplified), toleranceMeters)
+	}
+	return simplified
+}
+
+// point is a location expressed in meters within a local (east, north, up) frame of reference
+type point struct {
+	x, y, z float64
+}
+
+// projectPositions converts positions into points within a local, flat frame of reference
+// centered on the first position, which is adequate for measuring relatively short distances.
+// The east-west distance spanned by a degree of longitude is scaled by the latitude of each
+// position, so that it remains accurate as the track moves north or south.
+func projectPositions(positions []Position) []point {
+	const earthRadiusMeters = 6371000
+	const metersPerFoot = 0.3048
+	const radiansPerDegree = math.Pi / 180
+
+	origin := positions[0]
+	points := make([]point, len(positions))
+	for i, position := range positions {
+		deltaLon := math.Mod(position.Longitude-origin.Longitude+540, 360) - 180
+		cosLat := math.Cos(position.Latitude * radiansPerDegree)
+		points[i] = point{
+			x: deltaLon * radiansPerDegree * cosLat * earthRadiusMeters,
+			y: (position.Latitude - origin.Latitude) * radiansPerDegree * earthRadiusMeters,
+			z: position.AltMslD100 * 100 * metersPerFoot,
+		}
+	}
+	return points
+}
+
+// distanceToSegment returns the distance from the point to the nearest point on the line segment from "a" to "b"
+func (p point) distanceToSegment(a, b point) float64 {
+	ab := point{b.x - a.x, b.y - a.y, b.z - a.z}
+	ap := point{p.x - a.x, p.y - a.y, p.z - a.z}
+	abLengthSquared := ab.x*ab.x + ab.y*ab.y + ab.z*ab.z
+	var t float64
+	if abLengthSquared > 0 {
+		t = math.Max(0, math.Min(1, (ap.x*ab.x+ap.y*ab.y+ap.z*ab.z)/abLengthSquared))
+	}
+	dx, dy, dz := ap.x-t*ab.x, ap.y-t*ab.y, ap.z-t*ab.z
+	return math.Sqrt(dx*dx + dy*dy + dz*dz)
+}
@@ -0,0 +1,105 @@
+package aeroapi
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/testfixtures"
+)
+
+func TestSimplify(t *testing.T) {
+
+	// a straight, level line of positions spaced ~1.1km apart, with a climb (of ~300m) in its middle
+	straightWithClimb := make([]Position, 11)
+	for i := range straightWithClimb {
+		straightWithClimb[i] = Position{
+			Latitude:   20 + float64(i)*0.01,
+			Longitude:  -156,
+			AltMslD100: 30,
+			Timestamp:  newTestTime().Add(time.Duration(i) * time.Minute),
+		}
+	}
+	straightWithClimb[5].AltMslD100 = 40
+
+	// a level line north from the equator, with a position at 60ºN ~830m (0.015º of longitude) east of it
+	northward := []Position{
+		{Latitude: 0, Longitude: 0, AltMslD100: 30},
+		{Latitude: 30, Longitude: 0, AltMslD100: 30},
+		{Latitude: 59.99, Longitude: 0.015, AltMslD100: 30},
+		{Latitude: 60, Longitude: 0, AltMslD100: 30},
+	}
+
+	track, trackErr := TrackFromJson([]byte(testfixtures.NewMockTestAeroApiTrackResponse()))
+	require.NoError(t, trackErr)
+
+	testCases := []struct {
+		name               string
+		positions          []Position
+		toleranceMeters    float64
+		expectedIndices    []int
+		expectedMaxPercent int
+	}{
+		{
+			name:            "no tolerance",
+			positions:       straightWithClimb,
+			expectedIndices: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			name:            "retains climb",
+			positions:       straightWithClimb,
+			toleranceMeters: 100,
+			expectedIndices: []int{0, 4, 5, 6, 10},
+		},
+		{
+			name:            "tolerance exceeds climb",
+			positions:       straightWithClimb,
+			toleranceMeters: 1000,
+			expectedIndices: []int{0, 10},
+		},
+		{
+			name:            "too few positions",
+			positions:       straightWithClimb[:2],
+			toleranceMeters: 1000,
+			expectedIndices: []int{0, 1},
+		},
+		{
+			name:            "distance east at high latitude within tolerance",
+			positions:       northward,
+			toleranceMeters: 1000,
+			expectedIndices: []int{0, 3},
+		},
+		{
+			name:            "distance east at high latitude beyond tolerance",
+			positions:       northward,
+			toleranceMeters: 700,
+			expectedIndices: []int{0, 2, 3},
+		},
+		{
+			name:               "realistic track",
+			positions:          track.Positions,
+			toleranceMeters:    2000,
+			expectedMaxPercent: 75,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			simplified := (&Math{}).Simplify(tc.positions, tc.toleranceMeters)
+			requirer.Equal(tc.positions[0], simplified[0])
+			requirer.Equal(tc.positions[len(tc.positions)-1], simplified[len(simplified)-1])
+			if tc.expectedIndices != nil {
+				var expected []Position
+				for _, i := range tc.expectedIndices {
+					expected = append(expected, tc.positions[i])
+				}
+				requirer.Equal(expected, simplified)
+			}
+			if tc.expectedMaxPercent != 0 {
+				requirer.Less(len(simplified)*100, len(tc.positions)*tc.expectedMaxPercent)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package aeroapi
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// artifactTimeFormat is the format of the times embedded in the names of "flight ids" artifacts
+const artifactTimeFormat = "20060102T150405Z0700"
+
+// TimeRange selects the flights departing within it; a zero Start or End leaves the range open at that end
+type TimeRange struct {
+	Start time.Time
+	End   time.Time
+}
+
+// IsZero returns true if the range is unbounded (i.e., selects all flights)
+func (tr TimeRange) IsZero() bool {
+	return tr.Start.IsZero() && tr.End.IsZero()
+}
+
+// Contains returns true if the time is within the range; unknown (zero) times are presumed to be
+func (tr TimeRange) Contains(t time.Time) bool {
+	if t.IsZero() {
+		return true
+	}
+	return (tr.Start.IsZero() || !t.Before(tr.Start)) && (tr.End.IsZero() || !t.After(tr.End))
+}
+
+// TrimPositions returns those of the positions (e.g., of a recorded track) reported within the range
+func (tr TimeRange) TrimPositions(positions []Position) []Position {
+	if tr.IsZero() {
+		return positions
+	}
+	var trimmed []Position
+	for _, position := range positions {
+		if tr.Contains(position.Timestamp) {
+			trimmed = append(trimmed, position)
+		}
+	}
+	return trimmed
+}
+
+// Validate returns an error if the range is empty (i.e., it starts after it ends)
+func (tr TimeRange) Validate() error {
+	if !tr.Start.IsZero() && !tr.End.IsZero() && tr.Start.After(tr.End) {
+		return fmt.Errorf("start(%s) of time range is after its end(%s)",
+			tr.Start.Format(time.RFC3339), tr.End.Format(time.RFC3339))
+	}
+	return nil
+}
+
+func (tr TimeRange) String() string {
+	formatBound := func(t time.Time) string {
+		if t.IsZero() {
+			return "*"
+		}
+		return t.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s..%s", formatBound(tr.Start), formatBound(tr.End))
+}
+
+// getQueryId returns the qualifier of the name of the "flight ids" artifact holding the
+// flights of the range, e.g., "_cutoff-{end}", "_from-{start}" or "_range-{start}_{end}"
+func (tr TimeRange) getQueryId() string {
+	switch {
+	case tr.Start.IsZero() && tr.End.IsZero():
+		return ""
+	case tr.Start.IsZero():
+		return "_cutoff-" + tr.End.Format(artifactTimeFormat)
+	case tr.End.IsZero():
+		return "_from-" + tr.Start.Format(artifactTimeFormat)
+	}
+	return fmt.Sprintf("_range-%s_%s", tr.Start.Format(artifactTimeFormat), tr.End.Format(artifactTimeFormat))
+}
+
+// parseTimeRangeQueryId parses the time range qualifying the query id (i.e., the inverse of getQueryId),
+// returning it along with the length of its qualifier (or zero values if it's unqualified)
+func parseTimeRangeQueryId(queryId string) (TimeRange, int, error) {
+	var timeRange TimeRange
+	for _, qualifier := range []string{"_cutoff-", "_from-", "_range-"} {
+		i := strings.LastIndex(queryId, qualifier)
+		if i < 0 {
+			continue
+		}
+		var parseErr error
+		times := queryId[i+len(qualifier):]
+		switch qualifier {
+		case "_cutoff-":
+			timeRange.End, parseErr = time.Parse(artifactTimeFormat, times)
+		case "_from-":
+			timeRange.Start, parseErr = time.Parse(artifactTimeFormat, times)
+		default:
+			start, end, _ := strings.Cut(times, "_")
+			if timeRange.Start, parseErr = time.Parse(artifactTimeFormat, start); parseErr == nil {
+				timeRange.End, parseErr = time.Parse(artifactTimeFormat, end)
+			}
+		}
+		return timeRange, len(queryId) - i, parseErr
+	}
+	return timeRange, 0, nil
+}
@@ -0,0 +1,102 @@
+package aeroapi
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeRange_GetFlights(t *testing.T) {
+
+	may22 := time.Date(2023, 5, 22, 0, 0, 0, 0, time.UTC)
+	may23 := may22.AddDate(0, 0, 1)
+	may24 := may22.AddDate(0, 0, 2)
+	flightsJson := `{"flights": [
+		{"fa_flight_id": "f24", "scheduled_off": "2023-05-24T12:00:00Z"},
+		{"fa_flight_id": "f23", "scheduled_out": "2023-05-23T12:00:00Z", "actual_off": "2023-05-24T01:00:00Z"},
+		{"fa_flight_id": "f22", "actual_off": "2023-05-22T12:00:00Z"},
+		{"fa_flight_id": "fUnknown"}
+	]}`
+
+	testCases := []struct {
+		name              string
+		timeRange         TimeRange
+		expectedEndpoint  string
+		expectedFlightIds []string
+		expectedError     string
+	}{
+		{
+			name:              "unbounded",
+			expectedEndpoint:  "/flights/N12345",
+			expectedFlightIds: []string{"f24", "f23", "f22", "fUnknown"},
+		},
+		{
+			name:              "cutoff",
+			timeRange:         TimeRange{End: may23},
+			expectedEndpoint:  "/flights/N12345?&end=2023-05-23T00:00:00Z",
+			expectedFlightIds: []string{"f22", "fUnknown"},
+		},
+		{
+			name:              "start",
+			timeRange:         TimeRange{Start: may23},
+			expectedEndpoint:  "/flights/N12345?&start=2023-05-23T00:00:00Z",
+			expectedFlightIds: []string{"f24", "f23", "fUnknown"},
+		},
+		{
+			name:              "range by scheduled departure",
+			timeRange:         TimeRange{Start: may23, End: may24},
+			expectedEndpoint:  "/flights/N12345?&start=2023-05-23T00:00:00Z&end=2023-05-24T00:00:00Z",
+			expectedFlightIds: []string{"f23", "fUnknown"},
+		},
+		{
+			name:              "range in a time zone ahead of UTC",
+			timeRange:         TimeRange{Start: may23.In(time.FixedZone("CEST", 2*60*60)), End: may24.In(time.FixedZone("CEST", 2*60*60))},
+			expectedEndpoint:  "/flights/N12345?&start=2023-05-23T00:00:00Z&end=2023-05-24T00:00:00Z",
+			expectedFlightIds: []string{"f23", "fUnknown"},
+		},
+		{
+			name:          "empty range",
+			timeRange:     TimeRange{Start: may24, End: may22},
+			expectedError: "start(2023-05-24T00:00:00Z) of time range is after its end(2023-05-22T00:00:00Z)",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			if tc.expectedError != "" {
+				validateErr := tc.timeRange.Validate()
+				requirer.Error(validateErr)
+				requirer.Contains(validateErr.Error(), tc.expectedError)
+				return
+			}
+			requirer.NoError(tc.timeRange.Validate())
+
+			endpoint, getRefErr := (&HttpAeroApi{}).GetFlightIdsRef("N12345", tc.timeRange)
+			requirer.NoError(getRefErr)
+			requirer.Equal(tc.expectedEndpoint, endpoint)
+
+			api := &RetrieverSaverApiImpl{Retriever: &MockArtifactRetriever{Contents: []byte(flightsJson)}}
+			flightIds, getErr := api.GetFlightIds(context.Background(), "N12345", tc.timeRange)
+			requirer.NoError(getErr)
+			requirer.Equal(tc.expectedFlightIds, flightIds)
+		})
+	}
+}
+
+func TestTimeRange_TrimPositions(t *testing.T) {
+	requirer := require.New(t)
+	start := time.Date(2023, 5, 23, 20, 0, 0, 0, time.UTC)
+	var positions []Position
+	for i := 0; i < 4; i++ {
+		positions = append(positions, Position{Latitude: float64(i), Timestamp: start.Add(time.Duration(i) * time.Hour)})
+	}
+
+	requirer.Equal(positions, TimeRange{}.TrimPositions(positions))
+	requirer.Equal(positions[1:3], TimeRange{Start: start.Add(time.Hour), End: start.Add(2 * time.Hour)}.TrimPositions(positions))
+	requirer.Equal(positions[2:], TimeRange{Start: start.Add(90 * time.Minute)}.TrimPositions(positions))
+	requirer.Equal(positions[:1], TimeRange{End: start.Add(time.Minute)}.TrimPositions(positions))
+	requirer.Empty(TimeRange{Start: start.Add(5 * time.Hour)}.TrimPositions(positions))
+}
@@ -0,0 +1,197 @@
+package aeroapi
+
+import (
+	"math"
+)
+
+// WindEstimate describes the wind solved for a segment of a flight track
+type WindEstimate struct {
+	Position   Position // reported position nearest the middle of the segment
+	FromDeg    Degrees  // direction from which the wind is blowing (0 <= direction < 360)
+	SpeedKnots float64  // speed of the wind
+	TasKnots   float64  // apparent (true) airspeed of the aircraft throughout the segment
+	Samples    int      // number of ground velocity samples used for the estimate
+}
+
+const (
+	DefaultWindWindowSize = 8
+	DefaultWindMinTurn    = Degrees(60)
+)
+
+type groundVelocity struct {
+	position Position
+	track    Degrees
+	east     float64 // knots
+	north    float64 // knots
+}
+
+// EstimateWinds solves for the wind encountered along the flight by examining sliding windows of
+// "windowSize" consecutive ground velocity samples (i.e., the track and groundspeed reported at each
+// position, or else "imputed" between it and the next).  Only windows within which the ground track
+// turns through at least "minTurn" degrees (e.g., circling segments, or doglegs) are considered.
+// While flying at a constant airspeed through a steady wind, the ground velocity vectors sampled
+// during a turn lie on a circle (or, for a partial turn, an arc of it) whose center is the wind
+// vector and whose radius is the airspeed; a least-squares circle fit recovers both.  Zero values
+// for "windowSize" or "minTurn" select reasonable defaults.
+func (u *Math) EstimateWinds(positions []Position, windowSize int, minTurn Degrees) []WindEstimate {
+
+	if windowSize <= 0 {
+		windowSize = DefaultWindWindowSize
+	}
+	if minTurn <= 0 {
+		minTurn = DefaultWindMinTurn
+	}
+
+	samples := u.getGroundVelocities(positions)
+	step := windowSize / 2
+	if step < 1 {
+		step = 1
+	}
+
+	var estimates []WindEstimate
+	for start := 0; start+windowSize <= len(samples); start += step {
+		window := samples[start : start+windowSize]
+		if math.Abs(f(getTurn(window))) < f(minTurn) {
+			continue
+		}
+		windEast, windNorth, tas, ok := fitCircle(window)
+		if !ok || tas <= 0 {
+			continue
+		}
+		windSpeed := math.Hypot(windEast, windNorth)
+		if windSpeed >= tas {
+			// not physically sensible for a flight making progress through the air
+			continue
+		}
+		estimates = append(estimates, WindEstimate{
+			Position:   window[len(window)/2].position,
+			FromDeg:    getDirectionFrom(windEast, windNorth),
+			SpeedKnots: windSpeed,
+			TasKnots:   tas,
+			Samples:    len(window),
+		})
+	}
+	return estimates
+}
+
+// GetMeanWind returns the vector average of the given wind estimates, or
+// false if there are no estimates from which to calculate the average
+func (u *Math) GetMeanWind(estimates []WindEstimate) (WindEstimate, bool) {
+	if len(estimates) == 0 {
+		return WindEstimate{}, false
+	}
+	var sumEast, sumNorth, sumTas f
+	var samples int
+	for _, estimate := range estimates {
+		east, north := getVector(estimate.FromDeg+180, estimate.SpeedKnots)
+		sumEast += east
+		sumNorth += north
+		sumTas += estimate.TasKnots
+		samples += estimate.Samples
+	}
+	n := f(len(estimates))
+	meanEast, meanNorth := sumEast/n, sumNorth/n
+	return WindEstimate{
+		FromDeg:    getDirectionFrom(meanEast, meanNorth),
+		SpeedKnots: math.Hypot(meanEast, meanNorth),
+		TasKnots:   sumTas / n,
+		Samples:    samples,
+	}, true
+}
+
+func (u *Math) getGroundVelocities(positions []Position) []groundVelocity {
+	var samples []groundVelocity
+	for i := 0; i < len(positions)-1; i++ {
+		thisPosition := positions[i]
+		nextPosition := positions[i+1]
+		// AeroAPI reports the "heading" of the aircraft over the ground (i.e., its track), which
+		// along with its groundspeed is more precise than that imputed from its (rounded) positions
+		track, gsKnots := Degrees(thisPosition.Heading), thisPosition.GsKnots
+		if gsKnots <= 0 {
+			if !nextPosition.Timestamp.After(thisPosition.Timestamp) {
+				continue
+			}
+			gsKnots = u.GetGeoGsKnots(thisPosition, nextPosition)
+			if math.IsNaN(gsKnots) || math.IsInf(gsKnots, 0) {
+				continue
+			}
+			track = u.GetGeoBearing(thisPosition, nextPosition)
+		}
+		east, north := getVector(track, gsKnots)
+		samples = append(samples, groundVelocity{
+			position: thisPosition,
+			track:    track,
+			east:     east,
+			north:    north,
+		})
+	}
+	return samples
+}
+
+// getTurn returns the net change of ground track (positive to the right) across the samples
+func getTurn(samples []groundVelocity) Degrees {
+	var turn Degrees
+	for i := 1; i < len(samples); i++ {
+		delta := math.Mod(f(samples[i].track-samples[i-1].track)+540, 360) - 180
+		turn += Degrees(delta)
+	}
+	return turn
+}
+
+// fitCircle returns the center and radius of the circle best fitting (in the algebraic
+// least-squares sense, see "Kåsa fit") the ends of the ground velocity vectors
+func fitCircle(samples []groundVelocity) (centerEast, centerNorth, radius float64, ok bool) {
+	if len(samples) < 3 {
+		return
+	}
+
+	// minimize Σ(x² + y² + Dx + Ey + F)² by solving its normal equations for D, E & F
+	var sx, sy, sxx, syy, sxy, sxz, syz, sz f
+	for _, s := range samples {
+		x, y := s.east, s.north
+		z := x*x + y*y
+		sx += x
+		sy += y
+		sxx += x * x
+		syy += y * y
+		sxy += x * y
+		sxz += x * z
+		syz += y * z
+		sz += z
+	}
+	n := f(len(samples))
+
+	det := func(a11, a12, a13, a21, a22, a23, a31, a32, a33 f) f {
+		return a11*(a22*a33-a23*a32) - a12*(a21*a33-a23*a31) + a13*(a21*a32-a22*a31)
+	}
+	d := det(sxx, sxy, sx, sxy, syy, sy, sx, sy, n)
+	if math.Abs(d) < 1e-9 {
+		return
+	}
+	bx, by, bz := -sxz, -syz, -sz
+	coefD := det(bx, sxy, sx, by, syy, sy, bz, sy, n) / d
+	coefE := det(sxx, bx, sx, sxy, by, sy, sx, bz, n) / d
+	coefF := det(sxx, sxy, bx, sxy, syy, by, sx, sy, bz) / d
+
+	centerEast, centerNorth = -coefD/2, -coefE/2
+	radiusSquared := centerEast*centerEast + centerNorth*centerNorth - coefF
+	if radiusSquared <= 0 {
+		return
+	}
+	return centerEast, centerNorth, math.Sqrt(radiusSquared), true
+}
+
+// getVector decomposes a vector given as a compass direction and magnitude into its east & north components
+func getVector(direction Degrees, magnitude float64) (east, north float64) {
+	r := f(direction) * math.Pi / 180
+	return magnitude * math.Sin(r), magnitude * math.Cos(r)
+}
+
+// getDirectionFrom returns the compass direction (0 <= direction < 360) opposite to that of the given vector
+func getDirectionFrom(east, north float64) Degrees {
+	from := math.Atan2(-east, -north) * 180 / math.Pi
+	if from < 0 {
+		from += 360
+	}
+	return Degrees(math.Mod(from, 360))
+}
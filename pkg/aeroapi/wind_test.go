@@ -0,0 +1,159 @@
+package aeroapi
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEstimateWinds(t *testing.T) {
+
+	testCases := []struct {
+		name              string
+		positions         []Position
+		expectedEstimates bool
+		expectedFromDeg   Degrees
+		expectedSpeedKts  float64
+		expectedTasKts    float64
+	}{
+		{
+			name:              "circling in a westerly wind",
+			positions:         newTestFlightPositions(100, 270, 20, 30, 48),
+			expectedEstimates: true,
+			expectedFromDeg:   270,
+			expectedSpeedKts:  20,
+			expectedTasKts:    100,
+		},
+		{
+			name:              "circling in a northeasterly wind",
+			positions:         newTestFlightPositions(120, 45, 35, -40, 36),
+			expectedEstimates: true,
+			expectedFromDeg:   45,
+			expectedSpeedKts:  35,
+			expectedTasKts:    120,
+		},
+		{
+			name:              "circling, its velocity not reported",
+			positions:         withoutVelocities(newTestFlightPositions(100, 270, 20, 30, 48)),
+			expectedEstimates: true,
+			expectedFromDeg:   270,
+			expectedSpeedKts:  20,
+			expectedTasKts:    100,
+		},
+		{
+			name:              "doglegs in a southerly wind",
+			positions:         newTestFlightPositionsTurning(110, 180, 25, getTestDoglegTurns()),
+			expectedEstimates: true,
+			expectedFromDeg:   180,
+			expectedSpeedKts:  25,
+			expectedTasKts:    110,
+		},
+		{
+			name:              "doglegs, their velocity not reported",
+			positions:         withoutVelocities(newTestFlightPositionsTurning(110, 180, 25, getTestDoglegTurns())),
+			expectedEstimates: true,
+			expectedFromDeg:   180,
+			expectedSpeedKts:  25,
+			expectedTasKts:    110,
+		},
+		{
+			name:      "straight and level",
+			positions: newTestFlightPositions(120, 45, 35, 0, 36),
+		},
+		{
+			name:      "too few positions",
+			positions: newTestFlightPositions(100, 270, 20, 30, 3),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			m := &Math{}
+			estimates := m.EstimateWinds(tc.positions, 0, 0)
+			if !tc.expectedEstimates {
+				requirer.Empty(estimates)
+				_, ok := m.GetMeanWind(estimates)
+				requirer.False(ok)
+				return
+			}
+			requirer.NotEmpty(estimates)
+			for _, estimate := range estimates {
+				requirer.InDelta(f(tc.expectedFromDeg), f(estimate.FromDeg), 3)
+				requirer.InDelta(tc.expectedSpeedKts, estimate.SpeedKnots, 1)
+				requirer.InDelta(tc.expectedTasKts, estimate.TasKnots, 1)
+			}
+			mean, ok := m.GetMeanWind(estimates)
+			requirer.True(ok)
+			requirer.InDelta(f(tc.expectedFromDeg), f(mean.FromDeg), 3)
+			requirer.InDelta(tc.expectedSpeedKts, mean.SpeedKnots, 1)
+		})
+	}
+
+}
+
+// newTestFlightPositions simulates a flight at a constant airspeed through a steady wind,
+// changing heading by "turnRate" degrees between each of "n" positions reported at 10s intervals
+func newTestFlightPositions(tasKnots float64, windFromDeg Degrees, windKnots float64, turnRate float64, n int) []Position {
+	turns := make([]float64, n-1)
+	for i := range turns {
+		turns[i] = turnRate
+	}
+	return newTestFlightPositionsTurning(tasKnots, windFromDeg, windKnots, turns)
+}
+
+// newTestFlightPositionsTurning simulates a flight at a constant airspeed through a steady wind,
+// turning steadily by each of "turns" degrees between positions reported at 10s intervals, each
+// reporting (as does AeroAPI) the track over the ground as the "heading", and the groundspeed
+func newTestFlightPositionsTurning(tasKnots float64, windFromDeg Degrees, windKnots float64, turns []float64) []Position {
+	const interval = 10 * time.Second
+	windEast, windNorth := getVector(windFromDeg+180, windKnots)
+	lat, lon := 20.8, -156.3
+	var heading float64
+	var positions []Position
+	for i := 0; i <= len(turns); i++ {
+		airEast, airNorth := getVector(Degrees(heading), tasKnots)
+		positions = append(positions, Position{
+			AltMslD100: 45,
+			GsKnots:    math.Hypot(airEast+windEast, airNorth+windNorth),
+			Heading:    f(getDirectionFrom(-airEast-windEast, -airNorth-windNorth)),
+			Latitude:   lat,
+			Longitude:  lon,
+			Timestamp:  newTestTime().Add(time.Duration(i) * interval),
+		})
+		if i == len(turns) {
+			break
+		}
+		// the track flown while turning steadily is (about) that of the heading half way through
+		airEast, airNorth = getVector(Degrees(heading+turns[i]/2), tasKnots)
+		hours := f(interval) / f(time.Hour)
+		northNm := (airNorth + windNorth) * hours
+		eastNm := (airEast + windEast) * hours
+		lat += northNm / 60
+		lon += eastNm / (60 * math.Cos(lat*math.Pi/180))
+		heading += turns[i]
+	}
+	return positions
+}
+
+// getTestDoglegTurns returns the turns of a cross-country flight, which flies straight but
+// for partial turns: 60 degrees to the right, then (later) 90 degrees to the left
+func getTestDoglegTurns() []float64 {
+	var turns []float64
+	for _, leg := range [][]float64{{0, 12}, {20, 3}, {0, 12}, {-30, 3}, {0, 12}} {
+		for i := 0; i < int(leg[1]); i++ {
+			turns = append(turns, leg[0])
+		}
+	}
+	return turns
+}
+
+// withoutVelocities returns the positions as reported without their track ("heading") and groundspeed
+func withoutVelocities(positions []Position) []Position {
+	for i := range positions {
+		positions[i].Heading, positions[i].GsKnots = 0, 0
+	}
+	return positions
+}
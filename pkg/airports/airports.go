@@ -0,0 +1,201 @@
+package airports
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Airport describes an airport, heliport, seaplane base, etc.
+type Airport struct {
+	Ident        string // e.g., "KLAX"
+	Type         string // e.g., "large_airport", "heliport", "closed"
+	Name         string
+	Latitude     float64
+	Longitude    float64
+	ElevationFt  float64
+	Municipality string
+	IataCode     string
+	Runways      []Runway
+}
+
+// Runway describes a runway of an Airport by its two ends
+type Runway struct {
+	Closed  bool
+	LowEnd  RunwayEnd
+	HighEnd RunwayEnd
+}
+
+// RunwayEnd describes one end (i.e., direction) of a Runway
+type RunwayEnd struct {
+	Ident      string // e.g., "07L"
+	Latitude   float64
+	Longitude  float64
+	HeadingDeg float64 // true heading; zero if unknown
+	HasHeading bool
+}
+
+// Database is a collection of airports indexed by location
+type Database struct {
+	airports []*Airport
+	index    gridIndex
+}
+
+// OurAirports data files, see https://ourairports.com/data/
+const (
+	OurAirportsAirportsFilename = "airports.csv"
+	OurAirportsRunwaysFilename  = "runways.csv"
+)
+
+// LoadOurAirportsDir loads the airports (and, if present, their runways) from
+// files in the OurAirports CSV format located in the given directory
+func LoadOurAirportsDir(dir string) (*Database, error) {
+	airportsFile, openErr := os.Open(filepath.Join(dir, OurAirportsAirportsFilename))
+	if openErr != nil {
+		return nil, openErr
+	}
+	defer func() { _ = airportsFile.Close() }()
+
+	var runwaysReader io.Reader
+	runwaysFile, openRunwaysErr := os.Open(filepath.Join(dir, OurAirportsRunwaysFilename))
+	if openRunwaysErr == nil {
+		defer func() { _ = runwaysFile.Close() }()
+		runwaysReader = runwaysFile
+	} else if !errors.Is(openRunwaysErr, fs.ErrNotExist) {
+		return nil, openRunwaysErr
+	}
+
+	return LoadOurAirports(airportsFile, runwaysReader)
+}
+
+// LoadOurAirports loads the airports, and optionally (if "runwaysCsv" isn't nil)
+// their runways, from the given readers of data in the OurAirports CSV format
+func LoadOurAirports(airportsCsv, runwaysCsv io.Reader) (*Database, error) {
+
+	db := &Database{index: make(gridIndex)}
+	byIdent := make(map[string]*Airport)
+
+	readAirportsErr := readCsv(airportsCsv, func(row csvRow) error {
+		airport := &Airport{
+			Ident:        row.get("ident"),
+			Type:         row.get("type"),
+			Name:         row.get("name"),
+			Latitude:     row.getFloat("latitude_deg"),
+			Longitude:    row.getFloat("longitude_deg"),
+			ElevationFt:  row.getFloat("elevation_ft"),
+			Municipality: row.get("municipality"),
+			IataCode:     row.get("iata_code"),
+		}
+		if airport.Ident == "" {
+			return nil
+		}
+		db.airports = append(db.airports, airport)
+		byIdent[airport.Ident] = airport
+		return row.err
+	})
+	if readAirportsErr != nil {
+		return nil, fmt.Errorf("can't read airports: %w", readAirportsErr)
+	}
+
+	if runwaysCsv != nil {
+		readRunwaysErr := readCsv(runwaysCsv, func(row csvRow) error {
+			airport := byIdent[row.get("airport_ident")]
+			if airport == nil {
+				return nil
+			}
+			airport.Runways = append(airport.Runways, Runway{
+				Closed:  row.get("closed") == "1",
+				LowEnd:  row.getRunwayEnd("le_"),
+				HighEnd: row.getRunwayEnd("he_"),
+			})
+			return row.err
+		})
+		if readRunwaysErr != nil {
+			return nil, fmt.Errorf("can't read runways: %w", readRunwaysErr)
+		}
+	}
+
+	for _, airport := range db.airports {
+		db.index.add(airport)
+	}
+	return db, nil
+}
+
+// Len returns the number of airports in the database
+func (db *Database) Len() int {
+	return len(db.airports)
+}
+
+type csvRow struct {
+	columns map[string]int
+	record  []string
+	line    int
+	err     error
+}
+
+func (r *csvRow) get(column string) string {
+	if i, ok := r.columns[column]; ok && i < len(r.record) {
+		return strings.TrimSpace(r.record[i])
+	}
+	return ""
+}
+
+func (r *csvRow) getFloat(column string) float64 {
+	value, _ := r.getOptionalFloat(column)
+	return value
+}
+
+func (r *csvRow) getOptionalFloat(column string) (float64, bool) {
+	text := r.get(column)
+	if text == "" {
+		return 0, false
+	}
+	value, parseErr := strconv.ParseFloat(text, 64)
+	if parseErr != nil && r.err == nil {
+		r.err = fmt.Errorf("line %d: invalid %s(%s): %w", r.line, column, text, parseErr)
+	}
+	return value, parseErr == nil
+}
+
+func (r *csvRow) getRunwayEnd(prefix string) RunwayEnd {
+	heading, hasHeading := r.getOptionalFloat(prefix + "heading_degT")
+	return RunwayEnd{
+		Ident:      r.get(prefix + "ident"),
+		Latitude:   r.getFloat(prefix + "latitude_deg"),
+		Longitude:  r.getFloat(prefix + "longitude_deg"),
+		HeadingDeg: heading,
+		HasHeading: hasHeading,
+	}
+}
+
+func readCsv(reader io.Reader, rowHandler func(row csvRow) error) error {
+	csvReader := csv.NewReader(reader)
+	csvReader.ReuseRecord = true
+	header, readHeaderErr := csvReader.Read()
+	if readHeaderErr != nil {
+		return readHeaderErr
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.TrimSpace(name)] = i
+	}
+
+	for line := 2; ; line++ {
+		record, readErr := csvReader.Read()
+		if readErr == io.EOF {
+			return nil
+		}
+		if readErr != nil {
+			return readErr
+		}
+		if handleErr := rowHandler(csvRow{columns: columns, record: record, line: line}); handleErr != nil {
+			return handleErr
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package airports
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testAirportsCsv = `"id","ident","type","name","latitude_deg","longitude_deg","elevation_ft","continent","iso_country","iso_region","municipality","scheduled_service","gps_code","iata_code","local_code","home_link","wikipedia_link","keywords"
+3484,"KLAX","large_airport","Los Angeles International Airport",33.942501,-118.407997,125,"NA","US","US-CA","Los Angeles","yes","KLAX","LAX","LAX",,,
+5447,"PHOG","medium_airport","Kahului International Airport",20.898543,-156.431212,54,"OC","US","US-HI","Kahului","yes","PHOG","OGG","OGG",,,
+3635,"KLGB","medium_airport","Long Beach Airport (Daugherty Field)",33.816523,-118.149891,60,"NA","US","US-CA","Long Beach","yes","KLGB","LGB","LGB",,,
+99999,"XX99","closed","Closed Field",33.8166,-118.1600,10,"NA","US","US-CA","Long Beach","no",,,,,,
+`
+
+const testRunwaysCsv = `"id","airport_ref","airport_ident","length_ft","width_ft","surface","lighted","closed","le_ident","le_latitude_deg","le_longitude_deg","le_elevation_ft","le_heading_degT","le_displaced_threshold_ft","he_ident","he_latitude_deg","he_longitude_deg","he_elevation_ft","he_heading_degT","he_displaced_threshold_ft"
+1,3484,"KLAX",12091,150,"CON",1,0,"06R",33.9467,-118.435,100,83,,"24L",33.9501,-118.402,127,263,
+2,3484,"KLAX",11095,150,"CON",1,0,"07L",33.9358,-118.419,97,83,,"25R",33.9398,-118.382,114,263,
+3,5447,"PHOG",6995,150,"ASP",1,0,"02",20.8877,-156.4349,43,28,,"20",20.9051,-156.4244,54,208,
+4,5447,"PHOG",4980,150,"ASP",1,0,"05",20.8912,-156.4474,,,,"23",20.9011,-156.4329,,,
+`
+
+func TestLoadOurAirports(t *testing.T) {
+	requirer := require.New(t)
+
+	db, loadErr := LoadOurAirports(strings.NewReader(testAirportsCsv), strings.NewReader(testRunwaysCsv))
+	requirer.NoError(loadErr)
+	requirer.Equal(4, db.Len())
+
+	_, badLoadErr := LoadOurAirports(strings.NewReader(`ident,latitude_deg
+KXYZ,notanumber
+`), nil)
+	requirer.Error(badLoadErr)
+	requirer.Contains(badLoadErr.Error(), "latitude_deg")
+
+	dir := t.TempDir()
+	requirer.NoError(os.WriteFile(filepath.Join(dir, OurAirportsAirportsFilename), []byte(testAirportsCsv), 0644))
+	dirDb, loadDirErr := LoadOurAirportsDir(dir)
+	requirer.NoError(loadDirErr)
+	requirer.Equal(4, dirDb.Len())
+
+	_, loadMissingErr := LoadOurAirportsDir(filepath.Join(dir, "missing"))
+	requirer.Error(loadMissingErr)
+}
+
+func TestDatabase_IdentifyEndpoint(t *testing.T) {
+
+	db, loadErr := LoadOurAirports(strings.NewReader(testAirportsCsv), strings.NewReader(testRunwaysCsv))
+	require.NoError(t, loadErr)
+
+	testCases := []struct {
+		name            string
+		latitude        float64
+		longitude       float64
+		heading         float64
+		expectedFound   bool
+		expectedAirport string
+		expectedRunway  string
+	}{
+		{
+			name:            "departing LAX westbound",
+			latitude:        33.9445,
+			longitude:       -118.4460,
+			heading:         262,
+			expectedFound:   true,
+			expectedAirport: "KLAX",
+			expectedRunway:  "24L",
+		},
+		{
+			name:            "arriving OGG on runway 2",
+			latitude:        20.8920,
+			longitude:       -156.4330,
+			heading:         25,
+			expectedFound:   true,
+			expectedAirport: "PHOG",
+			expectedRunway:  "02",
+		},
+		{
+			name:            "near LGB, not aligned with any (known) runway, and ignoring a closer closed airport",
+			latitude:        33.8170,
+			longitude:       -118.1590,
+			heading:         315,
+			expectedFound:   true,
+			expectedAirport: "KLGB",
+		},
+		{
+			name:      "over the Pacific",
+			latitude:  27.0,
+			longitude: -140.0,
+			heading:   250,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			endpoint, found := db.IdentifyEndpoint(tc.latitude, tc.longitude, tc.heading, 0)
+			requirer.Equal(tc.expectedFound, found)
+			requirer.Equal(tc.expectedAirport, endpoint.Code())
+			requirer.Equal(tc.expectedRunway, endpoint.Runway)
+		})
+	}
+}
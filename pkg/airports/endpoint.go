@@ -0,0 +1,80 @@
+package airports
+
+import (
+	"math"
+)
+
+// Endpoint identifies the airport (and, if it can be determined, the runway) at
+// which a flight began or ended
+type Endpoint struct {
+	Airport    *Airport
+	Runway     string  // identifier of the runway end used; empty if not identified
+	DistanceNm float64 // distance from the reported position to the airport
+}
+
+const (
+	// DefaultMaxEndpointNm is the default distance from the first (or last) reported position
+	// within which the departure (or arrival) airport is sought
+	DefaultMaxEndpointNm = 5
+	maxRunwayHeadingDiff = 30
+)
+
+// IdentifyEndpoint returns the airport nearest to the first (or last) reported position of a flight,
+// along with the runway most closely aligned with the direction of travel there, or false if there
+// is no airport within "maxNm" (0=DefaultMaxEndpointNm)
+func (db *Database) IdentifyEndpoint(latitude, longitude, headingDeg, maxNm float64) (Endpoint, bool) {
+	if maxNm <= 0 {
+		maxNm = DefaultMaxEndpointNm
+	}
+	airport, distanceNm := db.Nearest(latitude, longitude, maxNm)
+	if airport == nil {
+		return Endpoint{}, false
+	}
+	return Endpoint{
+		Airport:    airport,
+		Runway:     identifyRunway(airport, latitude, longitude, headingDeg),
+		DistanceNm: distanceNm,
+	}, true
+}
+
+// Code returns the identifier of the airport, or an empty string if it wasn't identified
+func (ep Endpoint) Code() string {
+	if ep.Airport == nil {
+		return ""
+	}
+	return ep.Airport.Ident
+}
+
+func identifyRunway(airport *Airport, latitude, longitude, headingDeg float64) string {
+	var bestIdent string
+	var bestDiff, bestDistance float64
+	for _, runway := range airport.Runways {
+		if runway.Closed {
+			continue
+		}
+		for _, end := range []RunwayEnd{runway.LowEnd, runway.HighEnd} {
+			if end.Ident == "" || !end.HasHeading {
+				continue
+			}
+			diff := getHeadingDiff(end.HeadingDeg, headingDeg)
+			if diff > maxRunwayHeadingDiff {
+				continue
+			}
+			distance := getDistanceNm(latitude, longitude, end.Latitude, end.Longitude)
+			// prefer the best aligned runway; among (e.g., parallel) runways equally aligned, the nearest
+			if bestIdent == "" || diff < bestDiff-1 || (math.Abs(diff-bestDiff) <= 1 && distance < bestDistance) {
+				bestIdent, bestDiff, bestDistance = end.Ident, diff, distance
+			}
+		}
+	}
+	return bestIdent
+}
+
+// getHeadingDiff returns the magnitude (0 <= diff <= 180) of the difference between two headings
+func getHeadingDiff(h1, h2 float64) float64 {
+	diff := math.Mod(math.Abs(h1-h2), 360)
+	if diff > 180 {
+		diff = 360 - diff
+	}
+	return diff
+}
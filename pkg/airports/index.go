@@ -0,0 +1,78 @@
+package airports
+
+import (
+	"math"
+
+	"github.com/twpayne/go-kml/v3"
+	"github.com/twpayne/go-kml/v3/sphere"
+)
+
+// gridIndex is a simple spatial index bucketing airports into cells of one degree of latitude & longitude
+type gridIndex map[gridCell][]*Airport
+
+type gridCell struct {
+	lat, lon int
+}
+
+func newGridCell(latitude, longitude float64) gridCell {
+	return gridCell{lat: int(math.Floor(latitude)), lon: int(math.Floor(longitude))}
+}
+
+func (gi gridIndex) add(airport *Airport) {
+	cell := newGridCell(airport.Latitude, airport.Longitude)
+	gi[cell] = append(gi[cell], airport)
+}
+
+// nearby returns the airports in the cells which could contain an airport within "radiusNm" of the location
+func (gi gridIndex) nearby(latitude, longitude, radiusNm float64) []*Airport {
+	const nmPerDegreeLat = 60
+	latCells := int(math.Ceil(radiusNm / nmPerDegreeLat))
+	lonCells := latCells
+	if cosLat := math.Cos(math.Min(math.Abs(latitude)+float64(latCells), 89.9) * math.Pi / 180); cosLat > 0 {
+		lonCells = int(math.Ceil(radiusNm / (nmPerDegreeLat * cosLat)))
+	}
+	if lonCells > 180 {
+		lonCells = 180
+	}
+
+	center := newGridCell(latitude, longitude)
+	var candidates []*Airport
+	for dLat := -latCells; dLat <= latCells; dLat++ {
+		for dLon := -lonCells; dLon <= lonCells; dLon++ {
+			lon := center.lon + dLon
+			// wrap around the anti-meridian
+			lon = ((lon+180)%360+360)%360 - 180
+			candidates = append(candidates, gi[gridCell{lat: center.lat + dLat, lon: lon}]...)
+		}
+	}
+	return candidates
+}
+
+// Nearest returns the open airport nearest to the location and its distance (in nautical miles), or
+// nil if there is no airport within "maxNm"
+func (db *Database) Nearest(latitude, longitude, maxNm float64) (*Airport, float64) {
+	var nearest *Airport
+	nearestNm := maxNm
+	for _, airport := range db.index.nearby(latitude, longitude, maxNm) {
+		if airport.Type == "closed" {
+			continue
+		}
+		distanceNm := getDistanceNm(latitude, longitude, airport.Latitude, airport.Longitude)
+		if distanceNm <= nearestNm {
+			nearest = airport
+			nearestNm = distanceNm
+		}
+	}
+	return nearest, nearestNm
+}
+
+func getDistanceNm(fromLat, fromLon, toLat, toLon float64) float64 {
+	const earthRadiusKm = 6371
+	const kilometersPerNauticalMile = 1.852
+	earth := sphere.T{R: earthRadiusKm}
+	kilometers := earth.HaversineDistance(
+		kml.Coordinate{Lon: fromLon, Lat: fromLat},
+		kml.Coordinate{Lon: toLon, Lat: toLat},
+	)
+	return kilometers / kilometersPerNauticalMile
+}
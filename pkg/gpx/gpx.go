@@ -0,0 +1,95 @@
+// Package gpx reads flight tracks recorded in GPS Exchange Format (GPX) files, such as those
+// exported by flight planning and logging applications, as AeroAPI tracks.
+// See https://www.topografix.com/gpx.asp
+package gpx
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+const feetPerMeter = 3.28084
+
+// FileSuffix is the suffix of the names of GPX files
+const FileSuffix = ".gpx"
+
+type document struct {
+	XMLName xml.Name `xml:"gpx"`
+	Tracks  []struct {
+		Name     string `xml:"name"`
+		Segments []struct {
+			Points []point `xml:"trkpt"`
+		} `xml:"trkseg"`
+	} `xml:"trk"`
+}
+
+type point struct {
+	Latitude  float64    `xml:"lat,attr"`
+	Longitude float64    `xml:"lon,attr"`
+	Elevation float64    `xml:"ele"`  // meters (MSL)
+	Time      *time.Time `xml:"time"` // required of the points of a flight track
+}
+
+// IsGpxFilename returns true if the file is named as a GPX file
+func IsGpxFilename(fn string) bool {
+	return strings.EqualFold(filepath.Ext(fn), FileSuffix)
+}
+
+// ReadTrack returns the track recorded in the GPX document, along with its name (if any).  The points of
+// all its tracks (and their segments) are joined in order, and since GPX doesn't record them, the ground
+// speed and heading of each position are derived from the distance and bearing to the next.
+func ReadTrack(contents []byte) (*aeroapi.Track, string, error) {
+	var doc document
+	if unmarshalErr := xml.Unmarshal(contents, &doc); unmarshalErr != nil {
+		return nil, "", fmt.Errorf("invalid GPX document: %w", unmarshalErr)
+	}
+
+	var name string
+	var positions []aeroapi.Position
+	for _, track := range doc.Tracks {
+		if name == "" {
+			name = strings.TrimSpace(track.Name)
+		}
+		for _, segment := range track.Segments {
+			for i, pt := range segment.Points {
+				if pt.Time == nil {
+					return nil, "", fmt.Errorf("GPX track point(%d) at(%f,%f) has no time", i, pt.Latitude, pt.Longitude)
+				}
+				positions = append(positions, aeroapi.Position{
+					AltMslD100: pt.Elevation * feetPerMeter / 100,
+					Latitude:   pt.Latitude,
+					Longitude:  pt.Longitude,
+					Timestamp:  pt.Time.UTC(),
+				})
+			}
+		}
+	}
+	if len(positions) == 0 {
+		return nil, "", errors.New("GPX document has no track points")
+	}
+
+	var geo aeroapi.Math
+	for i := range positions {
+		from, to := i, i+1
+		if to == len(positions) {
+			// the last position continues as it arrived
+			from, to = i-1, i
+		}
+		if from < 0 || !positions[to].Timestamp.After(positions[from].Timestamp) {
+			if i > 0 {
+				positions[i].GsKnots = positions[i-1].GsKnots
+				positions[i].Heading = positions[i-1].Heading
+			}
+			continue
+		}
+		positions[i].GsKnots = geo.GetGeoGsKnots(positions[from], positions[to])
+		positions[i].Heading = float64(geo.GetGeoBearing(positions[from], positions[to]))
+	}
+	return &aeroapi.Track{Positions: positions}, name, nil
+}
@@ -0,0 +1,86 @@
+package gpx
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testGpx = `<?xml version="1.0" encoding="UTF-8"?>
+<gpx version="1.1" creator="test" xmlns="http://www.topografix.com/GPX/1/1">
+  <trk>
+    <name>N335SP Lanai</name>
+    <trkseg>
+      <trkpt lat="20.0" lon="-156.0"><ele>30.48</ele><time>2023-05-23T20:00:00Z</time></trkpt>
+      <trkpt lat="20.1" lon="-156.0"><ele>304.8</ele><time>2023-05-23T20:06:00Z</time></trkpt>
+    </trkseg>
+    <trkseg>
+      <trkpt lat="20.1" lon="-155.9"><ele>609.6</ele><time>2023-05-23T21:06:00-01:00</time></trkpt>
+    </trkseg>
+  </trk>
+</gpx>`
+
+func TestReadTrack(t *testing.T) {
+	testCases := []struct {
+		name           string
+		contents       string
+		expectedErrors []string
+	}{
+		{
+			name:     "valid",
+			contents: testGpx,
+		},
+		{
+			name:           "not XML",
+			contents:       "{}",
+			expectedErrors: []string{"invalid GPX document"},
+		},
+		{
+			name:           "no points",
+			contents:       `<gpx><trk><trkseg></trkseg></trk></gpx>`,
+			expectedErrors: []string{"GPX document has no track points"},
+		},
+		{
+			name:           "no time",
+			contents:       `<gpx><trk><trkseg><trkpt lat="20.0" lon="-156.0"/></trkseg></trk></gpx>`,
+			expectedErrors: []string{"GPX track point(0) at(20.000000,-156.000000) has no time"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			track, name, err := ReadTrack([]byte(tc.contents))
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedError := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedError)
+				}
+				return
+			}
+			requirer.NoError(err)
+			requirer.Equal("N335SP Lanai", name)
+			requirer.Len(track.Positions, 3)
+
+			first, second, last := track.Positions[0], track.Positions[1], track.Positions[2]
+			requirer.InDelta(1.0, first.AltMslD100, 0.001)
+			requirer.InDelta(20.0, last.AltMslD100, 0.001)
+			requirer.Equal(time.Date(2023, 5, 23, 22, 6, 0, 0, time.UTC), last.Timestamp)
+			// 0.1 degree of latitude (6 nautical miles) due north in 6 minutes
+			requirer.InDelta(0, first.Heading, 0.1)
+			requirer.InDelta(60, first.GsKnots, 0.1)
+			// heading east, the last position continues as it arrived
+			requirer.InDelta(90, second.Heading, 0.1)
+			requirer.Equal(second.Heading, last.Heading)
+			requirer.Equal(second.GsKnots, last.GsKnots)
+		})
+	}
+}
+
+func TestIsGpxFilename(t *testing.T) {
+	requirer := require.New(t)
+	requirer.True(IsGpxFilename("flights/lanai.gpx"))
+	requirer.True(IsGpxFilename("LANAI.GPX"))
+	requirer.False(IsGpxFilename("fvt_N335SP-1.json"))
+}
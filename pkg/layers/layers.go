@@ -0,0 +1,40 @@
+// Package layers lets Go code outside this module add layers to the KML visualizations made by fviz.
+// A program registers its layers (e.g., from an init function), then runs fviz from its own main
+// package by calling cmd.Execute; its layers can then be chosen like any other, e.g., using
+// "fviz tracks --layers path,contrail(color=#ffffff)", and are listed by "fviz layers".
+package layers
+
+import (
+	"github.com/noodnik2/flightvisualizer/internal/kml/builders"
+)
+
+type (
+	// Builder builds the KML depiction of a layer from the positions of a flight track
+	Builder = builders.KmlTrackBuilder
+	// ConfigurableBuilder is a Builder having options, given in the specification of its layer
+	ConfigurableBuilder = builders.ConfigurableBuilder
+	// Product is the KML element built by a Builder, and the assets (e.g., images) it references
+	Product = builders.KmlProduct
+	// Option describes an option of a ConfigurableBuilder
+	Option = builders.Option
+	// Settings holds the (command's) settings with which Builders are made
+	Settings = builders.Settings
+	// Layer names and describes a layer, and makes its Builder
+	Layer = builders.Layer
+)
+
+// Register makes a layer available by its name; it panics if the layer is incomplete, its name
+// is invalid (it must be alphanumeric, starting with a lowercase letter) or already registered
+func Register(layer Layer) {
+	builders.RegisterLayer(layer)
+}
+
+// Lookup returns the layer registered by the name, if any
+func Lookup(name string) (Layer, bool) {
+	return builders.LookupLayer(name)
+}
+
+// All returns the registered layers, in order of their names
+func All() []Layer {
+	return builders.Layers()
+}
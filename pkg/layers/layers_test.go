@@ -0,0 +1,92 @@
+package layers
+
+import (
+	"testing"
+
+	gokml "github.com/twpayne/go-kml/v3"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/noodnik2/flightvisualizer/pkg/aeroapi"
+)
+
+type contrailBuilder struct {
+	Width string
+}
+
+func (cb *contrailBuilder) Name() string {
+	return "Contrail"
+}
+
+func (cb *contrailBuilder) Build(positions []aeroapi.Position) (*Product, error) {
+	return &Product{Root: gokml.Folder(gokml.Name("Contrail"))}, nil
+}
+
+func (cb *contrailBuilder) Options() []Option {
+	return []Option{{Name: "width", Description: "pixels wide", Value: cb.Width}}
+}
+
+func (cb *contrailBuilder) SetOption(name, value string) error {
+	cb.Width = value
+	return nil
+}
+
+func init() {
+	Register(Layer{
+		Name:        "contrail",
+		Description: "Condensation trail",
+		New:         func(Settings) Builder { return &contrailBuilder{Width: "8"} },
+	})
+}
+
+func TestRegister(t *testing.T) {
+	requirer := require.New(t)
+
+	layer, ok := Lookup("contrail")
+	requirer.True(ok)
+	requirer.False(layer.Default)
+	requirer.Equal([]Option{{Name: "width", Description: "pixels wide", Value: "8"}}, layer.Options(Settings{}))
+	product, buildErr := layer.New(Settings{}).Build(nil)
+	requirer.NoError(buildErr)
+	requirer.NotNil(product.Root)
+
+	var names []string
+	for _, registered := range All() {
+		names = append(names, registered.Name)
+	}
+	requirer.Equal([]string{"camera", "contrail", "path", "placemark", "terrain", "vector", "wind"}, names)
+
+	_, ok = Lookup("smoke")
+	requirer.False(ok)
+}
+
+func TestRegister_Invalid(t *testing.T) {
+	newBuilder := func(Settings) Builder { return &contrailBuilder{} }
+	testCases := []struct {
+		name          string
+		layer         Layer
+		expectedPanic string
+	}{
+		{
+			name:          "duplicate",
+			layer:         Layer{Name: "path", New: newBuilder},
+			expectedPanic: "builders: layer(path) registered twice",
+		},
+		{
+			name:          "invalid name",
+			layer:         Layer{Name: "con-trail", New: newBuilder},
+			expectedPanic: "builders: invalid layer name(con-trail)",
+		},
+		{
+			name:          "no factory",
+			layer:         Layer{Name: "smoke"},
+			expectedPanic: "builders: layer(smoke) has no factory",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.PanicsWithValue(t, tc.expectedPanic, func() { Register(tc.layer) })
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package persistence
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression formats in which files can be saved
+const (
+	CompressionNone = ""
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+const gzipSuffix = ".gz"
+const zstdSuffix = ".zst"
+
+var gzipMagic = []byte{0x1f, 0x8b}
+var zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// compressionSuffixes are the suffixes of the names of compressed files, in order of preference
+var compressionSuffixes = []string{zstdSuffix, gzipSuffix}
+
+// ParseCompression validates the name of a compression format
+func ParseCompression(compression string) (string, error) {
+	switch compression {
+	case CompressionNone, CompressionGzip, CompressionZstd:
+		return compression, nil
+	}
+	return "", fmt.Errorf("unrecognized compression(%s); expected one of {%s, %s}", compression, CompressionGzip, CompressionZstd)
+}
+
+// CompressionSuffix returns the suffix added to the names of files saved with the compression format
+func CompressionSuffix(compression string) string {
+	switch compression {
+	case CompressionGzip:
+		return gzipSuffix
+	case CompressionZstd:
+		return zstdSuffix
+	}
+	return ""
+}
+
+// TrimCompressionSuffix returns the name of the file without the suffix denoting its compression, if any
+func TrimCompressionSuffix(fn string) string {
+	for _, suffix := range compressionSuffixes {
+		if strings.HasSuffix(fn, suffix) {
+			return strings.TrimSuffix(fn, suffix)
+		}
+	}
+	return fn
+}
+
+// Compress returns the contents compressed using the format
+func Compress(compression string, contents []byte) ([]byte, error) {
+	var compressed bytes.Buffer
+	var writer io.WriteCloser
+	switch compression {
+	case CompressionNone:
+		return contents, nil
+	case CompressionGzip:
+		writer = gzip.NewWriter(&compressed)
+	case CompressionZstd:
+		zstdWriter, newWriterErr := zstd.NewWriter(&compressed)
+		if newWriterErr != nil {
+			return nil, newWriterErr
+		}
+		writer = zstdWriter
+	default:
+		return nil, fmt.Errorf("unrecognized compression(%s)", compression)
+	}
+	if _, writeErr := writer.Write(contents); writeErr != nil {
+		_ = writer.Close()
+		return nil, writeErr
+	}
+	if closeErr := writer.Close(); closeErr != nil {
+		return nil, closeErr
+	}
+	return compressed.Bytes(), nil
+}
+
+// Decompress returns the contents decompressed if they're compressed (as recognized by their
+// leading "magic" bytes), or as they are otherwise
+func Decompress(contents []byte) ([]byte, error) {
+	switch {
+	case bytes.HasPrefix(contents, gzipMagic):
+		reader, newReaderErr := gzip.NewReader(bytes.NewReader(contents))
+		if newReaderErr != nil {
+			return nil, newReaderErr
+		}
+		defer func() { _ = reader.Close() }()
+		return io.ReadAll(reader)
+	case bytes.HasPrefix(contents, zstdMagic):
+		decoder, newDecoderErr := zstd.NewReader(nil)
+		if newDecoderErr != nil {
+			return nil, newDecoderErr
+		}
+		defer decoder.Close()
+		return decoder.DecodeAll(contents, nil)
+	}
+	return contents, nil
+}
@@ -0,0 +1,46 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompression(t *testing.T) {
+	contents := bytes.Repeat([]byte(`{"positions":[]}`), 100)
+
+	for _, compression := range []string{CompressionNone, CompressionGzip, CompressionZstd} {
+		t.Run(compression, func(t *testing.T) {
+			requirer := require.New(t)
+
+			compressed, compressErr := Compress(compression, contents)
+			requirer.NoError(compressErr)
+			if compression != CompressionNone {
+				requirer.Less(len(compressed), len(contents))
+			}
+			decompressed, decompressErr := Decompress(compressed)
+			requirer.NoError(decompressErr)
+			requirer.Equal(contents, decompressed)
+
+			// files saved compressed are found, and transparently decompressed, under their plain names
+			dir := t.TempDir()
+			filename := filepath.Join(dir, "fvt_N12345-1.json")
+			requirer.NoError((&FileSaver{Compression: compression}).Save(filename, contents))
+			_, statErr := os.Stat(filename + CompressionSuffix(compression))
+			requirer.NoError(statErr)
+			loaded, loadErr := (&FileLoader{}).Load(context.Background(), filename)
+			requirer.NoError(loadErr)
+			requirer.Equal(contents, loaded)
+			requirer.Equal(filename, TrimCompressionSuffix(filename+CompressionSuffix(compression)))
+		})
+	}
+
+	_, parseErr := ParseCompression("lz4")
+	require.Error(t, parseErr)
+	_, loadErr := (&FileLoader{}).Load(context.Background(), filepath.Join(t.TempDir(), "missing.json"))
+	require.ErrorIs(t, loadErr, os.ErrNotExist)
+}
@@ -1,24 +1,52 @@
 package persistence
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"log"
 	"os"
+	"path/filepath"
+	"strings"
 )
 
 type Saver interface {
 	Save(string, []byte) error
 }
 
+// NamingSaver is a Saver which may save to a file named differently than requested
+// (e.g., so as not to overwrite another), returning the name of the file saved
+type NamingSaver interface {
+	SaveAs(string, []byte) (string, error)
+}
+
 type Loader interface {
-	Load(string) ([]byte, error)
+	Load(context.Context, string) ([]byte, error)
 }
 
 type FileSaverWriter func(filePath string, contents []byte) error
 
 type FileLoaderReader func(filePath string) ([]byte, error)
 
+// FileSaverExister returns true if the file exists
+type FileSaverExister func(filePath string) (bool, error)
+
+// Indexer records the files saved (e.g., in a catalog of them)
+type Indexer interface {
+	Index(filePath string, contents []byte) error
+}
+
+// FileSaver saves files, by default replacing each atomically (i.e., writing a temporary file in
+// the same directory, then renaming it), creating the directories needed to hold them.  Under other
+// overwrite policies than OverwriteAlways, the temporary file is instead linked to its name, which
+// fails rather than replaces a file created by that name since it was found not to exist.
 type FileSaver struct {
-	Writer FileSaverWriter
+	Writer      FileSaverWriter
+	Exister     FileSaverExister // if set, checks whether files exist (else they're checked in the file system)
+	Indexer     Indexer          // if set, records each file saved
+	Compression string           // format (e.g., CompressionGzip) in which files are saved; its suffix is added to their names
+	Overwrite   string           // policy (e.g., OverwriteFail) when a file exists (""=OverwriteAlways)
 }
 
 type FileLoader struct {
@@ -26,10 +54,20 @@ type FileLoader struct {
 }
 
 func (rs *FileSaver) Save(fnRef string, contents []byte) error {
-	return rs.save(os.WriteFile, fnRef, contents)
+	_, err := rs.saveAs(writeFileAtomically(writeFileSynced), writeFileExclusively(writeFileSynced), fnRef, contents)
+	return err
+}
+
+// SaveAs saves the file, returning its name, which differs from the one requested
+// if it's compressed, or if its overwrite policy is OverwriteSuffix and the file exists
+func (rs *FileSaver) SaveAs(fnRef string, contents []byte) (string, error) {
+	return rs.saveAs(writeFileAtomically(writeFileSynced), writeFileExclusively(writeFileSynced), fnRef, contents)
 }
 
-func (fl *FileLoader) Load(fnRef string) (contents []byte, err error) {
+func (fl *FileLoader) Load(ctx context.Context, fnRef string) (contents []byte, err error) {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
 	return fl.load(os.ReadFile, fnRef)
 }
 
@@ -38,17 +76,209 @@ type underWriter func(name string, data []byte, perm os.FileMode) error
 type underReader func(name string) ([]byte, error)
 
 func (rs *FileSaver) save(uw underWriter, fnRef string, contents []byte) error {
+	_, err := rs.saveAs(uw, uw, fnRef, contents)
+	return err
+}
+
+// saveAs saves the file using replaceUw, or (where the overwrite policy forbids replacing an existing
+// file) createUw, which must fail with an error satisfying errors.Is(err, fs.ErrExist) if it exists
+func (rs *FileSaver) saveAs(replaceUw, createUw underWriter, fnRef string, contents []byte) (string, error) {
+	compression := rs.Compression
+	if compression == CompressionNone {
+		// e.g., re-saving a file already compressed
+		compression = getCompressionOf(fnRef)
+	}
+	if suffix := CompressionSuffix(compression); !strings.HasSuffix(fnRef, suffix) {
+		fnRef += suffix
+	}
+	compressed, compressErr := Compress(compression, contents)
+	if compressErr != nil {
+		return "", compressErr
+	}
+	contents = compressed
+
+	policy, parseErr := ParseOverwritePolicy(rs.Overwrite)
+	if parseErr != nil {
+		return fnRef, parseErr
+	}
+	if policy == OverwriteAlways {
+		return fnRef, rs.write(replaceUw, fnRef, contents)
+	}
+
+	// under the other policies, the file saved mustn't replace an existing one
+	for n := 0; n <= maxOverwriteSuffix; n++ {
+		candidateRef := fnRef
+		if n > 0 {
+			candidateRef = makeSuffixedName(fnRef, n)
+		}
+		exists, existsErr := rs.exists(candidateRef)
+		if existsErr != nil {
+			return candidateRef, existsErr
+		}
+		if !exists {
+			saveErr := rs.write(createUw, candidateRef, contents)
+			if !errors.Is(saveErr, fs.ErrExist) {
+				return candidateRef, saveErr
+			}
+			// another file was created by the name since it was found not to exist
+		}
+		switch policy {
+		case OverwriteSkip:
+			log.Printf("INFO: not overwriting existing file(%s)\n", candidateRef)
+			return candidateRef, nil
+		case OverwriteFail:
+			return candidateRef, &CollisionError{Filename: candidateRef}
+		}
+	}
+	return fnRef, fmt.Errorf("no unused suffix up to %d: %w", maxOverwriteSuffix, &CollisionError{Filename: fnRef})
+}
+
+// write saves the file using the Writer (if set) or else uw, then records it using the Indexer (if set)
+func (rs *FileSaver) write(uw underWriter, fnRef string, contents []byte) error {
 	log.Printf("INFO: saving to file(%s)\n", fnRef)
+	var saveErr error
 	if rs.Writer != nil {
-		return rs.Writer(fnRef, contents)
+		saveErr = rs.Writer(fnRef, contents)
+	} else {
+		saveErr = uw(fnRef, contents, 0644)
+	}
+	if saveErr != nil || rs.Indexer == nil {
+		return saveErr
 	}
-	return uw(fnRef, contents, 0644)
+	if indexErr := rs.Indexer.Index(fnRef, contents); indexErr != nil {
+		// the file was saved; only the record of it is missing
+		log.Printf("WARNING: couldn't index file(%s): %v\n", fnRef, indexErr)
+	}
+	return nil
+}
+
+func (rs *FileSaver) exists(fnRef string) (bool, error) {
+	if rs.Exister != nil {
+		return rs.Exister(fnRef)
+	}
+	_, statErr := os.Stat(fnRef)
+	if errors.Is(statErr, fs.ErrNotExist) {
+		return false, nil
+	}
+	return statErr == nil, statErr
+}
+
+// WriteFileAtomically is os.WriteFile, but writes into a temporary file (in the same directory,
+// created if needed) renamed to the file only once complete, so that neither an interrupted write
+// nor a concurrent reader ever sees a truncated file
+func WriteFileAtomically(name string, data []byte, perm os.FileMode) error {
+	return writeFileAtomically(writeFileSynced)(name, data, perm)
+}
+
+// writeFileAtomically returns a writer of files which writes each (using uw) into a temporary file
+// in its directory (created if needed), renamed to it only once complete, so that an interrupted
+// write never leaves a truncated file in its place
+func writeFileAtomically(uw underWriter) underWriter {
+	return writeFileVia(uw, os.Rename)
+}
+
+// writeFileExclusively returns a writer of files like writeFileAtomically, except that it fails
+// (with an error satisfying errors.Is(err, fs.ErrExist)) rather than replaces an existing file
+func writeFileExclusively(uw underWriter) underWriter {
+	return writeFileVia(uw, publishExclusively)
+}
+
+// writeFileVia returns a writer of files which writes each (using uw) into a temporary file
+// in its directory (created if needed), published by its name (using publish) once complete
+func writeFileVia(uw underWriter, publish func(tempName, name string) error) underWriter {
+	return func(name string, data []byte, perm os.FileMode) error {
+		dir := filepath.Dir(name)
+		if mkdirErr := os.MkdirAll(dir, 0755); mkdirErr != nil {
+			return mkdirErr
+		}
+		tempFile, createErr := os.CreateTemp(dir, "."+filepath.Base(name)+".*.tmp")
+		if createErr != nil {
+			return createErr
+		}
+		tempName := tempFile.Name()
+		_ = tempFile.Close()
+
+		writeErr := uw(tempName, data, perm)
+		if writeErr == nil {
+			// temporary files are created accessible only to their owner
+			writeErr = os.Chmod(tempName, perm)
+		}
+		if writeErr == nil {
+			writeErr = publish(tempName, name)
+		}
+		// a temporary file published by a link remains, so is removed too
+		_ = os.Remove(tempName)
+		return writeErr
+	}
+}
+
+// publishExclusively links the file by the name, which (unlike renaming it) fails if a file by
+// that name exists.  Where links aren't supported, the name is first reserved by creating an
+// (empty) file exclusively, which is then replaced.
+func publishExclusively(tempName, name string) error {
+	linkErr := os.Link(tempName, name)
+	if linkErr == nil || errors.Is(linkErr, fs.ErrExist) {
+		return linkErr
+	}
+	placeholder, createErr := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if createErr != nil {
+		return createErr
+	}
+	_ = placeholder.Close()
+	if renameErr := os.Rename(tempName, name); renameErr != nil {
+		_ = os.Remove(name)
+		return renameErr
+	}
+	return nil
+}
+
+// writeFileSynced is os.WriteFile, but also commits the file to stable storage before returning
+func writeFileSynced(name string, data []byte, perm os.FileMode) error {
+	f, openErr := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if openErr != nil {
+		return openErr
+	}
+	_, writeErr := f.Write(data)
+	if writeErr == nil {
+		writeErr = f.Sync()
+	}
+	if closeErr := f.Close(); writeErr == nil {
+		writeErr = closeErr
+	}
+	return writeErr
 }
 
 func (fl *FileLoader) load(ur underReader, fnRef string) (contents []byte, err error) {
-	log.Printf("INFO: reading from file(%s)\n", fnRef)
+	read := (func(string) ([]byte, error))(ur)
 	if fl.Reader != nil {
-		return fl.Reader(fnRef)
+		read = fl.Reader
+	}
+	log.Printf("INFO: reading from file(%s)\n", fnRef)
+	contents, err = read(fnRef)
+	for _, suffix := range compressionSuffixes {
+		if !errors.Is(err, fs.ErrNotExist) {
+			break
+		}
+		// the file may have been saved compressed
+		var compressedErr error
+		if contents, compressedErr = read(fnRef + suffix); compressedErr == nil {
+			log.Printf("INFO: read from compressed file(%s)\n", fnRef+suffix)
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	return Decompress(contents)
+}
+
+// getCompressionOf returns the compression format denoted by the suffix of the file's name
+func getCompressionOf(fnRef string) string {
+	switch {
+	case strings.HasSuffix(fnRef, gzipSuffix):
+		return CompressionGzip
+	case strings.HasSuffix(fnRef, zstdSuffix):
+		return CompressionZstd
 	}
-	return ur(fnRef)
+	return CompressionNone
 }
@@ -2,7 +2,9 @@ package persistence
 
 import (
     "errors"
+    "io/fs"
     "os"
+    "path/filepath"
     "testing"
 
     "github.com/stretchr/testify/require"
@@ -63,7 +65,7 @@ func TestFileSaver_Save(t *testing.T) {
             }
 
             saver := &FileSaver{Writer: writer}
-            const filenameSavedTo = "this is the filename"
+            filenameSavedTo := filepath.Join(t.TempDir(), "this is the filename")
             const contentsSaved = "this is what was saved"
             err := saver.save(uw, filenameSavedTo, []byte(contentsSaved))
             if tc.hasError {
@@ -83,6 +85,43 @@ func TestFileSaver_Save(t *testing.T) {
     }
 }
 
+type testIndexer struct {
+    indexed map[string]string
+    err     error
+}
+
+func (ti *testIndexer) Index(filePath string, contents []byte) error {
+    if ti.err != nil {
+        return ti.err
+    }
+    ti.indexed[filePath] = string(contents)
+    return nil
+}
+
+func TestFileSaver_SaveIndexed(t *testing.T) {
+    requirer := require.New(t)
+
+    dir := t.TempDir()
+    written := filepath.Join(dir, "written")
+    unwritable := filepath.Join(dir, "unwritable")
+    uw := func(filePath string, contents []byte, perm os.FileMode) error {
+        if filePath == unwritable {
+            return errors.New("can't write")
+        }
+        return nil
+    }
+    indexer := &testIndexer{indexed: make(map[string]string)}
+    saver := &FileSaver{Indexer: indexer}
+
+    requirer.NoError(saver.save(uw, written, []byte("contents")))
+    requirer.Error(saver.save(uw, unwritable, []byte("contents")))
+    requirer.Equal(map[string]string{written: "contents"}, indexer.indexed)
+
+    // failing to index a saved file isn't an error in saving it
+    indexer.err = errors.New("can't index")
+    requirer.NoError(saver.save(uw, filepath.Join(dir, "unindexed"), []byte("contents")))
+}
+
 func TestFileSaver_Load(t *testing.T) {
     testCases := []struct {
         name      string
@@ -156,3 +195,191 @@ func TestFileSaver_Load(t *testing.T) {
         })
     }
 }
+
+func TestFileSaver_SaveOverwrite(t *testing.T) {
+    testCases := []struct {
+        name          string
+        overwrite     string
+        existing      []string
+        expectedSaved string
+        expectedError error
+    }{
+        {
+            name:          "new file, fail",
+            overwrite:     OverwriteFail,
+            expectedSaved: "fvk_x.kmz",
+        },
+        {
+            name:          "existing file, default",
+            existing:      []string{"fvk_x.kmz"},
+            expectedSaved: "fvk_x.kmz",
+        },
+        {
+            name:          "existing file, overwrite",
+            overwrite:     OverwriteAlways,
+            existing:      []string{"fvk_x.kmz"},
+            expectedSaved: "fvk_x.kmz",
+        },
+        {
+            name:          "existing file, fail",
+            overwrite:     OverwriteFail,
+            existing:      []string{"fvk_x.kmz"},
+            expectedError: &CollisionError{Filename: "fvk_x.kmz"},
+        },
+        {
+            name:      "existing file, skip",
+            overwrite: OverwriteSkip,
+            existing:  []string{"fvk_x.kmz"},
+        },
+        {
+            name:          "existing files, suffix",
+            overwrite:     OverwriteSuffix,
+            existing:      []string{"fvk_x.kmz", "fvk_x-1.kmz"},
+            expectedSaved: "fvk_x-2.kmz",
+        },
+        {
+            name:          "unrecognized policy",
+            overwrite:     "clobber",
+            expectedError: errors.New("unrecognized overwrite policy(clobber); expected one of {fail, skip, suffix, overwrite}"),
+        },
+    }
+
+    for _, tc := range testCases {
+        t.Run(tc.name, func(t *testing.T) {
+            requirer := require.New(t)
+
+            var actualSaved []string
+            saver := &FileSaver{
+                Overwrite: tc.overwrite,
+                Writer: func(filePath string, contents []byte) error {
+                    actualSaved = append(actualSaved, filePath)
+                    return nil
+                },
+                Exister: func(filePath string) (bool, error) {
+                    for _, existing := range tc.existing {
+                        if existing == filePath {
+                            return true, nil
+                        }
+                    }
+                    return false, nil
+                },
+            }
+            savedAs, err := saver.SaveAs("fvk_x.kmz", []byte("contents"))
+            if tc.expectedError != nil {
+                requirer.EqualError(err, tc.expectedError.Error())
+                requirer.Empty(actualSaved)
+                return
+            }
+            requirer.NoError(err)
+            if tc.expectedSaved == "" {
+                requirer.Empty(actualSaved)
+                requirer.Equal("fvk_x.kmz", savedAs)
+                return
+            }
+            requirer.Equal([]string{tc.expectedSaved}, actualSaved)
+            requirer.Equal(tc.expectedSaved, savedAs)
+        })
+    }
+}
+
+func TestFileSaver_SaveCollision(t *testing.T) {
+    requirer := require.New(t)
+
+    existsErr := errors.New("can't tell")
+    saver := &FileSaver{
+        Overwrite: OverwriteFail,
+        Exister:   func(string) (bool, error) { return true, nil },
+    }
+    err := saver.Save("fvt_x.json", []byte("contents"))
+    var collisionErr *CollisionError
+    requirer.ErrorAs(err, &collisionErr)
+    requirer.Equal("fvt_x.json", collisionErr.Filename)
+    requirer.ErrorIs(err, fs.ErrExist)
+
+    // existence is checked for the name of the file as compressed
+    saver.Compression = CompressionGzip
+    requirer.EqualError(saver.Save("fvt_x.json", []byte("contents")), "file(fvt_x.json.gz) already exists")
+
+    // failing to check for an existing file fails the save
+    saver.Exister = func(string) (bool, error) { return false, existsErr }
+    requirer.ErrorIs(saver.Save("fvt_x.json", []byte("contents")), existsErr)
+}
+
+func TestFileSaver_SaveAtomically(t *testing.T) {
+    requirer := require.New(t)
+    dir := t.TempDir()
+    filename := filepath.Join(dir, "artifacts", "fvt_x.json")
+
+    // missing directories are created
+    saver := &FileSaver{}
+    requirer.NoError(saver.Save(filename, []byte("original")))
+    fileInfo, statErr := os.Stat(filename)
+    requirer.NoError(statErr)
+    requirer.Equal(os.FileMode(0644), fileInfo.Mode().Perm())
+
+    // a write interrupted part way leaves the original file intact, and no temporary file behind
+    interruptedErr := errors.New("interrupted")
+    interruptedWriter := func(filePath string, contents []byte, perm os.FileMode) error {
+        requirer.NotEqual(filename, filePath)
+        requirer.NoError(os.WriteFile(filePath, contents[:len(contents)/2], perm))
+        return interruptedErr
+    }
+    requirer.ErrorIs(saver.save(writeFileAtomically(interruptedWriter), filename, []byte("replacement")), interruptedErr)
+    contents, readErr := os.ReadFile(filename)
+    requirer.NoError(readErr)
+    requirer.Equal("original", string(contents))
+    entries, readDirErr := os.ReadDir(filepath.Dir(filename))
+    requirer.NoError(readDirErr)
+    requirer.Len(entries, 1)
+
+    requirer.NoError(saver.Save(filename, []byte("replacement")))
+    contents, readErr = os.ReadFile(filename)
+    requirer.NoError(readErr)
+    requirer.Equal("replacement", string(contents))
+
+    // files are found in the file system, absent an Exister
+    saver.Overwrite = OverwriteSuffix
+    savedAs, saveErr := saver.SaveAs(filename, []byte("another"))
+    requirer.NoError(saveErr)
+    requirer.Equal(filepath.Join(dir, "artifacts", "fvt_x-1.json"), savedAs)
+}
+
+func TestFileSaver_SaveNoClobber(t *testing.T) {
+    dir := t.TempDir()
+    filename := filepath.Join(dir, "fvk_x.kmz")
+
+    // files created after they were found not to exist (e.g., by a concurrent run) aren't replaced
+    for _, policy := range []string{OverwriteFail, OverwriteSkip, OverwriteSuffix} {
+        t.Run(policy, func(t *testing.T) {
+            requirer := require.New(t)
+            requirer.NoError(os.WriteFile(filename, []byte("concurrent"), 0644))
+            saver := &FileSaver{
+                Overwrite: policy,
+                Exister:   func(string) (bool, error) { return false, nil },
+            }
+            savedAs, saveErr := saver.SaveAs(filename, []byte("mine"))
+            switch policy {
+            case OverwriteFail:
+                requirer.ErrorIs(saveErr, fs.ErrExist)
+            case OverwriteSkip:
+                requirer.NoError(saveErr)
+                requirer.Equal(filename, savedAs)
+            case OverwriteSuffix:
+                requirer.NoError(saveErr)
+                requirer.Equal(filepath.Join(dir, "fvk_x-1.kmz"), savedAs)
+                contents, readErr := os.ReadFile(savedAs)
+                requirer.NoError(readErr)
+                requirer.Equal("mine", string(contents))
+                requirer.NoError(os.Remove(savedAs))
+            }
+            contents, readErr := os.ReadFile(filename)
+            requirer.NoError(readErr)
+            requirer.Equal("concurrent", string(contents))
+
+            // nor are temporary files left behind
+            entries, readDirErr := os.ReadDir(dir)
+            requirer.NoError(readDirErr)
+            requirer.Len(entries, 1)
+        })
+    }
+}
@@ -0,0 +1,38 @@
+package persistence
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// LockFile waits for (then takes) the lock held by (exclusively) creating the named file, returning
+// the function releasing it (by removing the file), so that concurrent processes (e.g., runs of fviz)
+// changing a shared file don't lose each other's changes.  A lock held for longer than "timeout" is
+// presumed to have been abandoned (e.g., by a process killed holding it), and is taken over.
+func LockFile(lockFilename string, timeout time.Duration) (func(), error) {
+	if mkdirErr := os.MkdirAll(filepath.Dir(lockFilename), 0755); mkdirErr != nil {
+		return nil, mkdirErr
+	}
+	for delay := time.Millisecond; ; {
+		f, createErr := os.OpenFile(lockFilename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if createErr == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(lockFilename) }, nil
+		}
+		if !errors.Is(createErr, fs.ErrExist) {
+			return nil, createErr
+		}
+		if fileInfo, statErr := os.Stat(lockFilename); statErr == nil && time.Since(fileInfo.ModTime()) > timeout {
+			// the lock was abandoned
+			_ = os.Remove(lockFilename)
+			continue
+		}
+		time.Sleep(delay)
+		if delay < 100*time.Millisecond {
+			delay *= 2
+		}
+	}
+}
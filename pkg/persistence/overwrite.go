@@ -0,0 +1,53 @@
+package persistence
+
+import (
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"strings"
+)
+
+// Overwrite policies, determining what's done when saving to a file which already exists
+const (
+	OverwriteAlways = "overwrite" // replace the existing file
+	OverwriteFail   = "fail"      // fail, returning a *CollisionError
+	OverwriteSkip   = "skip"      // keep the existing file, saving nothing
+	OverwriteSuffix = "suffix"    // save to a new file, named with a numeric suffix (e.g., "name-1.kmz")
+)
+
+// maxOverwriteSuffix limits the search for an unused name under OverwriteSuffix
+const maxOverwriteSuffix = 999
+
+// ParseOverwritePolicy validates the name of an overwrite policy; empty means OverwriteAlways
+func ParseOverwritePolicy(policy string) (string, error) {
+	switch policy {
+	case "":
+		return OverwriteAlways, nil
+	case OverwriteAlways, OverwriteFail, OverwriteSkip, OverwriteSuffix:
+		return policy, nil
+	}
+	return "", fmt.Errorf("unrecognized overwrite policy(%s); expected one of {%s, %s, %s, %s}",
+		policy, OverwriteFail, OverwriteSkip, OverwriteSuffix, OverwriteAlways)
+}
+
+// CollisionError reports that a file wasn't saved because another already exists with its name.
+// It satisfies errors.Is(err, fs.ErrExist).
+type CollisionError struct {
+	Filename string
+}
+
+func (e *CollisionError) Error() string {
+	return fmt.Sprintf("file(%s) already exists", e.Filename)
+}
+
+func (e *CollisionError) Unwrap() error {
+	return fs.ErrExist
+}
+
+// makeSuffixedName returns the name of the file with the numeric suffix inserted before
+// its extension(s), e.g., "fvk_x_path-2.kmz" or "fvt_x-2.json.gz"
+func makeSuffixedName(fnRef string, n int) string {
+	trimmed := TrimCompressionSuffix(fnRef)
+	ext := filepath.Ext(trimmed) + strings.TrimPrefix(fnRef, trimmed)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(fnRef, ext), n, ext)
+}
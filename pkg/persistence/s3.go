@@ -0,0 +1,253 @@
+package persistence
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3UrlScheme is the scheme of the URLs (e.g., "s3://bucket/prefix") locating objects in S3-compatible storage
+const S3UrlScheme = "s3"
+
+const s3DefaultRegion = "us-east-1"
+const s3SigningAlgorithm = "AWS4-HMAC-SHA256"
+const s3TimeFormat = "20060102T150405Z"
+
+// IsS3Url returns true if the reference locates an object (or prefix) in S3-compatible storage
+func IsS3Url(ref string) bool {
+	return strings.HasPrefix(ref, S3UrlScheme+"://")
+}
+
+// JoinRef returns the reference to the named file within the directory (or S3 prefix)
+func JoinRef(dir, name string) string {
+	if IsS3Url(dir) {
+		return strings.TrimRight(dir, "/") + "/" + name
+	}
+	return filepath.Join(dir, name)
+}
+
+// DirRef returns the reference to the directory (or S3 prefix) containing the file
+func DirRef(ref string) string {
+	if IsS3Url(ref) {
+		i := strings.LastIndex(ref, "/")
+		if i < len(S3UrlScheme+"://") {
+			return ref
+		}
+		return ref[:i]
+	}
+	return filepath.Dir(ref)
+}
+
+// S3Store saves and loads objects in S3-compatible storage (e.g., AWS S3 or MinIO), referenced by
+// URLs such as "s3://bucket/prefix/name".  Requests are signed using AWS Signature Version 4, and
+// address buckets in "path style" (e.g., "https://s3.us-east-1.amazonaws.com/bucket/key"), which
+// S3-compatible servers generally support.
+type S3Store struct {
+	Endpoint        string       // base URL of the storage service (""=AWS S3 in Region)
+	Region          string       // ""=us-east-1
+	AccessKeyId     string       // ""=anonymous (unsigned) requests
+	SecretAccessKey string       // secret of the access key
+	SessionToken    string       // needed only with temporary credentials
+	Client          *http.Client // nil=http.DefaultClient
+	now             func() time.Time
+}
+
+// Save stores the contents as the object referenced by the URL; it implements Saver
+func (s *S3Store) Save(ref string, contents []byte) error {
+	return s.Write(ref, contents)
+}
+
+// Load retrieves the contents of the object referenced by the URL; it implements Loader.
+// Objects which don't exist return an error satisfying errors.Is(err, fs.ErrNotExist).
+func (s *S3Store) Load(ctx context.Context, ref string) ([]byte, error) {
+	response, doErr := s.do(ctx, http.MethodGet, ref, nil)
+	if doErr != nil {
+		return nil, doErr
+	}
+	defer func() { _ = response.Body.Close() }()
+	return io.ReadAll(response.Body)
+}
+
+// Write stores the contents as the object referenced by the URL; it's a FileSaverWriter
+func (s *S3Store) Write(ref string, contents []byte) error {
+	response, doErr := s.do(context.Background(), http.MethodPut, ref, contents)
+	if doErr != nil {
+		return doErr
+	}
+	return response.Body.Close()
+}
+
+// Exists returns true if the object referenced by the URL exists; it's a FileSaverExister
+func (s *S3Store) Exists(ref string) (bool, error) {
+	response, doErr := s.do(context.Background(), http.MethodHead, ref, nil)
+	if errors.Is(doErr, fs.ErrNotExist) {
+		return false, nil
+	}
+	if doErr != nil {
+		return false, doErr
+	}
+	return true, response.Body.Close()
+}
+
+// Read retrieves the contents of the object referenced by the URL; it's a FileLoaderReader
+func (s *S3Store) Read(ref string) ([]byte, error) {
+	return s.Load(context.Background(), ref)
+}
+
+func (s *S3Store) do(ctx context.Context, method, ref string, body []byte) (*http.Response, error) {
+	objectUrl, getUrlErr := s.getObjectUrl(ref)
+	if getUrlErr != nil {
+		return nil, getUrlErr
+	}
+	request, newRequestErr := http.NewRequestWithContext(ctx, method, objectUrl, bytes.NewReader(body))
+	if newRequestErr != nil {
+		return nil, newRequestErr
+	}
+	if s.AccessKeyId != "" {
+		s.sign(request, body)
+	}
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, doErr := client.Do(request)
+	if doErr != nil {
+		return nil, doErr
+	}
+	if response.StatusCode/100 == 2 {
+		return response, nil
+	}
+	defer func() { _ = response.Body.Close() }()
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+	statusErr := fmt.Errorf("%s %s: %s: %s", method, ref, response.Status, strings.TrimSpace(string(message)))
+	if response.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%w: %v", fs.ErrNotExist, statusErr)
+	}
+	return nil, statusErr
+}
+
+// getObjectUrl returns the (path style) URL of the object referenced by an "s3://bucket/key" URL
+func (s *S3Store) getObjectUrl(ref string) (string, error) {
+	s3Url, parseErr := url.Parse(ref)
+	if parseErr != nil {
+		return "", parseErr
+	}
+	key := strings.TrimPrefix(s3Url.Path, "/")
+	if s3Url.Scheme != S3UrlScheme || s3Url.Host == "" || key == "" {
+		return "", fmt.Errorf("invalid object URL(%s); expected 's3://bucket/key'", ref)
+	}
+	endpoint := s.Endpoint
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", s.getRegion())
+	}
+	return fmt.Sprintf("%s/%s/%s", strings.TrimRight(endpoint, "/"), s3Url.Host, (&url.URL{Path: key}).EscapedPath()), nil
+}
+
+func (s *S3Store) getRegion() string {
+	if s.Region == "" {
+		return s3DefaultRegion
+	}
+	return s.Region
+}
+
+func (s *S3Store) getNow() time.Time {
+	if s.now != nil {
+		return s.now()
+	}
+	return time.Now()
+}
+
+// sign adds the headers authenticating the request using AWS Signature Version 4
+// (see https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html)
+func (s *S3Store) sign(request *http.Request, body []byte) {
+	now := s.getNow().UTC()
+	amzDate := now.Format(s3TimeFormat)
+	payloadHash := sha256Hex(body)
+	request.Header.Set("x-amz-date", amzDate)
+	request.Header.Set("x-amz-content-sha256", payloadHash)
+	if s.SessionToken != "" {
+		request.Header.Set("x-amz-security-token", s.SessionToken)
+	}
+
+	// the "host", "range" and "x-amz-*" headers are signed, in order of their names
+	headers := map[string]string{"host": request.URL.Host}
+	for name, values := range request.Header {
+		lowerName := strings.ToLower(name)
+		if lowerName == "host" || lowerName == "range" || strings.HasPrefix(lowerName, "x-amz-") {
+			headers[lowerName] = strings.TrimSpace(strings.Join(values, ","))
+		}
+	}
+	var headerNames []string
+	for name := range headers {
+		headerNames = append(headerNames, name)
+	}
+	sort.Strings(headerNames)
+	var canonicalHeaders strings.Builder
+	for _, name := range headerNames {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(headerNames, ";")
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		request.URL.EscapedPath(),
+		canonicalQuery(request.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	date := now.Format("20060102")
+	scope := fmt.Sprintf("%s/%s/s3/aws4_request", date, s.getRegion())
+	stringToSign := strings.Join([]string{s3SigningAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+
+	signingKey := hmacSha256([]byte("AWS4"+s.SecretAccessKey), date)
+	for _, part := range []string{s.getRegion(), "s3", "aws4_request"} {
+		signingKey = hmacSha256(signingKey, part)
+	}
+	signature := hex.EncodeToString(hmacSha256(signingKey, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3SigningAlgorithm, s.AccessKeyId, scope, signedHeaders, signature))
+}
+
+func canonicalQuery(query url.Values) string {
+	var keys []string
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := query[key]
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, url.QueryEscape(key)+"="+strings.ReplaceAll(url.QueryEscape(value), "+", "%20"))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
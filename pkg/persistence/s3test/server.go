@@ -0,0 +1,226 @@
+// Package s3test provides a fake S3-compatible object storage server, holding objects
+// in memory, for use in tests of persistence.S3Store and the code using it.
+package s3test
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// signingAlgorithm is that of the (Signature Version 4) signatures verified
+const signingAlgorithm = "AWS4-HMAC-SHA256"
+
+// Options configure the behavior of the fake S3 server
+type Options struct {
+	Buckets         []string // names of the buckets served; requests for others fail with "NoSuchBucket"
+	AccessKeyId     string   // access key required of signed requests (""=anonymous requests allowed)
+	SecretAccessKey string   // secret key with which the (Signature Version 4) signatures of requests are verified
+}
+
+// Server is a fake S3 server, serving "path style" GET, HEAD and PUT requests for objects,
+// which must be signed (using SecretAccessKey) if AccessKeyId is set
+type Server struct {
+	*httptest.Server
+	Options
+	mu      sync.Mutex
+	objects map[string][]byte // keyed by "bucket/key"
+}
+
+// NewServer starts and returns a new fake S3 server, which should be closed when no longer needed
+func NewServer(options Options) *Server {
+	s := &Server{Options: options, objects: make(map[string][]byte)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHttp))
+	return s
+}
+
+// Keys returns the "bucket/key" names of the objects stored, in order
+func (s *Server) Keys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var keys []string
+	for key := range s.objects {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Object returns the contents of the object stored as "bucket/key", if any
+func (s *Server) Object(bucketKey string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	contents, found := s.objects[bucketKey]
+	return contents, found
+}
+
+func (s *Server) serveHttp(w http.ResponseWriter, r *http.Request) {
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if !s.hasBucket(bucket) {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", bucket)
+		return
+	}
+	if key == "" {
+		writeError(w, http.StatusNotImplemented, "NotImplemented", "bucket operations")
+		return
+	}
+
+	body, readErr := io.ReadAll(r.Body)
+	if readErr != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", readErr.Error())
+		return
+	}
+	if authErr := s.checkAuthorization(r, body); authErr != "" {
+		writeError(w, http.StatusForbidden, "AccessDenied", authErr)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		s.objects[bucket+"/"+key] = body
+	case http.MethodGet, http.MethodHead:
+		contents, found := s.objects[bucket+"/"+key]
+		if !found {
+			writeError(w, http.StatusNotFound, "NoSuchKey", key)
+			return
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(contents)
+		}
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", r.Method)
+	}
+}
+
+func (s *Server) hasBucket(bucket string) bool {
+	for _, b := range s.Buckets {
+		if b == bucket {
+			return true
+		}
+	}
+	return false
+}
+
+// checkAuthorization returns the reason the request isn't authorized, if it isn't, having verified
+// its credential, the hash of its payload and its (Signature Version 4) signature
+// See https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *Server) checkAuthorization(r *http.Request, body []byte) string {
+	if s.AccessKeyId == "" {
+		return ""
+	}
+	// e.g., "AWS4-HMAC-SHA256 Credential={key}/{date}/{region}/s3/aws4_request, SignedHeaders=host;x-amz-date, Signature={hex}"
+	algorithm, parameters, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	fields := make(map[string]string)
+	for _, parameter := range strings.Split(parameters, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(parameter), "=")
+		fields[name] = value
+	}
+	accessKeyId, scope, _ := strings.Cut(fields["Credential"], "/")
+	if algorithm != signingAlgorithm || accessKeyId != s.AccessKeyId {
+		return "missing or unrecognized credential"
+	}
+	amzDate := r.Header.Get("x-amz-date")
+	if fields["Signature"] == "" || fields["SignedHeaders"] == "" || amzDate == "" {
+		return "request not signed"
+	}
+	scopeParts := strings.Split(scope, "/")
+	if len(scopeParts) != 4 || !strings.HasPrefix(amzDate, scopeParts[0]) || scopeParts[2] != "s3" || scopeParts[3] != "aws4_request" {
+		return "invalid credential scope"
+	}
+	payloadHash := sha256Hex(body)
+	if r.Header.Get("x-amz-content-sha256") != payloadHash {
+		return "payload hash mismatch"
+	}
+
+	var canonicalHeaders strings.Builder
+	signedHeaders := strings.Split(fields["SignedHeaders"], ";")
+	for _, name := range signedHeaders {
+		value := strings.Join(r.Header.Values(name), ",")
+		if name == "host" {
+			value = r.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	if !sort.StringsAreSorted(signedHeaders) || !containsAll(signedHeaders, "host", "x-amz-content-sha256", "x-amz-date") {
+		return "required headers not signed"
+	}
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		r.URL.EscapedPath(),
+		getCanonicalQuery(r.URL.Query()),
+		canonicalHeaders.String(),
+		fields["SignedHeaders"],
+		payloadHash,
+	}, "\n")
+	stringToSign := strings.Join([]string{signingAlgorithm, amzDate, scope, sha256Hex([]byte(canonicalRequest))}, "\n")
+	signingKey := []byte("AWS4" + s.SecretAccessKey)
+	for _, part := range scopeParts {
+		signingKey = hmacSha256(signingKey, part)
+	}
+	if !hmac.Equal([]byte(hex.EncodeToString(hmacSha256(signingKey, stringToSign))), []byte(fields["Signature"])) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+// getCanonicalQuery returns the query in the canonical form in which it's signed
+func getCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for key, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, uriEncode(key)+"="+uriEncode(value))
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// uriEncode encodes all but the "unreserved" characters, as required by Signature Version 4
+func uriEncode(value string) string {
+	var encoded strings.Builder
+	for _, b := range []byte(value) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || strings.IndexByte("-._~", b) >= 0 {
+			encoded.WriteByte(b)
+		} else {
+			_, _ = fmt.Fprintf(&encoded, "%%%02X", b)
+		}
+	}
+	return encoded.String()
+}
+
+// containsAll returns true if the (sorted) names include all those required
+func containsAll(names []string, required ...string) bool {
+	for _, name := range required {
+		if i := sort.SearchStrings(names, name); i == len(names) || names[i] != name {
+			return false
+		}
+	}
+	return true
+}
+
+func sha256Hex(data []byte) string {
+	digest := sha256.Sum256(data)
+	return hex.EncodeToString(digest[:])
+}
+
+func hmacSha256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, "<Error><Code>%s</Code><Message>%s</Message></Error>", code, message)
+}
@@ -0,0 +1,142 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// HgtDirectory is an ElevationProvider reading Digital Elevation Model (DEM) data from
+// SRTM ".hgt" tiles located in a local directory.  Each tile covers one degree of latitude
+// and longitude, and is named according to its south-west corner (e.g., "N37W123.hgt").
+// Tiles are loaded when first needed and retained for the life of the HgtDirectory.
+// See https://www.usgs.gov/centers/eros/science/usgs-eros-archive-digital-elevation-shuttle-radar-topography-mission-srtm
+type HgtDirectory struct {
+	Dir     string
+	Verbose bool
+	mu      sync.Mutex
+	tiles   map[string]*hgtTile // a nil entry marks a tile that isn't available
+}
+
+type hgtTile struct {
+	samples int // number of samples per row and per column (e.g., 1201 or 3601)
+	heights []int16
+}
+
+const hgtVoid = -32768
+
+func (hd *HgtDirectory) GetElevation(latitude, longitude float64) (float64, bool, error) {
+	tileLat := math.Floor(latitude)
+	tileLon := math.Floor(longitude)
+	tile, getTileErr := hd.getTile(int(tileLat), int(tileLon))
+	if getTileErr != nil || tile == nil {
+		return 0, false, getTileErr
+	}
+
+	// rows run north to south, columns west to east
+	last := float64(tile.samples - 1)
+	row := (tileLat + 1 - latitude) * last
+	col := (longitude - tileLon) * last
+	r0, c0 := int(math.Floor(row)), int(math.Floor(col))
+	r1, c1 := minInt(r0+1, tile.samples-1), minInt(c0+1, tile.samples-1)
+	dr, dc := row-float64(r0), col-float64(c0)
+
+	// bilinear interpolation between the four surrounding samples
+	corners := []struct {
+		r, c   int
+		weight float64
+	}{
+		{r0, c0, (1 - dr) * (1 - dc)},
+		{r0, c1, (1 - dr) * dc},
+		{r1, c0, dr * (1 - dc)},
+		{r1, c1, dr * dc},
+	}
+	var elevation, weights float64
+	for _, corner := range corners {
+		height := tile.heights[corner.r*tile.samples+corner.c]
+		if height == hgtVoid || corner.weight == 0 {
+			continue
+		}
+		elevation += float64(height) * corner.weight
+		weights += corner.weight
+	}
+	if weights == 0 {
+		return 0, false, nil
+	}
+	return elevation / weights, true, nil
+}
+
+// MakeHgtTileFilename returns the name of the SRTM tile whose south-west corner is at the given location
+func MakeHgtTileFilename(tileLat, tileLon int) string {
+	latHemisphere, lonHemisphere := 'N', 'E'
+	if tileLat < 0 {
+		latHemisphere = 'S'
+		tileLat = -tileLat
+	}
+	if tileLon < 0 {
+		lonHemisphere = 'W'
+		tileLon = -tileLon
+	}
+	return fmt.Sprintf("%c%02d%c%03d.hgt", latHemisphere, tileLat, lonHemisphere, tileLon)
+}
+
+func (hd *HgtDirectory) getTile(tileLat, tileLon int) (*hgtTile, error) {
+	hd.mu.Lock()
+	defer hd.mu.Unlock()
+
+	tileName := MakeHgtTileFilename(tileLat, tileLon)
+	if tile, ok := hd.tiles[tileName]; ok {
+		return tile, nil
+	}
+	if hd.tiles == nil {
+		hd.tiles = make(map[string]*hgtTile)
+	}
+
+	tilePath := filepath.Join(hd.Dir, tileName)
+	contents, readErr := os.ReadFile(tilePath)
+	if errors.Is(readErr, fs.ErrNotExist) {
+		if hd.Verbose {
+			log.Printf("INFO: no terrain tile(%s)\n", tilePath)
+		}
+		hd.tiles[tileName] = nil
+		return nil, nil
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+
+	tile, parseErr := parseHgtTile(contents)
+	if parseErr != nil {
+		return nil, fmt.Errorf("invalid terrain tile(%s): %w", tilePath, parseErr)
+	}
+	if hd.Verbose {
+		log.Printf("INFO: loaded terrain tile(%s)\n", tilePath)
+	}
+	hd.tiles[tileName] = tile
+	return tile, nil
+}
+
+func parseHgtTile(contents []byte) (*hgtTile, error) {
+	samples := int(math.Sqrt(float64(len(contents) / 2)))
+	if samples < 2 || samples*samples*2 != len(contents) {
+		return nil, fmt.Errorf("unexpected size(%d)", len(contents))
+	}
+	heights := make([]int16, samples*samples)
+	for i := range heights {
+		heights[i] = int16(binary.BigEndian.Uint16(contents[i*2:]))
+	}
+	return &hgtTile{samples: samples, heights: heights}, nil
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
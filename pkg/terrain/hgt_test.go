@@ -0,0 +1,132 @@
+package terrain
+
+import (
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeHgtTileFilename(t *testing.T) {
+
+	testCases := []struct {
+		tileLat, tileLon int
+		expectedFilename string
+	}{
+		{tileLat: 37, tileLon: -123, expectedFilename: "N37W123.hgt"},
+		{tileLat: -1, tileLon: 5, expectedFilename: "S01E005.hgt"},
+		{tileLat: 0, tileLon: 0, expectedFilename: "N00E000.hgt"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expectedFilename, func(t *testing.T) {
+			require.New(t).Equal(tc.expectedFilename, MakeHgtTileFilename(tc.tileLat, tc.tileLon))
+		})
+	}
+}
+
+func TestHgtDirectory_GetElevation(t *testing.T) {
+
+	dir := t.TempDir()
+	// 3x3 samples; rows run north to south
+	writeTestHgtTile(t, dir, "N20W157.hgt", []int16{
+		100, 200, 300,
+		400, 500, 600,
+		700, 800, hgtVoid,
+	})
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "N21W157.hgt"), []byte("garbage"), 0644))
+
+	testCases := []struct {
+		name              string
+		latitude          float64
+		longitude         float64
+		expectedElevation float64
+		expectedOk        bool
+		expectedErrors    []string
+	}{
+		{
+			name:              "north-west corner",
+			latitude:          20.99999999,
+			longitude:         -157,
+			expectedElevation: 100,
+			expectedOk:        true,
+		},
+		{
+			name:              "center",
+			latitude:          20.5,
+			longitude:         -156.5,
+			expectedElevation: 500,
+			expectedOk:        true,
+		},
+		{
+			name:              "interpolated",
+			latitude:          20.75,
+			longitude:         -156.75,
+			expectedElevation: 300,
+			expectedOk:        true,
+		},
+		{
+			name:              "adjacent to void",
+			latitude:          20.25,
+			longitude:         -156.25,
+			expectedElevation: (500 + 600 + 800) / 3.0,
+			expectedOk:        true,
+		},
+		{
+			name:      "missing tile",
+			latitude:  -33.9,
+			longitude: 18.4,
+		},
+		{
+			name:           "corrupt tile",
+			latitude:       21.5,
+			longitude:      -156.5,
+			expectedErrors: []string{"N21W157.hgt", "unexpected size"},
+		},
+	}
+
+	hd := &HgtDirectory{Dir: dir}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			requirer := require.New(t)
+			elevation, ok, err := hd.GetElevation(tc.latitude, tc.longitude)
+			if tc.expectedErrors != nil {
+				requirer.Error(err)
+				for _, expectedErr := range tc.expectedErrors {
+					requirer.Contains(err.Error(), expectedErr)
+				}
+				return
+			}
+			requirer.NoError(err)
+			requirer.Equal(tc.expectedOk, ok)
+			if ok {
+				requirer.InDelta(tc.expectedElevation, elevation, 0.01)
+			}
+		})
+	}
+}
+
+func TestGetAglFeet(t *testing.T) {
+	requirer := require.New(t)
+	dir := t.TempDir()
+	writeTestHgtTile(t, dir, "N20W157.hgt", []int16{1000, 1000, 1000, 1000})
+
+	agl, ok, err := GetAglFeet(&HgtDirectory{Dir: dir}, 20.5, -156.5, 5000)
+	requirer.NoError(err)
+	requirer.True(ok)
+	requirer.InDelta(5000-1000*feetPerMeter, agl, 0.01)
+
+	_, ok, err = GetAglFeet(nil, 20.5, -156.5, 5000)
+	requirer.NoError(err)
+	requirer.False(ok)
+}
+
+func writeTestHgtTile(t *testing.T, dir, name string, heights []int16) {
+	contents := make([]byte, len(heights)*2)
+	for i, height := range heights {
+		binary.BigEndian.PutUint16(contents[i*2:], uint16(height))
+	}
+	require.NoError(t, os.WriteFile(filepath.Join(dir, name), contents, 0644))
+}
@@ -0,0 +1,23 @@
+package terrain
+
+// ElevationProvider can report the elevation of the terrain at a location
+type ElevationProvider interface {
+	// GetElevation returns the elevation (in meters above mean sea level) of the terrain
+	// at the given location, or false if the elevation at that location isn't known
+	GetElevation(latitude, longitude float64) (float64, bool, error)
+}
+
+const feetPerMeter = 3.28084
+
+// GetAglFeet returns the height (in feet) above the terrain of a location given
+// its altitude in feet above mean sea level, or false if it can't be determined
+func GetAglFeet(ep ElevationProvider, latitude, longitude, altMslFeet float64) (float64, bool, error) {
+	if ep == nil {
+		return 0, false, nil
+	}
+	elevationMeters, ok, err := ep.GetElevation(latitude, longitude)
+	if err != nil || !ok {
+		return 0, false, err
+	}
+	return altMslFeet - elevationMeters*feetPerMeter, true, nil
+}